@@ -0,0 +1,80 @@
+package deebee
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Operation is a kind of access to the state checked by the function given to WithAccessControl
+type Operation int
+
+const (
+	// ReadOperation is checked by Reader, ReaderWithOptions, Exists, Versions and by CopyKey for the source key
+	ReadOperation Operation = iota
+	// WriteOperation is checked by Writer, WriterAsync, Undelete, Pin, Unpin and by Rename and CopyKey
+	// for the destination key
+	WriteOperation
+	// DeleteOperation is checked by Delete, DeletePrefix and by Rename for the old key
+	DeleteOperation
+)
+
+func (o Operation) String() string {
+	switch o {
+	case ReadOperation:
+		return "Read"
+	case WriteOperation:
+		return "Write"
+	case DeleteOperation:
+		return "Delete"
+	default:
+		return fmt.Sprintf("Operation(%d)", int(o))
+	}
+}
+
+// WithAccessControl registers the function consulted before the state is read, written or
+// deleted. When the function returns error, the operation is not executed and the error is
+// returned wrapped (see IsAccessDenied). Operations on all states, such as Compact, Backup
+// or Verify, are not checked.
+func WithAccessControl(check func(op Operation, key string) error) Option {
+	return func(db *DB) error {
+		if check == nil {
+			return errors.New("nil access control function")
+		}
+		db.accessControl = check
+		return nil
+	}
+}
+
+type accessDeniedError struct {
+	op  Operation
+	key string
+	err error
+}
+
+func (e *accessDeniedError) Error() string {
+	return fmt.Sprintf("%s access to key \"%s\" denied: %s", e.op, e.key, e.err)
+}
+
+func (e *accessDeniedError) Unwrap() error {
+	return e.err
+}
+
+func (e *accessDeniedError) IsClientError() bool {
+	return true
+}
+
+// IsAccessDenied returns true when operation was denied by the function given to WithAccessControl
+func IsAccessDenied(err error) bool {
+	var denied *accessDeniedError
+	return errors.As(err, &denied)
+}
+
+func (s *DB) checkAccess(op Operation, key string) error {
+	if s.accessControl == nil {
+		return nil
+	}
+	if err := s.accessControl(op, key); err != nil {
+		return &accessDeniedError{op: op, key: key, err: err}
+	}
+	return nil
+}
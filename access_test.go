@@ -0,0 +1,146 @@
+package deebee_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAccessControl(t *testing.T) {
+	t.Run("should return error for nil function", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithAccessControl(nil))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	denied := errors.New("denied")
+	// only "public" key can be accessed
+	publicOnly := func(op deebee.Operation, key string) error {
+		if key != "public" {
+			return denied
+		}
+		return nil
+	}
+
+	t.Run("should deny operations", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "secret", []byte("data"))
+		db := openDB(t, dir, deebee.WithAccessControl(publicOnly))
+
+		operations := map[string]func() error{
+			"Reader": func() error {
+				_, err := db.Reader("secret")
+				return err
+			},
+			"Writer": func() error {
+				_, err := db.Writer("secret")
+				return err
+			},
+			"WriterAsync": func() error {
+				_, err := db.WriterAsync("secret", nil)
+				return err
+			},
+			"Exists": func() error {
+				_, err := db.Exists("secret")
+				return err
+			},
+			"Versions": func() error {
+				_, err := db.Versions("secret")
+				return err
+			},
+			"Delete": func() error {
+				return db.Delete("secret")
+			},
+			"Undelete": func() error {
+				return db.Undelete("secret")
+			},
+			"Pin": func() error {
+				return db.Pin("secret", 0)
+			},
+			"Unpin": func() error {
+				return db.Unpin("secret", 0)
+			},
+			"Rename from": func() error {
+				return db.Rename("secret", "other")
+			},
+			"CopyKey": func() error {
+				return db.CopyKey("secret", "public")
+			},
+		}
+		for name, operation := range operations {
+			t.Run(name, func(t *testing.T) {
+				err := operation()
+				assert.True(t, deebee.IsAccessDenied(err))
+				assert.True(t, deebee.IsClientError(err))
+				assert.True(t, errors.Is(err, denied))
+			})
+		}
+		// and
+		assert.Equal(t, []byte("data"), readData(t, openDB(t, dir), "secret"))
+	})
+
+	t.Run("should deny Rename to key which cannot be written", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithAccessControl(publicOnly))
+		writeData(t, db, "public", []byte("data"))
+		// when
+		err := db.Rename("public", "secret")
+		// then
+		assert.True(t, deebee.IsAccessDenied(err))
+		assert.Equal(t, []byte("data"), readData(t, db, "public"))
+	})
+
+	t.Run("should allow operations", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithAccessControl(publicOnly))
+		// when
+		writeData(t, db, "public", []byte("data"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "public"))
+		require.NoError(t, db.Delete("public"))
+	})
+
+	t.Run("should pass operation and key", func(t *testing.T) {
+		type access struct {
+			op  deebee.Operation
+			key string
+		}
+		var accesses []access
+		db := openDB(t, fake.ExistingDir(), deebee.WithAccessControl(func(op deebee.Operation, key string) error {
+			accesses = append(accesses, access{op: op, key: key})
+			return nil
+		}))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		readData(t, db, "key")
+		require.NoError(t, db.Delete("key"))
+		// then
+		expected := []access{
+			{op: deebee.WriteOperation, key: "key"},
+			{op: deebee.ReadOperation, key: "key"},
+			{op: deebee.DeleteOperation, key: "key"},
+		}
+		assert.Equal(t, expected, accesses)
+	})
+
+	t.Run("should not check invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithAccessControl(func(op deebee.Operation, key string) error {
+			require.FailNow(t, "access control function should not be called")
+			return nil
+		}))
+		for _, key := range invalidKeys {
+			_, err := db.Reader(key)
+			assert.True(t, deebee.IsClientError(err))
+			assert.False(t, deebee.IsAccessDenied(err))
+		}
+	})
+}
+
+func TestOperation_String(t *testing.T) {
+	assert.Equal(t, "Read", deebee.ReadOperation.String())
+	assert.Equal(t, "Write", deebee.WriteOperation.String())
+	assert.Equal(t, "Delete", deebee.DeleteOperation.String())
+	assert.Equal(t, "Operation(10)", deebee.Operation(10).String())
+}
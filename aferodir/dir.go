@@ -0,0 +1,160 @@
+// Package aferodir provides deebee.Dir implementation backed by afero filesystem
+package aferodir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/spf13/afero"
+)
+
+// New returns deebee.Dir for the directory with given path inside afero filesystem
+func New(fs afero.Fs, path string) deebee.Dir {
+	return dir{fs: fs, path: path}
+}
+
+type dir struct {
+	fs   afero.Fs
+	path string
+}
+
+func (d dir) FileReader(name string) (io.ReadCloser, error) {
+	if name == "" {
+		return nil, errors.New("empty file name")
+	}
+	return d.fs.Open(d.join(name))
+}
+
+func (d dir) FileWriter(name string) (deebee.FileWriter, error) {
+	if name == "" {
+		return nil, errors.New("empty file name")
+	}
+	flags := os.O_CREATE | os.O_EXCL | os.O_WRONLY
+	return d.fs.OpenFile(d.join(name), flags, 0664)
+}
+
+func (d dir) FileModTime(name string) (time.Time, error) {
+	info, err := d.fs.Stat(d.join(name))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (d dir) FileSize(name string) (int64, error) {
+	info, err := d.fs.Stat(d.join(name))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (d dir) join(name string) string {
+	return filepath.Join(d.path, name)
+}
+
+func (d dir) Mkdir() error {
+	parent := filepath.Dir(d.path)
+	parentExists, err := afero.DirExists(d.fs, parent)
+	if err != nil {
+		return err
+	}
+	if !parentExists {
+		return fmt.Errorf("parent dir %s does not exist", parent)
+	}
+	err = d.fs.Mkdir(d.path, 0775)
+	if os.IsExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (d dir) Dir(name string) deebee.Dir {
+	return dir{fs: d.fs, path: d.join(name)}
+}
+
+func (d dir) Exists() (bool, error) {
+	return afero.DirExists(d.fs, d.path)
+}
+
+func (d dir) ListFiles() ([]string, error) {
+	return d.list(false)
+}
+
+func (d dir) ListDirs() ([]string, error) {
+	return d.list(true)
+}
+
+func (d dir) list(dirs bool) ([]string, error) {
+	fileInfos, err := afero.ReadDir(d.fs, d.path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range fileInfos {
+		if f.IsDir() == dirs {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+func (d dir) Rename(oldName, newName string) error {
+	if oldName == "" || newName == "" {
+		return errors.New("empty file name")
+	}
+	oldPath, newPath := d.join(oldName), d.join(newName)
+	if _, inMemory := d.fs.(*afero.MemMapFs); inMemory {
+		isDir, err := afero.IsDir(d.fs, oldPath)
+		if err != nil {
+			return err
+		}
+		if isDir {
+			return d.moveDir(oldPath, newPath)
+		}
+	}
+	return d.fs.Rename(oldPath, newPath)
+}
+
+// moveDir moves all files to the new dir one by one, because MemMapFs renames
+// only the directory itself, without its files
+func (d dir) moveDir(oldPath, newPath string) error {
+	exists, err := afero.Exists(d.fs, newPath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%s already exists", newPath)
+	}
+	if err = d.fs.Mkdir(newPath, 0775); err != nil {
+		return err
+	}
+	fileInfos, err := afero.ReadDir(d.fs, oldPath)
+	if err != nil {
+		return err
+	}
+	for _, f := range fileInfos {
+		from, to := filepath.Join(oldPath, f.Name()), filepath.Join(newPath, f.Name())
+		if f.IsDir() {
+			err = d.moveDir(from, to)
+		} else {
+			err = d.fs.Rename(from, to)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.fs.Remove(oldPath)
+}
+
+func (d dir) DeleteFile(name string) error {
+	if name == "" {
+		return errors.New("empty file name")
+	}
+	return d.fs.Remove(d.join(name))
+}
@@ -0,0 +1,82 @@
+package aferodir_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/aferodir"
+	"github.com/jacekolszak/deebee/test"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+var dirs = map[string]test.NewDir{
+	"memory root":   memoryRootDir,
+	"memory nested": memoryNestedDir,
+	"os":            osDir,
+}
+
+func memoryRootDir(t *testing.T) deebee.Dir {
+	fs := afero.NewMemMapFs()
+	err := fs.Mkdir("/root", 0775)
+	require.NoError(t, err)
+	return aferodir.New(fs, "/root")
+}
+
+func memoryNestedDir(t *testing.T) deebee.Dir {
+	dir := memoryRootDir(t)
+	err := dir.Dir("nested").Mkdir()
+	require.NoError(t, err)
+	return dir.Dir("nested")
+}
+
+func osDir(t *testing.T) deebee.Dir {
+	path, err := ioutil.TempDir("", "test")
+	require.NoError(t, err)
+	return aferodir.New(afero.NewOsFs(), path)
+}
+
+func TestDir_FileWriter(t *testing.T) {
+	test.TestDir_FileWriter(t, dirs)
+}
+
+func TestFileWriter_Write(t *testing.T) {
+	test.TestFileWriter_Write(t, dirs)
+}
+
+func TestDir_FileReader(t *testing.T) {
+	test.TestDir_FileReader(t, dirs)
+}
+
+func TestFileReader_Read(t *testing.T) {
+	test.TestFileReader_Read(t, dirs)
+}
+
+func TestDir_Exists(t *testing.T) {
+	test.TestDir_Exists(t, dirs)
+}
+
+func TestDir_Mkdir(t *testing.T) {
+	test.TestDir_Mkdir(t, dirs)
+}
+
+func TestDir_Dir(t *testing.T) {
+	test.TestDir_Dir(t, dirs)
+}
+
+func TestDir_ListFiles(t *testing.T) {
+	test.TestDir_ListFiles(t, dirs)
+}
+
+func TestDir_ListDirs(t *testing.T) {
+	test.TestDir_ListDirs(t, dirs)
+}
+
+func TestDir_Rename(t *testing.T) {
+	test.TestDir_Rename(t, dirs)
+}
+
+func TestDir_DeleteFile(t *testing.T) {
+	test.TestDir_DeleteFile(t, dirs)
+}
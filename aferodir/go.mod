@@ -0,0 +1,11 @@
+module github.com/jacekolszak/deebee/aferodir
+
+go 1.15
+
+require (
+	github.com/jacekolszak/deebee v0.0.0-20261015112523-990e42ad2fda
+	github.com/spf13/afero v1.6.0
+	github.com/stretchr/testify v1.7.0
+)
+
+replace github.com/jacekolszak/deebee => ../
@@ -0,0 +1,271 @@
+package deebee
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"time"
+)
+
+// WithArchive makes Archive move data versions older than olderThan to archive Dir, for
+// example to a cheaper and slower storage. Commit times are known only when Dir implements
+// FileModTimer - otherwise no version is archived. The latest data version is never archived.
+//
+// Archived versions are still returned by Versions, but VersionReader returns restore
+// required error (see IsRestoreRequired) until they are restored using RestoreArchived.
+// Compact removes them like other versions, according to the retention policy (see
+// WithRetention), together with their archive copies. They are not copied by Migrate.
+// Archive Dir uses the flat layout, even when DB was opened WithShardedLayout.
+func WithArchive(archive Dir, olderThan time.Duration) Option {
+	return func(db *DB) error {
+		if archive == nil {
+			return errors.New("nil archive dir")
+		}
+		if olderThan < 0 {
+			return errors.New("negative archive age")
+		}
+		db.archive = &archivePolicy{dir: archive, olderThan: olderThan}
+		return nil
+	}
+}
+
+type archivePolicy struct {
+	dir       Dir
+	olderThan time.Duration
+}
+
+type restoreRequiredError struct{}
+
+func (e *restoreRequiredError) Error() string {
+	return "version is archived, restore required"
+}
+
+// IsRestoreRequired returns true when the version was moved to the archive and must be
+// restored using RestoreArchived before it can be read
+func IsRestoreRequired(err error) bool {
+	var restoreRequired *restoreRequiredError
+	return errors.As(err, &restoreRequired)
+}
+
+// Archive moves data versions older than the age given to WithArchive to the archive Dir.
+// Each version is copied to the archive first and then replaced by a small marker file in
+// the state dir, so archiving can be resumed after failure by running it again. Versions
+// being read by readers which were not closed yet are archived by the next run.
+//
+// progress is called after each key and can be nil.
+func (s *DB) Archive(ctx context.Context, progress ProgressFunc) (err error) {
+	defer s.redactError(&err)
+	if s.archive == nil {
+		return newClientError("archive is available only for DB opened WithArchive")
+	}
+	if err = s.checkWritable(); err != nil {
+		return err
+	}
+	return s.forEachKey(ctx, progress, s.archiveKey)
+}
+
+func (s *DB) archiveKey(key string) (int64, error) {
+	if err := s.checkAccess(WriteOperation, key); err != nil {
+		return 0, err
+	}
+	stateDir := s.stateDir(key)
+	modTimer, ok := stateDir.(FileModTimer)
+	if !ok {
+		return 0, nil
+	}
+	unlock := s.protectionLocks.lock(key)
+	defer unlock()
+	var files []filename
+	archived := map[int]bool{}
+	err := iterateFiles(stateDir, func(file string) bool {
+		f, err := parseFilename(file)
+		switch {
+		case err != nil:
+		case f.kind == dataFile:
+			files = append(files, f)
+		case f.kind == archivedFile:
+			archived[f.version] = true
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	latest, found := youngestData(files)
+	if !found {
+		return 0, nil
+	}
+	now := time.Now()
+	var bytesCopied int64
+	for _, f := range files {
+		if !latest.youngerThan(f) {
+			continue
+		}
+		commitTime, err := modTimer.FileModTime(f.name)
+		if err != nil {
+			return bytesCopied, err
+		}
+		if now.Sub(commitTime) < s.archive.olderThan || s.openVersions.isOpen(key, f.version) {
+			continue
+		}
+		n, err := s.archiveVersion(key, stateDir, f, commitTime, archived[f.version])
+		bytesCopied += n
+		if err != nil {
+			return bytesCopied, err
+		}
+	}
+	return bytesCopied, nil
+}
+
+// archiveVersion copies the data file to the archive, marks it as archived and removes it.
+// The marker contains the commit time, used by the retention policy. The file was already
+// copied when marked is true. The data file is not removed while the version is read - it
+// is removed by the next Archive then.
+func (s *DB) archiveVersion(key string, stateDir Dir, f filename, commitTime time.Time, marked bool) (int64, error) {
+	var n int64
+	if !marked {
+		if err := mkdirKey(s.archive.dir, s.physicalKey(key)); err != nil {
+			return 0, err
+		}
+		archiveDir := keyDir(s.archive.dir, s.physicalKey(key))
+		_ = archiveDir.DeleteFile(f.temp()) // left by previous run which failed
+		var err error
+		if n, err = copyFile(stateDir, archiveDir, f.name); err != nil {
+			return n, err
+		}
+		marker, err := stateDir.FileWriter(newArchivedFilename(f.version).name)
+		if err != nil {
+			return n, err
+		}
+		if _, err = io.WriteString(marker, strconv.FormatInt(commitTime.UnixNano(), 10)); err != nil {
+			_ = marker.Close()
+			return n, err
+		}
+		if err = marker.Sync(); err != nil {
+			_ = marker.Close()
+			return n, err
+		}
+		if err = marker.Close(); err != nil {
+			return n, err
+		}
+	}
+	_, err := s.openVersions.unlessOpen(key, f.version, func() error {
+		return stateDir.DeleteFile(f.name)
+	})
+	return n, err
+}
+
+// removeArchived removes the archive copy of the version and then its marker, so removal
+// can be resumed by running Compact again
+func (s *DB) removeArchived(key string, stateDir Dir, f filename) error {
+	archiveDir := keyDir(s.archive.dir, s.physicalKey(key))
+	exists, err := archiveDir.Exists()
+	if err != nil {
+		return err
+	}
+	copied := false
+	name := newFilename(f.version).name
+	if exists {
+		err = iterateFiles(archiveDir, func(file string) bool {
+			copied = file == name
+			return !copied
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if copied {
+		if err = archiveDir.DeleteFile(name); err != nil {
+			return err
+		}
+	}
+	return stateDir.DeleteFile(f.name)
+}
+
+// archivedCommitTimer reports commit times of archived versions stored in their markers.
+// Times of other files are reported by the embedded FileModTimer.
+type archivedCommitTimer struct {
+	FileModTimer
+	dir Dir
+}
+
+// commitTimer returns FileModTimer reporting commit times of all versions stored in
+// stateDir, including archived ones. Returns nil when stateDir is not a FileModTimer.
+func commitTimer(stateDir Dir) FileModTimer {
+	modTimer, ok := stateDir.(FileModTimer)
+	if !ok {
+		return nil
+	}
+	return &archivedCommitTimer{FileModTimer: modTimer, dir: stateDir}
+}
+
+func (t *archivedCommitTimer) FileModTime(name string) (time.Time, error) {
+	f, err := parseFilename(name)
+	if err != nil || f.kind != archivedFile {
+		return t.FileModTimer.FileModTime(name)
+	}
+	reader, err := t.dir.FileReader(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return time.Time{}, err
+	}
+	nanos, err := strconv.ParseInt(string(content), 10, 64)
+	if err != nil {
+		// marker written by older version, archived after the commit
+		return t.FileModTimer.FileModTime(name)
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// RestoreArchived copies the archived data version back from the archive Dir, so it can be
+// read using VersionReader again. The version is removed from the archive. Does nothing
+// when the version is not archived. Returns data not found error when there is no such version.
+func (s *DB) RestoreArchived(key string, version int) (err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if s.archive == nil {
+		return newClientError("archive is available only for DB opened WithArchive")
+	}
+	if err = s.checkWritable(); err != nil {
+		return err
+	}
+	if err = s.checkKey(key); err != nil {
+		return err
+	}
+	if err = s.checkAccess(WriteOperation, key); err != nil {
+		return err
+	}
+	stateDir, err := s.existingStateDir(key)
+	if err != nil {
+		return err
+	}
+	versions, err := stateVersions(stateDir)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.Version != version || v.Deleted {
+			continue
+		}
+		if !v.Archived {
+			return nil
+		}
+		name := newFilename(version).name
+		archiveDir := keyDir(s.archive.dir, s.physicalKey(key))
+		_ = stateDir.DeleteFile(newFilename(version).temp()) // left by previous run which failed
+		if _, err = copyFile(archiveDir, stateDir, name); err != nil {
+			return err
+		}
+		if err = stateDir.DeleteFile(newArchivedFilename(version).name); err != nil {
+			return err
+		}
+		return archiveDir.DeleteFile(name)
+	}
+	return &dataNotFoundError{}
+}
@@ -0,0 +1,234 @@
+package deebee_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithArchive(t *testing.T) {
+	t.Run("should return error for invalid arguments", func(t *testing.T) {
+		_, err := deebee.Open(fake.ExistingDir(), deebee.WithArchive(nil, time.Hour))
+		assert.Error(t, err)
+		_, err = deebee.Open(fake.ExistingDir(), deebee.WithArchive(fake.ExistingDir(), -1))
+		assert.Error(t, err)
+	})
+}
+
+func TestDB_Archive(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("should return client error when DB was not opened WithArchive", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		err := db.Archive(ctx, nil)
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should move old versions to the archive", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		archive := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithArchive(archive, time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-3*time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-time.Minute))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		err = db.Archive(ctx, nil)
+		// then
+		require.NoError(t, err)
+		archived, err := db.Versions("key")
+		require.NoError(t, err)
+		require.Len(t, archived, 4)
+		assert.True(t, archived[0].Archived)
+		assert.True(t, archived[1].Archived)
+		assert.False(t, archived[2].Archived, "young version should not be archived")
+		assert.False(t, archived[3].Archived, "latest version should not be archived")
+		assert.Len(t, archive.Dir("key").(fake.Dir).Files(), 2)
+		for _, v := range versions[:2] {
+			_, err = db.VersionReader("key", v.Version)
+			assert.True(t, deebee.IsRestoreRequired(err))
+		}
+		assert.Equal(t, []byte(now.Add(-2*time.Hour).String()), readData(t, db, "key"))
+	})
+
+	t.Run("should not archive versions when Dir does not report commit times", func(t *testing.T) {
+		archive := fake.ExistingDir()
+		db := openDB(t, failing.FileConflicts(fake.ExistingDir(), 0), deebee.WithArchive(archive, 0))
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		// when
+		err := db.Archive(ctx, nil)
+		// then
+		require.NoError(t, err)
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		assert.False(t, versions[0].Archived)
+	})
+
+	t.Run("should not archive version being read", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithArchive(fake.ExistingDir(), time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now)
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		reader, err := db.VersionReader("key", versions[0].Version)
+		require.NoError(t, err)
+		// when
+		err = db.Archive(ctx, nil)
+		// then
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(now.Add(-2*time.Hour).String()), data)
+		require.NoError(t, reader.Close())
+		versions, err = db.Versions("key")
+		require.NoError(t, err)
+		assert.False(t, versions[0].Archived)
+		// and
+		require.NoError(t, db.Archive(ctx, nil))
+		versions, err = db.Versions("key")
+		require.NoError(t, err)
+		assert.True(t, versions[0].Archived)
+	})
+
+	t.Run("should not pin or label archived versions", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithArchive(fake.ExistingDir(), time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now)
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		require.NoError(t, db.Archive(ctx, nil))
+		// when
+		pinErr := db.Pin("key", versions[0].Version)
+		labelErr := db.Label("key", versions[0].Version, "label")
+		// then
+		assert.True(t, deebee.IsRestoreRequired(pinErr))
+		assert.True(t, deebee.IsRestoreRequired(labelErr))
+	})
+
+	t.Run("should remove archived versions together with archive copies by Compact", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		archive := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithArchive(archive, time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now)
+		require.NoError(t, db.Archive(ctx, nil))
+		// when
+		report, err := db.CompactWithOptions(ctx, deebee.CompactOptions{}, nil)
+		// then
+		require.NoError(t, err)
+		require.Len(t, report.Removed, 1)
+		assert.True(t, report.Removed[0].Archived)
+		assertVersionsCount(t, db, "key", 1)
+		assert.Empty(t, archive.Dir("key").(fake.Dir).Files())
+	})
+
+	t.Run("should keep archived versions retained by retention policy", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		archive := fake.ExistingDir()
+		var commitTimes []time.Time
+		keepAll := func(now time.Time, times []time.Time) []bool {
+			commitTimes = times
+			keep := make([]bool, len(times))
+			for i := range keep {
+				keep[i] = true
+			}
+			return keep
+		}
+		db := openDB(t, dir, deebee.WithArchive(archive, time.Hour), deebee.WithRetention(keepAll))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now)
+		require.NoError(t, db.Archive(ctx, nil))
+		// when
+		err := db.Compact(ctx, nil)
+		// then
+		require.NoError(t, err)
+		assertVersionsCount(t, db, "key", 2)
+		assert.Len(t, archive.Dir("key").(fake.Dir).Files(), 1)
+		require.Len(t, commitTimes, 1)
+		assert.True(t, commitTimes[0].Equal(now.Add(-2*time.Hour)), "commit time of archived version should be passed to the policy")
+	})
+
+	t.Run("should remove archive copies of deleted state by Compact", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		archive := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithArchive(archive, time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now)
+		require.NoError(t, db.Archive(ctx, nil))
+		require.NoError(t, db.Delete("key"))
+		// when
+		err := db.Compact(ctx, nil)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, archive.Dir("key").(fake.Dir).Files())
+		require.NoError(t, db.Undelete("key"))
+		assert.Equal(t, []byte(now.String()), readData(t, db, "key"))
+	})
+}
+
+func TestDB_RestoreArchived(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("should return client error when DB was not opened WithArchive", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		err := db.RestoreArchived("key", 0)
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should return data not found for missing version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithArchive(fake.ExistingDir(), time.Hour))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.RestoreArchived("key", latestVersion(t, db, "key")+1)
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should restore archived version", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		archive := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithArchive(archive, time.Hour))
+		old := now.Add(-2 * time.Hour)
+		writeDataCommittedAt(t, db, dir, "key", old)
+		version := latestVersion(t, db, "key")
+		writeDataCommittedAt(t, db, dir, "key", now)
+		require.NoError(t, db.Archive(ctx, nil))
+		// when
+		err := db.RestoreArchived("key", version)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte(old.String()), readVersion(t, db, "key", version))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		assert.False(t, versions[0].Archived)
+		assert.Empty(t, archive.Dir("key").(fake.Dir).Files())
+	})
+
+	t.Run("should do nothing when version is not archived", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithArchive(fake.ExistingDir(), time.Hour))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.RestoreArchived("key", latestVersion(t, db, "key"))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+}
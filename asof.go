@@ -0,0 +1,98 @@
+package deebee
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// OpenAsOf opens read-only view of the DB as it was at time t. Each key is resolved to the
+// youngest version committed at or before t, which makes time-travel debugging of the
+// application state possible. Versions removed by Compact are not available.
+//
+// Commit time is the modification time of the version file, therefore dir must implement
+// FileModTimer. All methods modifying the DB return client error. Options starting
+// background tasks or used only by writers, such as WithTempFileCleanup,
+// WithCompactionTrigger, WithWriteBehind, WithGroupCommit, WithPreload, WithMigrator and
+// WithArchive, return client error as well.
+func OpenAsOf(dir Dir, t time.Time, options ...Option) (*DB, error) {
+	if _, ok := dir.(FileModTimer); !ok && dir != nil {
+		return nil, newClientError("dir does not implement FileModTimer")
+	}
+	return open(dir, &t, options)
+}
+
+// checkReadOnlyOptions returns client error when DB opened using OpenAsOf was given the
+// option starting background tasks or used only by writers
+func (s *DB) checkReadOnlyOptions() error {
+	if s.asOf == nil {
+		return nil
+	}
+	var option string
+	switch {
+	case s.janitor != nil:
+		option = "WithTempFileCleanup"
+	case s.compactor != nil:
+		option = "WithCompactionTrigger"
+	case s.index != nil:
+		option = "WithPreload"
+	case s.migrator != nil:
+		option = "WithMigrator"
+	case s.archive != nil:
+		option = "WithArchive"
+	case s.writeBehind != nil || s.groupCommit != nil:
+		option = "WithWriteBehind or WithGroupCommit"
+	}
+	for _, o := range s.keyOptions {
+		if o.config.writeBehind != nil || o.config.groupCommit != nil {
+			option = "WithWriteBehind or WithGroupCommit"
+		}
+	}
+	if option != "" {
+		return newClientError(fmt.Sprintf("%s can't be used by DB opened as of time", option))
+	}
+	return nil
+}
+
+// checkWritable returns client error when DB was opened using OpenAsOf
+func (s *DB) checkWritable() error {
+	if s.asOf != nil {
+		return newClientError(fmt.Sprintf("DB opened as of %s is read-only", s.asOf.Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// youngestFileAsOf returns the youngest data file or tombstone committed at or before t.
+// Commit times are made monotonic first (see FileModTimer), so the version written after
+// a wall clock regression is not visible before the version written earlier.
+func youngestFileAsOf(dir Dir, t time.Time) (filename, bool, error) {
+	modTimer, ok := dir.(FileModTimer)
+	if !ok {
+		return filename{}, false, newClientError("dir does not implement FileModTimer")
+	}
+	var files []filename
+	err := iterateFiles(dir, func(file string) bool {
+		f, err := parseFilename(file)
+		if err == nil && f.isOneOf([]fileKind{dataFile, tombstoneFile}) {
+			files = append(files, f)
+		}
+		return true
+	})
+	if err != nil {
+		return filename{}, false, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[j].youngerThan(files[i])
+	})
+	commitTimes, err := commitTimesOf(modTimer, files)
+	if err != nil {
+		return filename{}, false, err
+	}
+	i := sort.Search(len(commitTimes), func(i int) bool {
+		return commitTimes[i].After(t)
+	})
+	if i == 0 {
+		return filename{}, false, nil
+	}
+	return files[i-1], true, nil
+}
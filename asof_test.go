@@ -0,0 +1,134 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAsOf(t *testing.T) {
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+
+	t.Run("should return client error when dir does not implement FileModTimer", func(t *testing.T) {
+		db, err := deebee.OpenAsOf(failing.Rename(fake.ExistingDir()), hourAgo)
+		assert.True(t, deebee.IsClientError(err))
+		assert.Nil(t, db)
+	})
+
+	t.Run("should return client error for options used by writers or background tasks", func(t *testing.T) {
+		options := map[string]deebee.Option{
+			"WithTempFileCleanup":   deebee.WithTempFileCleanup(time.Hour, 0),
+			"WithCompactionTrigger": deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxVersions: 2}),
+			"WithWriteBehind":       deebee.WithWriteBehind(time.Second),
+			"WithGroupCommit":       deebee.WithGroupCommit(time.Millisecond),
+			"WithPreload":           deebee.WithPreload(),
+			"WithArchive":           deebee.WithArchive(fake.ExistingDir(), time.Hour),
+			"key options":           deebee.WithKeyOptions("key-*", deebee.WithWriteBehind(time.Second)),
+		}
+		for name, option := range options {
+			t.Run(name, func(t *testing.T) {
+				// when
+				db, err := deebee.OpenAsOf(fake.ExistingDir(), hourAgo, option)
+				// then
+				assert.True(t, deebee.IsClientError(err))
+				assert.Nil(t, db)
+			})
+		}
+	})
+
+	t.Run("should not record sharded layout in empty dir", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		// when
+		_, err := deebee.OpenAsOf(dir, hourAgo, deebee.WithShardedLayout())
+		// then
+		require.NoError(t, err)
+		files, err := dir.ListFiles()
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("should read version committed before given time", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		expected := readData(t, db, "key")
+		writeDataCommittedAt(t, db, dir, "key", now)
+		// when
+		db, err := deebee.OpenAsOf(dir, hourAgo)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, expected, readData(t, db, "key"))
+	})
+
+	t.Run("should not read version written after clock went back before the previous one", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-time.Minute))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour)) // clock went back
+		// when
+		db, err := deebee.OpenAsOf(dir, hourAgo)
+		// then
+		require.NoError(t, err)
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should read the youngest version when versions have the same commit time", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		commitTime := now.Add(-2 * time.Hour)
+		writeDataCommittedAt(t, db, dir, "key", commitTime)
+		writeDataCommittedAt(t, db, dir, "key", commitTime)
+		expected := latestVersion(t, db, "key")
+		// when
+		db, err := deebee.OpenAsOf(dir, commitTime)
+		// then
+		require.NoError(t, err)
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		defer reader.Close()
+		assert.Equal(t, expected, reader.Version())
+	})
+
+	t.Run("should return data not found for state written after given time", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeDataCommittedAt(t, openDB(t, dir), dir, "key", now)
+		// when
+		db, err := deebee.OpenAsOf(dir, hourAgo)
+		// then
+		require.NoError(t, err)
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should read state deleted after given time", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		require.NoError(t, db.Delete("key"))
+		// when
+		db, err := deebee.OpenAsOf(dir, hourAgo)
+		// then
+		require.NoError(t, err)
+		assert.NotEmpty(t, readData(t, db, "key"))
+	})
+
+	t.Run("should return client error on modification", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db, err := deebee.OpenAsOf(dir, now)
+		require.NoError(t, err)
+		// when
+		_, err = db.Writer("key")
+		// then
+		assert.True(t, deebee.IsClientError(err))
+		assert.True(t, deebee.IsClientError(db.Delete("key")))
+		assert.True(t, deebee.IsClientError(db.Rename("key", "new")))
+	})
+}
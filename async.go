@@ -0,0 +1,171 @@
+package deebee
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// WriterAsync returns Writer for new version of state with given key. Contrary to Writer,
+// Close returns immediately and the data is synced and committed in the background.
+// onCommit is called once the commit finished - with nil error when data is durably
+// stored, or with an error explaining why the commit failed. onCommit can be nil.
+//
+// Use Flush to wait for all pending commits.
+//
+// Readers opened after Close, even when the commit is still pending, wait for it, so they
+// see the data written (read-your-writes). Keys, Count and List do not wait - use Barrier
+// to wait explicitly.
+//
+// Returned Writer does not know the version when Close returns, therefore its Version
+// always returns -1. Functions registered using Writer.OnCommit are called in the
+// background with the committed version, before onCommit.
+func (s *DB) WriterAsync(key string, onCommit func(error)) (*Writer, error) {
+	key = s.normalizeKey(key)
+	if writeBehind := s.configFor(key).writeBehind; writeBehind != nil {
+		w, err := s.newWriteBehindWriter(key, writeBehind, onCommit)
+		if err != nil {
+			return nil, s.redact(err, key)
+		}
+		return s.wrapWriter(key, w, nil), nil
+	}
+	w, err := s.newWriterWithTimeout(key)
+	if err != nil {
+		return nil, s.redact(err, key)
+	}
+	async := &asyncWriter{
+		writer:   w,
+		db:       s,
+		key:      key,
+		onCommit: onCommit,
+	}
+	async.wrapper = s.wrapWriter(key, async, nil)
+	return async.wrapper, nil
+}
+
+type asyncWriter struct {
+	*writer
+	db  *DB
+	key string
+	// wrapper is the Writer returned by WriterAsync. Functions registered using its
+	// OnCommit are called after the commit.
+	wrapper  *Writer
+	onCommit func(error)
+	once     sync.Once
+}
+
+func (w *asyncWriter) Close() error {
+	closed := true
+	w.once.Do(func() {
+		closed = false
+		done := w.db.pendingCommits.add(w.key)
+		go func() {
+			err := w.writer.Close()
+			// removed before onCommit, so it can read the key without waiting for itself
+			w.db.pendingCommits.remove(done)
+			if err == nil {
+				for _, fn := range w.wrapper.onCommit {
+					fn(Version{Version: w.writer.name.version})
+				}
+			}
+			if w.onCommit != nil {
+				w.onCommit(w.db.redact(err, w.key))
+			}
+		}()
+	})
+	if closed {
+		return errors.New("writer already closed")
+	}
+	return nil
+}
+
+// Flush persists data of keys configured using WithWriteBehind and waits until all
+// commits started by closing writers returned by WriterAsync are finished. Returns ctx.Err() when ctx was done before.
+func (s *DB) Flush(ctx context.Context) error {
+	if err := s.flushWriteBehind(); err != nil {
+		return err
+	}
+	return s.Barrier(ctx)
+}
+
+// Barrier waits until all commits started by closing writers returned by WriterAsync
+// before the call are finished, so the data is visible to all operations, including Keys,
+// Count and List. Contrary to Flush, data of keys configured using WithWriteBehind is not
+// persisted, because Reader already returns it. Returns ctx.Err() when ctx was done before.
+func (s *DB) Barrier(ctx context.Context) error {
+	for _, done := range s.pendingCommits.list() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// pendingCommits tracks commits running in the background. Each commit is represented
+// by a channel closed when the commit is finished, mapped to the key being committed.
+type pendingCommits struct {
+	mutex   sync.Mutex
+	commits map[chan struct{}]string
+}
+
+func (p *pendingCommits) add(key string) chan struct{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.commits == nil {
+		p.commits = map[chan struct{}]string{}
+	}
+	done := make(chan struct{})
+	p.commits[done] = key
+	return done
+}
+
+func (p *pendingCommits) remove(done chan struct{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.commits, done)
+	close(done)
+}
+
+func (p *pendingCommits) list() []chan struct{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var list []chan struct{}
+	for done := range p.commits {
+		list = append(list, done)
+	}
+	return list
+}
+
+// wait waits until commits of the key started before the call are finished, so the
+// committed version is visible to the reader opened afterwards. Returns timeout error
+// when commits did not finish within timeout. Zero timeout means no limit.
+func (p *pendingCommits) wait(key string, timeout time.Duration) error {
+	p.mutex.Lock()
+	var commits []chan struct{}
+	for done, k := range p.commits {
+		if k == key {
+			commits = append(commits, done)
+		}
+	}
+	p.mutex.Unlock()
+	if len(commits) == 0 {
+		return nil
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for _, done := range commits {
+		select {
+		case <-done:
+		case <-expired:
+			return &timeoutError{operation: "waiting for pending commit", timeout: timeout}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package deebee_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_WriterAsync(t *testing.T) {
+	t.Run("should return error for invalid keys", func(t *testing.T) {
+		for _, key := range invalidKeys {
+			t.Run(key, func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir())
+				// when
+				writer, err := db.WriterAsync(key, nil)
+				// then
+				assert.Nil(t, writer)
+				assert.True(t, deebee.IsClientError(err))
+			})
+		}
+	})
+
+	t.Run("should commit data in the background", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		committed := make(chan error, 1)
+		writer, err := db.WriterAsync("key", func(err error) {
+			committed <- err
+		})
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.NoError(t, <-committed)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should pass commit error to callback", func(t *testing.T) {
+		db := openDB(t, failing.Rename(fake.ExistingDir()))
+		committed := make(chan error, 1)
+		writer, err := db.WriterAsync("key", func(err error) {
+			committed <- err
+		})
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Error(t, <-committed)
+	})
+
+	t.Run("should call functions registered using OnCommit with committed version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		committed := make(chan error, 1)
+		writer, err := db.WriterAsync("key", func(err error) {
+			committed <- err
+		})
+		require.NoError(t, err)
+		versions := make(chan deebee.Version, 1)
+		writer.OnCommit(func(version deebee.Version) {
+			versions <- version
+		})
+		// when
+		require.NoError(t, writer.Close())
+		// then
+		require.NoError(t, <-committed)
+		assert.Equal(t, latestVersion(t, db, "key"), (<-versions).Version)
+		assert.Equal(t, -1, writer.Version())
+	})
+
+	t.Run("should not call functions registered using OnCommit when commit failed", func(t *testing.T) {
+		db := openDB(t, failing.Rename(fake.ExistingDir()))
+		committed := make(chan error, 1)
+		writer, err := db.WriterAsync("key", func(err error) {
+			committed <- err
+		})
+		require.NoError(t, err)
+		called := false
+		writer.OnCommit(func(deebee.Version) {
+			called = true
+		})
+		// when
+		require.NoError(t, writer.Close())
+		// then
+		require.Error(t, <-committed)
+		assert.False(t, called)
+	})
+
+	t.Run("should return key, bytes written and checksum", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		require.NoError(t, writer.EnableChecksum())
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "key", writer.Key())
+		assert.Equal(t, int64(4), writer.BytesWritten())
+		expected := sha256.Sum256([]byte("data"))
+		assert.Equal(t, expected[:], writer.Checksum())
+	})
+
+	t.Run("should return data to reader opened after Close when commit is pending", func(t *testing.T) {
+		unblock := make(chan struct{})
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(blockingCloseFilter(unblock)))
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		time.AfterFunc(10*time.Millisecond, func() { close(unblock) })
+		// when
+		data := readData(t, db, "key")
+		// then
+		assert.Equal(t, []byte("data"), data)
+	})
+
+	t.Run("should return true from Exists called after Close when commit is pending", func(t *testing.T) {
+		unblock := make(chan struct{})
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(blockingCloseFilter(unblock)))
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		time.AfterFunc(10*time.Millisecond, func() { close(unblock) })
+		// when
+		exists, err := db.Exists("key")
+		// then
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("should read the key in callback", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		read := make(chan []byte, 1)
+		writer, err := db.WriterAsync("key", func(err error) {
+			read <- readData(t, db, "key")
+		})
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		select {
+		case data := <-read:
+			assert.Equal(t, []byte("data"), data)
+		case <-time.After(time.Second):
+			assert.Fail(t, "callback blocked on reading the key")
+		}
+	})
+
+	t.Run("should return timeout error when pending commit does not finish within operation timeout", func(t *testing.T) {
+		unblock := make(chan struct{})
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithFilter(blockingCloseFilter(unblock)), deebee.WithOperationTimeout(10*time.Millisecond))
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		// when
+		_, err = db.Exists("key")
+		// then
+		assert.True(t, deebee.IsTimeout(err))
+		close(unblock)
+		require.NoError(t, db.Flush(context.Background()))
+	})
+
+	t.Run("should not wait for pending commits of other keys", func(t *testing.T) {
+		unblock := make(chan struct{})
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyOptions("blocked", deebee.WithFilter(blockingCloseFilter(unblock))))
+		writeData(t, db, "other", []byte("data"))
+		writer, err := db.WriterAsync("blocked", nil)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		// when
+		data := readData(t, db, "other")
+		// then
+		assert.Equal(t, []byte("data"), data)
+		close(unblock)
+		require.NoError(t, db.Flush(context.Background()))
+	})
+
+	t.Run("should return error when closed twice", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		// when
+		err = writer.Close()
+		// then
+		assert.Error(t, err)
+		require.NoError(t, db.Flush(context.Background()))
+	})
+}
+
+func TestDB_Flush(t *testing.T) {
+	t.Run("should return immediately when there are no pending commits", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		err := db.Flush(context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("should wait for pending commits", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t))
+		for _, data := range []string{"old", "new"} {
+			writer, err := db.WriterAsync("key", nil)
+			require.NoError(t, err)
+			_, err = writer.Write([]byte(data))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+		}
+		// when
+		err := db.Flush(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), readData(t, db, "key"))
+	})
+
+	t.Run("should return error when context is done before commit finished", func(t *testing.T) {
+		unblock := make(chan struct{})
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(blockingCloseFilter(unblock)))
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err = db.Flush(ctx)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		close(unblock)
+		require.NoError(t, db.Flush(context.Background()))
+	})
+}
+
+func TestDB_Barrier(t *testing.T) {
+	t.Run("should return immediately when there are no pending commits", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		err := db.Barrier(context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("should wait until keys written asynchronously are listed", func(t *testing.T) {
+		unblock := make(chan struct{})
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(blockingCloseFilter(unblock)))
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		time.AfterFunc(10*time.Millisecond, func() { close(unblock) })
+		// when
+		err = db.Barrier(context.Background())
+		// then
+		require.NoError(t, err)
+		keys, err := db.Keys()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"key"}, keys)
+	})
+
+	t.Run("should not persist data of write-behind keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.Barrier(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+		_, err = db.Versions("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return error when context is done before commit finished", func(t *testing.T) {
+		unblock := make(chan struct{})
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(blockingCloseFilter(unblock)))
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err = db.Barrier(ctx)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		close(unblock)
+		require.NoError(t, db.Barrier(context.Background()))
+	})
+}
+
+// blockingCloseFilter blocks Close of the writer until channel is closed
+type blockingCloseFilter chan struct{}
+
+func (f blockingCloseFilter) Writer(w io.WriteCloser) (io.WriteCloser, error) {
+	return blockingCloser{WriteCloser: w, unblock: f}, nil
+}
+
+func (f blockingCloseFilter) Reader(r io.ReadCloser) (io.ReadCloser, error) {
+	return ioutil.NopCloser(r), nil
+}
+
+type blockingCloser struct {
+	io.WriteCloser
+	unblock chan struct{}
+}
+
+func (c blockingCloser) Close() error {
+	<-c.unblock
+	return c.WriteCloser.Close()
+}
@@ -0,0 +1,109 @@
+package deebee
+
+import (
+	"context"
+	"io"
+)
+
+// Backup copies the latest version of each state to dst dir, which must exist. Files are
+// copied as they are stored, without passing them through filters, so dst can be opened
+// as DB using the same options. Deleted states are skipped.
+//
+// progress is called after each key and can be nil.
+func (s *DB) Backup(ctx context.Context, dst Dir, progress ProgressFunc) (err error) {
+	defer s.redactError(&err)
+	if err := s.checkDirExists(dst); err != nil {
+		return err
+	}
+	backup := &DB{dir: dst, hierarchicalKeys: s.hierarchicalKeys, keyMapper: s.keyMapper}
+	return s.forEachKey(ctx, progress, func(key string) (int64, error) {
+		stateDir := s.stateDir(key)
+		youngest, exists, err := s.latestFile(key)
+		if err != nil || !exists || youngest.kind == tombstoneFile {
+			return 0, err
+		}
+		dstStateDir := backup.stateDir(key)
+		if err = backup.mkdirState(key, dstStateDir); err != nil {
+			return 0, err
+		}
+		return copyFile(stateDir, dstStateDir, youngest.name)
+	})
+}
+
+// Restore writes the latest version of each state found in src dir, created by Backup,
+// as a new version of the state. Versions written before are kept.
+//
+// progress is called after each key and can be nil.
+func (s *DB) Restore(ctx context.Context, src Dir, progress ProgressFunc) (err error) {
+	defer s.redactError(&err)
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.checkDirExists(src); err != nil {
+		return err
+	}
+	backup := &DB{dir: src, hierarchicalKeys: s.hierarchicalKeys, keyMapper: s.keyMapper}
+	return backup.forEachKey(ctx, progress, func(key string) (int64, error) {
+		stateDir := backup.stateDir(key)
+		youngest, exists, err := youngestFile(stateDir)
+		if err != nil || !exists || youngest.kind == tombstoneFile {
+			return 0, err
+		}
+		reader, err := stateDir.FileReader(youngest.name)
+		if err != nil {
+			return 0, err
+		}
+		defer reader.Close()
+		writer, err := s.newRawWriter(key)
+		if err != nil {
+			return 0, err
+		}
+		bytes, err := io.Copy(writer, reader)
+		if err != nil {
+			_ = writer.abort()
+			return bytes, err
+		}
+		return bytes, writer.Close()
+	})
+}
+
+func (s *DB) checkDirExists(dir Dir) error {
+	if dir == nil {
+		return newClientError("nil dir")
+	}
+	exists, err := dir.Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return newClientError("dir not found")
+	}
+	return nil
+}
+
+// copyFile copies file using temporary file, which is renamed when all data is synced
+func copyFile(src, dst Dir, name string) (int64, error) {
+	reader, err := src.FileReader(name)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	temp := name + tempSuffix
+	writer, err := dst.FileWriter(temp)
+	if err != nil {
+		return 0, err
+	}
+	bytes, err := io.Copy(writer, reader)
+	if err == nil {
+		err = writer.Sync()
+	}
+	if err != nil {
+		_ = writer.Close()
+		_ = dst.DeleteFile(temp)
+		return bytes, err
+	}
+	if err = writer.Close(); err != nil {
+		return bytes, err
+	}
+	return bytes, dst.Rename(temp, name)
+}
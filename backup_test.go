@@ -0,0 +1,117 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Backup(t *testing.T) {
+	t.Run("should return client error when destination dir does not exist", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		err := db.Backup(context.Background(), fake.MissingDir(), nil)
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should copy latest versions which can be opened as DB", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(prefixFilter("A")))
+		writeData(t, db, "a", []byte("old"))
+		writeData(t, db, "a", []byte("new"))
+		writeData(t, db, "b", []byte("data"))
+		dst := fake.ExistingDir()
+		// when
+		err := db.Backup(context.Background(), dst, nil)
+		// then
+		require.NoError(t, err)
+		backup := openDB(t, dst, deebee.WithFilter(prefixFilter("A")))
+		assert.Equal(t, []byte("new"), readData(t, backup, "a"))
+		assert.Equal(t, []byte("data"), readData(t, backup, "b"))
+		assert.Equal(t, []byte("Anew"), fileData(t, dst, "a"))
+	})
+
+	t.Run("should skip deleted states", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		dst := fake.ExistingDir()
+		// when
+		err := db.Backup(context.Background(), dst, nil)
+		// then
+		require.NoError(t, err)
+		dirs, err := dst.ListDirs()
+		require.NoError(t, err)
+		assert.Empty(t, dirs)
+	})
+
+	t.Run("should report progress", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "a", []byte("12"))
+		writeData(t, db, "b", []byte("345"))
+		var reported []deebee.Progress
+		// when
+		err := db.Backup(context.Background(), fake.ExistingDir(), func(p deebee.Progress) {
+			reported = append(reported, p)
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []deebee.Progress{
+			{Key: "a", Done: 1, Total: 2, Bytes: 2},
+			{Key: "b", Done: 2, Total: 2, Bytes: 5},
+		}, reported)
+	})
+
+	t.Run("should return error when writing failed", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.Backup(context.Background(), failing.FileWriter(fake.ExistingDir()), nil)
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestDB_Restore(t *testing.T) {
+	t.Run("should return client error when source dir does not exist", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		err := db.Restore(context.Background(), fake.MissingDir(), nil)
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should restore states from backup", func(t *testing.T) {
+		backup := fake.ExistingDir()
+		src := openDB(t, fake.ExistingDir(), deebee.WithFilter(prefixFilter("A")))
+		writeData(t, src, "a", []byte("backup"))
+		require.NoError(t, src.Backup(context.Background(), backup, nil))
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(prefixFilter("A")))
+		writeData(t, db, "a", []byte("current"))
+		// when
+		err := db.Restore(context.Background(), backup, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("backup"), readData(t, db, "a"))
+	})
+
+	t.Run("should report progress", func(t *testing.T) {
+		backup := fake.ExistingDir()
+		src := openDB(t, fake.ExistingDir())
+		writeData(t, src, "a", []byte("12"))
+		writeData(t, src, "b", []byte("345"))
+		require.NoError(t, src.Backup(context.Background(), backup, nil))
+		var reported []deebee.Progress
+		// when
+		err := openDB(t, fake.ExistingDir()).Restore(context.Background(), backup, func(p deebee.Progress) {
+			reported = append(reported, p)
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []deebee.Progress{
+			{Key: "a", Done: 1, Total: 2, Bytes: 2},
+			{Key: "b", Done: 2, Total: 2, Bytes: 5},
+		}, reported)
+	})
+}
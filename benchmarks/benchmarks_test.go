@@ -0,0 +1,156 @@
+package benchmarks_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/aferodir"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+type newDir func(b *testing.B) deebee.Dir
+
+var dirs = map[string]newDir{
+	"os":     osDir,
+	"memory": memoryDir,
+}
+
+func osDir(b *testing.B) deebee.Dir {
+	dir, err := ioutil.TempDir("", "benchmark")
+	require.NoError(b, err)
+	b.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return deebee.OsDir(dir)
+}
+
+func memoryDir(b *testing.B) deebee.Dir {
+	fs := afero.NewMemMapFs()
+	require.NoError(b, fs.Mkdir("/db", 0775))
+	return aferodir.New(fs, "/db")
+}
+
+// BenchmarkSmallValueChurn updates the same key with small value over and over again
+func BenchmarkSmallValueChurn(b *testing.B) {
+	for name, newDir := range dirs {
+		b.Run(name, func(b *testing.B) {
+			db := openDB(b, newDir(b))
+			data := makeData(100)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				writeData(b, db, "key", data)
+			}
+		})
+	}
+}
+
+// BenchmarkLargeStreamingWrite writes large state in 64KB chunks
+func BenchmarkLargeStreamingWrite(b *testing.B) {
+	const size = 16 * 1024 * 1024
+	chunk := makeData(64 * 1024)
+	for name, newDir := range dirs {
+		b.Run(name, func(b *testing.B) {
+			db := openDB(b, newDir(b))
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				writer, err := db.Writer("key")
+				require.NoError(b, err)
+				for written := 0; written < size; written += len(chunk) {
+					_, err = writer.Write(chunk)
+					require.NoError(b, err)
+				}
+				require.NoError(b, writer.Close())
+			}
+		})
+	}
+}
+
+// BenchmarkManyKeys writes and then reads a thousand different keys
+func BenchmarkManyKeys(b *testing.B) {
+	const keys = 1000
+	data := makeData(1024)
+	for name, newDir := range dirs {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				db := openDB(b, newDir(b))
+				for k := 0; k < keys; k++ {
+					writeData(b, db, fmt.Sprintf("key%d", k), data)
+				}
+				for k := 0; k < keys; k++ {
+					readData(b, db, fmt.Sprintf("key%d", k))
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkConcurrentReadWrite reads and updates a small set of keys from many goroutines.
+// Every fourth operation is a write.
+func BenchmarkConcurrentReadWrite(b *testing.B) {
+	const keys = 16
+	data := makeData(4 * 1024)
+	for name, newDir := range dirs {
+		b.Run(name, func(b *testing.B) {
+			db := openDB(b, newDir(b))
+			for k := 0; k < keys; k++ {
+				writeData(b, db, fmt.Sprintf("key%d", k), data)
+			}
+			var counter int64
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					op := atomic.AddInt64(&counter, 1)
+					key := fmt.Sprintf("key%d", op%keys)
+					if op%4 == 0 {
+						writeData(b, db, key, data)
+					} else {
+						readData(b, db, key)
+					}
+				}
+			})
+		})
+	}
+}
+
+func openDB(b *testing.B, dir deebee.Dir) *deebee.DB {
+	db, err := deebee.Open(dir)
+	require.NoError(b, err)
+	return db
+}
+
+func writeData(b *testing.B, db *deebee.DB, key string, data []byte) {
+	writer, err := db.Writer(key)
+	require.NoError(b, err)
+	_, err = writer.Write(data)
+	require.NoError(b, err)
+	require.NoError(b, writer.Close())
+}
+
+func readData(b *testing.B, db *deebee.DB, key string) []byte {
+	reader, err := db.Reader(key)
+	require.NoError(b, err)
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(b, err)
+	require.NoError(b, reader.Close())
+	return data
+}
+
+func makeData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
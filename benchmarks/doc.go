@@ -0,0 +1,7 @@
+// Package benchmarks contains benchmarks of realistic DB workloads executed against
+// os and memory Dirs. Run them with:
+//
+//	go test -bench . -benchmem ./benchmarks
+//
+// Baseline numbers are documented in README.md in this directory.
+package benchmarks
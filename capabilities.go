@@ -0,0 +1,66 @@
+package deebee
+
+import "fmt"
+
+// DirCapabilities reports which optional interfaces are implemented by Dir
+type DirCapabilities struct {
+	// FileModTime is true when Dir implements FileModTimer. Required by OpenAsOf,
+	// WithTempFileCleanup, WithRetention and CleanTempFiles. Without it leases with damaged
+	// files are not considered held.
+	FileModTime bool
+	// FileSize is true when Dir implements FileSizer. Required by CompactionTrigger.MaxBytes.
+	FileSize bool
+	// FileIteration is true when Dir implements FileIterator, which makes listing big dirs
+	// cheaper
+	FileIteration bool
+	// DirSync is true when Dir implements DirSyncer. Required by WithDirSync.
+	DirSync bool
+	// FilesystemSync is true when Dir implements FilesystemSyncer, which makes
+	// WithGroupCommit flush the whole batch at once
+	FilesystemSync bool
+	// OsPaths is true when Dir is stored in the os filesystem, like OsDir. Required by
+	// VersionPath and Snapshot.
+	OsPaths bool
+}
+
+// Capabilities returns optional interfaces implemented by dir, so applications can check
+// which options can be used with the backend
+func Capabilities(dir Dir) DirCapabilities {
+	_, modTimer := dir.(FileModTimer)
+	_, sizer := dir.(FileSizer)
+	_, iterator := dir.(FileIterator)
+	_, dirSyncer := dir.(DirSyncer)
+	_, filesystemSyncer := dir.(FilesystemSyncer)
+	_, osPaths := asOsDir(dir)
+	return DirCapabilities{
+		FileModTime:    modTimer,
+		FileSize:       sizer,
+		FileIteration:  iterator,
+		DirSync:        dirSyncer,
+		FilesystemSync: filesystemSyncer,
+		OsPaths:        osPaths,
+	}
+}
+
+// checkCapabilities returns client error when an option used to open the DB requires
+// an interface not implemented by Dir
+func (s *DB) checkCapabilities() error {
+	capabilities := Capabilities(s.dir)
+	if s.janitor != nil && !capabilities.FileModTime {
+		return missingCapabilityError("FileModTimer", "WithTempFileCleanup")
+	}
+	if s.compactor != nil && s.compactor.trigger.MaxBytes > 0 && !capabilities.FileSize {
+		return missingCapabilityError("FileSizer", "CompactionTrigger.MaxBytes")
+	}
+	if s.usesRetention() && !capabilities.FileModTime {
+		return missingCapabilityError("FileModTimer", "WithRetention")
+	}
+	if s.dirSync && !capabilities.DirSync {
+		return missingCapabilityError("DirSyncer", "WithDirSync")
+	}
+	return nil
+}
+
+func missingCapabilityError(iface, option string) error {
+	return newClientError(fmt.Sprintf("dir does not implement %s required by %s", iface, option))
+}
@@ -0,0 +1,85 @@
+package deebee_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Run("should report all capabilities of OsDir", func(t *testing.T) {
+		capabilities := deebee.Capabilities(deebee.OsDir(createTempDir(t)))
+		expected := deebee.DirCapabilities{
+			FileModTime:    true,
+			FileSize:       true,
+			FileIteration:  true,
+			DirSync:        true,
+			FilesystemSync: runtime.GOOS == "linux",
+			OsPaths:        true,
+		}
+		assert.Equal(t, expected, capabilities)
+	})
+
+	t.Run("should report capabilities of fake dir", func(t *testing.T) {
+		capabilities := deebee.Capabilities(fake.ExistingDir())
+		expected := deebee.DirCapabilities{
+			FileModTime: true,
+			FileSize:    true,
+			DirSync:     true,
+		}
+		assert.Equal(t, expected, capabilities)
+	})
+
+	t.Run("should report no capabilities of dir implementing Dir only", func(t *testing.T) {
+		capabilities := deebee.Capabilities(failing.Rename(fake.ExistingDir()))
+		assert.Equal(t, deebee.DirCapabilities{}, capabilities)
+	})
+}
+
+func TestOpen_Capabilities(t *testing.T) {
+	t.Run("should return client error when option requires missing capability", func(t *testing.T) {
+		options := map[string]deebee.Option{
+			"WithTempFileCleanup": deebee.WithTempFileCleanup(time.Hour, 0),
+			"MaxBytes":            deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxBytes: 1}),
+			"WithDirSync":         deebee.WithDirSync(),
+			"WithRetention":       deebee.WithRetention(deebee.Tiered(deebee.Tier{Age: time.Hour})),
+		}
+		for name, option := range options {
+			t.Run(name, func(t *testing.T) {
+				// when
+				db, err := deebee.Open(failing.Rename(fake.ExistingDir()), option)
+				// then
+				require.Error(t, err)
+				assert.True(t, deebee.IsClientError(err))
+				assert.Contains(t, err.Error(), name)
+				assert.Nil(t, db)
+			})
+		}
+	})
+
+	t.Run("should return client error when key options require missing capability", func(t *testing.T) {
+		option := deebee.WithKeyOptions("logs/*", deebee.WithRetention(deebee.Tiered(deebee.Tier{Age: time.Hour})))
+		// when
+		_, err := deebee.Open(failing.Rename(fake.ExistingDir()), option)
+		// then
+		assert.True(t, deebee.IsClientError(err))
+		assert.Contains(t, err.Error(), "WithRetention")
+	})
+}
+
+func TestDB_Reconfigure_Capabilities(t *testing.T) {
+	t.Run("should return client error when retention is set for dir without FileModTimer", func(t *testing.T) {
+		db := openDB(t, failing.Rename(fake.ExistingDir()))
+		// when
+		err := db.Reconfigure(deebee.WithRetention(deebee.Tiered(deebee.Tier{Age: time.Hour})))
+		// then
+		assert.True(t, deebee.IsClientError(err))
+		assert.Contains(t, err.Error(), "WithRetention")
+	})
+}
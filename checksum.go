@@ -0,0 +1,152 @@
+package deebee
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+)
+
+const checksumSize = sha256.Size
+
+// WithChecksum stores SHA-256 checksum at the end of each file and verifies it on read.
+// Data is verified incrementally as it is read, therefore reading large values does not
+// have to wait until the whole file is hashed. When data does not match the checksum,
+// the final Read and Close return corruption error (see IsCorrupted). Verification can
+// be skipped for a single read using ReaderWithOptions.
+//
+// Checksum is calculated from data stored in the file, that is after all filters
+// were applied. Option must be used consistently - files written without a checksum
+// cannot be read when option is used and vice versa, unless they were written
+// WithFileHeader.
+func WithChecksum() Option {
+	return func(db *DB) error {
+		db.checksum = true
+		return nil
+	}
+}
+
+// ReaderOptions changes the behaviour of a single read
+type ReaderOptions struct {
+	// SkipVerification disables checksum verification. Makes the read faster, but
+	// corrupted data is returned without an error. Ignored when DB was opened without
+	// WithChecksum.
+	SkipVerification bool
+}
+
+// ErrCorrupted can be wrapped by errors returned from implementations of interfaces
+// defined by this package, such as KeyMapper, to report corrupted data (see IsCorrupted)
+var ErrCorrupted error = &corruptedError{message: "corrupted"}
+
+type corruptedError struct {
+	message string
+}
+
+func (e *corruptedError) Error() string {
+	return e.message
+}
+
+// IsCorrupted returns true when data read from the file does not match its checksum, or
+// the error wraps ErrCorrupted
+func IsCorrupted(err error) bool {
+	var corrupted *corruptedError
+	return errors.As(err, &corrupted)
+}
+
+// checksumWriter calculates checksum of all written data and writes it after the data
+type checksumWriter struct {
+	io.Writer
+	hash hash.Hash
+	file io.Writer
+}
+
+func newChecksumWriter(file io.Writer) *checksumWriter {
+	h := sha256.New()
+	return &checksumWriter{
+		Writer: io.MultiWriter(file, h),
+		hash:   h,
+		file:   file,
+	}
+}
+
+// Close writes the checksum. The file is not closed.
+func (w *checksumWriter) Close() error {
+	_, err := w.file.Write(w.hash.Sum(nil))
+	return err
+}
+
+// checksumReader strips checksum from the end of the file. When hash is not nil, the
+// data is verified incrementally as it is read. Checksum error is returned by the final
+// Read and by Close.
+type checksumReader struct {
+	io.ReadCloser
+	hash    hash.Hash
+	pending []byte
+	chunk   []byte
+	eof     bool
+	err     error
+}
+
+func newChecksumReader(r io.ReadCloser, verify bool) *checksumReader {
+	reader := &checksumReader{
+		ReadCloser: r,
+		chunk:      make([]byte, 32*1024),
+	}
+	if verify {
+		reader.hash = sha256.New()
+	}
+	return reader
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// last checksumSize bytes are held back until it is known whether they are the checksum
+	for !r.eof && len(r.pending) <= checksumSize {
+		n, err := r.ReadCloser.Read(r.chunk)
+		r.pending = append(r.pending, r.chunk[:n]...)
+		if err == io.EOF {
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	available := len(r.pending) - checksumSize
+	if available < 0 {
+		r.err = &corruptedError{message: "file is too short to contain checksum"}
+		return 0, r.err
+	}
+	if available == 0 {
+		r.err = r.verify()
+		return 0, r.err
+	}
+	n := copy(p, r.pending[:available])
+	if r.hash != nil {
+		r.hash.Write(p[:n])
+	}
+	r.pending = append(r.pending[:0], r.pending[n:]...)
+	return n, nil
+}
+
+// verify must be called after all data was read
+func (r *checksumReader) verify() error {
+	if r.hash != nil && !bytes.Equal(r.hash.Sum(nil), r.pending) {
+		return &corruptedError{message: "checksum mismatch"}
+	}
+	return io.EOF
+}
+
+// Close closes the file and returns checksum error if it was detected. Data which was
+// not read is not verified.
+func (r *checksumReader) Close() error {
+	err := r.ReadCloser.Close()
+	if IsCorrupted(r.err) {
+		return r.err
+	}
+	return err
+}
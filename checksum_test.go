@@ -0,0 +1,160 @@
+package deebee_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithChecksum(t *testing.T) {
+	t.Run("should read data written with checksum", func(t *testing.T) {
+		for _, data := range [][]byte{{}, []byte("data"), makeData(100000, 1)} {
+			db := openDB(t, fake.ExistingDir(), deebee.WithChecksum())
+			writeData(t, db, "key", data)
+			// expect
+			assert.Equal(t, data, readData(t, db, "key"))
+		}
+	})
+
+	t.Run("should read data with filter", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithChecksum(), deebee.WithFilter(prefixFilter("prefix")))
+		writeData(t, db, "key", []byte("data"))
+		// expect
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should store checksum after the data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		files := dir.Dir("key").(fake.Dir).Files()
+		require.Len(t, files, 1)
+		assert.Len(t, files[0].Data(), len("data")+32)
+	})
+
+	t.Run("should return corruption error when file was corrupted", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("data"))
+		corruptLatest(t, dir, "key")
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		// when
+		_, err = ioutil.ReadAll(reader)
+		// then
+		assert.True(t, deebee.IsCorrupted(err))
+		assert.False(t, deebee.IsClientError(err))
+		// and
+		err = reader.Close()
+		assert.True(t, deebee.IsCorrupted(err))
+	})
+
+	t.Run("should return data before the whole file was verified", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", makeData(1000000, 1))
+		corruptLatest(t, dir, "key")
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		// when
+		n, err := reader.Read(make([]byte, 10))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 10, n)
+		// and
+		_, err = ioutil.ReadAll(reader)
+		assert.True(t, deebee.IsCorrupted(err))
+	})
+
+	t.Run("should not return error from Close when data was not read", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("data"))
+		corruptLatest(t, dir, "key")
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		// when
+		err = reader.Close()
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return corruption error when file is too short", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, dir, deebee.WithChecksum())
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		// when
+		_, err = ioutil.ReadAll(reader)
+		// then
+		assert.True(t, deebee.IsCorrupted(err))
+		_ = reader.Close()
+	})
+
+	t.Run("should fail Verify when file was corrupted", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("data"))
+		corruptLatest(t, dir, "key")
+		// when
+		failed, err := db.Verify(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		assert.True(t, deebee.IsCorrupted(failed["key"]))
+	})
+}
+
+func TestDB_ReaderWithOptions(t *testing.T) {
+	t.Run("should skip verification", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("data"))
+		corruptLatest(t, dir, "key")
+		// when
+		reader, err := db.ReaderWithOptions("key", deebee.ReaderOptions{SkipVerification: true})
+		// then
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Len(t, data, len("data"))
+		assert.NotEqual(t, []byte("data"), data)
+		require.NoError(t, reader.Close())
+	})
+
+	t.Run("should read data without checksum", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		reader, err := db.ReaderWithOptions("key", deebee.ReaderOptions{SkipVerification: true})
+		// then
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), data)
+		require.NoError(t, reader.Close())
+	})
+
+	t.Run("should return client error for invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithChecksum())
+		for _, key := range invalidKeys {
+			_, err := db.ReaderWithOptions(key, deebee.ReaderOptions{})
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+}
+
+// corruptLatest corrupts the only file in the state dir
+func corruptLatest(t *testing.T, dir fake.Dir, key string) {
+	stateDir := dir.Dir(key).(fake.Dir)
+	files := stateDir.Files()
+	require.Len(t, files, 1)
+	require.NoError(t, stateDir.Corrupt(files[0].Name()))
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// commands lists names of commands which can be completed
+var commands = []string{"migrate", "compact", "corrupt", "truncate", "inspect", "repair", "completion"}
+
+// commandFlags lists flags of each command which can be completed
+var commandFlags = map[string][]string{
+	"migrate":  {"--from", "--to", "--from-layout", "--layout", "--history", "--json"},
+	"compact":  {"--dir", "--layout", "--dry-run", "--json"},
+	"corrupt":  {"--dir", "--layout", "--version", "--yes", "--json"},
+	"truncate": {"--dir", "--layout", "--version", "--yes", "--json"},
+	"inspect":  {"--dir", "--layout", "--checksum", "--json"},
+	"repair":   {"--dir", "--layout", "--checksum", "--remove-corrupted", "--force", "--compact", "--json"},
+}
+
+// keyCommands are commands accepting key as an argument
+var keyCommands = map[string]bool{"corrupt": true, "truncate": true, "inspect": true}
+
+var completionScripts = map[string]string{
+	"bash": `_deebee() {
+  local IFS=$'\n'
+  COMPREPLY=($(deebee __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+  if [ "${#COMPREPLY[@]}" -eq 1 ] && [[ "${COMPREPLY[0]}" == */ ]]; then
+    compopt -o nospace
+  fi
+}
+complete -F _deebee deebee
+`,
+	"zsh": `#compdef deebee
+_deebee() {
+  local -a completions dirs others
+  completions=("${(@f)$(deebee __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+  dirs=(${(M)completions:#*/})
+  others=(${completions:#*/})
+  (( ${#dirs} )) && compadd -S '' -- $dirs
+  (( ${#others} )) && compadd -- $others
+}
+compdef _deebee deebee
+`,
+	"fish": `function __deebee_complete
+    set -l tokens (commandline -opc)
+    set -l current (commandline -ct)
+    deebee __complete $tokens[2..-1] "$current" 2>/dev/null
+end
+complete -c deebee -f -a '(__deebee_complete)'
+`,
+}
+
+func completion(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("completion", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: deebee completion bash|zsh|fish")
+	}
+	if err := flags.Parse(args); err != nil {
+		// error was already printed by flags
+		return flag.ErrHelp
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	script, ok := completionScripts[flags.Arg(0)]
+	if !ok {
+		return errors.New("unsupported shell " + flags.Arg(0))
+	}
+	_, _ = fmt.Fprint(stdout, script)
+	return nil
+}
+
+// complete prints completions for words typed after "deebee", one per line. The last word
+// is the one being completed, possibly empty.
+func complete(words []string, stdout io.Writer) {
+	for _, line := range completions(words) {
+		_, _ = fmt.Fprintln(stdout, line)
+	}
+}
+
+func completions(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	if len(words) == 1 {
+		return withPrefix(commands, current)
+	}
+	command, previous := words[0], words[len(words)-2]
+	switch {
+	case command == "completion":
+		if len(words) == 2 {
+			return withPrefix([]string{"bash", "fish", "zsh"}, current)
+		}
+		return nil
+	case previous == "--dir" || previous == "--from" || previous == "--to":
+		return completeDirs(current)
+	case previous == "--layout" || previous == "--from-layout":
+		return withPrefix([]string{"flat", "sharded"}, current)
+	case strings.HasPrefix(current, "-"):
+		return withPrefix(commandFlags[command], current)
+	case keyCommands[command]:
+		return completeKeys(words[1:len(words)-1], current)
+	}
+	return nil
+}
+
+func withPrefix(candidates []string, prefix string) []string {
+	var matching []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matching = append(matching, candidate)
+		}
+	}
+	return matching
+}
+
+// completeDirs returns directories starting with prefix, ending with a separator
+func completeDirs(prefix string) []string {
+	parent, name := filepath.Split(prefix)
+	listed := parent
+	if listed == "" {
+		listed = "."
+	}
+	infos, err := ioutil.ReadDir(listed)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, info := range infos {
+		if info.IsDir() && strings.HasPrefix(info.Name(), name) {
+			dirs = append(dirs, parent+info.Name()+string(filepath.Separator))
+		}
+	}
+	return dirs
+}
+
+// completeKeys lists keys of the database given by --dir flag in args
+func completeKeys(args []string, prefix string) []string {
+	dir, layout := flagValue(args, "dir"), flagValue(args, "layout")
+	if dir == "" {
+		return nil
+	}
+	if layout == "" {
+		layout = "flat"
+	}
+	db, err := openDB(dir, layout)
+	if err != nil {
+		return nil
+	}
+	keys, err := db.List("")
+	if err != nil {
+		return nil
+	}
+	sort.Strings(keys)
+	return withPrefix(keys, prefix)
+}
+
+// flagValue returns the value of the flag given as "--name value" or "--name=value"
+func flagValue(args []string, name string) string {
+	for i, arg := range args {
+		trimmed := strings.TrimLeft(arg, "-")
+		if trimmed == arg {
+			continue
+		}
+		if trimmed == name && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(trimmed, name+"=") {
+			return strings.TrimPrefix(trimmed, name+"=")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletion(t *testing.T) {
+	t.Run("should print completion script", func(t *testing.T) {
+		for _, shell := range []string{"bash", "zsh", "fish"} {
+			stdout := &bytes.Buffer{}
+			// when
+			code := run([]string{"completion", shell}, stdout, ioutil.Discard)
+			// then
+			require.Equal(t, 0, code)
+			assert.Contains(t, stdout.String(), "deebee __complete")
+		}
+	})
+
+	t.Run("should return error for unsupported shell", func(t *testing.T) {
+		stderr := &bytes.Buffer{}
+		code := run([]string{"completion", "powershell"}, ioutil.Discard, stderr)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), "unsupported shell powershell")
+	})
+
+	t.Run("should list flags of all commands", func(t *testing.T) {
+		flagPattern := regexp.MustCompile(`(?m)^  -(\S+)`)
+		for command, flags := range commandFlags {
+			stderr := &bytes.Buffer{}
+			run([]string{command, "-h"}, ioutil.Discard, stderr)
+			var defined []string
+			for _, match := range flagPattern.FindAllStringSubmatch(stderr.String(), -1) {
+				defined = append(defined, "--"+match[1])
+			}
+			assert.ElementsMatch(t, defined, flags, command)
+		}
+	})
+}
+
+func TestComplete(t *testing.T) {
+	t.Run("should complete commands", func(t *testing.T) {
+		assert.Equal(t, []string{"compact", "corrupt", "completion"}, completeWords(t, "co"))
+		assert.Equal(t, commands, completeWords(t, ""))
+	})
+
+	t.Run("should complete flags", func(t *testing.T) {
+		assert.Equal(t, []string{"--dir", "--dry-run"}, completeWords(t, "compact", "--d"))
+	})
+
+	t.Run("should complete layouts", func(t *testing.T) {
+		assert.Equal(t, []string{"sharded"}, completeWords(t, "inspect", "--layout", "s"))
+	})
+
+	t.Run("should complete shells", func(t *testing.T) {
+		assert.Equal(t, []string{"bash", "fish", "zsh"}, completeWords(t, "completion", ""))
+	})
+
+	t.Run("should complete directories", func(t *testing.T) {
+		dir := createTempDir(t)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "data"), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "dump"), nil, 0600))
+		prefix := filepath.Join(dir, "d")
+		// when
+		completions := completeWords(t, "compact", "--dir", prefix)
+		// then
+		expected := filepath.Join(dir, "data") + string(filepath.Separator)
+		assert.Equal(t, []string{expected}, completions)
+	})
+
+	t.Run("should complete keys", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir))
+		require.NoError(t, err)
+		writeData(t, db, "apple", []byte("data"))
+		writeData(t, db, "avocado", []byte("data"))
+		writeData(t, db, "banana", []byte("data"))
+		// expect
+		assert.Equal(t, []string{"apple", "avocado"}, completeWords(t, "inspect", "--dir", dir, "a"))
+		assert.Equal(t, []string{"apple", "avocado", "banana"}, completeWords(t, "corrupt", "--dir="+dir, "--yes", ""))
+	})
+
+	t.Run("should complete keys of sharded database", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir), deebee.WithShardedLayout())
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("data"))
+		// expect
+		assert.Equal(t, []string{"key"}, completeWords(t, "truncate", "--layout", "sharded", "--dir", dir, ""))
+	})
+
+	t.Run("should not complete keys without dir", func(t *testing.T) {
+		assert.Empty(t, completeWords(t, "inspect", ""))
+	})
+}
+
+// completeWords returns completions printed by the hidden __complete command
+func completeWords(t *testing.T, words ...string) []string {
+	stdout := &bytes.Buffer{}
+	code := run(append([]string{"__complete"}, words...), stdout, ioutil.Discard)
+	require.Equal(t, 0, code)
+	var completions []string
+	for _, line := range bytes.Split(bytes.TrimSuffix(stdout.Bytes(), []byte("\n")), []byte("\n")) {
+		if len(line) > 0 {
+			completions = append(completions, string(line))
+		}
+	}
+	return completions
+}
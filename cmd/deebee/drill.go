@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jacekolszak/deebee"
+)
+
+// damageFunc damages the file at path
+type damageFunc func(path string) error
+
+func corrupt(args []string, stdout, stderr io.Writer) error {
+	return damage("corrupt", "flipped bits in", corruptFile, args, stdout, stderr)
+}
+
+func truncate(args []string, stdout, stderr io.Writer) error {
+	return damage("truncate", "truncated", truncateFile, args, stdout, stderr)
+}
+
+// damage damages the file of the key version, so operators can rehearse recovery
+// procedures and check if monitoring notices integrity failures
+func damage(command, verb string, fn damageFunc, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: deebee %s --dir <dir> [flags] --yes <key>\n", command)
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", "", "database directory")
+	layout := flags.String("layout", "flat", "layout of the database: flat or sharded")
+	version := flags.Int("version", -1, "version to damage, the latest one by default")
+	yes := flags.Bool("yes", false, "confirm that data should be damaged")
+	jsonOutput := flags.Bool("json", false, "print the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		// error was already printed by flags
+		return flag.ErrHelp
+	}
+	if *dir == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	if !*yes {
+		return errors.New("refusing to damage data without --yes")
+	}
+	key := flags.Arg(0)
+	db, err := openDB(*dir, *layout)
+	if err != nil {
+		return err
+	}
+	if *version < 0 {
+		if *version, err = latestVersion(db, key); err != nil {
+			return err
+		}
+	}
+	path, err := db.VersionPath(key, *version)
+	if err != nil {
+		return err
+	}
+	if err = fn(path); err != nil {
+		return err
+	}
+	if *jsonOutput {
+		return printJSON(stdout, damageResult{Command: command, Key: key, Version: *version})
+	}
+	_, _ = fmt.Fprintf(stdout, "%s version %d of %s\n", verb, *version, key)
+	return nil
+}
+
+type damageResult struct {
+	Command string `json:"command"`
+	Key     string `json:"key"`
+	Version int    `json:"version"`
+}
+
+// latestVersion returns the latest data version of the key
+func latestVersion(db *deebee.DB, key string) (int, error) {
+	versions, err := db.Versions(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, fmt.Errorf("key %s has no versions", key)
+	}
+	latest := versions[len(versions)-1]
+	if latest.Deleted {
+		return 0, fmt.Errorf("key %s is deleted", key)
+	}
+	return latest.Version, nil
+}
+
+// corruptFile flips all bits of the byte in the middle of the file
+func corruptFile(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	if info.Size() == 0 {
+		_, err = file.Write([]byte{0xff})
+	} else {
+		b := make([]byte, 1)
+		offset := info.Size() / 2
+		if _, err = file.ReadAt(b, offset); err == nil {
+			b[0] ^= 0xff
+			_, err = file.WriteAt(b, offset)
+		}
+	}
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// truncateFile removes the second half of the file, like a torn write would do
+func truncateFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.Truncate(path, info.Size()/2)
+}
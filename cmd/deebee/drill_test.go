@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDamage(t *testing.T) {
+	for _, command := range []string{"corrupt", "truncate"} {
+		t.Run(command, func(t *testing.T) {
+			t.Run("should refuse to damage data without --yes", func(t *testing.T) {
+				dir := createTempDir(t)
+				db, err := deebee.Open(deebee.OsDir(dir), deebee.WithChecksum())
+				require.NoError(t, err)
+				writeData(t, db, "key", []byte("data"))
+				stderr := &bytes.Buffer{}
+				// when
+				code := run([]string{command, "--dir", dir, "key"}, ioutil.Discard, stderr)
+				// then
+				assert.Equal(t, 1, code)
+				assert.Contains(t, stderr.String(), "--yes")
+				_, err = readAll(db, "key")
+				assert.NoError(t, err)
+			})
+
+			t.Run("should damage the latest version", func(t *testing.T) {
+				dir := createTempDir(t)
+				db, err := deebee.Open(deebee.OsDir(dir), deebee.WithChecksum())
+				require.NoError(t, err)
+				writeData(t, db, "key", []byte("data"))
+				// when
+				code := run([]string{command, "--dir", dir, "--yes", "key"}, ioutil.Discard, ioutil.Discard)
+				// then
+				require.Equal(t, 0, code)
+				_, err = readAll(db, "key")
+				assert.True(t, deebee.IsCorrupted(err))
+			})
+
+			t.Run("should damage given version", func(t *testing.T) {
+				dir := createTempDir(t)
+				db, err := deebee.Open(deebee.OsDir(dir), deebee.WithChecksum())
+				require.NoError(t, err)
+				writeData(t, db, "key", []byte("old"))
+				versions, err := db.Versions("key")
+				require.NoError(t, err)
+				writeData(t, db, "key", []byte("new"))
+				version := versions[0].Version
+				// when
+				code := run([]string{command, "--dir", dir, "--yes", "--version", strconv.Itoa(version), "key"}, ioutil.Discard, ioutil.Discard)
+				// then
+				require.Equal(t, 0, code)
+				data, err := readAll(db, "key")
+				require.NoError(t, err)
+				assert.Equal(t, []byte("new"), data)
+			})
+
+			t.Run("should print result as JSON", func(t *testing.T) {
+				dir := createTempDir(t)
+				db, err := deebee.Open(deebee.OsDir(dir))
+				require.NoError(t, err)
+				writeData(t, db, "key", []byte("data"))
+				stdout := &bytes.Buffer{}
+				// when
+				code := run([]string{command, "--dir", dir, "--yes", "--json", "key"}, stdout, ioutil.Discard)
+				// then
+				require.Equal(t, 0, code)
+				var result damageResult
+				require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+				assert.Equal(t, damageResult{Command: command, Key: "key", Version: 0}, result)
+			})
+
+			t.Run("should return error for missing key", func(t *testing.T) {
+				dir := createTempDir(t)
+				stderr := &bytes.Buffer{}
+				// when
+				code := run([]string{command, "--dir", dir, "--yes", "missing"}, ioutil.Discard, stderr)
+				// then
+				assert.Equal(t, 1, code)
+				assert.Contains(t, stderr.String(), "data not found")
+			})
+		})
+	}
+}
+
+func readAll(db *deebee.DB, key string) ([]byte, error) {
+	reader, err := db.Reader(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	return data, err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+)
+
+type inspectedVersion struct {
+	Version  int       `json:"version"`
+	Deleted  bool      `json:"deleted"`
+	Pinned   bool      `json:"pinned"`
+	Archived bool      `json:"archived"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	// Integrity is "ok", "unverified" or the error returned when reading the version
+	Integrity string `json:"integrity"`
+}
+
+type inspection struct {
+	Key      string             `json:"key"`
+	Versions []inspectedVersion `json:"versions"`
+}
+
+func inspect(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: deebee inspect --dir <dir> [flags] <key>")
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", "", "database directory")
+	layout := flags.String("layout", "flat", "layout of the database: flat or sharded")
+	checksum := flags.Bool("checksum", false, "verify checksums of versions written WithChecksum")
+	jsonOutput := flags.Bool("json", false, "print JSON instead of a table")
+	if err := flags.Parse(args); err != nil {
+		// error was already printed by flags
+		return flag.ErrHelp
+	}
+	if *dir == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	var options []deebee.Option
+	if *checksum {
+		options = append(options, deebee.WithChecksum())
+	}
+	db, err := openDB(*dir, *layout, options...)
+	if err != nil {
+		return err
+	}
+	result, err := inspectKey(db, flags.Arg(0), *checksum)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		return printJSON(stdout, result)
+	}
+	return printInspection(stdout, result)
+}
+
+func inspectKey(db *deebee.DB, key string, verify bool) (inspection, error) {
+	versions, err := db.Versions(key)
+	if err != nil {
+		return inspection{}, err
+	}
+	result := inspection{Key: key, Versions: []inspectedVersion{}}
+	for _, v := range versions {
+		inspected := inspectedVersion{
+			Version:   v.Version,
+			Deleted:   v.Deleted,
+			Pinned:    v.Pinned,
+			Archived:  v.Archived,
+			Integrity: "unverified",
+		}
+		if !v.Deleted && !v.Archived {
+			path, err := db.VersionPath(key, v.Version)
+			if err != nil {
+				return inspection{}, err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return inspection{}, err
+			}
+			inspected.Size = info.Size()
+			inspected.Modified = info.ModTime()
+			if verify {
+				inspected.Integrity = verifyVersion(db, key, v.Version)
+			}
+		}
+		result.Versions = append(result.Versions, inspected)
+	}
+	return result, nil
+}
+
+// verifyVersion reads the whole version, so the checksum is verified
+func verifyVersion(db *deebee.DB, key string, version int) string {
+	reader, err := db.VersionReader(key, version)
+	if err != nil {
+		return err.Error()
+	}
+	_, err = io.Copy(ioutil.Discard, reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+func printInspection(w io.Writer, result inspection) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "VERSION\tSIZE\tMODIFIED\tDELETED\tPINNED\tINTEGRITY")
+	for _, v := range result.Versions {
+		modified := "-"
+		if !v.Modified.IsZero() {
+			modified = v.Modified.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(table, "%d\t%d\t%s\t%t\t%t\t%s\n",
+			v.Version, v.Size, modified, v.Deleted, v.Pinned, v.Integrity)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	t.Run("should return usage error without key", func(t *testing.T) {
+		code := run([]string{"inspect", "--dir", createTempDir(t)}, ioutil.Discard, ioutil.Discard)
+		assert.Equal(t, 2, code)
+	})
+
+	t.Run("should print versions", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir))
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		stdout := &bytes.Buffer{}
+		// when
+		code := run([]string{"inspect", "--dir", dir, "key"}, stdout, ioutil.Discard)
+		// then
+		require.Equal(t, 0, code)
+		lines := bytes.Split(bytes.TrimSpace(stdout.Bytes()), []byte("\n"))
+		require.Len(t, lines, 3)
+		assert.Contains(t, string(lines[0]), "INTEGRITY")
+		assert.Regexp(t, `^0\s+4\s+\S+\s+false\s+false\s+unverified$`, string(lines[1]))
+		assert.Regexp(t, `^1\s+0\s+-\s+true\s+false\s+unverified$`, string(lines[2]))
+	})
+
+	t.Run("should print JSON with checksum status", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir), deebee.WithChecksum())
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		require.Equal(t, 0, run([]string{"corrupt", "--dir", dir, "--yes", "key"}, ioutil.Discard, ioutil.Discard))
+		stdout := &bytes.Buffer{}
+		// when
+		code := run([]string{"inspect", "--dir", dir, "--checksum", "--json", "key"}, stdout, ioutil.Discard)
+		// then
+		require.Equal(t, 0, code)
+		var result inspection
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+		assert.Equal(t, "key", result.Key)
+		require.Len(t, result.Versions, 2)
+		assert.Equal(t, "ok", result.Versions[0].Integrity)
+		assert.Equal(t, "checksum mismatch", result.Versions[1].Integrity)
+		assert.Equal(t, int64(3+32), result.Versions[0].Size)
+	})
+}
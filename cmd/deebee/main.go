@@ -0,0 +1,243 @@
+// Command deebee provides tools for operating deebee databases
+//
+// Usage:
+//
+//	deebee migrate --from <dir> --to <dir> [--from-layout flat|sharded] [--layout flat|sharded] [--history] [--json]
+//	deebee compact --dir <dir> [--layout flat|sharded] [--dry-run] [--json]
+//	deebee corrupt --dir <dir> [--layout flat|sharded] [--version n] [--json] --yes <key>
+//	deebee truncate --dir <dir> [--layout flat|sharded] [--version n] [--json] --yes <key>
+//	deebee inspect --dir <dir> [--layout flat|sharded] [--checksum] [--json] <key>
+//	deebee repair --dir <dir> [--layout flat|sharded] [--checksum] [--remove-corrupted] [--compact] [--json]
+//	deebee completion bash|zsh|fish
+//
+// With --json the output is printed as JSON values, one per line, so it can be parsed by
+// scripts. Errors are printed to stderr and the exit code is 1, or 2 for usage errors.
+//
+// Shell completion, including names of keys, is enabled by sourcing the output of the
+// completion command, for example:
+//
+//	source <(deebee completion bash)
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/jacekolszak/deebee"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+const usage = `Usage: deebee <command> [flags]
+
+Commands:
+  migrate     copy states between directories or layouts
+  compact     remove versions which are no longer needed
+  corrupt     flip bits in a version, to rehearse recovery procedures
+  truncate    cut a version in half, to rehearse recovery procedures
+  inspect     print versions of a key with their sizes and integrity
+  repair      recover the database after a crash, before the service is started
+  completion  print shell completion script for bash, zsh or fish
+`
+
+// run executes the command and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(stderr, usage)
+		return 2
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+	go func() {
+		select {
+		case <-interrupted:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = migrate(ctx, args[1:], stdout, stderr)
+	case "compact":
+		err = compact(ctx, args[1:], stdout, stderr)
+	case "corrupt":
+		err = corrupt(args[1:], stdout, stderr)
+	case "truncate":
+		err = truncate(args[1:], stdout, stderr)
+	case "inspect":
+		err = inspect(args[1:], stdout, stderr)
+	case "repair":
+		err = repair(ctx, args[1:], stdout, stderr)
+	case "completion":
+		err = completion(args[1:], stdout, stderr)
+	case "__complete":
+		complete(args[1:], stdout)
+	default:
+		_, _ = fmt.Fprintf(stderr, "unknown command %s\n\n%s", args[0], usage)
+		return 2
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 2
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+func migrate(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	from := flags.String("from", "", "source database directory")
+	to := flags.String("to", "", "destination database directory, which must exist")
+	fromLayout := flags.String("from-layout", "flat", "layout of the source database: flat or sharded")
+	layout := flags.String("layout", "flat", "layout of the destination database: flat or sharded")
+	history := flags.Bool("history", false, "copy all versions instead of the latest one only")
+	jsonOutput := flags.Bool("json", false, "print progress as JSON lines")
+	if err := flags.Parse(args); err != nil {
+		// error was already printed by flags
+		return flag.ErrHelp
+	}
+	if *from == "" || *to == "" {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	src, err := openDB(*from, *fromLayout)
+	if err != nil {
+		return fmt.Errorf("opening source failed: %w", err)
+	}
+	dst, err := openDB(*to, *layout)
+	if err != nil {
+		return fmt.Errorf("opening destination failed: %w", err)
+	}
+	err = deebee.Migrate(ctx, src, dst, deebee.MigrateOptions{History: *history}, func(p deebee.Progress) {
+		if *jsonOutput {
+			_ = printJSON(stdout, progressLine{Key: p.Key, Done: p.Done, Total: p.Total, Bytes: p.Bytes})
+			return
+		}
+		_, _ = fmt.Fprintf(stdout, "%d/%d %s (%d bytes)\n", p.Done, p.Total, p.Key, p.Bytes)
+	})
+	if err != nil {
+		return err
+	}
+	return dst.Close()
+}
+
+func compact(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("dir", "", "database directory")
+	layout := flags.String("layout", "flat", "layout of the database: flat or sharded")
+	dryRun := flags.Bool("dry-run", false, "only print versions which would be removed")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		// error was already printed by flags
+		return flag.ErrHelp
+	}
+	if *dir == "" {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	db, err := openDB(*dir, *layout)
+	if err != nil {
+		return err
+	}
+	report, err := db.CompactWithOptions(ctx, deebee.CompactOptions{DryRun: *dryRun}, nil)
+	if *jsonOutput {
+		_ = printJSON(stdout, newRemovalResult(report, *dryRun))
+	} else {
+		printRemovalReport(stdout, report, *dryRun)
+	}
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+func printRemovalReport(w io.Writer, report deebee.RemovalReport, dryRun bool) {
+	verb := "removed"
+	if dryRun {
+		verb = "would remove"
+	}
+	for _, removed := range report.Removed {
+		kind := "version"
+		if removed.Deleted {
+			kind = "tombstone"
+		}
+		_, _ = fmt.Fprintf(w, "%s %s %d of %s\n", verb, kind, removed.Version, removed.Key)
+	}
+	_, _ = fmt.Fprintf(w, "%s %d versions\n", verb, len(report.Removed))
+}
+
+type progressLine struct {
+	Key   string `json:"key"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	Bytes int64  `json:"bytes"`
+}
+
+type removalResult struct {
+	DryRun  bool             `json:"dryRun"`
+	Removed []removedVersion `json:"removed"`
+}
+
+type removedVersion struct {
+	Key     string `json:"key"`
+	Version int    `json:"version"`
+	Deleted bool   `json:"deleted"`
+}
+
+func newRemovalResult(report deebee.RemovalReport, dryRun bool) removalResult {
+	result := removalResult{DryRun: dryRun, Removed: []removedVersion{}}
+	for _, removed := range report.Removed {
+		result.Removed = append(result.Removed, removedVersion{
+			Key:     removed.Key,
+			Version: removed.Version,
+			Deleted: removed.Deleted,
+		})
+	}
+	return result
+}
+
+// printJSON prints v as a single line of JSON
+func printJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func openDB(location, layout string, options ...deebee.Option) (*deebee.DB, error) {
+	dir, options, err := locate(location, layout, options...)
+	if err != nil {
+		return nil, err
+	}
+	return deebee.Open(dir, options...)
+}
+
+// locate returns dir of the database together with options describing its layout
+func locate(location, layout string, options ...deebee.Option) (deebee.Dir, []deebee.Option, error) {
+	if i := strings.Index(location, "://"); i >= 0 {
+		return nil, nil, fmt.Errorf("unsupported backend %s: only local directories are supported", location[:i])
+	}
+	switch layout {
+	case "flat":
+	case "sharded":
+		options = append(options, deebee.WithShardedLayout())
+	default:
+		return nil, nil, fmt.Errorf("unknown layout %s", layout)
+	}
+	return deebee.OsDir(location), options, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("should return usage error", func(t *testing.T) {
+		argsList := [][]string{
+			{},
+			{"unknown"},
+			{"migrate"},
+			{"migrate", "--unknown"},
+		}
+		for _, args := range argsList {
+			stderr := &bytes.Buffer{}
+			code := run(args, ioutil.Discard, stderr)
+			assert.Equal(t, 2, code)
+			assert.NotEmpty(t, stderr.String())
+		}
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	t.Run("should return error for unsupported backend", func(t *testing.T) {
+		stderr := &bytes.Buffer{}
+		code := run([]string{"migrate", "--from", "s3://bucket", "--to", createTempDir(t)}, ioutil.Discard, stderr)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), "unsupported backend s3")
+	})
+
+	t.Run("should migrate to sharded layout", func(t *testing.T) {
+		from := createTempDir(t)
+		to := createTempDir(t)
+		src, err := deebee.Open(deebee.OsDir(from))
+		require.NoError(t, err)
+		writeData(t, src, "key", []byte("data"))
+		// when
+		code := run([]string{"migrate", "--from", from, "--to", to, "--layout", "sharded"}, ioutil.Discard, ioutil.Discard)
+		// then
+		require.Equal(t, 0, code)
+		dst, err := deebee.Open(deebee.OsDir(to), deebee.WithShardedLayout())
+		require.NoError(t, err)
+		reader, err := dst.Reader("key")
+		require.NoError(t, err)
+		defer reader.Close()
+		actual, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), actual)
+	})
+
+	t.Run("should print progress as JSON lines", func(t *testing.T) {
+		from := createTempDir(t)
+		src, err := deebee.Open(deebee.OsDir(from))
+		require.NoError(t, err)
+		writeData(t, src, "key", []byte("data"))
+		stdout := &bytes.Buffer{}
+		// when
+		code := run([]string{"migrate", "--from", from, "--to", createTempDir(t), "--json"}, stdout, ioutil.Discard)
+		// then
+		require.Equal(t, 0, code)
+		var line progressLine
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &line))
+		assert.Equal(t, progressLine{Key: "key", Done: 1, Total: 1, Bytes: 4}, line)
+	})
+}
+
+func writeData(t *testing.T, db *deebee.DB, key string, data []byte) {
+	writer, err := db.Writer(key)
+	require.NoError(t, err)
+	_, err = writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+}
+
+func createTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "deebee")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func TestCompact(t *testing.T) {
+	t.Run("should not remove versions in dry run mode", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir))
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		stdout := &bytes.Buffer{}
+		// when
+		code := run([]string{"compact", "--dir", dir, "--dry-run"}, stdout, ioutil.Discard)
+		// then
+		require.Equal(t, 0, code)
+		assert.Contains(t, stdout.String(), "would remove 1 versions")
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		assert.Len(t, versions, 2)
+	})
+
+	t.Run("should remove versions", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir))
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		// when
+		code := run([]string{"compact", "--dir", dir}, ioutil.Discard, ioutil.Discard)
+		// then
+		require.Equal(t, 0, code)
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("should print report as JSON", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir))
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("old"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("new"))
+		stdout := &bytes.Buffer{}
+		// when
+		code := run([]string{"compact", "--dir", dir, "--dry-run", "--json"}, stdout, ioutil.Discard)
+		// then
+		require.Equal(t, 0, code)
+		var result removalResult
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+		expected := removalResult{
+			DryRun:  true,
+			Removed: []removedVersion{{Key: "key", Version: versions[0].Version}},
+		}
+		assert.Equal(t, expected, result)
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/jacekolszak/deebee"
+)
+
+type repairResult struct {
+	RemovedTempFiles []removedVersion   `json:"removedTempFiles"`
+	Corrupted        []corruptedVersion `json:"corrupted"`
+	RemovedCorrupted bool               `json:"removedCorrupted"`
+	Compacted        []removedVersion   `json:"compacted"`
+}
+
+type corruptedVersion struct {
+	Key     string `json:"key"`
+	Version int    `json:"version"`
+	Error   string `json:"error"`
+	Removed bool   `json:"removed"`
+}
+
+func repair(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("repair", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("dir", "", "database directory")
+	layout := flags.String("layout", "flat", "layout of the database: flat or sharded")
+	checksum := flags.Bool("checksum", false, "verify checksums of versions written WithChecksum")
+	removeCorrupted := flags.Bool("remove-corrupted", false, "remove corrupted versions, keeping the ones of states without intact version")
+	force := flags.Bool("force", false, "with --remove-corrupted, remove also corrupted versions of states without intact version")
+	compactAfter := flags.Bool("compact", false, "remove versions which are no longer needed")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		// error was already printed by flags
+		return flag.ErrHelp
+	}
+	if *dir == "" {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	var options []deebee.Option
+	if *checksum {
+		options = append(options, deebee.WithChecksum())
+	}
+	location, options, err := locate(*dir, *layout, options...)
+	if err != nil {
+		return err
+	}
+	report, err := deebee.Repair(ctx, location, deebee.RepairOptions{
+		Options:         options,
+		RemoveCorrupted: *removeCorrupted,
+		Force:           *force,
+		Compact:         *compactAfter,
+	})
+	if *jsonOutput {
+		_ = printJSON(stdout, newRepairResult(report))
+	} else {
+		printRepairReport(stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.RemovedCorrupted {
+		if !*removeCorrupted {
+			return fmt.Errorf("found %d corrupted versions, use --remove-corrupted to remove them", len(report.Corrupted))
+		}
+		return fmt.Errorf("%d corrupted versions were not removed, because they could not be read for other reason or they are the last versions of the state (use --force)", kept(report))
+	}
+	return nil
+}
+
+func printRepairReport(w io.Writer, report deebee.RepairReport) {
+	for _, removed := range report.RemovedTempFiles {
+		_, _ = fmt.Fprintf(w, "removed temp file of version %d of %s\n", removed.Version, removed.Key)
+	}
+	for _, corrupted := range report.Corrupted {
+		verb := "found"
+		if corrupted.Removed {
+			verb = "removed"
+		}
+		_, _ = fmt.Fprintf(w, "%s corrupted version %d of %s: %s\n", verb, corrupted.Version, corrupted.Key, corrupted.Err)
+	}
+	printRemovalReport(w, deebee.RemovalReport{Removed: report.Compacted}, false)
+}
+
+func newRepairResult(report deebee.RepairReport) repairResult {
+	result := repairResult{
+		RemovedTempFiles: newRemovalResult(deebee.RemovalReport{Removed: report.RemovedTempFiles}, false).Removed,
+		Corrupted:        []corruptedVersion{},
+		RemovedCorrupted: report.RemovedCorrupted,
+		Compacted:        newRemovalResult(deebee.RemovalReport{Removed: report.Compacted}, false).Removed,
+	}
+	for _, corrupted := range report.Corrupted {
+		result.Corrupted = append(result.Corrupted, corruptedVersion{
+			Key:     corrupted.Key,
+			Version: corrupted.Version,
+			Error:   corrupted.Err.Error(),
+			Removed: corrupted.Removed,
+		})
+	}
+	return result
+}
+
+// kept returns the number of corrupted versions which were not removed
+func kept(report deebee.RepairReport) int {
+	n := 0
+	for _, corrupted := range report.Corrupted {
+		if !corrupted.Removed {
+			n++
+		}
+	}
+	return n
+}
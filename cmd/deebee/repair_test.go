@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepair(t *testing.T) {
+	t.Run("should succeed for intact database", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir))
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("data"))
+		// when
+		code := run([]string{"repair", "--dir", dir}, ioutil.Discard, ioutil.Discard)
+		// then
+		assert.Equal(t, 0, code)
+	})
+
+	t.Run("should fail when corrupted versions were found", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir), deebee.WithChecksum())
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("data"))
+		require.Equal(t, 0, run([]string{"corrupt", "--dir", dir, "--yes", "key"}, ioutil.Discard, ioutil.Discard))
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		// when
+		code := run([]string{"repair", "--dir", dir, "--checksum"}, stdout, stderr)
+		// then
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stdout.String(), "found corrupted version")
+		assert.Contains(t, stderr.String(), "--remove-corrupted")
+	})
+
+	t.Run("should remove corrupted versions", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir), deebee.WithChecksum())
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("intact"))
+		writeData(t, db, "key", []byte("corrupted"))
+		require.Equal(t, 0, run([]string{"corrupt", "--dir", dir, "--yes", "key"}, ioutil.Discard, ioutil.Discard))
+		stdout := &bytes.Buffer{}
+		// when
+		code := run([]string{"repair", "--dir", dir, "--checksum", "--remove-corrupted", "--compact", "--json"}, stdout, ioutil.Discard)
+		// then
+		require.Equal(t, 0, code)
+		var result repairResult
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+		assert.Len(t, result.Corrupted, 1)
+		assert.True(t, result.RemovedCorrupted)
+		assert.Empty(t, result.Compacted)
+		data, err := readAll(db, "key")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("intact"), data)
+	})
+
+	t.Run("should keep the last corrupted version unless forced", func(t *testing.T) {
+		dir := createTempDir(t)
+		db, err := deebee.Open(deebee.OsDir(dir), deebee.WithChecksum())
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("data"))
+		require.Equal(t, 0, run([]string{"corrupt", "--dir", dir, "--yes", "key"}, ioutil.Discard, ioutil.Discard))
+		stderr := &bytes.Buffer{}
+		// when
+		code := run([]string{"repair", "--dir", dir, "--checksum", "--remove-corrupted"}, ioutil.Discard, stderr)
+		// then
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), "--force")
+		// when
+		code = run([]string{"repair", "--dir", dir, "--checksum", "--remove-corrupted", "--force"}, ioutil.Discard, ioutil.Discard)
+		// then
+		assert.Equal(t, 0, code)
+		_, err = readAll(db, "key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+}
@@ -0,0 +1,263 @@
+package deebee
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Compact removes versions which are no longer needed - data versions and tombstones older
+// than the latest data version. The latest data version is kept even when the state is
+// deleted, so it can still be restored using Undelete. Versions being written, versions
+// being read by readers which were not closed yet, versions pinned using Pin or labeled
+// using Label and versions kept by retention policy (see WithRetention) are not removed.
+// Versions skipped because of readers are removed by the next Compact.
+//
+// progress is called after each key and can be nil.
+func (s *DB) Compact(ctx context.Context, progress ProgressFunc) error {
+	_, err := s.CompactWithOptions(ctx, CompactOptions{}, progress)
+	return err
+}
+
+// CompactOptions configures CompactWithOptions
+type CompactOptions struct {
+	// DryRun only reports versions which would be removed, without removing them
+	DryRun bool
+}
+
+// RemovalReport lists versions removed by the operation, or versions which would be
+// removed in dry run mode
+type RemovalReport struct {
+	Removed []RemovedVersion
+}
+
+type RemovedVersion struct {
+	Key     string
+	Version int
+	// Deleted is true for version written by Delete
+	Deleted bool
+	// Archived is true for version removed from the archive (see WithArchive)
+	Archived bool
+}
+
+// CompactWithOptions works the same as Compact, but returns the report of removed versions.
+// In dry run mode nothing is removed, so the impact can be previewed.
+func (s *DB) CompactWithOptions(ctx context.Context, options CompactOptions, progress ProgressFunc) (RemovalReport, error) {
+	var report RemovalReport
+	if err := s.checkWritable(); err != nil {
+		return report, err
+	}
+	err := s.forEachKey(ctx, progress, func(key string) (int64, error) {
+		removed, err := s.compactKey(key, options.DryRun)
+		report.Removed = append(report.Removed, removed...)
+		return 0, err
+	})
+	if err != nil {
+		return report, s.redact(err)
+	}
+	if !options.DryRun {
+		s.stats.add(statCompactions, 1)
+		s.emit(Event{Type: CompactionFinished})
+	}
+	return report, nil
+}
+
+func (s *DB) compactKey(key string, dryRun bool) ([]RemovedVersion, error) {
+	unlock := s.protectionLocks.lock(key)
+	defer unlock()
+	stateDir := s.stateDir(key)
+	var files, archived []filename
+	pinned := map[int]bool{}
+	err := iterateFiles(stateDir, func(file string) bool {
+		f, err := parseFilename(file)
+		switch {
+		case err != nil || f.kind == tempFile:
+		case f.kind == archivedFile:
+			if s.archive != nil {
+				archived = append(archived, f)
+			}
+		case f.kind == pinFile:
+			pinned[f.version] = true
+		default:
+			files = append(files, f)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	labels, err := s.stateLabels(key)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range labels {
+		pinned[version] = true
+	}
+	latest, found := youngestData(files)
+	if !found {
+		return nil, nil
+	}
+	var toRemove, older []filename
+	for _, f := range files {
+		if !latest.youngerThan(f) {
+			continue
+		}
+		switch {
+		case f.kind == tombstoneFile:
+			toRemove = append(toRemove, f)
+		case !pinned[f.version]:
+			older = append(older, f)
+		}
+	}
+	data := map[int]bool{}
+	for _, f := range files {
+		data[f.version] = true
+	}
+	for _, f := range archived {
+		// version is removed from the archive once its data file is removed
+		if !pinned[f.version] && !data[f.version] {
+			older = append(older, f)
+		}
+	}
+	sort.Slice(older, func(i, j int) bool {
+		return older[j].youngerThan(older[i])
+	})
+	keep, err := retained(commitTimer(stateDir), older, s.configFor(key).retention)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range older {
+		if !keep[i] {
+			toRemove = append(toRemove, f)
+		}
+	}
+	var removed []RemovedVersion
+	for _, f := range toRemove {
+		deleted := true
+		switch {
+		case f.kind == archivedFile && !dryRun:
+			if err = s.removeArchived(key, stateDir, f); err != nil {
+				return removed, err
+			}
+		case f.kind == archivedFile:
+		case !dryRun:
+			deleted, err = s.openVersions.unlessOpen(key, f.version, func() error {
+				return stateDir.DeleteFile(f.name)
+			})
+			if err != nil {
+				return removed, err
+			}
+		default:
+			deleted = !s.openVersions.isOpen(key, f.version)
+		}
+		if deleted {
+			removed = append(removed, RemovedVersion{
+				Key:      key,
+				Version:  f.version,
+				Deleted:  f.kind == tombstoneFile,
+				Archived: f.kind == archivedFile,
+			})
+		}
+	}
+	return removed, nil
+}
+
+// openVersions counts readers of each data version, so Compact does not remove versions
+// which are still being read
+type openVersions struct {
+	mutex   sync.Mutex
+	readers map[versionRef]int
+}
+
+type versionRef struct {
+	key     string
+	version int
+}
+
+// open registers the reader of the version. Returned function unregisters it and can be
+// called many times.
+func (o *openVersions) open(key string, version int) (release func()) {
+	ref := versionRef{key: key, version: version}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.readers == nil {
+		o.readers = map[versionRef]int{}
+	}
+	o.readers[ref]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			o.mutex.Lock()
+			defer o.mutex.Unlock()
+			o.readers[ref]--
+			if o.readers[ref] == 0 {
+				delete(o.readers, ref)
+			}
+		})
+	}
+}
+
+func (o *openVersions) isOpen(key string, version int) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.readers[versionRef{key: key, version: version}] > 0
+}
+
+// unlessOpen runs fn, which removes or replaces the version file, when the version has no
+// readers. Readers can't be registered and other functions passed to unlessOpen can't be
+// run in the meantime. Returns false when fn was not run.
+func (o *openVersions) unlessOpen(key string, version int, fn func() error) (bool, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.readers[versionRef{key: key, version: version}] > 0 {
+		return false, nil
+	}
+	return true, fn()
+}
+
+func newReleasingReader(r io.ReadCloser, release func()) io.ReadCloser {
+	reader := &releasingReader{ReadCloser: r, release: release}
+	if seeker, ok := r.(io.Seeker); ok {
+		return &releasingReadSeeker{releasingReader: reader, seeker: seeker}
+	}
+	return reader
+}
+
+// releasingReader unregisters the reader of the version on Close
+type releasingReader struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releasingReader) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, r.ReadCloser)
+}
+
+func (r *releasingReader) Close() error {
+	defer r.release()
+	return r.ReadCloser.Close()
+}
+
+func youngestData(files []filename) (filename, bool) {
+	var (
+		youngest filename
+		found    bool
+	)
+	for _, f := range files {
+		if f.kind == dataFile && (!found || f.youngerThan(youngest)) {
+			youngest = f
+			found = true
+		}
+	}
+	return youngest, found
+}
+
+type releasingReadSeeker struct {
+	*releasingReader
+	seeker io.Seeker
+}
+
+func (r *releasingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.seeker.Seek(offset, whence)
+}
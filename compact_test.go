@@ -0,0 +1,152 @@
+package deebee_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Compact(t *testing.T) {
+	t.Run("should keep only the latest version", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("v1"))
+		writeData(t, db, "key", []byte("v2"))
+		// when
+		err := db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("v2"), fileData(t, dir, "key"))
+		assert.Equal(t, []byte("v2"), readData(t, db, "key"))
+	})
+
+	t.Run("should keep latest version of deleted state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("v1"))
+		writeData(t, db, "key", []byte("v2"))
+		require.NoError(t, db.Delete("key"))
+		// when
+		err := db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+		require.NoError(t, db.Undelete("key"))
+		assert.Equal(t, []byte("v2"), readData(t, db, "key"))
+	})
+
+	t.Run("should not remove version being written", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("new"))
+		require.NoError(t, err)
+		// when
+		err = db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		assert.Equal(t, []byte("new"), readData(t, db, "key"))
+	})
+
+	t.Run("should not remove version being read", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("v1"))
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		defer reader.Close()
+		writeData(t, db, "key", []byte("v2"))
+		// when
+		err = db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		assertVersionsCount(t, db, "key", 2)
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("v1"), data)
+	})
+
+	t.Run("should remove version once its reader is closed", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("v1"))
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("v2"))
+		require.NoError(t, db.Compact(context.Background(), nil))
+		require.NoError(t, reader.Close())
+		// when
+		err = db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		assertVersionsCount(t, db, "key", 1)
+		assert.Equal(t, []byte("v2"), readData(t, db, "key"))
+	})
+
+	t.Run("should report progress", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "a", []byte("data"))
+		writeData(t, db, "b", []byte("data"))
+		var reported []deebee.Progress
+		// when
+		err := db.Compact(context.Background(), func(p deebee.Progress) {
+			reported = append(reported, p)
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []deebee.Progress{
+			{Key: "a", Done: 1, Total: 2},
+			{Key: "b", Done: 2, Total: 2},
+		}, reported)
+	})
+
+	t.Run("should return error when DeleteFile failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("v1"))
+		writeData(t, db, "key", []byte("v2"))
+		// when
+		err := openDB(t, failing.DeleteFile(dir)).Compact(context.Background(), nil)
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestDB_CompactWithOptions(t *testing.T) {
+	t.Run("should report removed versions", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		require.NoError(t, db.Delete("key"))
+		writeData(t, db, "key", []byte("new"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		report, err := db.CompactWithOptions(context.Background(), deebee.CompactOptions{}, nil)
+		// then
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []deebee.RemovedVersion{
+			{Key: "key", Version: versions[0].Version},
+			{Key: "key", Version: versions[1].Version, Deleted: true},
+		}, report.Removed)
+	})
+
+	t.Run("should not remove versions in dry run mode", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		// when
+		report, err := db.CompactWithOptions(context.Background(), deebee.CompactOptions{DryRun: true}, nil)
+		// then
+		require.NoError(t, err)
+		assert.Len(t, report.Removed, 1)
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		assert.Len(t, versions, 2)
+	})
+}
@@ -0,0 +1,206 @@
+package deebee
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// FileSizer is an optional interface which can be implemented by Dir. Sizes of files are
+// used by CompactionTrigger.MaxBytes.
+type FileSizer interface {
+	// FileSize returns size of the file in bytes. Must return error when file does not exist
+	FileSize(name string) (int64, error)
+}
+
+// CompactionTrigger configures when compaction is run automatically. Zero fields are not used.
+type CompactionTrigger struct {
+	// MaxVersions triggers compaction of the key when it has more versions, including the
+	// ones written by Delete
+	MaxVersions int
+	// MaxBytes triggers compaction of the key when the total size of its data versions is
+	// bigger. Dir must implement FileSizer.
+	MaxBytes int64
+	// Idle triggers compaction of all keys when nothing was written for this time
+	Idle time.Duration
+}
+
+// WithCompactionTrigger runs compaction in the background when thresholds are exceeded,
+// so the DB stays tidy without calling Compact. Thresholds of the key are checked after each
+// version is committed. CompactionFinished event is emitted after each compaction and
+// CompactionFailed when compaction failed.
+func WithCompactionTrigger(trigger CompactionTrigger) Option {
+	return func(db *DB) error {
+		if trigger.MaxVersions < 0 || trigger.MaxBytes < 0 || trigger.Idle < 0 {
+			return errors.New("negative compaction trigger threshold")
+		}
+		if trigger == (CompactionTrigger{}) {
+			return errors.New("no compaction trigger threshold")
+		}
+		db.compactor = &compactor{
+			db:      db,
+			trigger: trigger,
+			pending: map[string]struct{}{},
+		}
+		return nil
+	}
+}
+
+// compactor runs compactions triggered by CompactionTrigger. Nil compactor does nothing.
+type compactor struct {
+	db      *DB
+	trigger CompactionTrigger
+	mutex   sync.Mutex
+	// pending contains keys which thresholds should be checked
+	pending   map[string]struct{}
+	draining  bool
+	idleTimer *time.Timer
+	closed    bool
+	running   sync.WaitGroup
+}
+
+// committed is called after new version of the key was committed
+func (c *compactor) committed(key string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	if c.trigger.Idle > 0 {
+		if c.idleTimer == nil {
+			c.idleTimer = time.AfterFunc(c.trigger.Idle, c.compactAll)
+		} else {
+			c.idleTimer.Reset(c.trigger.Idle)
+		}
+	}
+	if c.trigger.MaxVersions == 0 && c.trigger.MaxBytes == 0 {
+		return
+	}
+	c.pending[key] = struct{}{}
+	if !c.draining {
+		c.draining = true
+		c.running.Add(1)
+		go c.drain()
+	}
+}
+
+// drain checks thresholds of pending keys until there are no more
+func (c *compactor) drain() {
+	defer c.running.Done()
+	for {
+		c.mutex.Lock()
+		var key string
+		for key = range c.pending {
+			break
+		}
+		if key == "" {
+			c.draining = false
+			c.mutex.Unlock()
+			return
+		}
+		delete(c.pending, key)
+		c.mutex.Unlock()
+
+		compacted, err := c.compactKey(key)
+		if err != nil {
+			c.db.emit(Event{Type: CompactionFailed, Key: key, Err: c.db.redact(err, key)})
+			continue
+		}
+		if compacted {
+			c.db.stats.add(statCompactions, 1)
+			c.db.emit(Event{Type: CompactionFinished, Key: key})
+		}
+	}
+}
+
+// compactKey compacts the key when its thresholds are exceeded
+func (c *compactor) compactKey(key string) (compacted bool, err error) {
+	err = c.db.runTask(CompactionTask, func() error {
+		exceeded, err := c.thresholdExceeded(key)
+		if err != nil || !exceeded {
+			return err
+		}
+		_, err = c.db.compactKey(key, false)
+		compacted = err == nil
+		return err
+	})
+	return compacted, err
+}
+
+func (c *compactor) thresholdExceeded(key string) (bool, error) {
+	stateDir := c.db.stateDir(key)
+	var (
+		versions int
+		data     []string
+	)
+	err := iterateFiles(stateDir, func(file string) bool {
+		f, err := parseFilename(file)
+		switch {
+		case err != nil || f.kind == tempFile || f.kind == pinFile || f.kind == archivedFile:
+		case f.kind == dataFile:
+			versions++
+			data = append(data, f.name)
+		default:
+			versions++
+		}
+		return true
+	})
+	if err != nil {
+		return false, err
+	}
+	if c.trigger.MaxVersions > 0 && versions > c.trigger.MaxVersions {
+		return true, nil
+	}
+	if c.trigger.MaxBytes == 0 {
+		return false, nil
+	}
+	sizer, ok := stateDir.(FileSizer)
+	if !ok {
+		return false, newClientError("dir does not implement FileSizer")
+	}
+	var bytes int64
+	for _, name := range data {
+		size, err := sizer.FileSize(name)
+		if err != nil {
+			return false, err
+		}
+		bytes += size
+	}
+	return bytes > c.trigger.MaxBytes, nil
+}
+
+// compactAll is run when nothing was written for Idle time
+func (c *compactor) compactAll() {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return
+	}
+	c.running.Add(1)
+	c.mutex.Unlock()
+	defer c.running.Done()
+	err := c.db.runTask(CompactionTask, func() error {
+		return c.db.Compact(context.Background(), nil)
+	})
+	if err != nil {
+		c.db.emit(Event{Type: CompactionFailed, Err: err})
+	}
+}
+
+// stop waits for running compactions. No compaction is started afterwards.
+func (c *compactor) stop() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	c.closed = true
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+	c.mutex.Unlock()
+	c.running.Wait()
+}
@@ -0,0 +1,108 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCompactionTrigger(t *testing.T) {
+	t.Run("should return error for invalid trigger", func(t *testing.T) {
+		triggers := map[string]deebee.CompactionTrigger{
+			"empty":                 {},
+			"negative max versions": {MaxVersions: -1},
+			"negative max bytes":    {MaxBytes: -1},
+			"negative idle":         {Idle: -1},
+		}
+		for name, trigger := range triggers {
+			t.Run(name, func(t *testing.T) {
+				db, err := deebee.Open(fake.ExistingDir(), deebee.WithCompactionTrigger(trigger))
+				assert.Error(t, err)
+				assert.Nil(t, db)
+			})
+		}
+	})
+
+	t.Run("should return error when dir does not implement FileSizer", func(t *testing.T) {
+		db, err := deebee.Open(failing.DeleteFile(fake.ExistingDir()), deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxBytes: 1}))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("should compact key with too many versions", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxVersions: 2}))
+		writeData(t, db, "key", []byte("1"))
+		writeData(t, db, "key", []byte("2"))
+		// when
+		writeData(t, db, "key", []byte("3"))
+		// then
+		require.NoError(t, db.Close())
+		assertVersionsCount(t, db, "key", 1)
+		assert.Equal(t, []byte("3"), readData(t, db, "key"))
+	})
+
+	t.Run("should not compact key with allowed number of versions", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxVersions: 2}))
+		writeData(t, db, "key", []byte("1"))
+		// when
+		writeData(t, db, "key", []byte("2"))
+		// then
+		require.NoError(t, db.Close())
+		assertVersionsCount(t, db, "key", 2)
+	})
+
+	t.Run("should compact key with too many bytes", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxBytes: 5}))
+		writeData(t, db, "key", []byte("123"))
+		// when
+		writeData(t, db, "key", []byte("456"))
+		// then
+		require.NoError(t, db.Close())
+		assertVersionsCount(t, db, "key", 1)
+	})
+
+	t.Run("should compact when nothing was written for idle time", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithCompactionTrigger(deebee.CompactionTrigger{Idle: time.Millisecond}))
+		writeData(t, db, "key", []byte("1"))
+		// when
+		writeData(t, db, "key", []byte("2"))
+		// then
+		assert.Eventually(t, func() bool {
+			versions, err := db.Versions("key")
+			return err == nil && len(versions) == 1
+		}, time.Second, time.Millisecond)
+		require.NoError(t, db.Close())
+	})
+
+	t.Run("should emit events", func(t *testing.T) {
+		events := make(chan deebee.Event, 10)
+		db := openDB(t, failing.DeleteFile(fake.ExistingDir()),
+			deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxVersions: 1}),
+			deebee.WithEventHandler(func(event deebee.Event) {
+				if event.Type == deebee.CompactionFailed {
+					events <- event
+				}
+			}),
+		)
+		writeData(t, db, "key", []byte("1"))
+		// when
+		writeData(t, db, "key", []byte("2"))
+		// then
+		require.NoError(t, db.Close())
+		require.Len(t, events, 1)
+		event := <-events
+		assert.Equal(t, "key", event.Key)
+		assert.Error(t, event.Err)
+	})
+}
+
+func assertVersionsCount(t *testing.T, db *deebee.DB, key string, expected int) {
+	versions, err := db.Versions(key)
+	require.NoError(t, err)
+	assert.Len(t, versions, expected)
+}
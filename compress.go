@@ -0,0 +1,112 @@
+package deebee
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// gzipMagic are the first bytes of gzip data
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// GzipFilter returns Filter compressing data using gzip. Reader detects whether the version
+// was compressed by its header, and reads versions written without the filter as is.
+// Therefore, the filter can be added to the DB storing uncompressed versions. Uncompressed
+// data starting with gzip magic bytes 0x1f 0x8b is supported only in files written
+// WithFileHeader, which records whether data was compressed.
+func GzipFilter() Filter {
+	return gzipFilter{compress: true, level: gzip.DefaultCompression}
+}
+
+// GzipFilterLevel works the same as GzipFilter, but compresses data using given level, such
+// as gzip.BestCompression. Writer returns error for invalid level.
+func GzipFilterLevel(level int) Filter {
+	return gzipFilter{compress: true, level: level}
+}
+
+// DecompressFilter returns Filter storing new versions uncompressed, but still reading
+// versions written using GzipFilter. Use it instead of GzipFilter to stop compressing data
+// without rewriting existing versions.
+func DecompressFilter() Filter {
+	return gzipFilter{}
+}
+
+type gzipFilter struct {
+	compress bool
+	level    int
+}
+
+func (f gzipFilter) Writer(w io.WriteCloser) (io.WriteCloser, error) {
+	if !f.compress {
+		return w, nil
+	}
+	compressed, err := gzip.NewWriterLevel(w, f.level)
+	if err != nil {
+		return nil, err
+	}
+	return &gzipWriter{Writer: compressed, next: w}, nil
+}
+
+// Reader detects whether data was compressed by gzip magic bytes, because files written
+// without the header do not record it
+func (gzipFilter) Reader(r io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		_ = r.Close()
+		return nil, err
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return &transformedReader{Reader: buffered, closer: r}, nil
+	}
+	return newGzipReader(buffered, r)
+}
+
+func newGzipReader(compressed io.Reader, next io.ReadCloser) (io.ReadCloser, error) {
+	reader, err := gzip.NewReader(compressed)
+	if err != nil {
+		_ = next.Close()
+		return nil, err
+	}
+	return &gzipReader{Reader: reader, next: next}, nil
+}
+
+// headerGzipFilter decompresses data according to compressedFlag of the file header,
+// instead of detecting gzip magic bytes
+type headerGzipFilter struct {
+	gzipFilter
+	compressed bool
+}
+
+func (f headerGzipFilter) Reader(r io.ReadCloser) (io.ReadCloser, error) {
+	if !f.compressed {
+		return r, nil
+	}
+	return newGzipReader(r, r)
+}
+
+// gzipWriter flushes compressed data and closes the next writer
+type gzipWriter struct {
+	*gzip.Writer
+	next io.WriteCloser
+}
+
+func (w *gzipWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		_ = w.next.Close()
+		return err
+	}
+	return w.next.Close()
+}
+
+// gzipReader closes the next reader
+type gzipReader struct {
+	*gzip.Reader
+	next io.ReadCloser
+}
+
+func (r *gzipReader) Close() error {
+	_ = r.Reader.Close()
+	return r.next.Close()
+}
@@ -0,0 +1,113 @@
+package deebee_test
+
+import (
+	"compress/gzip"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipFilter(t *testing.T) {
+	t.Run("should read compressed data", func(t *testing.T) {
+		tests := map[string][]byte{
+			"empty":      {},
+			"data":       []byte("data"),
+			"MB of data": makeData(1024*1024, 1),
+		}
+		for name, data := range tests {
+			t.Run(name, func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), deebee.WithFilter(deebee.GzipFilter()))
+				writeData(t, db, "key", data)
+				// when
+				actual := readData(t, db, "key")
+				// then
+				assert.Equal(t, data, actual)
+			})
+		}
+	})
+
+	t.Run("should store compressed data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithFilter(deebee.GzipFilter()))
+		data := makeData(1024*1024, 1)
+		// when
+		writeData(t, db, "key", data)
+		// then
+		files := dir.Dir("key").(fake.Dir).Files()
+		require.Len(t, files, 1)
+		assert.Less(t, len(files[0].Data()), len(data)/100)
+	})
+
+	t.Run("should read data written without compression", func(t *testing.T) {
+		tests := map[string][]byte{
+			"empty":    {},
+			"one byte": {0x1f},
+			"data":     []byte("data"),
+		}
+		for name, data := range tests {
+			t.Run(name, func(t *testing.T) {
+				dir := fake.ExistingDir()
+				writeData(t, openDB(t, dir), "key", data)
+				db := openDB(t, dir, deebee.WithFilter(deebee.GzipFilter()))
+				// when
+				actual := readData(t, db, "key")
+				// then
+				assert.Equal(t, data, actual)
+			})
+		}
+	})
+
+	t.Run("should return error when compressed data is corrupted", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte{0x1f, 0x8b, 0})
+		db := openDB(t, dir, deebee.WithFilter(deebee.GzipFilter()))
+		// when
+		_, err := db.Reader("key")
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestGzipFilterLevel(t *testing.T) {
+	t.Run("should read data compressed using given level", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(deebee.GzipFilterLevel(gzip.BestCompression)))
+		data := makeData(1024, 1)
+		writeData(t, db, "key", data)
+		// expect
+		assert.Equal(t, data, readData(t, db, "key"))
+	})
+
+	t.Run("should return error for invalid level", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(deebee.GzipFilterLevel(100)))
+		// when
+		_, err := db.Writer("key")
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestDecompressFilter(t *testing.T) {
+	t.Run("should read versions written with and without compression", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir, deebee.WithFilter(deebee.GzipFilter())), "compressed", []byte("data1"))
+		db := openDB(t, dir, deebee.WithFilter(deebee.DecompressFilter()))
+		writeData(t, db, "uncompressed", []byte("data2"))
+		// expect
+		assert.Equal(t, []byte("data1"), readData(t, db, "compressed"))
+		assert.Equal(t, []byte("data2"), readData(t, db, "uncompressed"))
+	})
+
+	t.Run("should store data uncompressed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithFilter(deebee.DecompressFilter()))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		files := dir.Dir("key").(fake.Dir).Files()
+		require.Len(t, files, 1)
+		assert.Equal(t, []byte("data"), files[0].Data())
+	})
+}
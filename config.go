@@ -0,0 +1,166 @@
+package deebee
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenWithConfig opens the DB using options read from the config file, so they can be tuned
+// without recompiling the application. Format is chosen by the extension of the file: .json,
+// .yaml or .yml. Unknown fields are rejected, so typos are detected. Options given as
+// arguments are applied after the ones from the file and override them. Example YAML file:
+//
+//	preset: durable
+//	checksum: true
+//	compression: true
+//	retention:
+//	  - age: 1h
+//	  - age: 24h
+//	    every: 1h
+//	groupCommitWindow: 10ms
+//	operationTimeout: 30s
+//	maxConcurrentWriters: 100
+//
+// Durations use the format of time.ParseDuration. Other fields are writeBehindInterval,
+// writerDeadline, slowOpThreshold, maxConcurrentWritersPerKey, rejectEmptyData, fileHeader,
+// shardedLayout and dirSync.
+func OpenWithConfig(dir Dir, path string, options ...Option) (*DB, error) {
+	cfg, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return Open(dir, append(cfg.options(), options...)...)
+}
+
+// fileConfig is the content of the config file read by OpenWithConfig
+type fileConfig struct {
+	Preset                     string       `json:"preset" yaml:"preset"`
+	Checksum                   bool         `json:"checksum" yaml:"checksum"`
+	Compression                bool         `json:"compression" yaml:"compression"`
+	Retention                  []tierConfig `json:"retention" yaml:"retention"`
+	RejectEmptyData            bool         `json:"rejectEmptyData" yaml:"rejectEmptyData"`
+	FileHeader                 bool         `json:"fileHeader" yaml:"fileHeader"`
+	ShardedLayout              bool         `json:"shardedLayout" yaml:"shardedLayout"`
+	DirSync                    bool         `json:"dirSync" yaml:"dirSync"`
+	GroupCommitWindow          duration     `json:"groupCommitWindow" yaml:"groupCommitWindow"`
+	WriteBehindInterval        duration     `json:"writeBehindInterval" yaml:"writeBehindInterval"`
+	OperationTimeout           duration     `json:"operationTimeout" yaml:"operationTimeout"`
+	WriterDeadline             duration     `json:"writerDeadline" yaml:"writerDeadline"`
+	SlowOpThreshold            duration     `json:"slowOpThreshold" yaml:"slowOpThreshold"`
+	MaxConcurrentWriters       int          `json:"maxConcurrentWriters" yaml:"maxConcurrentWriters"`
+	MaxConcurrentWritersPerKey int          `json:"maxConcurrentWritersPerKey" yaml:"maxConcurrentWritersPerKey"`
+}
+
+type tierConfig struct {
+	Age   duration `json:"age" yaml:"age"`
+	Every duration `json:"every" yaml:"every"`
+}
+
+// duration is decoded from string, such as "1h30m"
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d *duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d *duration) parse(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+func readConfig(path string) (fileConfig, error) {
+	var cfg fileConfig
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&cfg)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(&cfg); err != nil && len(bytes.TrimSpace(content)) == 0 {
+			err = nil // empty file
+		}
+	default:
+		return cfg, fmt.Errorf("unsupported config format %s, use .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// options returns options set in the config. Zero fields are not used.
+func (c fileConfig) options() []Option {
+	var options []Option
+	switch c.Preset {
+	case "":
+	case "durable":
+		options = append(options, DurableDefaults())
+	case "fast":
+		options = append(options, FastDefaults())
+	case "archival":
+		options = append(options, ArchivalDefaults())
+	default:
+		options = append(options, invalidOption(fmt.Errorf("unknown preset \"%s\"", c.Preset)))
+	}
+	add := func(enabled bool, option Option) {
+		if enabled {
+			options = append(options, option)
+		}
+	}
+	add(c.Checksum, WithChecksum())
+	add(c.Compression, WithFilter(GzipFilter()))
+	add(c.RejectEmptyData, WithRejectEmptyData())
+	add(c.FileHeader, WithFileHeader())
+	add(c.ShardedLayout, WithShardedLayout())
+	add(c.DirSync, WithDirSync())
+	add(c.GroupCommitWindow != 0, WithGroupCommit(time.Duration(c.GroupCommitWindow)))
+	add(c.WriteBehindInterval != 0, WithWriteBehind(time.Duration(c.WriteBehindInterval)))
+	add(c.OperationTimeout != 0, WithOperationTimeout(time.Duration(c.OperationTimeout)))
+	add(c.WriterDeadline != 0, WithWriterDeadline(time.Duration(c.WriterDeadline)))
+	add(c.SlowOpThreshold != 0, WithSlowOpThreshold(time.Duration(c.SlowOpThreshold)))
+	add(c.MaxConcurrentWriters != 0, WithMaxConcurrentWriters(c.MaxConcurrentWriters))
+	add(c.MaxConcurrentWritersPerKey != 0, WithMaxConcurrentWritersPerKey(c.MaxConcurrentWritersPerKey))
+	if len(c.Retention) > 0 {
+		tiers := make([]Tier, len(c.Retention))
+		for i, tier := range c.Retention {
+			tiers[i] = Tier{Age: time.Duration(tier.Age), Every: time.Duration(tier.Every)}
+		}
+		options = append(options, WithRetention(Tiered(tiers...)))
+	}
+	return options
+}
+
+func invalidOption(err error) Option {
+	return func(db *DB) error {
+		return err
+	}
+}
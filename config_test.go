@@ -0,0 +1,119 @@
+package deebee_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenWithConfig(t *testing.T) {
+	t.Run("should apply options from config file", func(t *testing.T) {
+		files := map[string]string{
+			"config.json": `{
+				"preset": "durable",
+				"compression": true,
+				"fileHeader": true,
+				"dirSync": true,
+				"groupCommitWindow": "10ms",
+				"operationTimeout": "30s",
+				"retention": [{"age": "24h", "every": "1h"}]
+			}`,
+			"config.yaml": `
+preset: durable
+compression: true
+fileHeader: true
+dirSync: true
+groupCommitWindow: 10ms
+operationTimeout: 30s
+retention:
+  - age: 24h
+    every: 1h
+`,
+		}
+		for name, content := range files {
+			t.Run(name, func(t *testing.T) {
+				path := writeConfigFile(t, name, content)
+				// when
+				db, err := deebee.OpenWithConfig(fake.ExistingDir(), path)
+				// then
+				require.NoError(t, err)
+				options := db.Options()
+				assert.Equal(t, "durable", options.Preset)
+				assert.True(t, options.Checksum)
+				assert.True(t, options.Compression)
+				assert.True(t, options.FileHeader)
+				assert.True(t, options.DirSync)
+				assert.True(t, options.Retention)
+				assert.Equal(t, 10*time.Millisecond, options.GroupCommitWindow)
+				assert.Equal(t, 30*time.Second, options.OperationTimeout)
+			})
+		}
+	})
+
+	t.Run("should open DB with empty config", func(t *testing.T) {
+		files := map[string]string{"config.json": "{}", "config.yml": ""}
+		for name, content := range files {
+			path := writeConfigFile(t, name, content)
+			// when
+			db, err := deebee.OpenWithConfig(fake.ExistingDir(), path)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, deebee.Options{}, db.Options())
+		}
+	})
+
+	t.Run("should override config using options", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "operationTimeout: 30s")
+		// when
+		db, err := deebee.OpenWithConfig(fake.ExistingDir(), path, deebee.WithOperationTimeout(time.Second))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, time.Second, db.Options().OperationTimeout)
+	})
+
+	t.Run("should return error for invalid config", func(t *testing.T) {
+		files := map[string]string{
+			"unknown field JSON":    `{"checksums": true}`,
+			"unknown field YAML":    "checksums: true",
+			"invalid duration":      `{"operationTimeout": "30"}`,
+			"invalid option value":  `{"operationTimeout": "-1s"}`,
+			"unknown preset":        `{"preset": "slow"}`,
+			"malformed JSON":        `{`,
+			"unsupported extension": `checksum = true`,
+		}
+		names := map[string]string{
+			"unknown field YAML":    "config.yaml",
+			"unsupported extension": "config.toml",
+		}
+		for testName, content := range files {
+			t.Run(testName, func(t *testing.T) {
+				name := names[testName]
+				if name == "" {
+					name = "config.json"
+				}
+				path := writeConfigFile(t, name, content)
+				// when
+				_, err := deebee.OpenWithConfig(fake.ExistingDir(), path)
+				// then
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("should return error when config file does not exist", func(t *testing.T) {
+		_, err := deebee.OpenWithConfig(fake.ExistingDir(), filepath.Join(createTempDir(t), "missing.json"))
+		assert.Error(t, err)
+	})
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(createTempDir(t), name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
@@ -0,0 +1,74 @@
+package deebee
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// CopyKey writes the latest version of srcKey as a new version of dstKey. Data is read
+// through filters configured for srcKey, so any verification done by filters is
+// performed, and then written through filters configured for dstKey. Nothing is written
+// when reading fails.
+//
+// Useful for creating restore points before risky operations.
+func (s *DB) CopyKey(srcKey, dstKey string) (err error) {
+	srcKey = s.normalizeKey(srcKey)
+	dstKey = s.normalizeKey(dstKey)
+	defer s.redactError(&err, srcKey, dstKey)
+	if err := s.checkKey(dstKey); err != nil {
+		return err
+	}
+	reader, err := s.Reader(srcKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	writer, err := s.newWriter(dstKey)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(writer, reader); err != nil {
+		_ = writer.abort()
+		return err
+	}
+	return writer.Close()
+}
+
+// CopyPrefix copies the latest version of each state which key starts with srcPrefix, in
+// the same way as CopyKey. srcPrefix in the key is replaced by dstPrefix. For example
+// CopyPrefix(ctx, "a/", "b/", nil) copies "a/x/y" to "b/x/y". Returns client error for empty
+// srcPrefix.
+//
+// progress is called after each key and can be nil.
+func (s *DB) CopyPrefix(ctx context.Context, srcPrefix, dstPrefix string, progress ProgressFunc) (err error) {
+	srcPrefix = s.normalizeKey(srcPrefix)
+	dstPrefix = s.normalizeKey(dstPrefix)
+	defer s.redactError(&err)
+	if srcPrefix == "" {
+		return newClientError("empty prefix")
+	}
+	keys, err := s.keys()
+	if err != nil {
+		return err
+	}
+	var matching []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, srcPrefix) {
+			matching = append(matching, key)
+		}
+	}
+	p := Progress{Total: len(matching)}
+	for _, key := range matching {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = s.CopyKey(key, dstPrefix+strings.TrimPrefix(key, srcPrefix)); err != nil {
+			return err
+		}
+		p.Key = key
+		p.Done++
+		progress.report(p)
+	}
+	return nil
+}
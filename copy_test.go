@@ -0,0 +1,140 @@
+package deebee_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_CopyKey(t *testing.T) {
+	t.Run("should return error for invalid keys", func(t *testing.T) {
+		for _, key := range invalidKeys {
+			t.Run(key, func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir())
+				writeData(t, db, "key", []byte("data"))
+				// expect
+				assert.True(t, deebee.IsClientError(db.CopyKey(key, "dst")))
+				assert.True(t, deebee.IsClientError(db.CopyKey("key", key)))
+			})
+		}
+	})
+
+	t.Run("should return data not found when source does not exist", func(t *testing.T) {
+		err := openDB(t, fake.ExistingDir()).CopyKey("missing", "dst")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should copy latest version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "src", []byte("old"))
+		writeData(t, db, "src", []byte("new"))
+		// when
+		err := db.CopyKey("src", "dst")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), readData(t, db, "dst"))
+		assert.Equal(t, []byte("new"), readData(t, db, "src"))
+	})
+
+	t.Run("should add new version to existing key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "src", []byte("src"))
+		writeData(t, db, "dst", []byte("dst"))
+		// when
+		err := db.CopyKey("src", "dst")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("src"), readData(t, db, "dst"))
+	})
+
+	t.Run("should not commit anything when reading failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithKeyOptions("src", deebee.WithFilter(failingReadFilter{})))
+		writeData(t, db, "src", []byte("data"))
+		// when
+		err := db.CopyKey("src", "dst")
+		// then
+		assert.Error(t, err)
+		_, err = db.Reader("dst")
+		assert.True(t, deebee.IsDataNotFound(err))
+		assert.Empty(t, dir.Dir("dst").(fake.Dir).Files())
+	})
+
+	t.Run("should return error when writer can't be created", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "src", []byte("data"))
+		db := openDB(t, failing.FileWriter(dir))
+		// when
+		err := db.CopyKey("src", "dst")
+		// then
+		assert.Error(t, err)
+	})
+}
+
+// failingReadFilter returns reader failing on Read
+type failingReadFilter struct{}
+
+func (f failingReadFilter) Writer(w io.WriteCloser) (io.WriteCloser, error) {
+	return w, nil
+}
+
+func (f failingReadFilter) Reader(r io.ReadCloser) (io.ReadCloser, error) {
+	return failingReader{r}, nil
+}
+
+type failingReader struct {
+	io.ReadCloser
+}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestDB_CopyPrefix(t *testing.T) {
+	t.Run("should return client error for empty prefix", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		err := db.CopyPrefix(context.Background(), "", "dst", nil)
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should copy subtree", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithHierarchicalKeys())
+		writeData(t, db, "a", []byte("parent"))
+		writeData(t, db, "a/b", []byte("child"))
+		writeData(t, db, "a/b/c", []byte("grandchild"))
+		var progress []deebee.Progress
+		// when
+		err := db.CopyPrefix(context.Background(), "a/", "x/", func(p deebee.Progress) {
+			progress = append(progress, p)
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("child"), readData(t, db, "x/b"))
+		assert.Equal(t, []byte("grandchild"), readData(t, db, "x/b/c"))
+		exists, err := db.Exists("x")
+		require.NoError(t, err)
+		assert.False(t, exists)
+		assert.Len(t, progress, 2)
+	})
+
+	t.Run("should stop when context is canceled", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "src", []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err := db.CopyPrefix(ctx, "s", "d", nil)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		exists, err := db.Exists("drc")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
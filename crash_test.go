@@ -0,0 +1,201 @@
+package deebee_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCrashConsistency runs operations which are killed after each filesystem operation.
+// Then power loss is simulated, the DB is opened again and it is checked whether it
+// contains either the state from before or after the operation.
+func TestCrashConsistency(t *testing.T) {
+	scenarios := map[string]crashScenario{
+		"write new key": {
+			action: func(db *deebee.DB) error {
+				return writeDataWithError(db, "key", []byte("new"))
+			},
+			expected: map[string][]string{"key": {"", "new"}},
+		},
+		"overwrite": {
+			setup: func(t *testing.T, db *deebee.DB) {
+				writeData(t, db, "key", []byte("old"))
+			},
+			action: func(db *deebee.DB) error {
+				return writeDataWithError(db, "key", []byte("new"))
+			},
+			expected: map[string][]string{"key": {"old", "new"}},
+		},
+		"overwrite with checksum": {
+			options: []deebee.Option{deebee.WithChecksum()},
+			setup: func(t *testing.T, db *deebee.DB) {
+				writeData(t, db, "key", []byte("old"))
+			},
+			action: func(db *deebee.DB) error {
+				return writeDataWithError(db, "key", []byte("new"))
+			},
+			expected: map[string][]string{"key": {"old", "new"}},
+		},
+		"delete": {
+			setup: func(t *testing.T, db *deebee.DB) {
+				writeData(t, db, "key", []byte("old"))
+			},
+			action: func(db *deebee.DB) error {
+				return db.Delete("key")
+			},
+			expected: map[string][]string{"key": {"old", ""}},
+		},
+		"rename": {
+			setup: func(t *testing.T, db *deebee.DB) {
+				writeData(t, db, "old", []byte("data"))
+			},
+			action: func(db *deebee.DB) error {
+				return db.Rename("old", "new")
+			},
+			expected: map[string][]string{"old": {"data", ""}, "new": {"", "data"}},
+		},
+		"write new key with dir sync": {
+			options: []deebee.Option{deebee.WithDirSync()},
+			action: func(db *deebee.DB) error {
+				return writeDataWithError(db, "key", []byte("new"))
+			},
+			expected:   map[string][]string{"key": {"", "new"}},
+			strictSync: true,
+		},
+		"overwrite with dir sync": {
+			options: []deebee.Option{deebee.WithDirSync(), deebee.WithShardedLayout()},
+			setup: func(t *testing.T, db *deebee.DB) {
+				writeData(t, db, "key", []byte("old"))
+			},
+			action: func(db *deebee.DB) error {
+				return writeDataWithError(db, "key", []byte("new"))
+			},
+			expected:   map[string][]string{"key": {"old", "new"}},
+			strictSync: true,
+		},
+		"delete with dir sync": {
+			options: []deebee.Option{deebee.WithDirSync()},
+			setup: func(t *testing.T, db *deebee.DB) {
+				writeData(t, db, "key", []byte("old"))
+			},
+			action: func(db *deebee.DB) error {
+				return db.Delete("key")
+			},
+			expected:   map[string][]string{"key": {"old", ""}},
+			strictSync: true,
+		},
+		"rename with dir sync": {
+			options: []deebee.Option{deebee.WithDirSync()},
+			setup: func(t *testing.T, db *deebee.DB) {
+				writeData(t, db, "old", []byte("data"))
+			},
+			action: func(db *deebee.DB) error {
+				return db.Rename("old", "new")
+			},
+			expected:   map[string][]string{"old": {"data", ""}, "new": {"", "data"}},
+			strictSync: true,
+		},
+		"compact": {
+			setup: func(t *testing.T, db *deebee.DB) {
+				writeData(t, db, "key", []byte("old"))
+				writeData(t, db, "key", []byte("new"))
+			},
+			action: func(db *deebee.DB) error {
+				return db.Compact(context.Background(), nil)
+			},
+			expected: map[string][]string{"key": {"new"}},
+		},
+	}
+	for name, scenario := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			scenario.run(t)
+		})
+	}
+}
+
+// crashScenario describes operation which is killed at each possible point
+type crashScenario struct {
+	options []deebee.Option
+	setup   func(t *testing.T, db *deebee.DB)
+	action  func(db *deebee.DB) error
+	// expected contains allowed data for each key after recovery. Empty string means
+	// that data is not found.
+	expected map[string][]string
+	// strictSync simulates power loss on filesystem which requires syncing dirs (see
+	// fake.Dir.CrashStrict). Once the action completed, only the last allowed data is
+	// expected.
+	strictSync bool
+}
+
+func (c crashScenario) run(t *testing.T) {
+	const maxKillPoint = 1000
+	for killPoint := 0; killPoint < maxKillPoint; killPoint++ {
+		dir := fake.ExistingDir()
+		if c.setup != nil {
+			c.setup(t, openDB(t, dir, c.options...))
+		}
+		db, err := deebee.Open(failing.CrashAfter(dir, killPoint), c.options...)
+		if err == nil {
+			err = c.action(db)
+		}
+		// when
+		crashed := dir.Crash()
+		if c.strictSync {
+			crashed = dir.CrashStrict()
+		}
+		recovered := openDB(t, crashed, c.options...)
+		// then
+		c.assertRecovered(t, recovered, killPoint, err == nil)
+		if t.Failed() {
+			return
+		}
+		if err == nil {
+			return // action completed before the kill point
+		}
+	}
+	t.Fatalf("action did not complete after %d operations", maxKillPoint)
+}
+
+func (c crashScenario) assertRecovered(t *testing.T, db *deebee.DB, killPoint int, completed bool) {
+	for key, allowed := range c.expected {
+		if completed && c.strictSync {
+			allowed = allowed[len(allowed)-1:]
+		}
+		actual := readDataOrEmpty(t, db, key)
+		assert.Contains(t, allowed, actual, "key %s after crash at operation %d", key, killPoint)
+		// and
+		writeData(t, db, key, []byte("after recovery"))
+		assert.Equal(t, []byte("after recovery"), readData(t, db, key), "key %s after crash at operation %d", key, killPoint)
+	}
+}
+
+func writeDataWithError(db *deebee.DB, key string, data []byte) error {
+	writer, err := db.Writer(key)
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(data); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// readDataOrEmpty returns empty string when data was not found
+func readDataOrEmpty(t *testing.T, db *deebee.DB, key string) string {
+	reader, err := db.Reader(key)
+	if deebee.IsDataNotFound(err) {
+		return ""
+	}
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
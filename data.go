@@ -2,45 +2,118 @@ package deebee
 
 import (
 	"strconv"
+	"strings"
+)
+
+const (
+	tempSuffix      = ".tmp"
+	tombstoneSuffix = ".deleted"
+	pinSuffix       = ".pinned"
+	archivedSuffix  = ".archived"
+)
+
+type fileKind int
+
+const (
+	// dataFile contains committed version of the state
+	dataFile fileKind = iota
+	// tempFile contains version which is still being written
+	tempFile
+	// tombstoneFile marks that the state was deleted
+	tombstoneFile
+	// pinFile marks that the data version with the same number is protected from removal
+	pinFile
+	// archivedFile marks that the data version with the same number was moved to the archive
+	archivedFile
 )
 
 type filename struct {
 	name    string
 	version int
+	kind    fileKind
+}
+
+func newFilename(version int) filename {
+	return filename{name: strconv.Itoa(version), version: version, kind: dataFile}
+}
+
+func newTombstoneFilename(version int) filename {
+	return filename{name: strconv.Itoa(version) + tombstoneSuffix, version: version, kind: tombstoneFile}
+}
+
+func newPinFilename(version int) filename {
+	return filename{name: strconv.Itoa(version) + pinSuffix, version: version, kind: pinFile}
+}
+
+func newArchivedFilename(version int) filename {
+	return filename{name: strconv.Itoa(version) + archivedSuffix, version: version, kind: archivedFile}
 }
 
 func parseFilename(file string) (filename, error) {
-	version, err := strconv.Atoi(file)
+	kind := dataFile
+	trimmed := file
+	switch {
+	case strings.HasSuffix(file, tempSuffix):
+		kind = tempFile
+		trimmed = strings.TrimSuffix(file, tempSuffix)
+	case strings.HasSuffix(file, tombstoneSuffix):
+		kind = tombstoneFile
+		trimmed = strings.TrimSuffix(file, tombstoneSuffix)
+	case strings.HasSuffix(file, pinSuffix):
+		kind = pinFile
+		trimmed = strings.TrimSuffix(file, pinSuffix)
+	case strings.HasSuffix(file, archivedSuffix):
+		kind = archivedFile
+		trimmed = strings.TrimSuffix(file, archivedSuffix)
+	}
+	version, err := strconv.Atoi(trimmed)
 	if err != nil {
 		return filename{}, err
 	}
-	return filename{name: file, version: version}, nil
+	return filename{name: file, version: version, kind: kind}, nil
+}
+
+// temp returns name of the file used while data is still being written
+func (f filename) temp() string {
+	return f.name + tempSuffix
 }
 
 func (f filename) youngerThan(filename filename) bool {
 	return f.version > filename.version
 }
 
-func toFilenames(files []string) []filename {
-	var names []filename
-	for _, file := range files {
+// youngestFile returns the youngest committed file stored in dir, which is either
+// a data file or a tombstone
+func youngestFile(dir Dir) (filename, bool, error) {
+	return youngest(dir, dataFile, tombstoneFile)
+}
+
+// youngestFileIncludingTemp returns the youngest file stored in dir, committed or not
+func youngestFileIncludingTemp(dir Dir) (filename, bool, error) {
+	return youngest(dir, dataFile, tempFile, tombstoneFile)
+}
+
+func youngest(dir Dir, kinds ...fileKind) (filename, bool, error) {
+	var (
+		youngest filename
+		found    bool
+	)
+	err := iterateFiles(dir, func(file string) bool {
 		f, err := parseFilename(file)
-		if err == nil {
-			names = append(names, f)
+		if err == nil && f.isOneOf(kinds) && (!found || f.youngerThan(youngest)) {
+			youngest = f
+			found = true
 		}
-	}
-	return names
+		return true
+	})
+	return youngest, found, err
 }
 
-func youngestFilename(names []filename) (filename, bool) {
-	if len(names) == 0 {
-		return filename{}, false
-	}
-	youngest := names[0]
-	for _, name := range names {
-		if name.youngerThan(youngest) {
-			youngest = name
+func (f filename) isOneOf(kinds []fileKind) bool {
+	for _, kind := range kinds {
+		if f.kind == kind {
+			return true
 		}
 	}
-	return youngest, true
+	return false
 }
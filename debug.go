@@ -0,0 +1,165 @@
+package deebee
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DebugString returns the state of the DB as printed by DumpState
+func (s *DB) DebugString() string {
+	var b strings.Builder
+	_ = s.DumpState(&b)
+	return b.String()
+}
+
+// DumpState prints the configuration, open handles, metadata cached for keys and the
+// status of background tasks in human-readable form, to paste into bug reports. Data is
+// never printed. Filters are printed by type only, so secrets they hold, such as
+// encryption keys, are not revealed. Keys and paths are redacted when DB was opened
+// WithErrorRedaction.
+//
+// The format is meant for humans and may change.
+func (s *DB) DumpState(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	s.dumpConfig(out)
+	s.dumpHandles(out)
+	s.dumpCache(out)
+	s.dumpTasks(out)
+	stats := s.Stats()
+	_, _ = fmt.Fprintf(out, "stats: writes=%d reads=%d bytesWritten=%d bytesRead=%d corruptionEvents=%d compactions=%d cacheHits=%d\n",
+		stats.Writes, stats.Reads, stats.BytesWritten, stats.BytesRead, stats.CorruptionEvents, stats.Compactions, stats.CacheHits)
+	return out.Flush()
+}
+
+func (s *DB) dumpConfig(out io.Writer) {
+	dir := fmt.Sprintf("%T", s.dir)
+	if root, ok := asOsDir(s.dir); ok && !s.errorRedaction {
+		dir = string(root.OsDir)
+	}
+	_, _ = fmt.Fprintf(out, "dir: %s\n", dir)
+	_, _ = fmt.Fprintf(out, "options: %+v\n", s.Options())
+	_, _ = fmt.Fprintf(out, "filters: %s\n", filterTypes(s.currentKeyConfig().filters))
+	maxWriters, maxWritersPerKey := s.writerLimits.limits()
+	_, _ = fmt.Fprintf(out, "maxConcurrentWriters: %d, perKey: %d\n", maxWriters, maxWritersPerKey)
+	var slowOpThreshold time.Duration
+	if slowOps := s.currentSlowOps(); slowOps != nil {
+		slowOpThreshold = slowOps.threshold
+	}
+	_, _ = fmt.Fprintf(out, "writerDeadline: %s, slowOpThreshold: %s\n", s.writerDeadline, slowOpThreshold)
+	for _, o := range s.keyOptions {
+		_, _ = fmt.Fprintf(out, "key options %s: checksum=%t filters=%s\n", s.debugKey(o.pattern), o.config.checksum, filterTypes(o.config.filters))
+	}
+}
+
+func filterTypes(filters []Filter) string {
+	types := make([]string, len(filters))
+	for i, filter := range filters {
+		types[i] = fmt.Sprintf("%T", filter)
+	}
+	return "[" + strings.Join(types, ", ") + "]"
+}
+
+func (s *DB) dumpHandles(out io.Writer) {
+	if s.handles == nil {
+		_, _ = fmt.Fprintln(out, "open handles: not tracked")
+		return
+	}
+	handles := s.handles.list()
+	_, _ = fmt.Fprintf(out, "open handles: %d\n", len(handles))
+	for _, handle := range handles {
+		_, _ = fmt.Fprintf(out, "  %s %s\n", handle.Kind, s.debugKey(handle.Key))
+	}
+}
+
+func (s *DB) dumpCache(out io.Writer) {
+	if s.index != nil {
+		latest := s.index.snapshot()
+		keys := make([]string, 0, len(latest))
+		for key := range latest {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		_, _ = fmt.Fprintf(out, "preload index: %d keys\n", len(keys))
+		for _, key := range limitDumpedKeys(out, keys) {
+			file := latest[key]
+			state := "data"
+			if file.kind == tombstoneFile {
+				state = "deleted"
+			}
+			_, _ = fmt.Fprintf(out, "  %s: version %d %s\n", s.debugKey(key), file.version, state)
+		}
+	}
+	if s.dirCache != nil {
+		_, _ = fmt.Fprintf(out, "dir existence cache: %d keys\n", s.dirCache.len())
+	}
+	configs := []keyConfig{s.currentKeyConfig()}
+	for _, o := range s.keyOptions {
+		configs = append(configs, o.config)
+	}
+	for _, config := range configs {
+		if config.writeBehind == nil {
+			continue
+		}
+		pending := config.writeBehind.pending()
+		keys := make([]string, 0, len(pending))
+		for key := range pending {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		_, _ = fmt.Fprintf(out, "write-behind pending: %d keys\n", len(keys))
+		for _, key := range limitDumpedKeys(out, keys) {
+			_, _ = fmt.Fprintf(out, "  %s: %d bytes\n", s.debugKey(key), pending[key])
+		}
+	}
+}
+
+// maxDumpedKeys limits the number of keys printed in each listing, so the dump of a DB with
+// millions of keys stays readable
+const maxDumpedKeys = 100
+
+// limitDumpedKeys returns first maxDumpedKeys keys and prints how many were omitted
+func limitDumpedKeys(out io.Writer, keys []string) []string {
+	if len(keys) <= maxDumpedKeys {
+		return keys
+	}
+	_, _ = fmt.Fprintf(out, "  (showing first %d keys)\n", maxDumpedKeys)
+	return keys[:maxDumpedKeys]
+}
+
+func (s *DB) dumpTasks(out io.Writer) {
+	health := s.Health()
+	names := make([]string, 0, len(health.Tasks))
+	for name := range health.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	_, _ = fmt.Fprintf(out, "tasks: %d, maintenance paused: %t\n", len(names), s.maintenance.isPaused())
+	for _, name := range names {
+		task := health.Tasks[name]
+		_, _ = fmt.Fprintf(out, "  %s: consecutiveFailures=%d lastSuccess=%s lastFailure=%s",
+			name, task.ConsecutiveFailures, debugTime(task.LastSuccess), debugTime(task.LastFailure))
+		if task.LastError != nil {
+			_, _ = fmt.Fprintf(out, " lastError=%q", task.LastError)
+		}
+		_, _ = fmt.Fprintln(out)
+	}
+}
+
+// debugKey returns the key, or redacted when DB was opened WithErrorRedaction
+func (s *DB) debugKey(key string) string {
+	if s.errorRedaction {
+		return redacted
+	}
+	return key
+}
+
+func debugTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,118 @@
+package deebee_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_DumpState(t *testing.T) {
+	t.Run("should print configuration", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithChecksum(), deebee.WithMaxConcurrentWriters(3))
+		// when
+		state := db.DebugString()
+		// then
+		assert.Contains(t, state, "Checksum:true")
+		assert.Contains(t, state, "maxConcurrentWriters: 3")
+		assert.Contains(t, state, "open handles: not tracked")
+	})
+
+	t.Run("should print filter types without secrets", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(prefixFilter("s3cr3t")))
+		// when
+		state := db.DebugString()
+		// then
+		assert.Contains(t, state, "prefixFilter")
+		assert.NotContains(t, state, "s3cr3t")
+	})
+
+	t.Run("should print open handles", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithHandleTracking())
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		defer writer.Close()
+		// when
+		state := db.DebugString()
+		// then
+		assert.Contains(t, state, "open handles: 1\n  writer key\n")
+	})
+
+	t.Run("should print keys cached by preload index", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithPreload())
+		writeData(t, db, "key", []byte("data"))
+		writeData(t, db, "deleted", []byte("data"))
+		require.NoError(t, db.Delete("deleted"))
+		// when
+		state := db.DebugString()
+		// then
+		assert.Contains(t, state, "preload index: 2 keys\n")
+		assert.Regexp(t, `  deleted: version \d+ deleted\n`, state)
+		assert.Regexp(t, `  key: version \d+ data\n`, state)
+	})
+
+	t.Run("should print limited number of keys cached by preload index", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithPreload())
+		for i := 0; i < 101; i++ {
+			writeData(t, db, fmt.Sprintf("key%03d", i), []byte("data"))
+		}
+		// when
+		state := db.DebugString()
+		// then
+		assert.Contains(t, state, "preload index: 101 keys\n  (showing first 100 keys)\n")
+		assert.Contains(t, state, "  key099: version")
+		assert.NotContains(t, state, "key100")
+	})
+
+	t.Run("should not race with Reconfigure", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		keepNone := func(now time.Time, commitTimes []time.Time) []bool {
+			return make([]bool, len(commitTimes))
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = db.Reconfigure(deebee.WithRetention(keepNone))
+		}()
+		// when
+		state := db.DebugString()
+		// then
+		<-done
+		assert.Contains(t, state, "filters: ")
+	})
+
+	t.Run("should print data not persisted yet by write-behind", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		state := db.DebugString()
+		// then
+		assert.Contains(t, state, "write-behind pending: 1 keys\n  key: 4 bytes\n")
+		assert.NotContains(t, state, "data\n")
+	})
+
+	t.Run("should redact keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithPreload(), deebee.WithErrorRedaction())
+		writeData(t, db, "secret-key", []byte("data"))
+		// when
+		state := db.DebugString()
+		// then
+		assert.Contains(t, state, "preload index: 1 keys\n")
+		assert.NotContains(t, state, "secret-key")
+	})
+
+	t.Run("should write the same as DebugString", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		var buffer bytes.Buffer
+		// when
+		err := db.DumpState(&buffer)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, db.DebugString(), buffer.String())
+	})
+}
@@ -4,23 +4,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sync"
+	"time"
 )
 
 func Open(dir Dir, options ...Option) (*DB, error) {
+	return open(dir, nil, options)
+}
+
+// open opens the DB, which is read-only view as of given time when asOf is not nil
+func open(dir Dir, asOf *time.Time, options []Option) (*DB, error) {
 	if dir == nil {
 		return nil, errors.New("nil dir")
 	}
-	dirExists, err := dir.Exists()
-	if err != nil {
-		return nil, err
-	}
-	if !dirExists {
-		return nil, newClientError(fmt.Sprintf("database dir %s not found", dir))
-	}
-
 	s := &DB{
-		dir: dir,
+		dir:   dir,
+		stats: &statsCounters{},
+		asOf:  asOf,
 	}
 	for _, apply := range options {
 		if apply != nil {
@@ -29,6 +30,43 @@ func Open(dir Dir, options ...Option) (*DB, error) {
 			}
 		}
 	}
+	if s.envOverrides {
+		if err := s.applyEnvOverrides(); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.applyKeyOptions(); err != nil {
+		return nil, err
+	}
+	if err := s.checkReadOnlyOptions(); err != nil {
+		return nil, err
+	}
+	if err := s.checkCapabilities(); err != nil {
+		return nil, err
+	}
+	dirExists, err := dir.Exists()
+	if err != nil {
+		return nil, s.redact(err)
+	}
+	if !dirExists {
+		return nil, s.redact(newClientError(fmt.Sprintf("database dir %s not found", dir)))
+	}
+	if err = s.checkLayout(); err != nil {
+		return nil, s.redact(err)
+	}
+	if s.index != nil {
+		if err = s.preload(); err != nil {
+			return nil, s.redact(err)
+		}
+	}
+	if s.migrator != nil {
+		if err = s.migrate(); err != nil {
+			return nil, s.redact(err)
+		}
+	}
+	if err = s.startJanitor(); err != nil {
+		return nil, err
+	}
 	return s, nil
 }
 
@@ -36,62 +74,485 @@ type Option func(db *DB) error
 
 // DB stores states. Each state has a key and data.
 type DB struct {
+	keyConfig
 	mutex   sync.Mutex
 	dir     Dir
 	version int
+	// pendingCommits tracks commits of async writers
+	pendingCommits pendingCommits
+	// committed contains versions returned by Sync
+	committed committedVersions
+	// openVersions protects versions being read from Compact
+	openVersions openVersions
+	// changes notifies goroutines blocked in WaitForChange
+	changes changeNotifier
+	// watchers receive events of keys matching their patterns
+	watchers watchers
+	// migrator is used only when DB was opened WithMigrator
+	migrator *Migrator
+	// preset is the name of the preset returned by Options
+	preset string
+	// writerLimits are set using WithMaxConcurrentWriters and WithMaxConcurrentWritersPerKey
+	writerLimits     writerLimits
+	keyOptions       []keyOptions
+	errorRedaction   bool
+	operationTimeout time.Duration
+	writerDeadline   time.Duration
+	// handles are tracked only when DB was opened WithHandleTracking
+	handles *openHandles
+	// index is used only when DB was opened WithPreload
+	index *stateIndex
+	// dirCache is used only when DB was opened WithDirExistenceCache
+	dirCache *dirCache
+	stats    *statsCounters
+	// eventHandlers are registered using WithEventHandler
+	eventHandlers []func(Event)
+	// asOf is set when DB was opened using OpenAsOf
+	asOf *time.Time
+	// janitor is used only when DB was opened WithTempFileCleanup
+	janitor *janitor
+	// compactor is used only when DB was opened WithCompactionTrigger
+	compactor *compactor
+	// archive is used only when DB was opened WithArchive
+	archive *archivePolicy
+	// slowOps is used only when DB was opened WithSlowOpThreshold
+	slowOps *slowOps
+	// envOverrides is true when DB was opened WithEnvOverrides
+	envOverrides bool
+	// tunableMutex protects options which can be changed using Reconfigure
+	tunableMutex sync.RWMutex
+	// maintenance pauses background tasks
+	maintenance maintenance
+	// tasks records results of background tasks returned by Health
+	tasks tasks
+	// sharded is true when DB was opened WithShardedLayout
+	sharded bool
+	// hierarchicalKeys is true when DB was opened WithHierarchicalKeys
+	hierarchicalKeys bool
+	// keyCase is used only when DB was opened WithKeyCaseCollisionCheck
+	keyCase *keyCaseChecker
+	// keyNormalization is set using WithKeyNormalization
+	keyNormalization KeyNormalization
+	// accessControl is set using WithAccessControl
+	accessControl func(op Operation, key string) error
+	// appendLocks serialize JSONL appends, merges and queue operations of the same key
+	appendLocks keyLocks
+	// protectionLocks serialize Pin and Label with Compact and Archive of the same key, so
+	// a version is not removed right after it was protected
+	protectionLocks keyLocks
+	// labelsMutex serializes updates of the labels file
+	labelsMutex sync.Mutex
+	// readTransformer is set using WithReadTransformer
+	readTransformer func(key string, r io.Reader) (io.Reader, error)
+	// keyMapper is used only when DB was opened WithKeyMapper or WithKeyEncryption
+	keyMapper KeyMapper
+	// dirSync is true when DB was opened WithDirSync
+	dirSync bool
+}
+
+// keyConfig contains settings which can be overridden for specific keys using WithKeyOptions
+type keyConfig struct {
+	filters     []Filter
+	groupCommit *groupCommit
+	retention   RetentionPolicy
+	checksum    bool
+	writeBehind *writeBehind
+	validator   func(key string, data []byte) error
+	rejectEmpty bool
+	// fileHeader is true when new files start with the header describing their format
+	fileHeader bool
 }
 
 // Returns Writer for new version of state with given key
-func (s *DB) Writer(key string) (io.WriteCloser, error) {
-	if err := validateKey(key); err != nil {
+func (s *DB) Writer(key string) (*Writer, error) {
+	key = s.normalizeKey(key)
+	if writeBehind := s.configFor(key).writeBehind; writeBehind != nil {
+		w, err := s.newWriteBehindWriter(key, writeBehind, nil)
+		if err != nil {
+			return nil, s.redact(err, key)
+		}
+		return s.wrapWriter(key, w, nil), nil
+	}
+	w, err := s.newWriterWithTimeout(key)
+	if err != nil {
+		return nil, s.redact(err, key)
+	}
+	return s.wrapWriter(key, w, w), nil
+}
+
+// newWriterWithTimeout creates writer within the time configured using WithOperationTimeout
+func (s *DB) newWriterWithTimeout(key string) (*writer, error) {
+	var w *writer
+	err := withTimeout("creating writer", s.operationTimeout, func() (err error) {
+		w, err = s.newWriter(key)
+		return err
+	}, func() {
+		_ = w.abort()
+	})
+	return w, err
+}
+
+func (s *DB) newWriter(key string) (*writer, error) {
+	return s.newWriterWithConfig(key, s.configFor(key))
+}
+
+// newRawWriter returns writer which does not use filters and does not add a checksum.
+// Data is stored as is.
+func (s *DB) newRawWriter(key string) (*writer, error) {
+	config := s.configFor(key)
+	config.filters = nil
+	config.checksum = false
+	config.validator = nil
+	config.rejectEmpty = false
+	config.fileHeader = false
+	return s.newWriterWithConfig(key, config)
+}
+
+func (s *DB) newWriterWithConfig(key string, config keyConfig) (_ *writer, err error) {
+	if err := s.checkWritable(); err != nil {
 		return nil, err
 	}
+	if err := s.checkKey(key); err != nil {
+		return nil, err
+	}
+	if err := s.checkAccess(WriteOperation, key); err != nil {
+		return nil, err
+	}
+	if err := s.checkKeyCase(key); err != nil {
+		return nil, err
+	}
+	releaseLimit, err := s.writerLimits.acquire(key)
+	if err != nil {
+		return nil, err
+	}
+	untrack := s.handles.track(WriterHandle, key)
+	release := func() {
+		releaseLimit()
+		untrack()
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+	defer s.dirCache.invalidateOnError(key, &err)
 
-	stateDir := s.dir.Dir(key)
-	stateDirExists, err := stateDir.Exists()
+	stateDir := s.stateDir(key)
+	stateDirExists, err := s.stateDirExists(key, stateDir)
 	if err != nil {
 		return nil, err
 	}
 	if !stateDirExists {
-		if err := stateDir.Mkdir(); err != nil {
+		if err := s.mkdirState(key, stateDir); err != nil {
 			return nil, err
 		}
+		s.dirCache.add(key)
 	}
-	name := s.nextVersionFilename()
-	return stateDir.FileWriter(name)
+	version, file, err := s.createVersionFile(key, stateDir, func(version int) string {
+		return newFilename(version).temp()
+	})
+	if err != nil {
+		return nil, err
+	}
+	file = s.currentSlowOps().writer(key, file)
+	name := newFilename(version)
+	filtered, err := config.storedDataWriter(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if config.validator != nil {
+		filtered = &validatingWriter{next: filtered, key: key, config: config}
+	}
+	w := &writer{
+		writeBehind: config.writeBehind,
+		generation:  config.writeBehind.generation(key),
+		filtered:    filtered,
+		file:        file,
+		dir:         stateDir,
+		name:        name,
+		groupCommit: config.groupCommit,
+		syncDir:     s.syncDir,
+		timeout:     s.operationTimeout,
+		key:         key,
+		index:       s.index,
+		committed:   &s.committed,
+		stats:       s.stats,
+		emit:        s.emit,
+		compactor:   s.compactor,
+		rejectEmpty: config.rejectEmpty,
+		release:     release,
+	}
+	if s.writerDeadline > 0 {
+		w.mutex.Lock()
+		w.deadline = time.AfterFunc(s.writerDeadline, w.expire)
+		w.mutex.Unlock()
+	}
+	return w, nil
 }
 
-func (s *DB) nextVersionFilename() string {
+// nextVersion returns version younger than all versions already stored in stateDir,
+// including not committed ones
+func (s *DB) nextVersion(stateDir Dir) (int, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	name := fmt.Sprintf("%d", s.version)
+	youngest, exists, err := youngestFileIncludingTemp(stateDir)
+	if err != nil {
+		return 0, err
+	}
+	if exists && youngest.version >= s.version {
+		s.version = youngest.version + 1
+	}
+	version := s.version
 	s.version++
-	return name
+	return version, nil
+}
+
+// maxVersionConflicts limits how many times the next version is tried when the file of the
+// version was created by another process sharing the dir
+const maxVersionConflicts = 10
+
+// createVersionFile creates the file of the next version. Processes sharing the dir, or DB
+// instances opened for the same dir in one process, may pick the same version
+// concurrently. Only one of them creates the file, because FileWriter fails when the file
+// exists, and the others try the next version, emitting VersionConflict.
+func (s *DB) createVersionFile(key string, stateDir Dir, name func(version int) string) (int, FileWriter, error) {
+	for attempt := 0; ; attempt++ {
+		version, err := s.nextVersion(stateDir)
+		if err != nil {
+			return 0, nil, err
+		}
+		file, err := stateDir.FileWriter(name(version))
+		if err == nil {
+			return version, file, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			youngest, exists, listErr := youngestFileIncludingTemp(stateDir)
+			if listErr != nil || !exists || youngest.version < version {
+				return 0, nil, err // failed for other reason than conflict
+			}
+		}
+		if attempt == maxVersionConflicts {
+			return 0, nil, fmt.Errorf("file of version %d already exists, giving up after %d conflicts: %w", version, attempt+1, err)
+		}
+		s.emit(Event{Type: VersionConflict, Key: key, Version: version, Err: err})
+	}
 }
 
 // Returns Reader for state with given key
-func (s *DB) Reader(key string) (io.ReadCloser, error) {
-	if err := validateKey(key); err != nil {
+func (s *DB) Reader(key string) (*Reader, error) {
+	return s.ReaderWithOptions(key, ReaderOptions{})
+}
+
+// ReaderWithOptions returns Reader for state with given key. Options change the
+// behaviour of this read only.
+func (s *DB) ReaderWithOptions(key string, options ReaderOptions) (_ *Reader, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkKey(key); err != nil {
+		return nil, err
+	}
+	if err = s.checkAccess(ReadOperation, key); err != nil {
 		return nil, err
 	}
+	var (
+		reader  io.ReadCloser
+		version int
+	)
+	err = withTimeout("creating reader", s.operationTimeout, func() (err error) {
+		reader, version, err = s.reader(key, options)
+		return err
+	}, func() {
+		_ = reader.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.decorateReader(key, version, reader), nil
+}
 
-	stateDir := s.dir.Dir(key)
-	stateDirExists, err := stateDir.Exists()
+// VersionReader returns Reader for given data version of the state, for example to restore
+// an older version. Returns data not found error when there is no such version or it was
+// written by Delete.
+func (s *DB) VersionReader(key string, version int) (_ *Reader, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkKey(key); err != nil {
+		return nil, err
+	}
+	if err = s.checkAccess(ReadOperation, key); err != nil {
+		return nil, err
+	}
+	var reader io.ReadCloser
+	err = withTimeout("creating reader", s.operationTimeout, func() (err error) {
+		reader, err = s.versionReaderIfExists(key, version)
+		return err
+	}, func() {
+		_ = reader.Close()
+	})
 	if err != nil {
 		return nil, err
 	}
-	if !stateDirExists {
-		return nil, &dataNotFoundError{}
+	return s.decorateReader(key, version, reader), nil
+}
+
+func (s *DB) versionReaderIfExists(key string, version int) (io.ReadCloser, error) {
+	if err := s.pendingCommits.wait(key, s.operationTimeout); err != nil {
+		return nil, err
+	}
+	latest, exists, err := s.latestFile(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || version > latest.version {
+		return nil, &dataNotFoundError{} // DB opened OpenAsOf does not see younger versions
+	}
+	versions, err := stateVersions(s.stateDir(key))
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.Version == version && v.Archived {
+			return nil, &restoreRequiredError{}
+		}
+		if v.Version == version && !v.Deleted {
+			return s.versionReader(key, s.configFor(key), newFilename(version), ReaderOptions{})
+		}
+	}
+	return nil, &dataNotFoundError{}
+}
+
+// decorateReader counts read bytes, applies operation timeout, error redaction and handle
+// tracking. Version is -1 for data kept in memory.
+func (s *DB) decorateReader(key string, version int, reader io.ReadCloser) *Reader {
+	s.stats.add(statReads, 1)
+	seeker, _ := reader.(io.Seeker)
+	reader = &countingReader{ReadCloser: reader, stats: s.stats}
+	if s.handles != nil {
+		reader = &trackedReader{ReadCloser: reader, untrack: s.handles.track(ReaderHandle, key)}
+	}
+	if s.operationTimeout > 0 {
+		reader = &timeoutReader{ReadCloser: reader, timeout: s.operationTimeout}
+	}
+	if s.errorRedaction {
+		reader = &redactingReader{ReadCloser: reader, db: s, key: key}
+	}
+	return &Reader{next: reader, seeker: seeker, key: key, version: version}
+}
+
+// reader returns reader of the latest data together with its version, which is -1 for
+// data kept in memory
+func (s *DB) reader(key string, options ReaderOptions) (_ io.ReadCloser, version int, err error) {
+	config := s.configFor(key)
+	if data, ok := config.writeBehind.get(key); ok {
+		reader, err := s.writeBehindReader(key, data)
+		return reader, -1, err
 	}
-	files, err := stateDir.ListFiles()
+	if err = s.pendingCommits.wait(key, s.operationTimeout); err != nil {
+		return nil, 0, err
+	}
+	defer s.dirCache.invalidateOnError(key, &err)
+	youngest, exists, err := s.latestFile(key)
+	for {
+		if err != nil {
+			return nil, 0, err
+		}
+		if !exists || youngest.kind == tombstoneFile {
+			return nil, 0, &dataNotFoundError{}
+		}
+		reader, readerErr := s.versionReader(key, config, youngest, options)
+		if readerErr == nil {
+			return reader, youngest.version, nil
+		}
+		// version could be removed by Compact after a younger one was committed
+		previous := youngest
+		youngest, exists, err = s.latestFile(key)
+		if err != nil || !exists || youngest.version <= previous.version {
+			return nil, 0, readerErr
+		}
+	}
+}
+
+// versionReader returns reader of given data version, passing it through the checksum
+// verification, filters and read transformer
+func (s *DB) versionReader(key string, config keyConfig, version filename, options ReaderOptions) (io.ReadCloser, error) {
+	filtered, err := s.storedDataReader(key, config, version, options)
 	if err != nil {
 		return nil, err
 	}
-	dataFile, exists := youngestFilename(toFilenames(files))
-	if !exists {
+	return s.transform(key, filtered)
+}
+
+// storedDataReader returns reader of given data version, passing it through the checksum
+// verification and filters, but not through the read transformer
+func (s *DB) storedDataReader(key string, config keyConfig, version filename, options ReaderOptions) (_ io.ReadCloser, err error) {
+	release := s.openVersions.open(key, version.version)
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+	file, err := s.stateDir(key).FileReader(version.name)
+	if err != nil {
+		return nil, err
+	}
+	file = s.currentSlowOps().reader(key, file)
+	data, header, hasHeader, err := readFileHeader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	file = data
+	checksum := config.checksum
+	if hasHeader {
+		checksum = header.flags&checksumFlag != 0
+	}
+	if checksum {
+		file = newChecksumReader(file, !options.SkipVerification)
+	}
+	var filtered io.ReadCloser
+	if hasHeader {
+		filtered, err = config.headerFilterReader(file, header)
+	} else {
+		filtered, err = config.filterReader(file)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return newReleasingReader(filtered, release), nil
+}
+
+// storedDataWriter writes the file header to the file and returns writer passing data
+// through filters and the checksum. Closing the returned writer does not close the file.
+func (c keyConfig) storedDataWriter(file FileWriter) (io.WriteCloser, error) {
+	if c.fileHeader {
+		if err := c.headerFor().write(file); err != nil {
+			return nil, err
+		}
+	}
+	var out io.WriteCloser = unclosableWriter{file}
+	if c.checksum {
+		out = newChecksumWriter(file)
+	}
+	return c.filterWriter(out)
+}
+
+// existingStateDir returns dir of the state with given key. Returns data not found
+// error when dir does not exist.
+func (s *DB) existingStateDir(key string) (Dir, error) {
+	if err := s.checkKey(key); err != nil {
+		return nil, err
+	}
+	stateDir := s.stateDir(key)
+	stateDirExists, err := s.stateDirExists(key, stateDir)
+	if err != nil {
+		return nil, err
+	}
+	if !stateDirExists {
 		return nil, &dataNotFoundError{}
 	}
-	return stateDir.FileReader(dataFile.name)
+	return stateDir, nil
 }
 
 // Dir is a filesystem abstraction useful for unit testing and decoupling the code from `os` package.
@@ -100,7 +561,8 @@ func (s *DB) Reader(key string) (io.ReadCloser, error) {
 type Dir interface {
 	// Opens an existing file for read. Must return error when file does not exist
 	FileReader(name string) (io.ReadCloser, error)
-	// Creates a new file for write. Must return error when file already exists
+	// Creates a new file for write. Must return error when file already exists, which should
+	// wrap os.ErrExist
 	FileWriter(name string) (FileWriter, error)
 	// Creates this directory. Do nothing when directory already exists
 	Mkdir() error
@@ -110,6 +572,13 @@ type Dir interface {
 	Exists() (bool, error)
 	// List files excluding directories
 	ListFiles() ([]string, error)
+	// List directories excluding files
+	ListDirs() ([]string, error)
+	// Renames file or directory. Should replace the file atomically. Must return error when
+	// target directory already exists and is not empty
+	Rename(oldName, newName string) error
+	// Deletes file. Must return error when file does not exist
+	DeleteFile(name string) error
 }
 
 type FileWriter interface {
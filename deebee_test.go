@@ -66,7 +66,7 @@ func (e testError) Error() string {
 	return "test-error"
 }
 
-var invalidKeys = []string{"", " a", "a ", ".", "..", "/", "a/b", "\\", "a\\b"}
+var invalidKeys = []string{"", " a", "a ", ".", "..", "/", "a/b", "\\", "a\\b", ".deebee"}
 
 func TestDB_Reader(t *testing.T) {
 	t.Run("should return error for invalid keys", func(t *testing.T) {
@@ -93,15 +93,16 @@ func TestDB_Reader(t *testing.T) {
 	})
 
 	t.Run("should return error when DB is failing", func(t *testing.T) {
-		dirs := map[string]deebee.Dir{
-			"ListFiles":  failing.ListFiles(fake.ExistingDir()),
-			"FileReader": failing.FileReader(fake.ExistingDir()),
+		decorators := map[string]func(deebee.Dir) deebee.Dir{
+			"ListFiles":  failing.ListFiles,
+			"FileReader": failing.FileReader,
 		}
-		for name, dir := range dirs {
+		for name, decorate := range decorators {
 
 			t.Run(name, func(t *testing.T) {
-				db := openDB(t, dir)
-				writeData(t, db, "key", []byte("data"))
+				dir := fake.ExistingDir()
+				writeData(t, openDB(t, dir), "key", []byte("data"))
+				db := openDB(t, decorate(dir))
 				// when
 				reader, err := db.Reader("key")
 				// then
@@ -112,6 +113,49 @@ func TestDB_Reader(t *testing.T) {
 	})
 }
 
+func TestDB_VersionReader(t *testing.T) {
+	t.Run("should return error for invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, key := range invalidKeys {
+			_, err := db.VersionReader(key, 0)
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+
+	t.Run("should read given version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		reader, err := db.VersionReader("key", versions[0].Version)
+		// then
+		require.NoError(t, err)
+		defer reader.Close()
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), data)
+	})
+
+	t.Run("should return data not found for missing and deleted versions", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		for _, version := range []int{versions[1].Version, versions[1].Version + 1} {
+			// when
+			_, err = db.VersionReader("key", version)
+			// then
+			assert.True(t, deebee.IsDataNotFound(err))
+		}
+		_, err = db.VersionReader("missing", 0)
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+}
+
 func TestDB_Writer(t *testing.T) {
 	t.Run("should return error for invalid keys", func(t *testing.T) {
 		for _, key := range invalidKeys {
@@ -130,6 +174,7 @@ func TestDB_Writer(t *testing.T) {
 		dirs := map[string]deebee.Dir{
 			"Mkdir":      failing.Mkdir(fake.ExistingDir()),
 			"FileWriter": failing.FileWriter(fake.ExistingDir()),
+			"ListFiles":  failing.ListFiles(fake.ExistingDir()),
 		}
 		for name, dir := range dirs {
 
@@ -143,6 +188,106 @@ func TestDB_Writer(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("should return error on Close when Rename failed", func(t *testing.T) {
+		db := openDB(t, failing.Rename(fake.ExistingDir()))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should remove temp file when Rename failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, failing.Rename(dir))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		assert.Error(t, err)
+		assert.Empty(t, dir.Dir("key").(fake.Dir).Files())
+	})
+
+	t.Run("should not make data visible before Close", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("new"))
+		require.NoError(t, err)
+		// when
+		actual := readData(t, db, "key")
+		// then
+		assert.Equal(t, []byte("old"), actual)
+	})
+
+	t.Run("should sync data before Close", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		data := []byte("data")
+		// when
+		writeData(t, db, "key", data)
+		// then
+		files := dir.Dir("key").(fake.Dir).Files()
+		require.Len(t, files, 1)
+		assert.Equal(t, data, files[0].SyncedData())
+	})
+
+	t.Run("should try next version when file of the version was created by another process", func(t *testing.T) {
+		db := openDB(t, failing.FileConflicts(fake.ExistingDir(), 2))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should emit event when file of the version was created by another process", func(t *testing.T) {
+		events := make(chan deebee.Event, 1)
+		db := openDB(t, failing.FileConflicts(fake.ExistingDir(), 1), deebee.WithEventHandler(func(event deebee.Event) {
+			if event.Type == deebee.VersionConflict {
+				events <- event
+			}
+		}))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		event := <-events
+		assert.Equal(t, "key", event.Key)
+		assert.Error(t, event.Err)
+		assert.Less(t, event.Version, latestVersion(t, db, "key"))
+	})
+
+	t.Run("should write concurrently using two DB instances sharing the dir", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db1 := openDB(t, dir)
+		db2 := openDB(t, dir)
+		writer1, err := db1.Writer("key")
+		require.NoError(t, err)
+		// when
+		writer2, err := db2.Writer("key")
+		// then
+		require.NoError(t, err)
+		_, err = writer1.Write([]byte("1"))
+		require.NoError(t, err)
+		_, err = writer2.Write([]byte("2"))
+		require.NoError(t, err)
+		require.NoError(t, writer1.Close())
+		require.NoError(t, writer2.Close())
+		assert.NotEqual(t, writer1.Version(), writer2.Version())
+		assertVersionsCount(t, db1, "key", 2)
+	})
+
+	t.Run("should return error when versions are constantly taken by other processes", func(t *testing.T) {
+		db := openDB(t, failing.FileConflicts(fake.ExistingDir(), 100))
+		// when
+		writer, err := db.Writer("key")
+		// then
+		assert.Error(t, err)
+		assert.Nil(t, writer)
+	})
 }
 
 func TestReadAfterWrite(t *testing.T) {
@@ -175,10 +320,22 @@ func TestReadAfterWrite(t *testing.T) {
 		// then
 		assert.Equal(t, updatedData, string(actual))
 	})
+
+	t.Run("after reopen should read last written data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "state", []byte("data"))
+		db := openDB(t, dir)
+		updatedData := "updated"
+		writeData(t, db, "state", []byte(updatedData))
+		// when
+		actual := readData(t, db, "state")
+		// then
+		assert.Equal(t, updatedData, string(actual))
+	})
 }
 
-func openDB(t *testing.T, dir deebee.Dir) *deebee.DB {
-	db, err := deebee.Open(dir)
+func openDB(t *testing.T, dir deebee.Dir, options ...deebee.Option) *deebee.DB {
+	db, err := deebee.Open(dir, options...)
 	require.NoError(t, err)
 	return db
 }
@@ -0,0 +1,61 @@
+// Package deebeeprom provides prometheus.Collector exporting deebee.DB statistics
+package deebeeprom
+
+import (
+	"github.com/jacekolszak/deebee"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "deebee"
+
+var (
+	writesDesc = prometheus.NewDesc(namespace+"_writes_total",
+		"Number of committed versions", nil, nil)
+	readsDesc = prometheus.NewDesc(namespace+"_reads_total",
+		"Number of readers opened", nil, nil)
+	bytesWrittenDesc = prometheus.NewDesc(namespace+"_written_bytes_total",
+		"Number of bytes written, before passing them through filters", nil, nil)
+	bytesReadDesc = prometheus.NewDesc(namespace+"_read_bytes_total",
+		"Number of bytes read, after passing them through filters", nil, nil)
+	corruptionEventsDesc = prometheus.NewDesc(namespace+"_corruption_events_total",
+		"Number of states which failed verification", nil, nil)
+	compactionsDesc = prometheus.NewDesc(namespace+"_compactions_total",
+		"Number of finished compactions", nil, nil)
+	cacheHitsDesc = prometheus.NewDesc(namespace+"_cache_hits_total",
+		"Number of lookups served by the index or the dir existence cache", nil, nil)
+)
+
+// NewCollector returns prometheus.Collector exporting db.Stats() as counters. Use
+// prometheus.WrapRegistererWith to add labels, for example when many DBs are registered.
+func NewCollector(db *deebee.DB) prometheus.Collector {
+	return collector{db: db}
+}
+
+type collector struct {
+	db *deebee.DB
+}
+
+func (c collector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- writesDesc
+	descs <- readsDesc
+	descs <- bytesWrittenDesc
+	descs <- bytesReadDesc
+	descs <- corruptionEventsDesc
+	descs <- compactionsDesc
+	descs <- cacheHitsDesc
+}
+
+func (c collector) Collect(metrics chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	metrics <- counter(writesDesc, stats.Writes)
+	metrics <- counter(readsDesc, stats.Reads)
+	metrics <- counter(bytesWrittenDesc, stats.BytesWritten)
+	metrics <- counter(bytesReadDesc, stats.BytesRead)
+	metrics <- counter(corruptionEventsDesc, stats.CorruptionEvents)
+	metrics <- counter(compactionsDesc, stats.Compactions)
+	metrics <- counter(cacheHitsDesc, stats.CacheHits)
+}
+
+func counter(desc *prometheus.Desc, value int64) prometheus.Metric {
+	return prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value))
+}
@@ -0,0 +1,52 @@
+package deebeeprom_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/deebeeprom"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCollector(t *testing.T) {
+	t.Run("should collect stats", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir())
+		require.NoError(t, err)
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		collector := deebeeprom.NewCollector(db)
+		// expect
+		expected := `
+# HELP deebee_writes_total Number of committed versions
+# TYPE deebee_writes_total counter
+deebee_writes_total 1
+# HELP deebee_written_bytes_total Number of bytes written, before passing them through filters
+# TYPE deebee_written_bytes_total counter
+deebee_written_bytes_total 4
+`
+		err = testutil.CollectAndCompare(collector, strings.NewReader(expected),
+			"deebee_writes_total", "deebee_written_bytes_total")
+		assert.NoError(t, err)
+	})
+
+	t.Run("should register all metrics", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir())
+		require.NoError(t, err)
+		registry := prometheus.NewPedanticRegistry()
+		// when
+		err = registry.Register(deebeeprom.NewCollector(db))
+		// then
+		require.NoError(t, err)
+		count, err := testutil.GatherAndCount(registry)
+		require.NoError(t, err)
+		assert.Equal(t, 7, count)
+	})
+}
@@ -0,0 +1,23 @@
+// Package deebeetest provides helpers for tests of applications using deebee
+package deebeetest
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+)
+
+// VerifyNoLeaks fails the test when db has readers or writers which were not closed,
+// reporting stack traces of their creation. DB must be opened using
+// deebee.WithHandleTracking. Call it at the end of the test, for example using t.Cleanup.
+func VerifyNoLeaks(t testing.TB, db *deebee.DB) {
+	t.Helper()
+	handles, err := db.OpenHandles()
+	if err != nil {
+		t.Fatalf("verifying leaks failed: %s", err)
+		return
+	}
+	for _, handle := range handles {
+		t.Errorf("%s of key \"%s\" was not closed, created at:\n%s", handle.Kind, handle.Key, handle.Stack)
+	}
+}
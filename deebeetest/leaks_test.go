@@ -0,0 +1,89 @@
+package deebeetest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/deebeetest"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyNoLeaks(t *testing.T) {
+	t.Run("should pass when all readers and writers were closed", func(t *testing.T) {
+		db := openDB(t)
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		recorder := &recordingT{TB: t}
+		// when
+		deebeetest.VerifyNoLeaks(recorder, db)
+		// then
+		assert.Empty(t, recorder.errors)
+	})
+
+	t.Run("should report not closed writer with stack trace", func(t *testing.T) {
+		db := openDB(t)
+		_, err := db.Writer("key")
+		require.NoError(t, err)
+		recorder := &recordingT{TB: t}
+		// when
+		deebeetest.VerifyNoLeaks(recorder, db)
+		// then
+		require.Len(t, recorder.errors, 1)
+		assert.Contains(t, recorder.errors[0], "writer of key \"key\"")
+		assert.Contains(t, recorder.errors[0], "TestVerifyNoLeaks")
+	})
+
+	t.Run("should report not closed reader", func(t *testing.T) {
+		db := openDB(t)
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		_, err = db.Reader("key")
+		require.NoError(t, err)
+		recorder := &recordingT{TB: t}
+		// when
+		deebeetest.VerifyNoLeaks(recorder, db)
+		// then
+		require.Len(t, recorder.errors, 1)
+		assert.Contains(t, recorder.errors[0], "reader of key \"key\"")
+	})
+
+	t.Run("should fail when handle tracking is not enabled", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir())
+		require.NoError(t, err)
+		recorder := &recordingT{TB: t}
+		// when
+		deebeetest.VerifyNoLeaks(recorder, db)
+		// then
+		assert.Len(t, recorder.errors, 1)
+	})
+}
+
+func openDB(t *testing.T) *deebee.DB {
+	db, err := deebee.Open(fake.ExistingDir(), deebee.WithHandleTracking())
+	require.NoError(t, err)
+	return db
+}
+
+// recordingT records failures instead of failing the test
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) Fatalf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,128 @@
+package deebee
+
+// Delete marks the state as deleted by writing a tombstone version. Reader returns data
+// not found error afterwards, but previous versions are kept and the state can be
+// restored using Undelete. Returns data not found error when there is no state to delete.
+func (s *DB) Delete(key string) (err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.checkKey(key); err != nil {
+		return err
+	}
+	if err := s.checkAccess(DeleteOperation, key); err != nil {
+		return err
+	}
+	if err := s.flushWriteBehindKey(key); err != nil {
+		return err
+	}
+	defer s.dirCache.invalidateOnError(key, &err)
+	stateDir, err := s.existingStateDir(key)
+	if err != nil {
+		return err
+	}
+	youngest, exists, err := youngestFile(stateDir)
+	if err != nil {
+		return err
+	}
+	if !exists || youngest.kind == tombstoneFile {
+		return &dataNotFoundError{}
+	}
+	// tombstone is written to the temp file first, so it is not visible when writing failed
+	version, file, err := s.createVersionFile(key, stateDir, func(version int) string {
+		return newFilename(version).temp()
+	})
+	if err != nil {
+		return err
+	}
+	temp := newFilename(version).temp()
+	tombstone := newTombstoneFilename(version)
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		_ = stateDir.DeleteFile(temp)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		_ = stateDir.DeleteFile(temp)
+		return err
+	}
+	if err = stateDir.Rename(temp, tombstone.name); err != nil {
+		_ = stateDir.DeleteFile(temp)
+		return err
+	}
+	if err = s.syncDir(stateDir); err != nil {
+		// the tombstone may not survive power loss, therefore it is not published
+		_ = stateDir.DeleteFile(tombstone.name)
+		return err
+	}
+	s.index.committed(key, tombstone)
+	s.committed.committed(key, version)
+	s.emit(Event{Type: VersionDeleted, Key: key, Version: version})
+	s.compactor.committed(key)
+	return nil
+}
+
+// Undelete restores the state deleted with Delete. The youngest version written before
+// the deletion becomes the latest one again. Does nothing when the state is not deleted.
+// Returns data not found error when there is no version to restore.
+func (s *DB) Undelete(key string) (err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.checkKey(key); err != nil {
+		return err
+	}
+	if err := s.checkAccess(WriteOperation, key); err != nil {
+		return err
+	}
+	defer s.dirCache.invalidateOnError(key, &err)
+	stateDir, err := s.existingStateDir(key)
+	if err != nil {
+		return err
+	}
+	var (
+		tombstones []filename
+		youngest   filename
+		found      bool
+	)
+	err = iterateFiles(stateDir, func(file string) bool {
+		f, err := parseFilename(file)
+		if err != nil {
+			return true
+		}
+		switch {
+		case f.kind == tombstoneFile:
+			tombstones = append(tombstones, f)
+		case f.kind == dataFile && (!found || f.youngerThan(youngest)):
+			youngest = f
+			found = true
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return &dataNotFoundError{}
+	}
+	restored := false
+	for _, tombstone := range tombstones {
+		if tombstone.youngerThan(youngest) {
+			if err = stateDir.DeleteFile(tombstone.name); err != nil {
+				return err
+			}
+			restored = true
+		}
+	}
+	// tombstones are already removed, so the index follows the dir even when sync fails
+	s.index.set(key, youngest)
+	s.committed.set(key, youngest.version)
+	if restored {
+		s.emit(Event{Type: VersionRestored, Key: key, Version: youngest.version})
+	}
+	return s.syncDir(stateDir)
+}
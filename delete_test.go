@@ -0,0 +1,176 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Delete(t *testing.T) {
+	t.Run("should return error for invalid keys", func(t *testing.T) {
+		for _, key := range invalidKeys {
+			t.Run(key, func(t *testing.T) {
+				err := openDB(t, fake.ExistingDir()).Delete(key)
+				assert.True(t, deebee.IsClientError(err))
+			})
+		}
+	})
+
+	t.Run("should return data not found when state does not exist", func(t *testing.T) {
+		err := openDB(t, fake.ExistingDir()).Delete("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return data not found when state is already deleted", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		// when
+		err := db.Delete("key")
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("Reader should return data not found after delete", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.Delete("key")
+		// then
+		require.NoError(t, err)
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should read data written after delete", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		require.NoError(t, db.Delete("key"))
+		// when
+		writeData(t, db, "key", []byte("new"))
+		// then
+		assert.Equal(t, []byte("new"), readData(t, db, "key"))
+	})
+
+	t.Run("should return error when FileWriter failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, failing.FileWriter(dir))
+		// when
+		err := db.Delete("key")
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should not delete state when Rename failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, failing.Rename(dir))
+		// when
+		err := db.Delete("key")
+		// then
+		assert.Error(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+		assert.Len(t, dir.Dir("key").(fake.Dir).Files(), 1, "temp file should be removed")
+	})
+
+	t.Run("should try next version when file of the version was created by another process", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, failing.FileConflicts(dir, 1))
+		// when
+		err := db.Delete("key")
+		// then
+		require.NoError(t, err)
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+}
+
+func TestDB_Undelete(t *testing.T) {
+	t.Run("should return error for invalid keys", func(t *testing.T) {
+		for _, key := range invalidKeys {
+			t.Run(key, func(t *testing.T) {
+				err := openDB(t, fake.ExistingDir()).Undelete(key)
+				assert.True(t, deebee.IsClientError(err))
+			})
+		}
+	})
+
+	t.Run("should return data not found when state does not exist", func(t *testing.T) {
+		err := openDB(t, fake.ExistingDir()).Undelete("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should restore deleted state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		// when
+		err := db.Undelete("key")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should notify watchers", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		version := latestVersion(t, db, "key")
+		require.NoError(t, db.Delete("key"))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := db.Watch(ctx, "key")
+		require.NoError(t, err)
+		// when
+		err = db.Undelete("key")
+		// then
+		require.NoError(t, err)
+		select {
+		case event := <-events:
+			assert.Equal(t, deebee.Event{Type: deebee.VersionRestored, Key: "key", Version: version}, event)
+		case <-time.After(time.Second):
+			require.Fail(t, "no event received")
+		}
+	})
+
+	t.Run("should do nothing when state is not deleted", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.Undelete("key")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should restore state after reopen", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		db = openDB(t, dir)
+		// when
+		err := db.Undelete("key")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should return error when DeleteFile failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		// when
+		err := openDB(t, failing.DeleteFile(dir)).Undelete("key")
+		// then
+		assert.Error(t, err)
+	})
+}
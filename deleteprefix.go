@@ -0,0 +1,130 @@
+package deebee
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DeletePrefixOptions configures DeletePrefixWithOptions
+type DeletePrefixOptions struct {
+	// DryRun only reports keys which would be deleted, without deleting them
+	DryRun bool
+	// Parallelism is the maximum number of keys deleted concurrently. Default is 8.
+	Parallelism int
+}
+
+// DeletePrefix deletes all states which keys start with prefix, in the same way as Delete.
+// Keys are deleted concurrently. Returns client error for empty prefix.
+//
+// progress is called after each key and can be nil.
+func (s *DB) DeletePrefix(ctx context.Context, prefix string, progress ProgressFunc) error {
+	_, err := s.DeletePrefixWithOptions(ctx, prefix, DeletePrefixOptions{}, progress)
+	return err
+}
+
+// DeletePrefixWithOptions works the same as DeletePrefix, but returns sorted keys of deleted
+// states. In dry run mode nothing is deleted, so the impact can be previewed.
+func (s *DB) DeletePrefixWithOptions(ctx context.Context, prefix string, options DeletePrefixOptions, progress ProgressFunc) (deleted []string, err error) {
+	prefix = s.normalizeKey(prefix)
+	defer s.redactError(&err)
+	if prefix == "" {
+		return nil, newClientError("empty prefix")
+	}
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+	keys, err := s.stateKeys()
+	if err != nil {
+		return nil, err
+	}
+	var matching []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			matching = append(matching, key)
+		}
+	}
+	parallelism := options.Parallelism
+	if parallelism <= 0 {
+		parallelism = 8
+	}
+	var (
+		mutex sync.Mutex
+		p     = Progress{Total: len(matching)}
+	)
+	err = runParallel(ctx, matching, parallelism, func(key string) error {
+		wasDeleted, err := s.deleteKey(key, options.DryRun)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		if wasDeleted {
+			deleted = append(deleted, key)
+		}
+		p.Key = key
+		p.Done++
+		progress.report(p)
+		return nil
+	})
+	sort.Strings(deleted)
+	return deleted, err
+}
+
+// deleteKey deletes the state. Returns false when there is nothing to delete.
+func (s *DB) deleteKey(key string, dryRun bool) (bool, error) {
+	if dryRun {
+		latest, exists, err := s.latestFile(key)
+		return exists && latest.kind == dataFile, err
+	}
+	err := s.Delete(key)
+	if IsDataNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// runParallel runs fn for each key using at most parallelism goroutines. Stops when ctx
+// is done or fn returned error.
+func runParallel(ctx context.Context, keys []string, parallelism int, fn func(key string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	queue := make(chan string)
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range queue {
+				if err := fn(key); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+loop:
+	for _, key := range keys {
+		select {
+		case queue <- key:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
@@ -0,0 +1,80 @@
+package deebee_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_DeletePrefix(t *testing.T) {
+	t.Run("should return client error for empty prefix", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		err := db.DeletePrefix(context.Background(), "", nil)
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should delete keys with prefix", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for i := 0; i < 20; i++ {
+			writeData(t, db, fmt.Sprintf("tenant-%d", i), []byte("data"))
+		}
+		writeData(t, db, "other", []byte("data"))
+		var progress []deebee.Progress
+		// when
+		err := db.DeletePrefix(context.Background(), "tenant-", func(p deebee.Progress) {
+			progress = append(progress, p)
+		})
+		// then
+		require.NoError(t, err)
+		for i := 0; i < 20; i++ {
+			_, err = db.Reader(fmt.Sprintf("tenant-%d", i))
+			assert.True(t, deebee.IsDataNotFound(err))
+		}
+		assert.Equal(t, []byte("data"), readData(t, db, "other"))
+		require.Len(t, progress, 20)
+		assert.Equal(t, 20, progress[19].Done)
+		assert.Equal(t, 20, progress[19].Total)
+	})
+
+	t.Run("should stop when context is canceled", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		err := db.DeletePrefix(ctx, "k", nil)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestDB_DeletePrefixWithOptions(t *testing.T) {
+	t.Run("should report deleted keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "a1", []byte("data"))
+		writeData(t, db, "a2", []byte("data"))
+		writeData(t, db, "a3", []byte("data"))
+		require.NoError(t, db.Delete("a3"))
+		// when
+		deleted, err := db.DeletePrefixWithOptions(context.Background(), "a", deebee.DeletePrefixOptions{Parallelism: 1}, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a1", "a2"}, deleted)
+	})
+
+	t.Run("should not delete keys in dry run mode", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "a1", []byte("data"))
+		// when
+		deleted, err := db.DeletePrefixWithOptions(context.Background(), "a", deebee.DeletePrefixOptions{DryRun: true}, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a1"}, deleted)
+		assert.Equal(t, []byte("data"), readData(t, db, "a1"))
+	})
+}
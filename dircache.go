@@ -0,0 +1,80 @@
+package deebee
+
+import "sync"
+
+// WithDirExistenceCache caches the result of checking whether state dir exists. Once found,
+// the dir is not checked again, unless the state was renamed. This saves round trips on
+// each Reader and Writer creation when Dir is remote.
+//
+// The dir is checked again after an operation on the state failed, because it could have
+// been removed by someone else.
+func WithDirExistenceCache() Option {
+	return func(db *DB) error {
+		db.dirCache = &dirCache{dirs: map[string]struct{}{}}
+		return nil
+	}
+}
+
+// stateDirExists returns true when state dir exists. Uses the cache when DB was opened
+// WithDirExistenceCache.
+func (s *DB) stateDirExists(key string, stateDir Dir) (bool, error) {
+	if s.dirCache.contains(key) {
+		s.stats.add(statCacheHits, 1)
+		return true, nil
+	}
+	exists, err := stateDir.Exists()
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		s.dirCache.add(key)
+	}
+	return exists, nil
+}
+
+// dirCache contains keys of state dirs known to exist. Nil cache is not used.
+type dirCache struct {
+	mutex sync.Mutex
+	dirs  map[string]struct{}
+}
+
+func (c *dirCache) contains(key string) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.dirs[key]
+	return ok
+}
+
+func (c *dirCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.dirs)
+}
+
+func (c *dirCache) add(key string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.dirs[key] = struct{}{}
+}
+
+func (c *dirCache) remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.dirs, key)
+}
+
+// invalidateOnError removes key from the cache when operation failed unexpectedly
+func (c *dirCache) invalidateOnError(key string, err *error) {
+	if *err != nil && !IsDataNotFound(*err) && !IsClientError(*err) && !IsNotModified(*err) {
+		c.remove(key)
+	}
+}
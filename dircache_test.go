@@ -0,0 +1,48 @@
+package deebee_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDirExistenceCache(t *testing.T) {
+	t.Run("should write and read data", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithDirExistenceCache())
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should check dir again after operation failed", func(t *testing.T) {
+		dir := createTempDir(t)
+		db := openDB(t, deebee.OsDir(dir), deebee.WithDirExistenceCache())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, "key")))
+		// when
+		_, err := db.Reader("key")
+		// then
+		require.Error(t, err)
+		assert.False(t, deebee.IsDataNotFound(err), "cached dir should not be checked")
+		// and
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should read renamed state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithDirExistenceCache())
+		writeData(t, db, "old", []byte("data"))
+		// when
+		require.NoError(t, db.Rename("old", "new"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "new"))
+		_, err := db.Reader("old")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+}
@@ -0,0 +1,68 @@
+package deebee
+
+import "strings"
+
+// DirSyncer is an optional interface which can be implemented by Dir. On many filesystems,
+// such as ext4 on Linux, a created or renamed file survives power loss only after the dir
+// containing it is synced, even when the file itself was synced.
+type DirSyncer interface {
+	// SyncDir makes changes of the dir entries - created, renamed and deleted files and
+	// dirs - durable
+	SyncDir() error
+}
+
+// WithDirSync syncs dirs each time a change of their entries must survive power loss:
+// after the committed version is renamed, after dirs of a new state are created and after
+// Delete, Undelete and Rename. Without it a version committed right before power loss may
+// be lost, or even the whole state when its dir was just created. Requires Dir
+// implementing DirSyncer, such as OsDir. Each write costs one additional sync, therefore
+// the option is disabled by default.
+//
+// When the sync fails, the version or the tombstone is removed and the error is returned,
+// so it is not reported as committed. The dir can only be synced after the rename, so
+// readers opened in the meantime may see the version before it is removed.
+//
+// Files written by maintenance, such as Compact, Backup or labels, are not followed by
+// the dir sync.
+func WithDirSync() Option {
+	return func(db *DB) error {
+		db.dirSync = true
+		return nil
+	}
+}
+
+// syncDir syncs the dir when DB was opened WithDirSync
+func (s *DB) syncDir(dir Dir) error {
+	if !s.dirSync {
+		return nil
+	}
+	if syncer, ok := dir.(DirSyncer); ok {
+		return syncer.SyncDir()
+	}
+	return nil
+}
+
+// syncStateParents syncs all dirs containing the state dir, from the closest one to the
+// root, so the state dir survives power loss
+func (s *DB) syncStateParents(physicalKey string) error {
+	if !s.dirSync {
+		return nil
+	}
+	parent := s.dir
+	parents := []Dir{parent}
+	if s.sharded {
+		parent = parent.Dir(shard(physicalKey))
+		parents = append(parents, parent)
+	}
+	segments := strings.Split(physicalKey, keySeparator)
+	for _, segment := range segments[:len(segments)-1] {
+		parent = parent.Dir(segment)
+		parents = append(parents, parent)
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		if err := s.syncDir(parents[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package deebee_test
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDirSync(t *testing.T) {
+	t.Run("should sync state dir after committed version is renamed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithDirSync())
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.True(t, db.Options().DirSync)
+		history := dir.History()
+		last := history[len(history)-1]
+		assert.Equal(t, fake.Operation{Name: "SyncDir", Path: "key"}, last)
+	})
+
+	t.Run("should keep committed version after power loss", func(t *testing.T) {
+		layouts := map[string]struct {
+			key     string
+			options []deebee.Option
+		}{
+			"flat":         {key: "key", options: []deebee.Option{deebee.WithDirSync()}},
+			"sharded":      {key: "key", options: []deebee.Option{deebee.WithDirSync(), deebee.WithShardedLayout()}},
+			"hierarchical": {key: "a/b/c", options: []deebee.Option{deebee.WithDirSync(), deebee.WithHierarchicalKeys()}},
+			"key mapper":   {key: "key", options: []deebee.Option{deebee.WithDirSync(), deebee.WithKeyMapper(deebee.HashingKeyMapper())}},
+		}
+		for name, layout := range layouts {
+			t.Run(name, func(t *testing.T) {
+				dir := fake.ExistingDir()
+				writeData(t, openDB(t, dir, layout.options...), layout.key, []byte("data"))
+				// when
+				db := openDB(t, dir.CrashStrict(), layout.options...)
+				// then
+				assert.Equal(t, []byte("data"), readData(t, db, layout.key))
+				keys, err := db.Keys()
+				require.NoError(t, err)
+				assert.Equal(t, []string{layout.key}, keys)
+			})
+		}
+	})
+
+	t.Run("should lose committed version after power loss without the option", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		// when
+		db := openDB(t, dir.CrashStrict())
+		// then
+		_, err := db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should keep renamed state after power loss", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithDirSync())
+		writeData(t, db, "old", []byte("data"))
+		// when
+		require.NoError(t, db.Rename("old", "new"))
+		// then
+		recovered := openDB(t, dir.CrashStrict(), deebee.WithDirSync())
+		assert.Equal(t, []byte("data"), readData(t, recovered, "new"))
+		exists, err := recovered.Exists("old")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("should remove version when dir sync failed", func(t *testing.T) {
+		for name, options := range map[string][]deebee.Option{"default": nil, "preload": {deebee.WithPreload()}} {
+			t.Run(name, func(t *testing.T) {
+				dir := fake.ExistingDir()
+				writeData(t, openDB(t, dir), "key", []byte("old"))
+				db := openDB(t, failing.SyncDir(dir), append(options, deebee.WithDirSync())...)
+				writer, err := db.Writer("key")
+				require.NoError(t, err)
+				_, err = writer.Write([]byte("new"))
+				require.NoError(t, err)
+				// when
+				err = writer.Close()
+				// then
+				assert.Error(t, err)
+				assert.Equal(t, []byte("old"), readData(t, db, "key"))
+			})
+		}
+	})
+
+	t.Run("should remove tombstone when dir sync failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, failing.SyncDir(dir), deebee.WithDirSync(), deebee.WithPreload())
+		// when
+		err := db.Delete("key")
+		// then
+		assert.Error(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should return error when dir sync failed", func(t *testing.T) {
+		db := openDB(t, failing.SyncDir(fake.ExistingDir()), deebee.WithDirSync())
+		writer, err := db.Writer("key")
+		if err == nil {
+			err = writer.Close()
+		}
+		assert.Error(t, err)
+	})
+}
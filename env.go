@@ -0,0 +1,92 @@
+package deebee
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Environment variables read by DB opened WithEnvOverrides
+const (
+	// EnvGroupCommitWindow overrides the window of WithGroupCommit. "0" disables group
+	// commit, so each file is synced on its own.
+	EnvGroupCommitWindow = "DEEBEE_GROUP_COMMIT_WINDOW"
+	// EnvCompactionIdle overrides CompactionTrigger.Idle of WithCompactionTrigger. Compaction
+	// trigger is enabled when it was not used. "0" disables idle compaction.
+	EnvCompactionIdle = "DEEBEE_COMPACTION_IDLE"
+	// EnvOperationTimeout overrides the timeout of WithOperationTimeout. "0" disables it.
+	EnvOperationTimeout = "DEEBEE_OPERATION_TIMEOUT"
+	// EnvSlowOpThreshold overrides the threshold of WithSlowOpThreshold. "0" disables it.
+	EnvSlowOpThreshold = "DEEBEE_SLOW_OP_THRESHOLD"
+)
+
+// WithEnvOverrides makes Open read tuning knobs from environment variables EnvGroupCommitWindow,
+// EnvCompactionIdle, EnvOperationTimeout and EnvSlowOpThreshold. Values use the format of
+// time.ParseDuration. Variables which are set override all other options, regardless of
+// their order, but not options given to WithKeyOptions. Useful for mitigating incidents
+// without deploying a new version of the application.
+//
+// Open fails when the variable has invalid value.
+func WithEnvOverrides() Option {
+	return func(db *DB) error {
+		db.envOverrides = true
+		return nil
+	}
+}
+
+// applyEnvOverrides applies options set using environment variables
+func (s *DB) applyEnvOverrides() error {
+	overrides := []struct {
+		variable string
+		apply    func(d time.Duration) error
+	}{
+		{EnvGroupCommitWindow, func(d time.Duration) error {
+			if d == 0 {
+				s.groupCommit = nil
+				return nil
+			}
+			return WithGroupCommit(d)(s)
+		}},
+		{EnvCompactionIdle, func(d time.Duration) error {
+			if s.compactor == nil {
+				if d == 0 {
+					return nil
+				}
+				return WithCompactionTrigger(CompactionTrigger{Idle: d})(s)
+			}
+			if d < 0 {
+				return fmt.Errorf("negative compaction trigger threshold")
+			}
+			s.compactor.trigger.Idle = d
+			return nil
+		}},
+		{EnvOperationTimeout, func(d time.Duration) error {
+			if d == 0 {
+				s.operationTimeout = 0
+				return nil
+			}
+			return WithOperationTimeout(d)(s)
+		}},
+		{EnvSlowOpThreshold, func(d time.Duration) error {
+			if d == 0 {
+				s.slowOps = nil
+				return nil
+			}
+			return WithSlowOpThreshold(d)(s)
+		}},
+	}
+	for _, override := range overrides {
+		value, ok := os.LookupEnv(override.variable)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err == nil {
+			err = override.apply(d)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", override.variable, err)
+		}
+	}
+	return nil
+}
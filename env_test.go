@@ -0,0 +1,104 @@
+package deebee_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithEnvOverrides(t *testing.T) {
+	t.Run("should override options", func(t *testing.T) {
+		setEnv(t, deebee.EnvGroupCommitWindow, "5ms")
+		setEnv(t, deebee.EnvOperationTimeout, "1m")
+		// when
+		db, err := deebee.Open(fake.ExistingDir(),
+			deebee.WithEnvOverrides(),
+			deebee.WithGroupCommit(time.Second),
+			deebee.WithOperationTimeout(time.Second),
+		)
+		// then
+		require.NoError(t, err)
+		options := db.Options()
+		assert.Equal(t, 5*time.Millisecond, options.GroupCommitWindow)
+		assert.Equal(t, time.Minute, options.OperationTimeout)
+	})
+
+	t.Run("should disable options using zero", func(t *testing.T) {
+		setEnv(t, deebee.EnvGroupCommitWindow, "0")
+		setEnv(t, deebee.EnvOperationTimeout, "0s")
+		// when
+		db, err := deebee.Open(fake.ExistingDir(),
+			deebee.WithGroupCommit(time.Second),
+			deebee.WithOperationTimeout(time.Second),
+			deebee.WithEnvOverrides(),
+		)
+		// then
+		require.NoError(t, err)
+		options := db.Options()
+		assert.Zero(t, options.GroupCommitWindow)
+		assert.Zero(t, options.OperationTimeout)
+	})
+
+	t.Run("should enable idle compaction", func(t *testing.T) {
+		setEnv(t, deebee.EnvCompactionIdle, "1ms")
+		events := make(chan deebee.Event, 10)
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithEnvOverrides(),
+			deebee.WithEventHandler(func(event deebee.Event) {
+				if event.Type == deebee.CompactionFinished {
+					events <- event
+				}
+			}),
+		)
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assertEvent(t, events, deebee.CompactionFinished, "")
+	})
+
+	t.Run("should not read variables without the option", func(t *testing.T) {
+		setEnv(t, deebee.EnvOperationTimeout, "1m")
+		// when
+		db := openDB(t, fake.ExistingDir())
+		// then
+		assert.Zero(t, db.Options().OperationTimeout)
+	})
+
+	t.Run("should return error for invalid values", func(t *testing.T) {
+		variables := []string{
+			deebee.EnvGroupCommitWindow,
+			deebee.EnvCompactionIdle,
+			deebee.EnvOperationTimeout,
+			deebee.EnvSlowOpThreshold,
+		}
+		for _, variable := range variables {
+			for _, value := range []string{"fast", "-1s"} {
+				t.Run(variable+"="+value, func(t *testing.T) {
+					setEnv(t, variable, value)
+					// when
+					_, err := deebee.Open(fake.ExistingDir(), deebee.WithEnvOverrides())
+					// then
+					assert.Error(t, err)
+				})
+			}
+		}
+	})
+}
+
+// setEnv sets the environment variable until the end of the test
+func setEnv(t *testing.T, key, value string) {
+	previous, existed := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value))
+	t.Cleanup(func() {
+		if existed {
+			_ = os.Setenv(key, previous)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package deebee
 
+import "errors"
+
 type deebeeError struct {
 	message string
 }
@@ -39,6 +41,43 @@ func (e *dataNotFoundError) Error() string {
 }
 
 func IsDataNotFound(err error) bool {
-	_, ok := err.(*dataNotFoundError)
-	return ok
+	var dataNotFound *dataNotFoundError
+	return errors.As(err, &dataNotFound)
+}
+
+// IsRetryable returns true when err is transient and the operation may succeed when retried,
+// for example when network Dir timed out or file is temporarily locked. Client errors and
+// data not found errors are never retryable.
+//
+// Dir implementations can mark their errors as retryable by implementing Retryable() bool
+// method. Errors implementing Timeout() bool or Temporary() bool (such as net.Error,
+// syscall.Errno or context.DeadlineExceeded) are recognized as well.
+func IsRetryable(err error) bool {
+	type retryable interface {
+		Retryable() bool
+	}
+	type timeout interface {
+		Timeout() bool
+	}
+	type temporary interface {
+		Temporary() bool
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if IsClientError(err) {
+			return false
+		}
+		if _, ok := err.(*dataNotFoundError); ok {
+			return false
+		}
+		if e, ok := err.(retryable); ok {
+			return e.Retryable()
+		}
+		if e, ok := err.(timeout); ok && e.Timeout() {
+			return true
+		}
+		if e, ok := err.(temporary); ok && e.Temporary() {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,73 @@
+package deebee_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCorrupted(t *testing.T) {
+	t.Run("should return true for error wrapping ErrCorrupted", func(t *testing.T) {
+		err := fmt.Errorf("invalid manifest: %w", deebee.ErrCorrupted)
+		assert.True(t, deebee.IsCorrupted(err))
+	})
+
+	t.Run("should return false for other errors", func(t *testing.T) {
+		assert.False(t, deebee.IsCorrupted(errors.New("generic")))
+		assert.False(t, deebee.IsCorrupted(nil))
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Run("should return false for permanent errors", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		_, invalidKey := db.Writer(" invalid")
+		_, dataNotFound := db.Reader("missing")
+		errs := map[string]error{
+			"nil":               nil,
+			"generic":           errors.New("generic"),
+			"client error":      invalidKey,
+			"data not found":    dataNotFound,
+			"canceled":          context.Canceled,
+			"not retryable":     retryableError{retryable: false},
+			"permission denied": syscall.EACCES,
+		}
+		for name, err := range errs {
+			t.Run(name, func(t *testing.T) {
+				assert.False(t, deebee.IsRetryable(err))
+			})
+		}
+	})
+
+	t.Run("should return true for transient errors", func(t *testing.T) {
+		errs := map[string]error{
+			"retryable":         retryableError{retryable: true},
+			"deadline exceeded": context.DeadlineExceeded,
+			"temporary errno":   syscall.EAGAIN,
+			"wrapped":           fmt.Errorf("wrapped: %w", retryableError{retryable: true}),
+		}
+		for name, err := range errs {
+			t.Run(name, func(t *testing.T) {
+				assert.True(t, deebee.IsRetryable(err))
+			})
+		}
+	})
+}
+
+type retryableError struct {
+	retryable bool
+}
+
+func (e retryableError) Error() string {
+	return "retryable error"
+}
+
+func (e retryableError) Retryable() bool {
+	return e.retryable
+}
@@ -0,0 +1,137 @@
+package deebee
+
+import (
+	"errors"
+	"time"
+)
+
+type EventType int
+
+const (
+	// VersionCommitted is emitted after writer was closed and the version is durably stored
+	VersionCommitted EventType = iota
+	// VersionDeleted is emitted after the state was deleted using Delete
+	VersionDeleted
+	// CorruptionDetected is emitted by Verify for each state which could not be read, and
+	// when keys are listed for each key manifest which could not be decoded (see
+	// WithKeyMapper)
+	CorruptionDetected
+	// CompactionFinished is emitted after Compact finished successfully, or after the key
+	// was compacted because of WithCompactionTrigger
+	CompactionFinished
+	// TempFileRemoved is emitted for each temp file of never committed version removed
+	// by CleanTempFiles
+	TempFileRemoved
+	// TempFileCleanupFailed is emitted when periodic cleanup configured using
+	// WithTempFileCleanup failed
+	TempFileCleanupFailed
+	// WriteBehindFlushFailed is emitted when data written to keys configured using
+	// WithWriteBehind could not be persisted in the background
+	WriteBehindFlushFailed
+	// CompactionFailed is emitted when compaction started by WithCompactionTrigger failed
+	CompactionFailed
+	// WriterExpired is emitted when writer was aborted, because it was not closed within
+	// the deadline set using WithWriterDeadline
+	WriterExpired
+	// SlowOperation is emitted when reading, writing or syncing the file took longer than
+	// the threshold set using WithSlowOpThreshold
+	SlowOperation
+	// TaskPanicked is emitted when background task, such as compaction, panicked. The panic
+	// is recovered and the task is run again later (see Health).
+	TaskPanicked
+	// Reconfigured is emitted after options were changed using Reconfigure
+	Reconfigured
+	// VersionConflict is emitted when the file of the new version already existed, because
+	// another process or DB instance sharing the dir picked the same version. The next
+	// version is tried then.
+	VersionConflict
+	// EventsDropped is delivered by Watch in place of the oldest events which were dropped,
+	// because the receiver was too slow. The receiver should read the watched states again.
+	EventsDropped
+	// VersionRestored is emitted after the state deleted using Delete was restored using
+	// Undelete. Version is the restored data version.
+	VersionRestored
+)
+
+func (t EventType) String() string {
+	switch t {
+	case VersionCommitted:
+		return "VersionCommitted"
+	case VersionDeleted:
+		return "VersionDeleted"
+	case CorruptionDetected:
+		return "CorruptionDetected"
+	case CompactionFinished:
+		return "CompactionFinished"
+	case TempFileRemoved:
+		return "TempFileRemoved"
+	case TempFileCleanupFailed:
+		return "TempFileCleanupFailed"
+	case WriteBehindFlushFailed:
+		return "WriteBehindFlushFailed"
+	case CompactionFailed:
+		return "CompactionFailed"
+	case WriterExpired:
+		return "WriterExpired"
+	case SlowOperation:
+		return "SlowOperation"
+	case TaskPanicked:
+		return "TaskPanicked"
+	case Reconfigured:
+		return "Reconfigured"
+	case VersionConflict:
+		return "VersionConflict"
+	case EventsDropped:
+		return "EventsDropped"
+	case VersionRestored:
+		return "VersionRestored"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event describes what happened in the DB
+type Event struct {
+	Type EventType
+	// Key is empty for events not related to a single state, such as CompactionFinished
+	// emitted by Compact
+	Key string
+	// Version is set for VersionCommitted, VersionDeleted, VersionRestored, TempFileRemoved,
+	// WriterExpired, VersionConflict and CorruptionDetected emitted by Repair
+	Version int
+	// Err is set for CorruptionDetected, TempFileCleanupFailed, WriteBehindFlushFailed,
+	// CompactionFailed, TaskPanicked and VersionConflict
+	Err error
+	// SlowOp is set for SlowOperation
+	SlowOp SlowOp
+	// Duration is set for SlowOperation
+	Duration time.Duration
+	// Bytes is set for SlowOperation. It is the number of bytes read or written by
+	// the operation, zero for SyncOp.
+	Bytes int64
+}
+
+// WithEventHandler registers handler called synchronously for each event. Handler is
+// called from many goroutines when DB is used concurrently, therefore it must be
+// thread-safe. It should return quickly, because it delays the operation emitting the event.
+//
+// Can be used many times to register many handlers.
+func WithEventHandler(handler func(Event)) Option {
+	return func(db *DB) error {
+		if handler == nil {
+			return errors.New("nil event handler")
+		}
+		db.eventHandlers = append(db.eventHandlers, handler)
+		return nil
+	}
+}
+
+func (s *DB) emit(event Event) {
+	if event.Type == VersionCommitted || event.Type == VersionDeleted || event.Type == VersionRestored {
+		s.changes.changed(event.Key)
+		s.watchers.notify(event)
+	}
+	for _, handle := range s.eventHandlers {
+		handle(event)
+	}
+}
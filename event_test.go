@@ -0,0 +1,69 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithEventHandler(t *testing.T) {
+	t.Run("should return error for nil handler", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithEventHandler(nil))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("should emit events", func(t *testing.T) {
+		var events []deebee.Event
+		db := openDB(t, fake.ExistingDir(), deebee.WithEventHandler(func(event deebee.Event) {
+			events = append(events, event)
+		}))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		require.NoError(t, db.Compact(context.Background(), nil))
+		// then
+		require.Len(t, events, 3)
+		assert.Equal(t, deebee.VersionCommitted, events[0].Type)
+		assert.Equal(t, "key", events[0].Key)
+		assert.Equal(t, deebee.VersionDeleted, events[1].Type)
+		assert.Equal(t, "key", events[1].Key)
+		assert.Greater(t, events[1].Version, events[0].Version)
+		assert.Equal(t, deebee.Event{Type: deebee.CompactionFinished}, events[2])
+	})
+
+	t.Run("should emit corruption detected by Verify", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		var events []deebee.Event
+		db := openDB(t, dir,
+			deebee.WithFilter(failingReadFilter{}),
+			deebee.WithEventHandler(func(event deebee.Event) {
+				events = append(events, event)
+			}))
+		// when
+		_, err := db.Verify(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, deebee.CorruptionDetected, events[0].Type)
+		assert.Equal(t, "key", events[0].Key)
+		assert.Error(t, events[0].Err)
+	})
+
+	t.Run("should call all handlers", func(t *testing.T) {
+		var first, second int
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithEventHandler(func(deebee.Event) { first++ }),
+			deebee.WithEventHandler(func(deebee.Event) { second++ }))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.Equal(t, 1, first)
+		assert.Equal(t, 1, second)
+	})
+}
@@ -0,0 +1,49 @@
+package failing
+
+import (
+	"sync"
+
+	"github.com/jacekolszak/deebee"
+)
+
+// FileConflicts returns Dir simulating another process sharing decoratedDir, which creates
+// the file right before FileWriter does, as if both processes picked the same name. FileWriter
+// fails then, because the file exists. Given number of FileWriter calls conflict, for the
+// whole tree of dirs.
+func FileConflicts(decoratedDir deebee.Dir, conflicts int) deebee.Dir {
+	return fileConflicts(decoratedDir, &conflictCounter{remaining: conflicts})
+}
+
+type conflictCounter struct {
+	mutex     sync.Mutex
+	remaining int
+}
+
+// next returns true when the next FileWriter call should conflict
+func (c *conflictCounter) next() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.remaining <= 0 {
+		return false
+	}
+	c.remaining--
+	return true
+}
+
+func fileConflicts(decoratedDir deebee.Dir, c *conflictCounter) deebee.Dir {
+	dir := decorate(decoratedDir)
+	dir.fileWriter = func(name string) (deebee.FileWriter, error) {
+		if c.next() {
+			other, err := decoratedDir.FileWriter(name)
+			if err != nil {
+				return nil, err
+			}
+			_ = other.Close()
+		}
+		return decoratedDir.FileWriter(name)
+	}
+	dir.dir = func(name string) deebee.Dir {
+		return fileConflicts(decoratedDir.Dir(name), c)
+	}
+	return dir
+}
@@ -0,0 +1,134 @@
+package failing
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/jacekolszak/deebee"
+)
+
+// ErrCrashed is returned by all operations executed after the crash
+var ErrCrashed = errors.New("crashed")
+
+// CrashAfter returns Dir which executes given number of operations on decoratedDir
+// and fails all next ones with ErrCrashed, as if the process was killed. All methods
+// of Dir (except Dir), SyncDir when decoratedDir implements deebee.DirSyncer and methods
+// of files returned by FileWriter are counted, for the whole tree of dirs.
+func CrashAfter(decoratedDir deebee.Dir, operations int) deebee.Dir {
+	return crashAfter(decoratedDir, &killPoint{remaining: operations})
+}
+
+type killPoint struct {
+	mutex     sync.Mutex
+	remaining int
+}
+
+// pass returns ErrCrashed when kill point was reached
+func (k *killPoint) pass() error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if k.remaining <= 0 {
+		return ErrCrashed
+	}
+	k.remaining--
+	return nil
+}
+
+func crashAfter(decoratedDir deebee.Dir, k *killPoint) deebee.Dir {
+	dir := decorate(decoratedDir)
+	dir.fileReader = func(name string) (io.ReadCloser, error) {
+		if err := k.pass(); err != nil {
+			return nil, err
+		}
+		return decoratedDir.FileReader(name)
+	}
+	dir.fileWriter = func(name string) (deebee.FileWriter, error) {
+		if err := k.pass(); err != nil {
+			return nil, err
+		}
+		file, err := decoratedDir.FileWriter(name)
+		if err != nil {
+			return nil, err
+		}
+		return &crashingFile{FileWriter: file, killPoint: k}, nil
+	}
+	dir.mkdir = func() error {
+		if err := k.pass(); err != nil {
+			return err
+		}
+		return decoratedDir.Mkdir()
+	}
+	dir.exists = func() (bool, error) {
+		if err := k.pass(); err != nil {
+			return false, err
+		}
+		return decoratedDir.Exists()
+	}
+	dir.listFiles = func() ([]string, error) {
+		if err := k.pass(); err != nil {
+			return nil, err
+		}
+		return decoratedDir.ListFiles()
+	}
+	dir.listDirs = func() ([]string, error) {
+		if err := k.pass(); err != nil {
+			return nil, err
+		}
+		return decoratedDir.ListDirs()
+	}
+	dir.rename = func(oldName, newName string) error {
+		if err := k.pass(); err != nil {
+			return err
+		}
+		return decoratedDir.Rename(oldName, newName)
+	}
+	dir.deleteFile = func(name string) error {
+		if err := k.pass(); err != nil {
+			return err
+		}
+		return decoratedDir.DeleteFile(name)
+	}
+	dir.dir = func(name string) deebee.Dir {
+		return crashAfter(decoratedDir.Dir(name), k)
+	}
+	syncer, ok := decoratedDir.(deebee.DirSyncer)
+	if !ok {
+		return dir
+	}
+	return &dirSyncer{
+		failingDir: dir,
+		syncDir: func() error {
+			if err := k.pass(); err != nil {
+				return err
+			}
+			return syncer.SyncDir()
+		},
+	}
+}
+
+type crashingFile struct {
+	deebee.FileWriter
+	killPoint *killPoint
+}
+
+func (f *crashingFile) Write(p []byte) (int, error) {
+	if err := f.killPoint.pass(); err != nil {
+		return 0, err
+	}
+	return f.FileWriter.Write(p)
+}
+
+func (f *crashingFile) Sync() error {
+	if err := f.killPoint.pass(); err != nil {
+		return err
+	}
+	return f.FileWriter.Sync()
+}
+
+func (f *crashingFile) Close() error {
+	if err := f.killPoint.pass(); err != nil {
+		return err
+	}
+	return f.FileWriter.Close()
+}
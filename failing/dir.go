@@ -63,6 +63,53 @@ func ListFiles(decoratedDir deebee.Dir) deebee.Dir {
 	return dir
 }
 
+func ListDirs(decoratedDir deebee.Dir) deebee.Dir {
+	dir := decorate(decoratedDir)
+	dir.listDirs = func() ([]string, error) {
+		return nil, errors.New("listDirs failed")
+	}
+	dir.dir = func(name string) deebee.Dir {
+		return ListDirs(decoratedDir.Dir(name))
+	}
+	return dir
+}
+
+func Rename(decoratedDir deebee.Dir) deebee.Dir {
+	dir := decorate(decoratedDir)
+	dir.rename = func(oldName, newName string) error {
+		return errors.New("rename failed")
+	}
+	dir.dir = func(name string) deebee.Dir {
+		return Rename(decoratedDir.Dir(name))
+	}
+	return dir
+}
+
+func DeleteFile(decoratedDir deebee.Dir) deebee.Dir {
+	dir := decorate(decoratedDir)
+	dir.deleteFile = func(name string) error {
+		return errors.New("deleteFile failed")
+	}
+	dir.dir = func(name string) deebee.Dir {
+		return DeleteFile(decoratedDir.Dir(name))
+	}
+	return dir
+}
+
+// SyncDir returns Dir implementing deebee.DirSyncer, which fails each SyncDir
+func SyncDir(decoratedDir deebee.Dir) deebee.Dir {
+	dir := decorate(decoratedDir)
+	dir.dir = func(name string) deebee.Dir {
+		return SyncDir(decoratedDir.Dir(name))
+	}
+	return &dirSyncer{
+		failingDir: dir,
+		syncDir: func() error {
+			return errors.New("syncDir failed")
+		},
+	}
+}
+
 func decorate(dir deebee.Dir) *failingDir {
 	return &failingDir{
 		fileReader: dir.FileReader,
@@ -70,6 +117,9 @@ func decorate(dir deebee.Dir) *failingDir {
 		mkdir:      dir.Mkdir,
 		exists:     dir.Exists,
 		listFiles:  dir.ListFiles,
+		listDirs:   dir.ListDirs,
+		rename:     dir.Rename,
+		deleteFile: dir.DeleteFile,
 	}
 }
 
@@ -80,6 +130,9 @@ type failingDir struct {
 	dir        func(name string) deebee.Dir
 	exists     func() (bool, error)
 	listFiles  func() ([]string, error)
+	listDirs   func() ([]string, error)
+	rename     func(oldName, newName string) error
+	deleteFile func(name string) error
 }
 
 func (d *failingDir) FileReader(name string) (io.ReadCloser, error) {
@@ -105,3 +158,25 @@ func (d *failingDir) Exists() (bool, error) {
 func (d *failingDir) ListFiles() ([]string, error) {
 	return d.listFiles()
 }
+
+func (d *failingDir) ListDirs() ([]string, error) {
+	return d.listDirs()
+}
+
+func (d *failingDir) Rename(oldName, newName string) error {
+	return d.rename(oldName, newName)
+}
+
+func (d *failingDir) DeleteFile(name string) error {
+	return d.deleteFile(name)
+}
+
+// dirSyncer is failingDir implementing deebee.DirSyncer
+type dirSyncer struct {
+	*failingDir
+	syncDir func() error
+}
+
+func (d *dirSyncer) SyncDir() error {
+	return d.syncDir()
+}
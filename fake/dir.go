@@ -1,3 +1,5 @@
+// Package fake provides in-memory deebee.Dir implementation useful in unit tests. It is
+// safe for concurrent use, records operation history and provides assertions of durability.
 package fake
 
 import (
@@ -5,6 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
 
 	"github.com/jacekolszak/deebee"
 )
@@ -18,22 +25,68 @@ func MissingDir() Dir {
 }
 
 func newRootDir(name string, missing bool) *dir {
-	return newDir(name, missing, nil)
+	return newDir(name, missing, nil, &filesystem{})
 }
 
-func newDir(name string, missing bool, parent *dir) *dir {
+func newDir(name string, missing bool, parent *dir, fs *filesystem) *dir {
 	return &dir{
 		parent:      parent,
 		filesByName: map[string]*File{},
 		dirsByName:  map[string]*dir{},
 		missing:     missing,
 		name:        name,
+		fs:          fs,
 	}
 }
 
 type Dir interface {
 	deebee.Dir
+	deebee.DirSyncer
 	Files() []*File
+	// History returns operations modifying files and dirs (including Corrupt) and FileReader
+	// calls, executed on the whole tree of dirs in the order of execution
+	History() []Operation
+	// AssertFileSynced reports test error when file in this dir does not exist or not all
+	// its data was synced
+	AssertFileSynced(t testing.TB, name string)
+	// Corrupt modifies the data of file in this dir, as if it was damaged by the disk
+	Corrupt(name string) error
+	// Crash returns a copy of the whole tree of dirs as it would look like after power
+	// loss - data which was not synced is lost. Dir operations such as Rename or
+	// DeleteFile are considered durable. Returned dir is the root of the copy.
+	Crash() Dir
+	// CrashStrict is like Crash, but dir operations are durable only when followed by
+	// SyncDir of the dir containing the entry, like on ext4 in Linux. Files created,
+	// renamed or deleted and dirs created or renamed after the last SyncDir are reverted.
+	CrashStrict() Dir
+}
+
+// Operation is a record of method executed on Dir or File
+type Operation struct {
+	// Name of the method, for example "FileWriter" or "Sync"
+	Name string
+	// Path of the file or dir relative to the root dir, for example "key/1.tmp"
+	Path string
+	// NewPath is set for Rename
+	NewPath string
+}
+
+func (o Operation) String() string {
+	if o.NewPath != "" {
+		return fmt.Sprintf("%s %s %s", o.Name, o.Path, o.NewPath)
+	}
+	return fmt.Sprintf("%s %s", o.Name, o.Path)
+}
+
+// filesystem contains state shared by all dirs and files in the tree
+type filesystem struct {
+	mutex   sync.Mutex
+	history []Operation
+}
+
+// record must be called with mutex locked
+func (fs *filesystem) record(name, path string) {
+	fs.history = append(fs.history, Operation{Name: name, Path: path})
 }
 
 type dir struct {
@@ -42,36 +95,80 @@ type dir struct {
 	dirsByName  map[string]*dir
 	missing     bool
 	name        string
+	fs          *filesystem
+	// durableFiles and durableDirs contain entries as of the last SyncDir
+	durableFiles map[string]*File
+	durableDirs  map[string]*dir
+}
+
+// path returns path relative to the root dir
+func (f *dir) path(name string) string {
+	for d := f; d.parent != nil; d = d.parent {
+		name = path.Join(d.name, name)
+	}
+	return name
 }
 
 func (f *dir) FileReader(name string) (io.ReadCloser, error) {
 	if name == "" {
 		return nil, errors.New("empty file name")
 	}
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	f.fs.record("FileReader", f.path(name))
 	file, exists := f.filesByName[name]
 	if !exists {
 		return nil, fmt.Errorf("file %s does not exist", name)
 	}
-	file.closed = false
-	return file, nil
+	return &fileReader{
+		name:   name,
+		reader: bytes.NewReader(append([]byte{}, file.data.Bytes()...)),
+	}, nil
 }
 
 func (f *dir) FileWriter(name string) (deebee.FileWriter, error) {
 	if name == "" {
 		return nil, errors.New("empty file name")
 	}
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	f.fs.record("FileWriter", f.path(name))
 	_, exists := f.filesByName[name]
 	if exists {
-		return nil, fmt.Errorf("file %s already exists", name)
+		return nil, fmt.Errorf("file %s: %w", name, os.ErrExist)
 	}
 	file := &File{
-		name: name,
+		name:    name,
+		modTime: time.Now(),
+		dir:     f,
 	}
 	f.filesByName[name] = file
 	return file, nil
 }
 
+func (f *dir) FileModTime(name string) (time.Time, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	file, exists := f.filesByName[name]
+	if !exists {
+		return time.Time{}, fmt.Errorf("file %s does not exist", name)
+	}
+	return file.modTime, nil
+}
+
+func (f *dir) FileSize(name string) (int64, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	file, exists := f.filesByName[name]
+	if !exists {
+		return 0, fmt.Errorf("file %s does not exist", name)
+	}
+	return int64(file.data.Len()), nil
+}
+
 func (f *dir) Files() []*File {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
 	var slice []*File
 	for _, file := range f.filesByName {
 		slice = append(slice, file)
@@ -79,11 +176,121 @@ func (f *dir) Files() []*File {
 	return slice
 }
 
+func (f *dir) History() []Operation {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	return append([]Operation{}, f.fs.history...)
+}
+
+func (f *dir) AssertFileSynced(t testing.TB, name string) {
+	t.Helper()
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	file, exists := f.filesByName[name]
+	if !exists {
+		t.Errorf("file %s does not exist", f.path(name))
+		return
+	}
+	if file.syncedBytes != file.data.Len() {
+		t.Errorf("file %s is not synced: %d of %d bytes synced", f.path(name), file.syncedBytes, file.data.Len())
+	}
+}
+
+func (f *dir) Corrupt(name string) error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	f.fs.record("Corrupt", f.path(name))
+	file, exists := f.filesByName[name]
+	if !exists {
+		return fmt.Errorf("file %s does not exist", name)
+	}
+	data := file.data.Bytes()
+	if len(data) == 0 {
+		file.data.WriteByte(0)
+		return nil
+	}
+	for i := range data {
+		data[i] ^= 0xff
+	}
+	return nil
+}
+
+func (f *dir) Crash() Dir {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	return f.root().copySynced(nil, &filesystem{})
+}
+
+func (f *dir) CrashStrict() Dir {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	root := f.root()
+	return root.copyDurable(root.name, nil, &filesystem{})
+}
+
+func (f *dir) root() *dir {
+	root := f
+	for root.parent != nil {
+		root = root.parent
+	}
+	return root
+}
+
+// copySynced must be called with mutex locked
+func (f *dir) copySynced(parent *dir, fs *filesystem) *dir {
+	d := newDir(f.name, f.missing, parent, fs)
+	for name, file := range f.filesByName {
+		d.filesByName[name] = file.copySynced(name, d)
+	}
+	for name, child := range f.dirsByName {
+		d.dirsByName[name] = child.copySynced(d, fs)
+	}
+	return d
+}
+
+// copyDurable copies entries as of the last SyncDir. Must be called with mutex locked.
+func (f *dir) copyDurable(name string, parent *dir, fs *filesystem) *dir {
+	d := newDir(name, f.missing, parent, fs)
+	for name, file := range f.durableFiles {
+		d.filesByName[name] = file.copySynced(name, d)
+	}
+	for name, child := range f.durableDirs {
+		d.dirsByName[name] = child.copyDurable(name, d, fs)
+	}
+	return d
+}
+
+// SyncDir makes current entries of the dir durable for CrashStrict
+func (f *dir) SyncDir() error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	f.fs.record("SyncDir", f.path(""))
+	if f.missing {
+		return fmt.Errorf("dir %s does not exist", f.name)
+	}
+	f.durableFiles = map[string]*File{}
+	for name, file := range f.filesByName {
+		f.durableFiles[name] = file
+	}
+	f.durableDirs = map[string]*dir{}
+	for name, d := range f.dirsByName {
+		if !d.missing {
+			f.durableDirs[name] = d
+		}
+	}
+	return nil
+}
+
 func (f *dir) Exists() (bool, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
 	return !f.missing, nil
 }
 
 func (f *dir) Mkdir() error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	f.fs.record("Mkdir", f.path(""))
 	if f.parent != nil {
 		if f.parent.missing {
 			return fmt.Errorf("parent dir %s does not exist", f.parent.name)
@@ -94,15 +301,63 @@ func (f *dir) Mkdir() error {
 }
 
 func (f *dir) Dir(name string) deebee.Dir {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
 	d, exists := f.dirsByName[name]
 	if !exists {
-		d = newDir(name, true, f)
+		d = newDir(name, true, f, f.fs)
 		f.dirsByName[name] = d
 	}
 	return d
 }
 
+func (f *dir) Rename(oldName, newName string) error {
+	if oldName == "" || newName == "" {
+		return errors.New("empty file name")
+	}
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	f.fs.history = append(f.fs.history, Operation{Name: "Rename", Path: f.path(oldName), NewPath: f.path(newName)})
+	if d, exists := f.dirsByName[oldName]; exists && !d.missing {
+		return f.renameDir(d, newName)
+	}
+	file, exists := f.filesByName[oldName]
+	if !exists {
+		return fmt.Errorf("file %s does not exist", oldName)
+	}
+	delete(f.filesByName, oldName)
+	file.name = newName
+	f.filesByName[newName] = file
+	return nil
+}
+
+func (f *dir) renameDir(d *dir, newName string) error {
+	if existing, exists := f.dirsByName[newName]; exists && !existing.missing {
+		return fmt.Errorf("dir %s already exists", newName)
+	}
+	delete(f.dirsByName, d.name)
+	d.name = newName
+	f.dirsByName[newName] = d
+	return nil
+}
+
+func (f *dir) DeleteFile(name string) error {
+	if name == "" {
+		return errors.New("empty file name")
+	}
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	f.fs.record("DeleteFile", f.path(name))
+	if _, exists := f.filesByName[name]; !exists {
+		return fmt.Errorf("file %s does not exist", name)
+	}
+	delete(f.filesByName, name)
+	return nil
+}
+
 func (f *dir) ListFiles() ([]string, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
 	if f.missing {
 		return nil, fmt.Errorf("dir %s does not exist", f.name)
 	}
@@ -113,49 +368,134 @@ func (f *dir) ListFiles() ([]string, error) {
 	return files, nil
 }
 
+func (f *dir) ListDirs() ([]string, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	if f.missing {
+		return nil, fmt.Errorf("dir %s does not exist", f.name)
+	}
+	var dirs []string
+	for name, d := range f.dirsByName {
+		if !d.missing {
+			dirs = append(dirs, name)
+		}
+	}
+	return dirs, nil
+}
+
 type File struct {
 	data        bytes.Buffer
 	syncedBytes int
 	name        string
 	closed      bool
+	modTime     time.Time
+	dir         *dir
+}
+
+func (f *File) Name() string {
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
+	return f.name
 }
 
 func (f *File) Empty() bool {
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
 	return f.data.Len() == 0
 }
 
 func (f *File) Closed() bool {
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
 	return f.closed
 }
 
 func (f *File) Data() []byte {
-	return f.data.Bytes()
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
+	return append([]byte{}, f.data.Bytes()...)
 }
 
 func (f *File) Write(p []byte) (n int, err error) {
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
+	f.dir.fs.record("Write", f.dir.path(f.name))
 	if f.closed {
 		return 0, fmt.Errorf("cant write: file %s is closed", f.name)
 	}
+	f.modTime = time.Now()
 	return f.data.Write(p)
 }
 
 func (f *File) Sync() error {
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
+	f.dir.fs.record("Sync", f.dir.path(f.name))
 	f.syncedBytes = f.data.Len()
 	return nil
 }
 
+// copySynced must be called with mutex locked
+func (f *File) copySynced(name string, d *dir) *File {
+	c := &File{
+		name:        name,
+		syncedBytes: f.syncedBytes,
+		closed:      true,
+		modTime:     f.modTime,
+		dir:         d,
+	}
+	c.data.Write(f.data.Bytes()[:f.syncedBytes])
+	return c
+}
+
 func (f *File) SyncedData() []byte {
-	return f.data.Bytes()[:f.syncedBytes]
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
+	return append([]byte{}, f.data.Bytes()[:f.syncedBytes]...)
+}
+
+// ModTime returns the time of the last write, or the time set using SetModTime
+func (f *File) ModTime() time.Time {
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
+	return f.modTime
+}
+
+func (f *File) SetModTime(t time.Time) {
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
+	f.modTime = t
 }
 
 func (f *File) Close() error {
+	f.dir.fs.mutex.Lock()
+	defer f.dir.fs.mutex.Unlock()
+	f.dir.fs.record("Close", f.dir.path(f.name))
 	f.closed = true
 	return nil
 }
 
-func (f *File) Read(p []byte) (n int, err error) {
+type fileReader struct {
+	name   string
+	reader *bytes.Reader
+	closed bool
+}
+
+func (f *fileReader) Read(p []byte) (n int, err error) {
 	if f.closed {
 		return 0, fmt.Errorf("cant read: file %s is closed", f.name)
 	}
-	return f.data.Read(p)
+	return f.reader.Read(p)
+}
+
+func (f *fileReader) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, fmt.Errorf("cant seek: file %s is closed", f.name)
+	}
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *fileReader) Close() error {
+	f.closed = true
+	return nil
 }
@@ -1,6 +1,9 @@
 package fake_test
 
 import (
+	"io/ioutil"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/jacekolszak/deebee"
@@ -138,3 +141,237 @@ func TestDir_Dir(t *testing.T) {
 func TestDir_ListFiles(t *testing.T) {
 	test.TestDir_ListFiles(t, dirs)
 }
+
+func TestDir_Rename(t *testing.T) {
+	test.TestDir_Rename(t, dirs)
+}
+
+func TestDir_ListDirs(t *testing.T) {
+	test.TestDir_ListDirs(t, dirs)
+}
+
+func TestDir_DeleteFile(t *testing.T) {
+	test.TestDir_DeleteFile(t, dirs)
+}
+
+func TestDir_History(t *testing.T) {
+	t.Run("should record operations executed on the tree", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		nested := dir.Dir("nested")
+		require.NoError(t, nested.Mkdir())
+		test.WriteFile(t, nested, "tmp", []byte("data"))
+		require.NoError(t, nested.Rename("tmp", fileName))
+		// when
+		history := dir.History()
+		// then
+		assert.Equal(t, []fake.Operation{
+			{Name: "Mkdir", Path: "nested"},
+			{Name: "FileWriter", Path: "nested/tmp"},
+			{Name: "Write", Path: "nested/tmp"},
+			{Name: "Close", Path: "nested/tmp"},
+			{Name: "Rename", Path: "nested/tmp", NewPath: "nested/test"},
+		}, history)
+	})
+}
+
+func TestDir_AssertFileSynced(t *testing.T) {
+	t.Run("should pass for synced file", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		file, err := dir.FileWriter(fileName)
+		require.NoError(t, err)
+		_, err = file.Write([]byte("data"))
+		require.NoError(t, err)
+		require.NoError(t, file.Sync())
+		// expect
+		dir.AssertFileSynced(t, fileName)
+	})
+
+	t.Run("should report error", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		file, err := dir.FileWriter("not-synced")
+		require.NoError(t, err)
+		_, err = file.Write([]byte("data"))
+		require.NoError(t, err)
+		names := []string{"not-synced", "missing"}
+		for _, name := range names {
+			t.Run(name, func(t *testing.T) {
+				recorder := &errorRecorder{}
+				// when
+				dir.AssertFileSynced(recorder, name)
+				// then
+				assert.True(t, recorder.failed)
+			})
+		}
+	})
+}
+
+func TestDir_Corrupt(t *testing.T) {
+	t.Run("should return error when file does not exist", func(t *testing.T) {
+		err := fake.ExistingDir().Corrupt("missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("should modify data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		for _, data := range [][]byte{{}, []byte("data")} {
+			test.WriteFile(t, dir, string(data)+fileName, data)
+			// when
+			err := dir.Corrupt(string(data) + fileName)
+			// then
+			require.NoError(t, err)
+			reader, err := dir.FileReader(string(data) + fileName)
+			require.NoError(t, err)
+			actual, err := ioutil.ReadAll(reader)
+			require.NoError(t, err)
+			assert.NotEqual(t, data, actual)
+		}
+	})
+}
+
+func TestDir_Crash(t *testing.T) {
+	t.Run("should keep only synced data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		file, err := dir.Dir("nested").FileWriter(fileName)
+		require.NoError(t, err)
+		_, err = file.Write([]byte("synced"))
+		require.NoError(t, err)
+		require.NoError(t, file.Sync())
+		_, err = file.Write([]byte("lost"))
+		require.NoError(t, err)
+		// when
+		crashed := dir.Crash()
+		// then
+		files := crashed.Dir("nested").(fake.Dir).Files()
+		require.Len(t, files, 1)
+		assert.Equal(t, []byte("synced"), files[0].Data())
+		assert.Equal(t, fileName, files[0].Name())
+	})
+
+	t.Run("should copy the whole tree when called on nested dir", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		nested := dir.Dir("nested")
+		require.NoError(t, nested.Mkdir())
+		// when
+		crashed := nested.(fake.Dir).Crash()
+		// then
+		dirs, err := crashed.ListDirs()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"nested"}, dirs)
+	})
+
+	t.Run("should not change the original dir", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		file, err := dir.FileWriter(fileName)
+		require.NoError(t, err)
+		_, err = file.Write([]byte("data"))
+		require.NoError(t, err)
+		crashed := dir.Crash()
+		// when
+		require.NoError(t, crashed.DeleteFile(fileName))
+		// then
+		assert.Equal(t, []byte("data"), dir.Files()[0].Data())
+	})
+}
+
+func TestDir_CrashStrict(t *testing.T) {
+	t.Run("should lose entries created after the last SyncDir", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		require.NoError(t, dir.Dir("synced").Mkdir())
+		test.WriteFile(t, dir, "synced", []byte("data"))
+		require.NoError(t, dir.SyncDir())
+		require.NoError(t, dir.Dir("lost").Mkdir())
+		test.WriteFile(t, dir, "lost", []byte("data"))
+		// when
+		crashed := dir.CrashStrict()
+		// then
+		dirs, err := crashed.ListDirs()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"synced"}, dirs)
+		files, err := crashed.ListFiles()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"synced"}, files)
+	})
+
+	t.Run("should revert rename and delete done after the last SyncDir", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		test.WriteFile(t, dir, "renamed", []byte("data"))
+		test.WriteFile(t, dir, "deleted", []byte("data"))
+		require.NoError(t, dir.SyncDir())
+		require.NoError(t, dir.Rename("renamed", "new"))
+		require.NoError(t, dir.DeleteFile("deleted"))
+		// when
+		crashed := dir.CrashStrict()
+		// then
+		files, err := crashed.ListFiles()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"renamed", "deleted"}, files)
+	})
+
+	t.Run("should keep only synced data of files", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		file, err := dir.FileWriter(fileName)
+		require.NoError(t, err)
+		_, err = file.Write([]byte("synced"))
+		require.NoError(t, err)
+		require.NoError(t, file.Sync())
+		_, err = file.Write([]byte("lost"))
+		require.NoError(t, err)
+		require.NoError(t, dir.SyncDir())
+		// when
+		crashed := dir.CrashStrict()
+		// then
+		files := crashed.(fake.Dir).Files()
+		require.Len(t, files, 1)
+		assert.Equal(t, []byte("synced"), files[0].Data())
+	})
+
+	t.Run("should lose entries of dir created after the last SyncDir of its parent", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		nested := dir.Dir("nested")
+		require.NoError(t, nested.Mkdir())
+		test.WriteFile(t, nested, fileName, []byte("data"))
+		require.NoError(t, nested.(fake.Dir).SyncDir())
+		// when
+		crashed := dir.CrashStrict()
+		// then
+		exists, err := crashed.Dir("nested").Exists()
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestDir_Concurrency(t *testing.T) {
+	t.Run("should be safe for concurrent use", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				nested := dir.Dir(strconv.Itoa(i % 2))
+				_ = nested.Mkdir()
+				if file, err := nested.FileWriter(strconv.Itoa(i)); err == nil {
+					_, _ = file.Write([]byte("data"))
+					_ = file.Close()
+				}
+				_, _ = nested.ListFiles()
+			}(i)
+		}
+		wg.Wait()
+		files, err := dir.Dir("0").ListFiles()
+		require.NoError(t, err)
+		assert.Len(t, files, 5)
+	})
+}
+
+// errorRecorder records whether the test failed
+type errorRecorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *errorRecorder) Helper() {}
+
+func (r *errorRecorder) Errorf(string, ...interface{}) {
+	r.failed = true
+}
@@ -0,0 +1,162 @@
+package deebee
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// WithFileHeader writes the header at the beginning of each new file. The header contains
+// magic number, format version and flags describing how data was written, for example
+// whether the checksum is stored or data is compressed. Files written with the header are
+// read according to their flags, therefore WithChecksum can be added or removed without
+// making them unreadable, and GzipFilter decompresses only data which was compressed.
+//
+// Headers are detected on read no matter if the option is used, and files written
+// without the header are still read as before. Enable the option only when all processes
+// reading the DB run the release which detects headers.
+func WithFileHeader() Option {
+	return func(db *DB) error {
+		db.fileHeader = true
+		return nil
+	}
+}
+
+// fileMagic starts each file written with the header. The first byte is not valid UTF-8,
+// so text data stored in legacy files without the header is never mistaken for one.
+var fileMagic = []byte{0x89, 'D', 'B', 'E'}
+
+const (
+	// fileFormatVersion is the version of the header written to new files
+	fileFormatVersion = 1
+	// fileHeaderSize is the size of the header without metadata: magic, format version,
+	// flags and metadata length
+	fileHeaderSize = 4 + 1 + 1 + 4
+	// maxFileMetadataSize limits metadata, so the corrupted header does not allocate
+	// much memory
+	maxFileMetadataSize = 64 * 1024
+)
+
+// fileFlags describe how the data stored after the header was written
+type fileFlags uint8
+
+const (
+	// checksumFlag is set when the SHA-256 checksum is stored at the end of the file
+	checksumFlag fileFlags = 1 << iota
+	// compressedFlag is set when data was compressed using GzipFilter
+	compressedFlag
+)
+
+// fileHeader is written at the beginning of each file: 4 bytes of magic, 1 byte of format
+// version, 1 byte of flags, 4 bytes of metadata length (big-endian) and metadata. Metadata
+// is not written yet. It is skipped by the reader, so it can be added without breaking
+// older releases.
+type fileHeader struct {
+	version  uint8
+	flags    fileFlags
+	metadata []byte
+}
+
+func (h fileHeader) size() int64 {
+	return int64(fileHeaderSize + len(h.metadata))
+}
+
+func (h fileHeader) write(w io.Writer) error {
+	header := make([]byte, fileHeaderSize, h.size())
+	copy(header, fileMagic)
+	header[4] = h.version
+	header[5] = byte(h.flags)
+	binary.BigEndian.PutUint32(header[6:], uint32(len(h.metadata)))
+	_, err := w.Write(append(header, h.metadata...))
+	return err
+}
+
+// headerFor returns header of the file written using config
+func (c keyConfig) headerFor() fileHeader {
+	header := fileHeader{version: fileFormatVersion}
+	if c.checksum {
+		header.flags |= checksumFlag
+	}
+	for _, filter := range c.filters {
+		if gzip, ok := filter.(gzipFilter); ok && gzip.compress {
+			header.flags |= compressedFlag
+		}
+	}
+	return header
+}
+
+// readFileHeader reads the header from the beginning of the file. Returns false when the
+// file was written without the header. Returned reader is positioned right after the
+// header, or at the beginning of the legacy file. Returned reader is seekable when the
+// file is, with offsets relative to the data after the header.
+func readFileHeader(file io.ReadCloser) (io.ReadCloser, fileHeader, bool, error) {
+	magic := make([]byte, len(fileMagic))
+	n, err := io.ReadFull(file, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fileHeader{}, false, err
+	}
+	if !bytes.Equal(magic[:n], fileMagic) {
+		legacy, err := unread(file, magic[:n])
+		return legacy, fileHeader{}, false, err
+	}
+	rest := make([]byte, fileHeaderSize-len(fileMagic))
+	if _, err = io.ReadFull(file, rest); err != nil {
+		return nil, fileHeader{}, false, &corruptedError{message: "file header is truncated"}
+	}
+	header := fileHeader{version: rest[0], flags: fileFlags(rest[1])}
+	if header.version != fileFormatVersion {
+		return nil, fileHeader{}, false, fmt.Errorf("unsupported file format version %d", header.version)
+	}
+	metadataLen := binary.BigEndian.Uint32(rest[2:])
+	if metadataLen > maxFileMetadataSize {
+		return nil, fileHeader{}, false, &corruptedError{message: "file header metadata is too long"}
+	}
+	header.metadata = make([]byte, metadataLen)
+	if _, err = io.ReadFull(file, header.metadata); err != nil {
+		return nil, fileHeader{}, false, &corruptedError{message: "file header is truncated"}
+	}
+	if seeker, ok := file.(io.Seeker); ok {
+		return &offsetReader{ReadCloser: file, seeker: seeker, offset: header.size()}, header, true, nil
+	}
+	return file, header, true, nil
+}
+
+// unread returns reader of the whole file, including bytes already read from it
+func unread(file io.ReadCloser, read []byte) (io.ReadCloser, error) {
+	if seeker, ok := file.(io.Seeker); ok {
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+	return &transformedReader{Reader: io.MultiReader(bytes.NewReader(read), file), closer: file}, nil
+}
+
+// offsetReader hides the header of the file from Seek
+type offsetReader struct {
+	io.ReadCloser
+	seeker io.Seeker
+	offset int64
+}
+
+func (r *offsetReader) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, r.ReadCloser)
+}
+
+func (r *offsetReader) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		offset += r.offset
+	}
+	position, err := r.seeker.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	if position < r.offset {
+		if _, err = r.seeker.Seek(r.offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("seek before the beginning of data")
+	}
+	return position - r.offset, nil
+}
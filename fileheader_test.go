@@ -0,0 +1,169 @@
+package deebee_test
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithFileHeader(t *testing.T) {
+	t.Run("should read data written with header", func(t *testing.T) {
+		options := map[string][]deebee.Option{
+			"no options": nil,
+			"checksum":   {deebee.WithChecksum()},
+			"gzip":       {deebee.WithFilter(deebee.GzipFilter())},
+		}
+		for name, opts := range options {
+			t.Run(name, func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), append(opts, deebee.WithFileHeader())...)
+				writeData(t, db, "key", []byte("data"))
+				// when
+				actual := readData(t, db, "key")
+				// then
+				assert.Equal(t, []byte("data"), actual)
+			})
+		}
+	})
+
+	t.Run("should store header before the data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithFileHeader())
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		files := dir.Dir("key").(fake.Dir).Files()
+		require.Len(t, files, 1)
+		expected := []byte{0x89, 'D', 'B', 'E', 1, 0, 0, 0, 0, 0, 'd', 'a', 't', 'a'}
+		assert.Equal(t, expected, files[0].Data())
+	})
+
+	t.Run("should store checksum flag", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithFileHeader(), deebee.WithChecksum())
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		files := dir.Dir("key").(fake.Dir).Files()
+		require.Len(t, files, 1)
+		assert.Equal(t, byte(1), files[0].Data()[5])
+	})
+
+	t.Run("should read uncompressed data starting with gzip magic bytes", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		data := []byte{0x1f, 0x8b, 'd', 'a', 't', 'a'}
+		writeData(t, openDB(t, dir, deebee.WithFileHeader()), "key", data)
+		db := openDB(t, dir, deebee.WithFilter(deebee.GzipFilter()))
+		// when
+		actual := readData(t, db, "key")
+		// then
+		assert.Equal(t, data, actual)
+	})
+
+	t.Run("should return client error when compressed data is read without gzip filter", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir, deebee.WithFileHeader(), deebee.WithFilter(deebee.GzipFilter())), "key", []byte("data"))
+		db := openDB(t, dir)
+		// when
+		_, err := db.Reader("key")
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should read files written without header", func(t *testing.T) {
+		tests := map[string][]byte{
+			"empty":         {},
+			"short":         {0x89},
+			"part of magic": {0x89, 'D', 'B'},
+			"data":          []byte("data"),
+		}
+		for name, data := range tests {
+			t.Run(name, func(t *testing.T) {
+				dir := fake.ExistingDir()
+				writeData(t, openDB(t, dir), "key", data)
+				db := openDB(t, dir, deebee.WithFileHeader())
+				// when
+				actual := readData(t, db, "key")
+				// then
+				assert.Equal(t, data, actual)
+			})
+		}
+	})
+
+	t.Run("should read files with header by DB opened without the option", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir, deebee.WithFileHeader()), "key", []byte("data"))
+		db := openDB(t, dir)
+		// when
+		actual := readData(t, db, "key")
+		// then
+		assert.Equal(t, []byte("data"), actual)
+	})
+
+	t.Run("should verify checksum according to the header", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir, deebee.WithFileHeader(), deebee.WithChecksum()), "with", []byte("data1"))
+		writeData(t, openDB(t, dir, deebee.WithFileHeader()), "without", []byte("data2"))
+		corruptLatest(t, dir, "with")
+		// when
+		db := openDB(t, dir, deebee.WithChecksum())
+		// then
+		reader, err := db.Reader("with")
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(reader)
+		assert.True(t, deebee.IsCorrupted(err))
+		// and
+		assert.Equal(t, []byte("data2"), readData(t, openDB(t, dir, deebee.WithChecksum()), "without"))
+	})
+
+	t.Run("should skip metadata", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		raw := []byte{0x89, 'D', 'B', 'E', 1, 0, 0, 0, 0, 2, 'm', 'd', 'd', 'a', 't', 'a'}
+		writeData(t, openDB(t, dir), "key", raw)
+		db := openDB(t, dir)
+		// when
+		actual := readData(t, db, "key")
+		// then
+		assert.Equal(t, []byte("data"), actual)
+	})
+
+	t.Run("should return error for unsupported format version", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte{0x89, 'D', 'B', 'E', 2, 0, 0, 0, 0, 0})
+		db := openDB(t, dir)
+		// when
+		_, err := db.Reader("key")
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should return corruption error for truncated header", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte{0x89, 'D', 'B', 'E', 1})
+		db := openDB(t, dir)
+		// when
+		_, err := db.Reader("key")
+		// then
+		assert.True(t, deebee.IsCorrupted(err))
+	})
+
+	t.Run("should seek data after the header", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t), deebee.WithFileHeader())
+		writeData(t, db, "key", []byte("data"))
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		defer reader.Close()
+		// when
+		position, err := reader.Seek(2, io.SeekStart)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), position)
+		actual, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("ta"), actual)
+	})
+}
@@ -0,0 +1,71 @@
+package deebee
+
+import (
+	"errors"
+	"io"
+)
+
+// Filter transforms data on its way to and from the Dir. Examples are compression,
+// encryption or checksum calculation.
+//
+// Filters are applied in the order they were added with WithFilter. Data written by
+// the application goes through the first filter, then the second one and so on
+// until it reaches the file. Data read from the file goes the opposite way.
+type Filter interface {
+	// Writer wraps w. Closing returned writer must flush all data and close w.
+	Writer(w io.WriteCloser) (io.WriteCloser, error)
+	// Reader wraps r. Closing returned reader must close r.
+	Reader(r io.ReadCloser) (io.ReadCloser, error)
+}
+
+// WithFilter adds filter at the end of the filter chain
+func WithFilter(filter Filter) Option {
+	return func(db *DB) error {
+		if filter == nil {
+			return errors.New("nil filter")
+		}
+		db.filters = append(db.filters, filter)
+		return nil
+	}
+}
+
+func (c keyConfig) filterWriter(w io.WriteCloser) (io.WriteCloser, error) {
+	for i := len(c.filters) - 1; i >= 0; i-- {
+		var err error
+		w, err = c.filters[i].Writer(w)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// headerFilterReader works like filterReader, but gzip filters decompress data only when
+// the header has compressedFlag
+func (c keyConfig) headerFilterReader(r io.ReadCloser, header fileHeader) (io.ReadCloser, error) {
+	compressed := header.flags&compressedFlag != 0
+	filters := make([]Filter, len(c.filters))
+	decompressed := false
+	for i, filter := range c.filters {
+		if gzip, ok := filter.(gzipFilter); ok {
+			filter = headerGzipFilter{gzipFilter: gzip, compressed: compressed}
+			decompressed = true
+		}
+		filters[i] = filter
+	}
+	if compressed && !decompressed {
+		return nil, newClientError("data is compressed, use GzipFilter or DecompressFilter to read it")
+	}
+	return keyConfig{filters: filters}.filterReader(r)
+}
+
+func (c keyConfig) filterReader(r io.ReadCloser) (io.ReadCloser, error) {
+	for i := len(c.filters) - 1; i >= 0; i-- {
+		var err error
+		r, err = c.filters[i].Reader(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
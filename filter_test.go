@@ -0,0 +1,123 @@
+package deebee_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithFilter(t *testing.T) {
+	t.Run("should return error for nil filter", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithFilter(nil))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("should apply filters in order when writing", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithFilter(prefixFilter("A")), deebee.WithFilter(prefixFilter("B")))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		files := dir.Dir("key").(fake.Dir).Files()
+		require.Len(t, files, 1)
+		assert.Equal(t, []byte("BAdata"), files[0].Data())
+	})
+
+	t.Run("should read data written with filters", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(prefixFilter("A")), deebee.WithFilter(prefixFilter("B")))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		actual := readData(t, db, "key")
+		// then
+		assert.Equal(t, []byte("data"), actual)
+	})
+
+	t.Run("should return error when filter failed to create writer", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(failingFilter{}))
+		// when
+		writer, err := db.Writer("key")
+		// then
+		assert.Error(t, err)
+		assert.Nil(t, writer)
+	})
+
+	t.Run("should return error when filter failed to create reader", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, dir, deebee.WithFilter(failingFilter{}))
+		// when
+		reader, err := db.Reader("key")
+		// then
+		assert.Error(t, err)
+		assert.Nil(t, reader)
+	})
+
+	t.Run("should not commit data when filter failed on Close", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(failingCloseFilter{}))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		assert.Error(t, err)
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+}
+
+// prefixFilter writes prefix before the data and verifies it on read
+type prefixFilter string
+
+func (f prefixFilter) Writer(w io.WriteCloser) (io.WriteCloser, error) {
+	if _, err := w.Write([]byte(f)); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (f prefixFilter) Reader(r io.ReadCloser) (io.ReadCloser, error) {
+	prefix := make([]byte, len(f))
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(prefix, []byte(f)) {
+		return nil, errors.New("invalid prefix")
+	}
+	return r, nil
+}
+
+type failingFilter struct{}
+
+func (f failingFilter) Writer(io.WriteCloser) (io.WriteCloser, error) {
+	return nil, errors.New("writer failed")
+}
+
+func (f failingFilter) Reader(io.ReadCloser) (io.ReadCloser, error) {
+	return nil, errors.New("reader failed")
+}
+
+type failingCloseFilter struct{}
+
+func (f failingCloseFilter) Writer(w io.WriteCloser) (io.WriteCloser, error) {
+	return failingCloser{w}, nil
+}
+
+func (f failingCloseFilter) Reader(r io.ReadCloser) (io.ReadCloser, error) {
+	return ioutil.NopCloser(r), nil
+}
+
+type failingCloser struct {
+	io.Writer
+}
+
+func (failingCloser) Close() error {
+	return errors.New("close failed")
+}
@@ -0,0 +1,179 @@
+//go:build go1.16
+// +build go1.16
+
+package deebee
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// FS returns read-only view of the DB. Each key with at least one committed version
+// is represented as a file in the root directory. Reading the file returns the latest
+// version of the state.
+func (s *DB) FS() fs.FS {
+	return dbFS{db: s}
+}
+
+type dbFS struct {
+	db *DB
+}
+
+func (f dbFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		keys, err := f.keys()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: f.db.redact(err)}
+		}
+		return &rootFile{fs: f, keys: keys}, nil
+	}
+	reader, err := f.db.Reader(name)
+	if IsDataNotFound(err) || IsClientError(err) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &stateFile{name: name, reader: reader}, nil
+}
+
+func (f dbFS) keys() ([]string, error) {
+	return f.db.keys()
+}
+
+type stateFile struct {
+	name   string
+	reader *Reader
+}
+
+func (f *stateFile) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+func (f *stateFile) Close() error {
+	return f.reader.Close()
+}
+
+// Seek is supported only when underlying Dir returns seekable readers
+func (f *stateFile) Seek(offset int64, whence int) (int64, error) {
+	if !f.reader.seekable() {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errors.New("seek not supported")}
+	}
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *stateFile) Stat() (fs.FileInfo, error) {
+	info := fileInfo{name: f.name, mode: 0444}
+	if f.reader.seekable() {
+		current, err := f.reader.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		info.size, err = f.reader.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = f.reader.Seek(current, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+type rootFile struct {
+	fs     dbFS
+	keys   []string
+	offset int
+}
+
+func (f *rootFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: errors.New("is a directory")}
+}
+
+func (f *rootFile) Close() error {
+	return nil
+}
+
+func (f *rootFile) Stat() (fs.FileInfo, error) {
+	return fileInfo{name: ".", mode: fs.ModeDir | 0555}, nil
+}
+
+func (f *rootFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := f.keys[f.offset:]
+	if n > 0 {
+		if len(remaining) == 0 {
+			return nil, io.EOF
+		}
+		if len(remaining) > n {
+			remaining = remaining[:n]
+		}
+	}
+	entries := make([]fs.DirEntry, len(remaining))
+	for i, key := range remaining {
+		entries[i] = dirEntry{fs: f.fs, name: key}
+	}
+	f.offset += len(remaining)
+	return entries, nil
+}
+
+type dirEntry struct {
+	fs   dbFS
+	name string
+}
+
+func (e dirEntry) Name() string {
+	return e.name
+}
+
+func (e dirEntry) IsDir() bool {
+	return false
+}
+
+func (e dirEntry) Type() fs.FileMode {
+	return 0
+}
+
+func (e dirEntry) Info() (fs.FileInfo, error) {
+	file, err := e.fs.Open(e.name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return file.Stat()
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (i fileInfo) Name() string {
+	return i.name
+}
+
+func (i fileInfo) Size() int64 {
+	return i.size
+}
+
+func (i fileInfo) Mode() fs.FileMode {
+	return i.mode
+}
+
+// ModTime returns zero time, because modification time is not tracked
+func (i fileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (i fileInfo) IsDir() bool {
+	return i.mode.IsDir()
+}
+
+func (i fileInfo) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,76 @@
+//go:build go1.16
+// +build go1.16
+
+package deebee_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_FS(t *testing.T) {
+	dirs := map[string]deebee.Dir{
+		"fake": fake.ExistingDir(),
+		"os":   existingRootDir(t),
+	}
+	for name, dir := range dirs {
+
+		t.Run(name, func(t *testing.T) {
+
+			t.Run("should pass fstest", func(t *testing.T) {
+				db := openDB(t, dir)
+				writeData(t, db, "a", []byte("data"))
+				writeData(t, db, "b", []byte("old"))
+				writeData(t, db, "b", []byte("new"))
+				writeData(t, db, "empty", []byte{})
+				// expect
+				err := fstest.TestFS(db.FS(), "a", "b", "empty")
+				assert.NoError(t, err)
+			})
+
+			t.Run("should read latest version", func(t *testing.T) {
+				db := openDB(t, dir)
+				writeData(t, db, "key", []byte("old"))
+				writeData(t, db, "key", []byte("new"))
+				// when
+				data, err := fs.ReadFile(db.FS(), "key")
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, []byte("new"), data)
+			})
+		})
+	}
+
+	t.Run("should return ErrNotExist for missing and invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, name := range []string{"missing", "a/b", "a b "} {
+			_, err := db.FS().Open(name)
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+		}
+	})
+
+	t.Run("should not list keys without committed version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writer, err := db.Writer("pending")
+		require.NoError(t, err)
+		defer writer.Close()
+		// when
+		entries, err := fs.ReadDir(db.FS(), ".")
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("should return error when ListDirs failed", func(t *testing.T) {
+		db := openDB(t, failing.ListDirs(fake.ExistingDir()))
+		_, err := fs.ReadDir(db.FS(), ".")
+		assert.Error(t, err)
+	})
+}
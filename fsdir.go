@@ -0,0 +1,93 @@
+//go:build go1.16
+// +build go1.16
+
+package deebee
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+)
+
+var errReadOnly = errors.New("read-only dir")
+
+// FSDir returns read-only Dir backed by fs.FS. All methods modifying the filesystem
+// return error. Useful for reading states embedded in the binary or served by any
+// fs.FS implementation.
+func FSDir(fsys fs.FS) Dir {
+	return fsDir{fsys: fsys, path: "."}
+}
+
+type fsDir struct {
+	fsys fs.FS
+	path string
+}
+
+func (d fsDir) FileReader(name string) (io.ReadCloser, error) {
+	if name == "" {
+		return nil, errors.New("empty file name")
+	}
+	return d.fsys.Open(path.Join(d.path, name))
+}
+
+func (d fsDir) FileWriter(string) (FileWriter, error) {
+	return nil, errReadOnly
+}
+
+// Mkdir does nothing when dir exists, because fs.FS can't create directories
+func (d fsDir) Mkdir() error {
+	exists, err := d.Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errReadOnly
+	}
+	return nil
+}
+
+func (d fsDir) Dir(name string) Dir {
+	return fsDir{fsys: d.fsys, path: path.Join(d.path, name)}
+}
+
+func (d fsDir) Exists() (bool, error) {
+	info, err := fs.Stat(d.fsys, d.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+func (d fsDir) ListFiles() ([]string, error) {
+	return d.list(false)
+}
+
+func (d fsDir) ListDirs() ([]string, error) {
+	return d.list(true)
+}
+
+func (d fsDir) list(dirs bool) ([]string, error) {
+	entries, err := fs.ReadDir(d.fsys, d.path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() == dirs {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (d fsDir) Rename(string, string) error {
+	return errReadOnly
+}
+
+func (d fsDir) DeleteFile(string) error {
+	return errReadOnly
+}
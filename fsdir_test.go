@@ -0,0 +1,61 @@
+//go:build go1.16
+// +build go1.16
+
+package deebee_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"state/0":     {Data: []byte("old")},
+		"state/1":     {Data: []byte("new")},
+		"state/2.tmp": {Data: []byte("not committed")},
+		"other/0":     {Data: []byte("other")},
+	}
+
+	t.Run("should read latest version", func(t *testing.T) {
+		db := openDB(t, deebee.FSDir(fsys))
+		actual := readData(t, db, "state")
+		assert.Equal(t, []byte("new"), actual)
+	})
+
+	t.Run("should return error when writing", func(t *testing.T) {
+		db := openDB(t, deebee.FSDir(fsys))
+		writer, err := db.Writer("state")
+		assert.Error(t, err)
+		assert.Nil(t, writer)
+	})
+
+	t.Run("should return data not found for missing key", func(t *testing.T) {
+		db := openDB(t, deebee.FSDir(fsys))
+		_, err := db.Reader("missing")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should list files and dirs", func(t *testing.T) {
+		dir := deebee.FSDir(fsys)
+		dirs, err := dir.ListDirs()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"state", "other"}, dirs)
+		files, err := dir.Dir("state").ListFiles()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"0", "1", "2.tmp"}, files)
+	})
+
+	t.Run("Mkdir should do nothing for existing dir", func(t *testing.T) {
+		err := deebee.FSDir(fsys).Dir("state").Mkdir()
+		assert.NoError(t, err)
+	})
+
+	t.Run("Mkdir should return error for missing dir", func(t *testing.T) {
+		err := deebee.FSDir(fsys).Dir("missing").Mkdir()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,12 @@
+package deebee
+
+// GetOrDefault returns the latest data of the key, or def when the state does not exist or
+// was deleted. Useful for reading the state on startup, when it may not be written yet.
+// Other errors, such as corruption, are returned.
+func (s *DB) GetOrDefault(key string, def []byte) ([]byte, error) {
+	data, err := s.readAll(key)
+	if IsDataNotFound(err) {
+		return def, nil
+	}
+	return data, err
+}
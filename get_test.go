@@ -0,0 +1,55 @@
+package deebee_test
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_GetOrDefault(t *testing.T) {
+	t.Run("should return default for missing and deleted states", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "deleted", []byte("data"))
+		require.NoError(t, db.Delete("deleted"))
+		for _, key := range []string{"missing", "deleted"} {
+			// when
+			data, err := db.GetOrDefault(key, []byte("default"))
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []byte("default"), data)
+		}
+	})
+
+	t.Run("should return latest data", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		// when
+		data, err := db.GetOrDefault("key", []byte("default"))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), data)
+	})
+
+	t.Run("should return error for invalid key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		data, err := db.GetOrDefault("/", []byte("default"))
+		// then
+		assert.Nil(t, data)
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should return error when reading failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, dir, deebee.WithFilter(failingReadFilter{}))
+		// when
+		_, err := db.GetOrDefault("key", []byte("default"))
+		// then
+		assert.Error(t, err)
+	})
+}
@@ -3,7 +3,9 @@ module github.com/jacekolszak/deebee
 go 1.15
 
 require (
-	github.com/stretchr/objx v0.3.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/stretchr/testify v1.7.0
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	golang.org/x/sys v0.0.0-20210603081109-ebe580a85c40
+	golang.org/x/text v0.3.3
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
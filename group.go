@@ -0,0 +1,93 @@
+package deebee
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// FilesystemSyncer is an optional interface which can be implemented by Dir. It flushes
+// all data written to the filesystem containing the dir with one call, such as syncfs on
+// Linux. Used by WithGroupCommit.
+type FilesystemSyncer interface {
+	// SyncFilesystem makes all data written to the filesystem durable
+	SyncFilesystem() error
+}
+
+// WithGroupCommit coalesces syncs of files committed by concurrent writers. The first
+// writer being closed starts the window. When Dir implements FilesystemSyncer, such as
+// OsDir on Linux, all files closed within the window are made durable by one filesystem
+// flush. Otherwise they are synced one by one, concurrently, so the option only adds the
+// window to the latency of Close. Close of each writer returns only after its file is
+// durable.
+//
+// Useful when many small states are written in bursts. The filesystem flush writes all
+// dirty data of the filesystem, including data of other processes, therefore it pays off
+// only when the batch is big.
+func WithGroupCommit(window time.Duration) Option {
+	return func(db *DB) error {
+		if window <= 0 {
+			return errors.New("group commit window must be positive")
+		}
+		db.groupCommit = &groupCommit{window: window}
+		return nil
+	}
+}
+
+type groupCommit struct {
+	window time.Duration
+	mutex  sync.Mutex
+	batch  []syncRequest
+}
+
+type syncRequest struct {
+	dir    Dir
+	file   FileWriter
+	result chan error
+}
+
+// sync adds file stored in dir to the current batch and waits until the batch is synced
+func (g *groupCommit) sync(dir Dir, file FileWriter) error {
+	result := make(chan error, 1)
+	g.mutex.Lock()
+	g.batch = append(g.batch, syncRequest{dir: dir, file: file, result: result})
+	if len(g.batch) == 1 {
+		time.AfterFunc(g.window, g.syncBatch)
+	}
+	g.mutex.Unlock()
+	return <-result
+}
+
+func (g *groupCommit) syncBatch() {
+	g.mutex.Lock()
+	batch := g.batch
+	g.batch = nil
+	g.mutex.Unlock()
+
+	if syncer, ok := filesystemSyncer(batch); ok {
+		err := syncer.SyncFilesystem()
+		for _, request := range batch {
+			request.result <- err
+		}
+		return
+	}
+	for _, request := range batch {
+		go func(request syncRequest) {
+			request.result <- request.file.Sync()
+		}(request)
+	}
+}
+
+// filesystemSyncer returns FilesystemSyncer flushing all files of the batch, when dirs
+// of all files implement it
+func filesystemSyncer(batch []syncRequest) (FilesystemSyncer, bool) {
+	var syncer FilesystemSyncer
+	for _, request := range batch {
+		s, ok := request.dir.(FilesystemSyncer)
+		if !ok {
+			return nil, false
+		}
+		syncer = s
+	}
+	return syncer, syncer != nil
+}
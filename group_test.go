@@ -0,0 +1,114 @@
+package deebee_test
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithGroupCommit(t *testing.T) {
+	t.Run("should return error for non-positive window", func(t *testing.T) {
+		for _, window := range []time.Duration{0, -1} {
+			db, err := deebee.Open(fake.ExistingDir(), deebee.WithGroupCommit(window))
+			assert.Error(t, err)
+			assert.Nil(t, db)
+		}
+	})
+
+	t.Run("should commit data written by concurrent writers", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t), deebee.WithGroupCommit(10*time.Millisecond))
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				writeData(t, db, fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("data%d", i)))
+			}(i)
+		}
+		wg.Wait()
+		// expect
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, []byte(fmt.Sprintf("data%d", i)), readData(t, db, fmt.Sprintf("key%d", i)))
+		}
+	})
+
+	t.Run("should flush the batch once when dir implements FilesystemSyncer", func(t *testing.T) {
+		dir := newFilesystemSyncingDir(fake.ExistingDir())
+		db := openDB(t, dir, deebee.WithGroupCommit(100*time.Millisecond))
+		var writers []io.WriteCloser
+		for i := 0; i < 10; i++ {
+			writer, err := db.Writer(fmt.Sprintf("key%d", i))
+			require.NoError(t, err)
+			writers = append(writers, writer)
+		}
+		var wg sync.WaitGroup
+		// when
+		for _, writer := range writers {
+			wg.Add(1)
+			go func(writer io.WriteCloser) {
+				defer wg.Done()
+				assert.NoError(t, writer.Close())
+			}(writer)
+		}
+		wg.Wait()
+		// then
+		assert.Equal(t, int32(1), atomic.LoadInt32(dir.calls))
+	})
+
+	t.Run("Close should wait for the window", func(t *testing.T) {
+		window := 50 * time.Millisecond
+		db := openDB(t, fake.ExistingDir(), deebee.WithGroupCommit(window))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		start := time.Now()
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(window))
+	})
+}
+
+// filesystemSyncingDir counts SyncFilesystem calls made on the whole tree of dirs
+type filesystemSyncingDir struct {
+	next  deebee.Dir
+	calls *int32
+}
+
+func newFilesystemSyncingDir(next deebee.Dir) filesystemSyncingDir {
+	return filesystemSyncingDir{next: next, calls: new(int32)}
+}
+
+func (d filesystemSyncingDir) Dir(name string) deebee.Dir {
+	return filesystemSyncingDir{next: d.next.Dir(name), calls: d.calls}
+}
+
+func (d filesystemSyncingDir) SyncFilesystem() error {
+	atomic.AddInt32(d.calls, 1)
+	return nil
+}
+
+func (d filesystemSyncingDir) Mkdir() error                 { return d.next.Mkdir() }
+func (d filesystemSyncingDir) Exists() (bool, error)        { return d.next.Exists() }
+func (d filesystemSyncingDir) ListFiles() ([]string, error) { return d.next.ListFiles() }
+func (d filesystemSyncingDir) ListDirs() ([]string, error)  { return d.next.ListDirs() }
+func (d filesystemSyncingDir) DeleteFile(name string) error { return d.next.DeleteFile(name) }
+func (d filesystemSyncingDir) Rename(oldName, newName string) error {
+	return d.next.Rename(oldName, newName)
+}
+
+func (d filesystemSyncingDir) FileReader(name string) (io.ReadCloser, error) {
+	return d.next.FileReader(name)
+}
+
+func (d filesystemSyncingDir) FileWriter(name string) (deebee.FileWriter, error) {
+	return d.next.FileWriter(name)
+}
@@ -0,0 +1,100 @@
+package deebee
+
+import (
+	"io"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+// HandleKind is the kind of the handle returned by OpenHandles
+type HandleKind string
+
+const (
+	ReaderHandle HandleKind = "reader"
+	WriterHandle HandleKind = "writer"
+)
+
+// Handle is a reader or writer which was created and not closed yet
+type Handle struct {
+	Kind HandleKind
+	Key  string
+	// Stack is the stack trace of the goroutine which created the handle
+	Stack string
+}
+
+// WithHandleTracking records readers and writers until they are closed, together with
+// stack traces of their creation. Use OpenHandles or deebeetest.VerifyNoLeaks to find
+// handles which were never closed. Capturing stack traces is slow, therefore the option
+// is meant for tests only.
+func WithHandleTracking() Option {
+	return func(db *DB) error {
+		db.handles = &openHandles{handles: map[int]Handle{}}
+		return nil
+	}
+}
+
+// OpenHandles returns readers and writers which were not closed yet, in the order of
+// creation. Returns client error when DB was opened without WithHandleTracking.
+func (s *DB) OpenHandles() ([]Handle, error) {
+	if s.handles == nil {
+		return nil, newClientError("handle tracking is not enabled, use WithHandleTracking")
+	}
+	return s.handles.list(), nil
+}
+
+// openHandles is a registry of readers and writers which were not closed yet. Nil
+// registry does not track anything.
+type openHandles struct {
+	mutex   sync.Mutex
+	next    int
+	handles map[int]Handle
+}
+
+// track registers the handle. Returned function unregisters it and can be called many times.
+func (h *openHandles) track(kind HandleKind, key string) (untrack func()) {
+	if h == nil {
+		return func() {}
+	}
+	stack := string(debug.Stack())
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	id := h.next
+	h.next++
+	h.handles[id] = Handle{Kind: kind, Key: key, Stack: stack}
+	return func() {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		delete(h.handles, id)
+	}
+}
+
+func (h *openHandles) list() []Handle {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	ids := make([]int, 0, len(h.handles))
+	for id := range h.handles {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	handles := make([]Handle, len(ids))
+	for i, id := range ids {
+		handles[i] = h.handles[id]
+	}
+	return handles
+}
+
+// trackedReader unregisters the handle on Close
+type trackedReader struct {
+	io.ReadCloser
+	untrack func()
+}
+
+func (r *trackedReader) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, r.ReadCloser)
+}
+
+func (r *trackedReader) Close() error {
+	r.untrack()
+	return r.ReadCloser.Close()
+}
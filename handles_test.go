@@ -0,0 +1,56 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_OpenHandles(t *testing.T) {
+	t.Run("should return client error when handle tracking is not enabled", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		_, err := db.OpenHandles()
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should return handles in the order of creation", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithHandleTracking())
+		writeData(t, db, "key", []byte("data"))
+		_, err := db.Reader("key")
+		require.NoError(t, err)
+		_, err = db.Writer("other")
+		require.NoError(t, err)
+		// when
+		handles, err := db.OpenHandles()
+		// then
+		require.NoError(t, err)
+		require.Len(t, handles, 2)
+		assert.Equal(t, deebee.ReaderHandle, handles[0].Kind)
+		assert.Equal(t, "key", handles[0].Key)
+		assert.Equal(t, deebee.WriterHandle, handles[1].Kind)
+		assert.Equal(t, "other", handles[1].Key)
+		assert.NotEmpty(t, handles[1].Stack)
+	})
+
+	t.Run("should track write-behind writers", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithHandleTracking(), deebee.WithWriteBehind(time.Hour))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		// when
+		handles, err := db.OpenHandles()
+		// then
+		require.NoError(t, err)
+		assert.Len(t, handles, 1)
+		// and
+		require.NoError(t, writer.Close())
+		handles, err = db.OpenHandles()
+		require.NoError(t, err)
+		assert.Empty(t, handles)
+	})
+}
@@ -0,0 +1,79 @@
+package deebee
+
+import (
+	"io/ioutil"
+)
+
+// internalDirName is the name of the dir inside the DB dir containing internal files, such
+// as leases and applied migrations. Key validation prevents applications from using it.
+const internalDirName = ".deebee"
+
+func (s *DB) internalDir() Dir {
+	return s.dir.Dir(internalDirName)
+}
+
+// InternalFiles returns contents of internal files of the DB, such as leases and IDs of
+// applied migrations, by their names. It is meant for debugging only, the format of files
+// may change between releases.
+func (s *DB) InternalFiles() (_ map[string][]byte, err error) {
+	defer s.redactError(&err)
+	names, err := s.internalFiles()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte, len(names))
+	for _, name := range names {
+		content, err := s.readInternalFile(name)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+	return files, nil
+}
+
+// internalFiles returns names of internal files. Returns nil when the internal dir was not
+// created yet.
+func (s *DB) internalFiles() ([]string, error) {
+	exists, err := s.internalDir().Exists()
+	if err != nil || !exists {
+		return nil, err
+	}
+	return s.internalDir().ListFiles()
+}
+
+func (s *DB) readInternalFile(name string) ([]byte, error) {
+	reader, err := s.internalDir().FileReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// writeInternalFile replaces the internal file atomically, by renaming the temp file
+func (s *DB) writeInternalFile(name string, content []byte) error {
+	dir := s.internalDir()
+	if err := dir.Mkdir(); err != nil {
+		return err
+	}
+	temp := name + tempSuffix
+	_ = dir.DeleteFile(temp) // left by a crash
+	file, err := dir.FileWriter(temp)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		_ = file.Close()
+		_ = dir.DeleteFile(temp)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return dir.Rename(temp, name)
+}
@@ -0,0 +1,66 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_InternalFiles(t *testing.T) {
+	t.Run("should return no files for new DB", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		files, err := db.InternalFiles()
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("should return leases and applied migrations", func(t *testing.T) {
+		migrator := &deebee.Migrator{}
+		migrator.Register("1", func(db *deebee.DB) error { return nil })
+		db := openDB(t, fake.ExistingDir(), deebee.WithMigrator(migrator))
+		_, err := db.TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// when
+		files, err := db.InternalFiles()
+		// then
+		require.NoError(t, err)
+		assert.Len(t, files, 2)
+		assert.Equal(t, []byte("1"), files["migrations"])
+		assert.Contains(t, files, "leader.1.lease")
+	})
+
+	t.Run("should not make internal files a state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		_, err := db.TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// when
+		keys, err := db.List("")
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("should not allow hierarchical keys inside internal dir", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithHierarchicalKeys())
+		// when
+		_, err := db.Writer(".deebee/migrations")
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should open empty dir with internal files using sharded layout", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		_, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// when
+		_, err = deebee.Open(dir, deebee.WithShardedLayout())
+		// then
+		assert.NoError(t, err)
+	})
+}
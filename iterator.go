@@ -0,0 +1,27 @@
+package deebee
+
+// FileIterator is an optional interface which can be implemented by Dir. It lists files
+// without loading all names into memory at once, which matters for dirs containing
+// tens of thousands of files or dirs stored on object stores returning paged results.
+type FileIterator interface {
+	// ListFilesIter calls fn for each file excluding directories. Iteration is stopped
+	// when fn returns false.
+	ListFilesIter(fn func(name string) bool) error
+}
+
+// iterateFiles uses FileIterator when implemented by dir, otherwise ListFiles
+func iterateFiles(dir Dir, fn func(name string) bool) error {
+	if iterator, ok := dir.(FileIterator); ok {
+		return iterator.ListFilesIter(fn)
+	}
+	files, err := dir.ListFiles()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !fn(file) {
+			return nil
+		}
+	}
+	return nil
+}
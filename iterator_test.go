@@ -0,0 +1,78 @@
+package deebee_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileIterator(t *testing.T) {
+	t.Run("should be used by DB instead of ListFiles", func(t *testing.T) {
+		dir := iteratingDir{decorated: fake.ExistingDir()}
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		// when
+		actual := readData(t, db, "key")
+		// then
+		assert.Equal(t, []byte("new"), actual)
+	})
+}
+
+// iteratingDir implements deebee.FileIterator and fails on ListFiles
+type iteratingDir struct {
+	decorated deebee.Dir
+}
+
+func (d iteratingDir) FileReader(name string) (io.ReadCloser, error) {
+	return d.decorated.FileReader(name)
+}
+
+func (d iteratingDir) FileWriter(name string) (deebee.FileWriter, error) {
+	return d.decorated.FileWriter(name)
+}
+
+func (d iteratingDir) Mkdir() error {
+	return d.decorated.Mkdir()
+}
+
+func (d iteratingDir) Exists() (bool, error) {
+	return d.decorated.Exists()
+}
+
+func (d iteratingDir) ListDirs() ([]string, error) {
+	return d.decorated.ListDirs()
+}
+
+func (d iteratingDir) DeleteFile(name string) error {
+	return d.decorated.DeleteFile(name)
+}
+
+func (d iteratingDir) Rename(oldName, newName string) error {
+	return d.decorated.Rename(oldName, newName)
+}
+
+func (d iteratingDir) ListFiles() ([]string, error) {
+	return nil, errors.New("ListFiles should not be used")
+}
+
+func (d iteratingDir) ListFilesIter(fn func(name string) bool) error {
+	files, err := d.decorated.ListFiles()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !fn(file) {
+			break
+		}
+	}
+	return nil
+}
+
+func (d iteratingDir) Dir(name string) deebee.Dir {
+	return iteratingDir{decorated: d.decorated.Dir(name)}
+}
@@ -0,0 +1,131 @@
+package deebee
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithTempFileCleanup removes temp files of versions which were never committed, for example
+// because the process crashed while writing. Temp files not modified for longer than maxAge
+// are removed on Open and then every interval, until DB is closed. Zero interval means
+// that files are removed on Open only. Dir must implement FileModTimer.
+//
+// maxAge must be longer than the time between writes to any writer, otherwise the temp file
+// of a writer which is still in use is removed and its Close fails.
+func WithTempFileCleanup(maxAge, interval time.Duration) Option {
+	return func(db *DB) error {
+		if maxAge <= 0 {
+			return errors.New("temp file max age must be positive")
+		}
+		if interval < 0 {
+			return errors.New("negative temp file cleanup interval")
+		}
+		db.janitor = &janitor{maxAge: maxAge, interval: interval}
+		return nil
+	}
+}
+
+type janitor struct {
+	maxAge   time.Duration
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// startJanitor removes temp files and starts periodic removal in the background
+func (s *DB) startJanitor() error {
+	if s.janitor == nil {
+		return nil
+	}
+	if err := s.CleanTempFiles(context.Background(), s.janitor.maxAge); err != nil {
+		return err
+	}
+	if s.janitor.interval == 0 {
+		return nil
+	}
+	s.janitor.stop = make(chan struct{})
+	s.janitor.done = make(chan struct{})
+	go func() {
+		defer close(s.janitor.done)
+		timer := time.NewTimer(s.janitor.interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-s.janitor.stop:
+				return
+			case <-timer.C:
+				err := s.runTask(TempFileCleanupTask, func() error {
+					return s.CleanTempFiles(context.Background(), s.janitor.maxAge)
+				})
+				if err != nil {
+					s.emit(Event{Type: TempFileCleanupFailed, Err: err})
+				}
+				timer.Reset(s.tasks.backoff(TempFileCleanupTask, s.janitor.interval))
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *DB) stopJanitor() {
+	if s.janitor == nil || s.janitor.stop == nil {
+		return
+	}
+	close(s.janitor.stop)
+	<-s.janitor.done
+}
+
+// CleanTempFiles removes temp files not modified for longer than maxAge. Temp files contain
+// versions which were never committed, for example because the process crashed while writing.
+// Dir must implement FileModTimer. Emits TempFileRemoved event for each removed file.
+func (s *DB) CleanTempFiles(ctx context.Context, maxAge time.Duration) (err error) {
+	defer s.redactError(&err)
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	return s.forEachKey(ctx, nil, func(key string) (int64, error) {
+		return 0, s.cleanTempFiles(key, time.Now().Add(-maxAge))
+	})
+}
+
+func (s *DB) cleanTempFiles(key string, modifiedBefore time.Time) error {
+	stateDir := s.stateDir(key)
+	modTimer, ok := stateDir.(FileModTimer)
+	if !ok {
+		return newClientError("dir does not implement FileModTimer")
+	}
+	var temps []filename
+	err := iterateFiles(stateDir, func(file string) bool {
+		if f, err := parseFilename(file); err == nil && f.kind == tempFile {
+			temps = append(temps, f)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, temp := range temps {
+		modTime, err := modTimer.FileModTime(temp.name)
+		if err != nil {
+			return err
+		}
+		if modTime.Before(modifiedBefore) {
+			if err = stateDir.DeleteFile(temp.name); err != nil {
+				return err
+			}
+			s.emit(Event{Type: TempFileRemoved, Key: key, Version: temp.version})
+		}
+	}
+	return nil
+}
+
+// Close stops background tasks, such as temp file cleanup or compaction, persists data of keys configured
+// using WithWriteBehind and waits for commits started by writers returned by WriterAsync. DB must not be used after Close.
+func (s *DB) Close() error {
+	s.ResumeMaintenance()
+	s.stopJanitor()
+	err := s.Flush(context.Background())
+	s.compactor.stop()
+	return err
+}
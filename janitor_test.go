@@ -0,0 +1,103 @@
+package deebee_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTempFileCleanup(t *testing.T) {
+	t.Run("should return error for invalid arguments", func(t *testing.T) {
+		options := map[string]deebee.Option{
+			"zero max age":      deebee.WithTempFileCleanup(0, 0),
+			"negative interval": deebee.WithTempFileCleanup(time.Hour, -1),
+		}
+		for name, option := range options {
+			t.Run(name, func(t *testing.T) {
+				db, err := deebee.Open(fake.ExistingDir(), option)
+				assert.Error(t, err)
+				assert.Nil(t, db)
+			})
+		}
+	})
+
+	t.Run("should return error when dir does not implement FileModTimer", func(t *testing.T) {
+		db, err := deebee.Open(failing.Rename(fake.ExistingDir()), deebee.WithTempFileCleanup(time.Hour, 0))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("should remove old temp files on Open", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("data"))
+		startWriting(t, db, dir, "key", time.Now().Add(-2*time.Hour))
+		startWriting(t, db, dir, "key", time.Now())
+		var events []deebee.Event
+		// when
+		openDB(t, dir,
+			deebee.WithEventHandler(func(event deebee.Event) {
+				events = append(events, event)
+			}),
+			deebee.WithTempFileCleanup(time.Hour, 0))
+		// then
+		assert.Len(t, tempFiles(dir, "key"), 1)
+		require.Len(t, events, 1)
+		assert.Equal(t, deebee.TempFileRemoved, events[0].Type)
+		assert.Equal(t, "key", events[0].Key)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should remove old temp files periodically until Close", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithTempFileCleanup(time.Hour, time.Millisecond))
+		// when
+		startWriting(t, db, dir, "key", time.Now().Add(-2*time.Hour))
+		// then
+		assert.Eventually(t, func() bool {
+			return len(tempFiles(dir, "key")) == 0
+		}, time.Second, time.Millisecond)
+		require.NoError(t, db.Close())
+	})
+}
+
+func TestDB_CleanTempFiles(t *testing.T) {
+	t.Run("should return client error when dir does not implement FileModTimer", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, failing.Rename(dir))
+		// when
+		err := db.CleanTempFiles(context.Background(), time.Hour)
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+}
+
+// startWriting opens writer which is never closed and sets modification time of its temp file
+func startWriting(t *testing.T, db *deebee.DB, dir fake.Dir, key string, modTime time.Time) {
+	before := tempFiles(dir, key)
+	_, err := db.Writer(key)
+	require.NoError(t, err)
+	for _, file := range tempFiles(dir, key) {
+		if _, exists := before[file.Name()]; !exists {
+			file.SetModTime(modTime)
+		}
+	}
+}
+
+func tempFiles(dir fake.Dir, key string) map[string]*fake.File {
+	files := map[string]*fake.File{}
+	for _, file := range dir.Dir(key).(fake.Dir).Files() {
+		if strings.HasSuffix(file.Name(), ".tmp") {
+			files[file.Name()] = file
+		}
+	}
+	return files
+}
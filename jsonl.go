@@ -0,0 +1,109 @@
+package deebee
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// JSONL gives access to the state holding JSON values, one per line. Each line is prefixed
+// with a checksum of the value. Useful as a light structured log.
+type JSONL struct {
+	db  *DB
+	key string
+}
+
+// JSONL returns JSONL for the state with given key. The state should not be written
+// using Writer.
+func (s *DB) JSONL(key string) *JSONL {
+	key = s.normalizeKey(key)
+	return &JSONL{db: s, key: key}
+}
+
+// Append writes a new version of the state with v encoded as JSON added at the end.
+// All lines are copied to the new version, therefore Append is slower the more lines
+// there are - use Compact with retention to remove old versions. Appends executed
+// concurrently by the same DB to the same state are serialized, but appends from other
+// processes are not.
+func (j *JSONL) Append(v interface{}) (err error) {
+	defer j.db.redactError(&err, j.key)
+	line, err := json.Marshal(v)
+	if err != nil {
+		return newClientError(fmt.Sprintf("encoding JSON failed: %s", err))
+	}
+	unlock := j.db.appendLocks.lock(j.key)
+	defer unlock()
+	reader, err := j.db.Reader(j.key)
+	if err != nil && !IsDataNotFound(err) {
+		return err
+	}
+	writer, err := j.db.newWriterWithTimeout(j.key)
+	if err != nil {
+		if reader != nil {
+			_ = reader.Close()
+		}
+		return err
+	}
+	if reader != nil {
+		_, err = io.Copy(writer, reader)
+		_ = reader.Close()
+		if err != nil {
+			_ = writer.abort()
+			return err
+		}
+	}
+	if _, err = fmt.Fprintf(writer, "%08x %s\n", crc32.ChecksumIEEE(line), line); err != nil {
+		_ = writer.abort()
+		return err
+	}
+	return writer.Close()
+}
+
+// Scan calls fn for each value, starting from the oldest one, until fn returns false.
+// Returns corruption error (see IsCorrupted) when a line does not match its checksum.
+func (j *JSONL) Scan(fn func(raw json.RawMessage) bool) (err error) {
+	defer j.db.redactError(&err, j.key)
+	reader, err := j.db.Reader(j.key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	buffered := bufio.NewReader(reader)
+	for number := 1; ; number++ {
+		line, err := buffered.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		raw, err := parseJSONLine(line)
+		if err != nil {
+			return &corruptedError{message: fmt.Sprintf("line %d: %s", number, err)}
+		}
+		if !fn(raw) {
+			return nil
+		}
+	}
+}
+
+func parseJSONLine(line []byte) (json.RawMessage, error) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	separator := bytes.IndexByte(line, ' ')
+	if separator != 8 {
+		return nil, errors.New("missing checksum")
+	}
+	var checksum uint32
+	if _, err := fmt.Sscanf(string(line[:separator]), "%08x", &checksum); err != nil {
+		return nil, fmt.Errorf("invalid checksum: %w", err)
+	}
+	value := line[separator+1:]
+	if crc32.ChecksumIEEE(value) != checksum {
+		return nil, errors.New("checksum mismatch")
+	}
+	return json.RawMessage(value), nil
+}
@@ -0,0 +1,118 @@
+package deebee_test
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type logEntry struct {
+	Message string `json:"message"`
+}
+
+func TestJSONL_Append(t *testing.T) {
+	t.Run("should return client error for invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, key := range invalidKeys {
+			err := db.JSONL(key).Append(logEntry{})
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+
+	t.Run("should return client error when value cannot be encoded", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		err := db.JSONL("log").Append(func() {})
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should append values", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		log := db.JSONL("log")
+		// when
+		require.NoError(t, log.Append(logEntry{Message: "first"}))
+		require.NoError(t, log.Append(logEntry{Message: "second"}))
+		// then
+		assert.Equal(t, []logEntry{{Message: "first"}, {Message: "second"}}, scanLog(t, log))
+	})
+
+	t.Run("should append concurrently", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = db.JSONL("log").Append(logEntry{Message: "entry"})
+			}()
+		}
+		wg.Wait()
+		// expect
+		assert.Len(t, scanLog(t, db.JSONL("log")), 10)
+	})
+}
+
+func TestJSONL_Scan(t *testing.T) {
+	t.Run("should return data not found for missing key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		err := db.JSONL("missing").Scan(func(json.RawMessage) bool { return true })
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should stop when fn returns false", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		log := db.JSONL("log")
+		require.NoError(t, log.Append(logEntry{Message: "first"}))
+		require.NoError(t, log.Append(logEntry{Message: "second"}))
+		var calls int
+		// when
+		err := log.Scan(func(json.RawMessage) bool {
+			calls++
+			return false
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("should return corruption error for damaged line", func(t *testing.T) {
+		lines := map[string]string{
+			"checksum mismatch": "00000000 {}\n",
+			"missing checksum":  "{}\n",
+			"invalid checksum":  "xxxxxxxx {}\n",
+		}
+		for name, line := range lines {
+			t.Run(name, func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir())
+				writeData(t, db, "log", []byte(line))
+				// when
+				err := db.JSONL("log").Scan(func(json.RawMessage) bool { return true })
+				// then
+				assert.True(t, deebee.IsCorrupted(err))
+			})
+		}
+	})
+
+	t.Run("should scan last line without new line", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "log", []byte(`8384be8f {"message":"a"}`))
+		// expect
+		assert.Equal(t, []logEntry{{Message: "a"}}, scanLog(t, db.JSONL("log")))
+	})
+}
+
+func scanLog(t *testing.T, log *deebee.JSONL) []logEntry {
+	var entries []logEntry
+	err := log.Scan(func(raw json.RawMessage) bool {
+		var entry logEntry
+		require.NoError(t, json.Unmarshal(raw, &entry))
+		entries = append(entries, entry)
+		return true
+	})
+	require.NoError(t, err)
+	return entries
+}
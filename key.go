@@ -1,10 +1,73 @@
 package deebee
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+
+	"golang.org/x/text/unicode/norm"
 )
 
+// keySeparator separates segments of hierarchical keys
+const keySeparator = "/"
+
+// WithHierarchicalKeys allows keys made of segments separated by "/", such as "a/b/c".
+// State of such key is stored in nested dirs. Each segment must be a valid key. State
+// can have both data and child states, for example "a" and "a/b".
+//
+// Use List to get children of the key, DeletePrefix to delete the subtree and CopyPrefix
+// to copy it.
+func WithHierarchicalKeys() Option {
+	return func(db *DB) error {
+		db.hierarchicalKeys = true
+		return nil
+	}
+}
+
+// checkKey validates the key, taking into account whether DB allows hierarchical keys
+func (s *DB) checkKey(key string) error {
+	if !s.hierarchicalKeys {
+		return validateKey(key)
+	}
+	for _, segment := range strings.Split(key, keySeparator) {
+		if validateKey(segment) != nil {
+			return newClientError(fmt.Sprintf("invalid key: \"%s\"", key))
+		}
+	}
+	return nil
+}
+
+// keyDir returns dir of the key inside root. Hierarchical keys are stored in nested dirs.
+func keyDir(root Dir, key string) Dir {
+	dir := root
+	for _, segment := range strings.Split(key, keySeparator) {
+		dir = dir.Dir(segment)
+	}
+	return dir
+}
+
+// splitKey splits hierarchical key into the key of its parent and the last segment.
+// Parent is empty for top-level keys.
+func splitKey(key string) (parent, name string) {
+	i := strings.LastIndex(key, keySeparator)
+	if i < 0 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
+
+// mkdirKey creates the dir of the key inside root together with all its parents
+func mkdirKey(root Dir, key string) error {
+	dir := root
+	for _, segment := range strings.Split(key, keySeparator) {
+		dir = dir.Dir(segment)
+		if err := dir.Mkdir(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func validateKey(key string) error {
 	if strings.HasPrefix(key, " ") {
 		return newClientError(fmt.Sprintf("invalid key: starts with space: \"%s\"", key))
@@ -15,5 +78,44 @@ func validateKey(key string) error {
 	if key == "" || key == "." || key == ".." || strings.Contains(key, "/") || strings.Contains(key, "\\") {
 		return newClientError(fmt.Sprintf("invalid key: \"%s\"", key))
 	}
+	if key == internalDirName {
+		return newClientError(fmt.Sprintf("invalid key: \"%s\" is reserved for internal files", key))
+	}
 	return nil
 }
+
+// KeyNormalization is a Unicode normalization form applied to keys
+type KeyNormalization int
+
+const (
+	// NFC composes characters, for example "e" followed by combining acute accent becomes "é".
+	// It is the form used by most Linux and Windows applications.
+	NFC KeyNormalization = iota + 1
+	// NFD decomposes characters. It is the form used by HFS+ filesystem on macOS.
+	NFD
+)
+
+// WithKeyNormalization normalizes all keys passed to DB methods using given Unicode form, so
+// the same logical key written on macOS and Linux resolves to the same state. Keys of states
+// written before the option was used, which are not normalized, can't be accessed.
+func WithKeyNormalization(form KeyNormalization) Option {
+	return func(db *DB) error {
+		if form != NFC && form != NFD {
+			return errors.New("invalid key normalization")
+		}
+		db.keyNormalization = form
+		return nil
+	}
+}
+
+// normalizeKey returns the key normalized using the form set WithKeyNormalization
+func (s *DB) normalizeKey(key string) string {
+	switch s.keyNormalization {
+	case NFC:
+		return norm.NFC.String(key)
+	case NFD:
+		return norm.NFD.String(key)
+	default:
+		return key
+	}
+}
@@ -0,0 +1,174 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHierarchicalKeys(t *testing.T) {
+	t.Run("should return client error for invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithHierarchicalKeys())
+		for _, key := range []string{"", "/", "a/", "/a", "a//b", "a/./b", "a/../b", "a/ b", "a\\b"} {
+			_, err := db.Writer(key)
+			assert.True(t, deebee.IsClientError(err), key)
+		}
+	})
+
+	options := map[string][]deebee.Option{
+		"default": {deebee.WithHierarchicalKeys()},
+		"sharded": {deebee.WithHierarchicalKeys(), deebee.WithShardedLayout()},
+		"preload": {deebee.WithHierarchicalKeys(), deebee.WithPreload()},
+	}
+	for name, opts := range options {
+		t.Run(name, func(t *testing.T) {
+			t.Run("should read data of parent and child", func(t *testing.T) {
+				dir := fake.ExistingDir()
+				db := openDB(t, dir, opts...)
+				writeData(t, db, "a", []byte("parent"))
+				writeData(t, db, "a/b/c", []byte("child"))
+				// expect
+				assert.Equal(t, []byte("parent"), readData(t, db, "a"))
+				assert.Equal(t, []byte("child"), readData(t, db, "a/b/c"))
+				// and
+				reopened := openDB(t, dir, opts...)
+				count, err := reopened.Count()
+				require.NoError(t, err)
+				assert.Equal(t, 2, count)
+				assert.Equal(t, []byte("child"), readData(t, reopened, "a/b/c"))
+			})
+
+			t.Run("should rename parent leaving children", func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), opts...)
+				writeData(t, db, "a", []byte("parent"))
+				writeData(t, db, "a/b", []byte("child"))
+				// when
+				err := db.Rename("a", "x/y")
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, []byte("parent"), readData(t, db, "x/y"))
+				assert.Equal(t, []byte("child"), readData(t, db, "a/b"))
+				exists, err := db.Exists("a")
+				require.NoError(t, err)
+				assert.False(t, exists)
+			})
+
+			t.Run("should rename child", func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), opts...)
+				writeData(t, db, "a/b", []byte("child"))
+				// when
+				err := db.Rename("a/b", "a/c")
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, []byte("child"), readData(t, db, "a/c"))
+			})
+
+			t.Run("should delete subtree", func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), opts...)
+				writeData(t, db, "a", []byte("parent"))
+				writeData(t, db, "a/b", []byte("child"))
+				writeData(t, db, "a/b/c", []byte("grandchild"))
+				// when
+				err := db.DeletePrefix(context.Background(), "a/", nil)
+				// then
+				require.NoError(t, err)
+				children, err := db.List("")
+				require.NoError(t, err)
+				assert.Equal(t, []string{"a"}, children)
+			})
+		})
+	}
+
+	t.Run("should backup and restore nested keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithHierarchicalKeys())
+		writeData(t, db, "a/b", []byte("data"))
+		backup := fake.ExistingDir()
+		require.NoError(t, db.Backup(context.Background(), backup, nil))
+		restored := openDB(t, fake.ExistingDir(), deebee.WithHierarchicalKeys())
+		// when
+		err := restored.Restore(context.Background(), backup, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, restored, "a/b"))
+	})
+
+	t.Run("should store nested keys in OsDir", func(t *testing.T) {
+		dir := deebee.OsDir(createTempDir(t))
+		db := openDB(t, dir, deebee.WithHierarchicalKeys())
+		// when
+		writeData(t, db, "a/b", []byte("data"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "a/b"))
+		exists, err := dir.Dir("a").Dir("b").Exists()
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
+func TestWithKeyNormalization(t *testing.T) {
+	const (
+		nfc = "caf\u00e9"
+		nfd = "cafe\u0301"
+	)
+
+	t.Run("should return error for invalid form", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithKeyNormalization(0))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	forms := map[string]deebee.KeyNormalization{
+		"NFC": deebee.NFC,
+		"NFD": deebee.NFD,
+	}
+	for name, form := range forms {
+		t.Run(name, func(t *testing.T) {
+			t.Run("should read key written in other form", func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), deebee.WithKeyNormalization(form))
+				writeData(t, db, nfd, []byte("data"))
+				// expect
+				assert.Equal(t, []byte("data"), readData(t, db, nfc))
+				// and
+				count, err := db.Count()
+				require.NoError(t, err)
+				assert.Equal(t, 1, count)
+			})
+
+			t.Run("should delete key written in other form", func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), deebee.WithKeyNormalization(form))
+				writeData(t, db, nfc, []byte("data"))
+				// when
+				err := db.Delete(nfd)
+				// then
+				require.NoError(t, err)
+				exists, err := db.Exists(nfc)
+				require.NoError(t, err)
+				assert.False(t, exists)
+			})
+
+			t.Run("should read key put in other form using PutMulti", func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), deebee.WithKeyNormalization(form))
+				// when
+				failed := db.PutMulti(map[string][]byte{nfd: []byte("data")})
+				// then
+				assert.Empty(t, failed)
+				assert.Equal(t, []byte("data"), readData(t, db, nfc))
+			})
+		})
+	}
+
+	t.Run("should store key in given form", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithKeyNormalization(deebee.NFD))
+		// when
+		writeData(t, db, nfc, []byte("data"))
+		// then
+		dirs, err := dir.ListDirs()
+		require.NoError(t, err)
+		assert.Equal(t, []string{nfd}, dirs)
+	})
+}
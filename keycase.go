@@ -0,0 +1,102 @@
+package deebee
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// WithKeyCaseCollisionCheck returns client error when the key is written, but another key
+// differing only in letter case, such as "State" and "state", already exists. Such keys
+// share the same state dir on case-insensitive filesystems (default on macOS and Windows),
+// so the check makes DB behave the same way on all systems.
+//
+// All keys are listed once, when a key is written for the first time since Open. Keys
+// created by other processes afterwards are not detected.
+func WithKeyCaseCollisionCheck() Option {
+	return func(db *DB) error {
+		db.keyCase = &keyCaseChecker{}
+		return nil
+	}
+}
+
+// keyCaseChecker remembers existing keys by their folded form. Nil checker does not check
+// anything.
+type keyCaseChecker struct {
+	mutex sync.Mutex
+	// keys are existing keys by their folded form. Nil until keys were listed.
+	keys map[string][]string
+}
+
+// checkKeyCase returns client error when the key collides with existing key
+func (s *DB) checkKeyCase(key string) error {
+	c := s.keyCase
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.keys == nil {
+		keys, err := s.stateKeys()
+		if err != nil {
+			return err
+		}
+		c.keys = map[string][]string{}
+		for _, existing := range keys {
+			c.add(existing)
+		}
+	}
+	for _, existing := range c.keys[foldKey(key)] {
+		if existing != key {
+			return newClientError(fmt.Sprintf("key \"%s\" differs only in case from existing key \"%s\"", key, existing))
+		}
+	}
+	c.add(key)
+	return nil
+}
+
+// add must be called with mutex locked
+func (c *keyCaseChecker) add(key string) {
+	folded := foldKey(key)
+	for _, existing := range c.keys[folded] {
+		if existing == key {
+			return
+		}
+	}
+	c.keys[folded] = append(c.keys[folded], key)
+}
+
+// forget is called when the key was renamed
+func (c *keyCaseChecker) forget(key string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	folded := foldKey(key)
+	keys := c.keys[folded]
+	for i, existing := range keys {
+		if existing == key {
+			c.keys[folded] = append(keys[:i:i], keys[i+1:]...)
+			break
+		}
+	}
+	if len(c.keys[folded]) == 0 {
+		delete(c.keys, folded)
+	}
+}
+
+// foldKey returns the same string for all keys equal under Unicode case folding, the same
+// way as strings.EqualFold
+func foldKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		folded := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < folded {
+				folded = f
+			}
+		}
+		return folded
+	}, key)
+}
@@ -0,0 +1,87 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithKeyCaseCollisionCheck(t *testing.T) {
+	t.Run("should return client error when key differs only in case", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "State", []byte("data"))
+		db := openDB(t, dir, deebee.WithKeyCaseCollisionCheck())
+		// when
+		writer, err := db.Writer("state")
+		// then
+		assert.Nil(t, writer)
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should write keys differing in other way", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyCaseCollisionCheck())
+		writeData(t, db, "State", []byte("1"))
+		// when
+		writeData(t, db, "State", []byte("2"))
+		writeData(t, db, "States", []byte("3"))
+		// then
+		assert.Equal(t, []byte("2"), readData(t, db, "State"))
+		assert.Equal(t, []byte("3"), readData(t, db, "States"))
+	})
+
+	t.Run("should return client error when key is renamed to colliding key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyCaseCollisionCheck())
+		writeData(t, db, "State", []byte("data"))
+		writeData(t, db, "other", []byte("data"))
+		// when
+		err := db.Rename("other", "STATE")
+		// then
+		assert.True(t, deebee.IsClientError(err))
+		assert.Equal(t, []byte("data"), readData(t, db, "other"))
+	})
+
+	t.Run("should return client error when key collides with key written after Open", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyCaseCollisionCheck())
+		writeData(t, db, "other", []byte("data"))
+		writeData(t, db, "State", []byte("data"))
+		// when
+		_, err := db.Writer("STATE")
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should compare keys using Unicode case folding", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyCaseCollisionCheck())
+		writeData(t, db, "kelvin", []byte("data"))
+		// when
+		_, err := db.Writer("\u212aelvin") // Kelvin sign
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "differs only in case")
+	})
+
+	t.Run("should write key colliding with renamed key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyCaseCollisionCheck())
+		writeData(t, db, "State", []byte("data"))
+		require.NoError(t, db.Rename("State", "other"))
+		// when
+		writeData(t, db, "STATE", []byte("data"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "STATE"))
+	})
+
+	t.Run("should check keys written using write-behind", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "State", []byte("data"))
+		db := openDB(t, dir, deebee.WithKeyCaseCollisionCheck(), deebee.WithWriteBehind(time.Hour))
+		// when
+		_, err := db.Writer("state")
+		// then
+		require.Error(t, err)
+		assert.True(t, deebee.IsClientError(err))
+	})
+}
@@ -0,0 +1,86 @@
+package deebee
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+)
+
+// minKeyEncryptionSecret is the minimum length of the secret passed to NewEncryptingKeyMapper
+const minKeyEncryptionSecret = 16
+
+// WithKeyEncryption is WithKeyMapper using NewEncryptingKeyMapper, so a snapshot of the
+// filesystem does not reveal keys, such as user emails.
+func WithKeyEncryption(secret []byte) Option {
+	return func(db *DB) error {
+		mapper, err := NewEncryptingKeyMapper(secret)
+		if err != nil {
+			return err
+		}
+		return WithKeyMapper(mapper)(db)
+	}
+}
+
+// NewEncryptingKeyMapper returns KeyMapper naming dirs using HMAC-SHA256 of the key, so the
+// state is found without any lookup, and storing the key encrypted using AES-GCM in the
+// manifest. secret must have at least 16 bytes. DecodeKey returns corrupted error when
+// the manifest was written using another secret.
+func NewEncryptingKeyMapper(secret []byte) (KeyMapper, error) {
+	if len(secret) < minKeyEncryptionSecret {
+		return nil, fmt.Errorf("key encryption secret must have at least %d bytes", minKeyEncryptionSecret)
+	}
+	block, err := aes.NewCipher(deriveKey(secret, "manifest"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingKeyMapper{nameKey: deriveKey(secret, "name"), aead: aead}, nil
+}
+
+type encryptingKeyMapper struct {
+	nameKey []byte
+	aead    cipher.AEAD
+}
+
+// deriveKey returns 32-byte key for given purpose, so the same secret is not used for
+// both hashing and encryption
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func (m *encryptingKeyMapper) Name(key string) string {
+	mac := hmac.New(sha256.New, m.nameKey)
+	_, _ = mac.Write([]byte(key))
+	return encodeDirName(mac.Sum(nil))
+}
+
+func (m *encryptingKeyMapper) EncodeKey(key string) ([]byte, error) {
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return m.aead.Seal(nonce, nonce, []byte(key), nil), nil
+}
+
+func (m *encryptingKeyMapper) DecodeKey(name string, manifest []byte) (string, error) {
+	if len(manifest) < m.aead.NonceSize() {
+		return "", &corruptedError{message: "key manifest is truncated"}
+	}
+	nonce := manifest[:m.aead.NonceSize()]
+	key, err := m.aead.Open(nil, nonce, manifest[len(nonce):], nil)
+	if err != nil {
+		return "", &corruptedError{message: "key manifest can't be decrypted, possibly because of wrong secret"}
+	}
+	if m.Name(string(key)) != name {
+		return "", &corruptedError{message: "key manifest is stored in the dir of other key"}
+	}
+	return string(key), nil
+}
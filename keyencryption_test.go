@@ -0,0 +1,158 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/jacekolszak/deebee/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithKeyEncryption(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	const key = "user@example.com"
+
+	t.Run("should return error for short secret", func(t *testing.T) {
+		_, err := deebee.Open(fake.ExistingDir(), deebee.WithKeyEncryption([]byte("short")))
+		assert.Error(t, err)
+	})
+
+	t.Run("should not store key in names of dirs and files", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithKeyEncryption(secret))
+		// when
+		writeData(t, db, key, []byte("data"))
+		// then
+		assertKeyNotStored(t, dir, key)
+		assert.Equal(t, []byte("data"), readData(t, db, key))
+		assert.True(t, db.Options().KeyEncryption)
+		assert.NotContains(t, db.DebugString(), string(secret))
+	})
+
+	t.Run("should read state after reopening", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir, deebee.WithKeyEncryption(secret)), key, []byte("data"))
+		// when
+		db := openDB(t, dir, deebee.WithKeyEncryption(secret))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, key))
+	})
+
+	t.Run("should list keys", func(t *testing.T) {
+		options := map[string][]deebee.Option{
+			"flat":         {deebee.WithKeyEncryption(secret)},
+			"sharded":      {deebee.WithKeyEncryption(secret), deebee.WithShardedLayout()},
+			"hierarchical": {deebee.WithKeyEncryption(secret), deebee.WithHierarchicalKeys()},
+			"preload":      {deebee.WithKeyEncryption(secret), deebee.WithPreload()},
+		}
+		for name, opts := range options {
+			t.Run(name, func(t *testing.T) {
+				dir := fake.ExistingDir()
+				writeData(t, openDB(t, dir, opts...), "b", []byte("data"))
+				db := openDB(t, dir, opts...)
+				writeData(t, db, "a", []byte("data"))
+				// when
+				keys, err := db.Keys()
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, []string{"a", "b"}, keys)
+			})
+		}
+	})
+
+	t.Run("should list children of hierarchical keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyEncryption(secret), deebee.WithHierarchicalKeys())
+		writeData(t, db, "users/a", []byte("data"))
+		writeData(t, db, "users/b", []byte("data"))
+		writeData(t, db, "other", []byte("data"))
+		// when
+		children, err := db.List("users/")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"users/a", "users/b"}, children)
+	})
+
+	t.Run("should skip keys and report corruption when keys are listed with other secret", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir, deebee.WithKeyEncryption(secret)), key, []byte("data"))
+		var reported error
+		handler := func(event deebee.Event) {
+			if event.Type == deebee.CorruptionDetected {
+				reported = event.Err
+			}
+		}
+		db := openDB(t, dir, deebee.WithKeyEncryption([]byte("fedcba9876543210")), deebee.WithEventHandler(handler))
+		// when
+		keys, err := db.Keys()
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+		assert.True(t, deebee.IsCorrupted(reported))
+	})
+
+	t.Run("should not store key in labels", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithKeyEncryption(secret))
+		writeData(t, db, key, []byte("data"))
+		// when
+		err := db.Label(key, latestVersion(t, db, key), "known-good")
+		// then
+		require.NoError(t, err)
+		labels := test.ReadFile(t, dir.Dir(".deebee"), "labels")
+		assert.NotContains(t, string(labels), key)
+		actual, err := db.Labels(key)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"known-good": latestVersion(t, db, key)}, actual)
+	})
+
+	t.Run("should rename", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithKeyEncryption(secret))
+		writeData(t, db, "old", []byte("data"))
+		// when
+		err := db.Rename("old", key)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, key))
+		keys, err := db.Keys()
+		require.NoError(t, err)
+		assert.Equal(t, []string{key}, keys)
+		assertKeyNotStored(t, dir, key)
+	})
+
+	t.Run("should backup and restore", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyEncryption(secret))
+		writeData(t, db, key, []byte("data"))
+		backup := fake.ExistingDir()
+		// when
+		require.NoError(t, db.Backup(context.Background(), backup, nil))
+		// then
+		assertKeyNotStored(t, backup, key)
+		restored := openDB(t, fake.ExistingDir(), deebee.WithKeyEncryption(secret))
+		require.NoError(t, restored.Restore(context.Background(), backup, nil))
+		assert.Equal(t, []byte("data"), readData(t, restored, key))
+	})
+
+	t.Run("should migrate to sharded layout", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir, deebee.WithKeyEncryption(secret)), key, []byte("data"))
+		// when
+		err := deebee.MigrateToShardedLayout(context.Background(), dir, nil)
+		// then
+		require.NoError(t, err)
+		db := openDB(t, dir, deebee.WithKeyEncryption(secret), deebee.WithShardedLayout())
+		assert.Equal(t, []byte("data"), readData(t, db, key))
+		keys, err := db.Keys()
+		require.NoError(t, err)
+		assert.Equal(t, []string{key}, keys)
+	})
+}
+
+func assertKeyNotStored(t *testing.T, dir fake.Dir, key string) {
+	for _, operation := range dir.History() {
+		assert.NotContains(t, operation.String(), key)
+	}
+}
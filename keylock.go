@@ -0,0 +1,42 @@
+package deebee
+
+import "sync"
+
+// keyLocks serializes operations on the same key, without blocking operations on other keys
+type keyLocks struct {
+	mutex sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	// users is the number of goroutines holding or waiting for the lock. The lock is
+	// removed when there are none.
+	users int
+}
+
+// lock locks the key and returns function unlocking it
+func (l *keyLocks) lock(key string) (unlock func()) {
+	l.mutex.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*keyLock{}
+	}
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		l.locks[key] = lock
+	}
+	lock.users++
+	l.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		lock.users--
+		if lock.users == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package deebee
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// keyManifestFile is stored in the state dir when DB was opened WithKeyMapper. It contains
+// the key encoded by KeyMapper, so keys can be listed.
+const keyManifestFile = "key"
+
+// KeyMapper maps keys to names of dirs storing states, for example to hide keys or to
+// avoid names which are too long for the filesystem. Implementations must be safe for
+// concurrent use.
+type KeyMapper interface {
+	// Name returns the name of the state dir. It must be deterministic, must be a valid key
+	// and must not contain "/".
+	Name(key string) string
+	// EncodeKey returns the content of the manifest file stored in the state dir, from
+	// which the key is recovered when keys are listed
+	EncodeKey(key string) ([]byte, error)
+	// DecodeKey returns the key stored in the dir with given name. It should return error
+	// wrapping ErrCorrupted when manifest is invalid.
+	DecodeKey(name string, manifest []byte) (string, error)
+}
+
+// WithKeyMapper stores each state in the dir named by mapper, instead of the key itself.
+// The key encoded by mapper is stored in a manifest file next to versions, so keys can be
+// listed. Mapper must be the same each time the DB is opened. States written without the
+// option are not visible.
+//
+// Backup, ExportSnapshotDir and WithArchive name dirs in the same way. Rename moves
+// versions one by one, therefore it is not atomic. WithShardedLayout shards mapped names,
+// and WithHierarchicalKeys stores all states in flat dirs. Labels are stored under names
+// of dirs too. Keys with manifest which could not be decoded are not listed.
+func WithKeyMapper(mapper KeyMapper) Option {
+	return func(db *DB) error {
+		if mapper == nil {
+			return errors.New("nil key mapper")
+		}
+		db.keyMapper = mapper
+		return nil
+	}
+}
+
+// HashingKeyMapper names dirs using SHA-256 hash of the key, so names have the same length
+// regardless of the key, and stores the key in plaintext in the manifest. It hides keys
+// from casual inspection only - use NewEncryptingKeyMapper when keys are sensitive.
+func HashingKeyMapper() KeyMapper {
+	return hashingKeyMapper{}
+}
+
+type hashingKeyMapper struct{}
+
+func (hashingKeyMapper) Name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return encodeDirName(sum[:])
+}
+
+func (hashingKeyMapper) EncodeKey(key string) ([]byte, error) {
+	return []byte(key), nil
+}
+
+func (m hashingKeyMapper) DecodeKey(name string, manifest []byte) (string, error) {
+	key := string(manifest)
+	if m.Name(key) != name {
+		return "", &corruptedError{message: "key manifest is stored in the dir of other key"}
+	}
+	return key, nil
+}
+
+var dirNameEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// encodeDirName returns the dir name made of first 20 bytes of the hash
+func encodeDirName(hash []byte) string {
+	return strings.ToLower(dirNameEncoding.EncodeToString(hash[:20]))
+}
+
+// physicalKey returns the key used to name the dir of the state
+func (s *DB) physicalKey(key string) string {
+	if s.keyMapper == nil {
+		return key
+	}
+	return s.keyMapper.Name(key)
+}
+
+// writeKeyManifest stores the encoded key in the state dir, unless it was stored before.
+// The manifest is written to the temp file with unique name and renamed, so it is never
+// read partially written, also when the state dir is created by many writers concurrently.
+func (s *DB) writeKeyManifest(key string, stateDir Dir) error {
+	if s.keyMapper == nil {
+		return nil
+	}
+	exists, err := hasKeyManifest(stateDir)
+	if err != nil || exists {
+		return err
+	}
+	manifest, err := s.keyMapper.EncodeKey(key)
+	if err != nil {
+		return err
+	}
+	temp, err := keyManifestTemp()
+	if err != nil {
+		return err
+	}
+	file, err := stateDir.FileWriter(temp)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(manifest)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = stateDir.Rename(temp, keyManifestFile)
+	}
+	if err != nil {
+		_ = stateDir.DeleteFile(temp)
+	}
+	return err
+}
+
+// keyManifestTemp returns unique name of the temp file of the key manifest
+func keyManifestTemp() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return keyManifestFile + "." + hex.EncodeToString(id) + tempSuffix, nil
+}
+
+// decodeKeys returns keys of states stored in dirs with given names inside root. Dirs
+// without the key manifest are skipped. Dirs with manifest which could not be decoded are
+// skipped as well and CorruptionDetected is emitted for each of them.
+func (s *DB) decodeKeys(root Dir, names []string) ([]string, error) {
+	if s.keyMapper == nil {
+		return names, nil
+	}
+	var keys []string
+	for _, name := range names {
+		manifest, ok, err := readKeyManifest(root.Dir(name))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		key, err := s.keyMapper.DecodeKey(name, manifest)
+		if err != nil {
+			s.stats.add(statCorruptionEvents, 1)
+			s.emit(Event{Type: CorruptionDetected, Err: s.redact(fmt.Errorf("key manifest of dir %s: %w", name, err))})
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readKeyManifest returns the content of the key manifest, or false when there is none
+func readKeyManifest(stateDir Dir) ([]byte, bool, error) {
+	reader, err := stateDir.FileReader(keyManifestFile)
+	if err != nil {
+		exists, listErr := hasKeyManifest(stateDir)
+		if listErr == nil && !exists {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	manifest, err := ioutil.ReadAll(reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return manifest, true, nil
+}
+
+func hasKeyManifest(stateDir Dir) (bool, error) {
+	found := false
+	err := iterateFiles(stateDir, func(file string) bool {
+		found = file == keyManifestFile
+		return !found
+	})
+	return found, err
+}
+
+// copyKeyManifest copies the key manifest when it exists in src and not in dst
+func copyKeyManifest(src, dst Dir) error {
+	exists, err := hasKeyManifest(src)
+	if err != nil || !exists {
+		return err
+	}
+	if exists, err = hasKeyManifest(dst); err != nil || exists {
+		return err
+	}
+	_, err = copyFile(src, dst, keyManifestFile)
+	return err
+}
@@ -0,0 +1,179 @@
+package deebee_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/jacekolszak/deebee/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithKeyMapper(t *testing.T) {
+	t.Run("should return error for nil mapper", func(t *testing.T) {
+		_, err := deebee.Open(fake.ExistingDir(), deebee.WithKeyMapper(nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("should store state in dir named by mapper", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithKeyMapper(upperCaseMapper{}))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		dirs, err := dir.ListDirs()
+		require.NoError(t, err)
+		assert.Contains(t, dirs, "KEY")
+		assert.NotContains(t, dirs, "key")
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+		assert.True(t, db.Options().KeyMapper)
+		assert.False(t, db.Options().KeyEncryption)
+	})
+
+	t.Run("should list keys decoded by mapper", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyMapper(upperCaseMapper{}))
+		writeData(t, db, "b", []byte("data"))
+		writeData(t, db, "a", []byte("data"))
+		// when
+		keys, err := db.Keys()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, keys)
+	})
+
+	t.Run("should skip dirs without key manifest", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "other", []byte("data"))
+		db := openDB(t, dir, deebee.WithKeyMapper(upperCaseMapper{}))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		keys, err := db.Keys()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"key"}, keys)
+	})
+
+	t.Run("should write key manifest using rename", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithKeyMapper(upperCaseMapper{}))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		renamed := false
+		for _, operation := range dir.History() {
+			renamed = renamed || operation.Name == "Rename" && operation.NewPath == "KEY/key"
+		}
+		assert.True(t, renamed)
+		files, err := dir.Dir("KEY").ListFiles()
+		require.NoError(t, err)
+		for _, file := range files {
+			assert.False(t, strings.HasSuffix(file, ".tmp"), file)
+		}
+	})
+
+	t.Run("should skip key with manifest which could not be decoded", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		var events []deebee.Event
+		handler := func(event deebee.Event) {
+			if event.Type == deebee.CorruptionDetected {
+				events = append(events, event)
+			}
+		}
+		mapper := deebee.HashingKeyMapper()
+		db := openDB(t, dir, deebee.WithKeyMapper(mapper), deebee.WithEventHandler(handler))
+		writeData(t, db, "a", []byte("data"))
+		writeData(t, db, "b", []byte("data"))
+		stateDir := dir.Dir(mapper.Name("a"))
+		require.NoError(t, stateDir.DeleteFile("key"))
+		test.WriteFile(t, stateDir, "key", []byte("other"))
+		// when
+		keys, err := db.Keys()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b"}, keys)
+		require.Len(t, events, 1)
+		assert.True(t, deebee.IsCorrupted(events[0].Err))
+	})
+}
+
+func TestHashingKeyMapper(t *testing.T) {
+	const key = "user@example.com"
+
+	t.Run("should not store key in names of dirs and files", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithKeyMapper(deebee.HashingKeyMapper()))
+		// when
+		writeData(t, db, key, []byte("data"))
+		// then
+		assertKeyNotStored(t, dir, key)
+		assert.Equal(t, []byte("data"), readData(t, db, key))
+	})
+
+	t.Run("should return names of the same length", func(t *testing.T) {
+		mapper := deebee.HashingKeyMapper()
+		// when
+		short := mapper.Name("a")
+		long := mapper.Name(strings.Repeat("a", 1000))
+		// then
+		assert.Len(t, long, len(short))
+		assert.NotEqual(t, short, long)
+		assert.NotContains(t, short, "/")
+	})
+
+	t.Run("should list keys after reopening", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir, deebee.WithKeyMapper(deebee.HashingKeyMapper())), key, []byte("data"))
+		db := openDB(t, dir, deebee.WithKeyMapper(deebee.HashingKeyMapper()))
+		// when
+		keys, err := db.Keys()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{key}, keys)
+	})
+
+	t.Run("should return corrupted error when manifest stores other key", func(t *testing.T) {
+		mapper := deebee.HashingKeyMapper()
+		// when
+		_, err := mapper.DecodeKey(mapper.Name("a"), []byte("b"))
+		// then
+		assert.True(t, deebee.IsCorrupted(err))
+	})
+}
+
+func TestNewEncryptingKeyMapper(t *testing.T) {
+	t.Run("should return error for short secret", func(t *testing.T) {
+		_, err := deebee.NewEncryptingKeyMapper([]byte("short"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should decode encoded key", func(t *testing.T) {
+		mapper, err := deebee.NewEncryptingKeyMapper([]byte("0123456789abcdef"))
+		require.NoError(t, err)
+		manifest, err := mapper.EncodeKey("key")
+		require.NoError(t, err)
+		// when
+		key, err := mapper.DecodeKey(mapper.Name("key"), manifest)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "key", key)
+		assert.NotContains(t, string(manifest), "key")
+	})
+}
+
+// upperCaseMapper names dirs using upper-case keys, which are never valid for tests using
+// lower-case keys
+type upperCaseMapper struct{}
+
+func (upperCaseMapper) Name(key string) string {
+	return strings.ToUpper(key)
+}
+
+func (upperCaseMapper) EncodeKey(key string) ([]byte, error) {
+	return []byte(key), nil
+}
+
+func (upperCaseMapper) DecodeKey(_ string, manifest []byte) (string, error) {
+	return string(manifest), nil
+}
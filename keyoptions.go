@@ -0,0 +1,67 @@
+package deebee
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+)
+
+// WithKeyOptions applies options only to keys matching the pattern. Pattern syntax is
+// the same as in path.Match, for example "events-*". Options are applied on top of
+// the options given to Open directly, no matter the order. When key matches many
+// patterns, the first WithKeyOptions wins.
+//
+// Only options changing how the data of a key is stored can be used, such as
+// WithFilter, WithGroupCommit, WithRetention, WithChecksum, WithWriteBehind,
+// WithWriteValidator, WithRejectEmptyData, WithAllowEmptyData and WithFileHeader. Other
+// options make Open return client error.
+func WithKeyOptions(pattern string, options ...Option) Option {
+	return func(db *DB) error {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid key pattern \"%s\": %w", pattern, err)
+		}
+		db.keyOptions = append(db.keyOptions, keyOptions{
+			pattern: pattern,
+			options: options,
+		})
+		return nil
+	}
+}
+
+type keyOptions struct {
+	pattern string
+	options []Option
+	config  keyConfig
+}
+
+// applyKeyOptions builds keyConfig for each pattern. Must be run after all
+// other options were applied.
+func (s *DB) applyKeyOptions() error {
+	for i, o := range s.keyOptions {
+		scoped := &DB{keyConfig: s.keyConfig}
+		scoped.filters = append([]Filter{}, s.filters...)
+		for _, apply := range o.options {
+			if apply != nil {
+				if err := apply(scoped); err != nil {
+					return fmt.Errorf("applying option for key pattern \"%s\" failed: %w", o.pattern, err)
+				}
+			}
+		}
+		s.keyOptions[i].config = scoped.keyConfig
+		scoped.keyConfig = keyConfig{}
+		if !reflect.DeepEqual(scoped, &DB{}) {
+			return newClientError(fmt.Sprintf("only options changing how the data is stored can be "+
+				"given to WithKeyOptions, check options for key pattern \"%s\"", o.pattern))
+		}
+	}
+	return nil
+}
+
+func (s *DB) configFor(key string) keyConfig {
+	for _, o := range s.keyOptions {
+		if matched, _ := path.Match(o.pattern, key); matched {
+			return o.config
+		}
+	}
+	return s.currentKeyConfig()
+}
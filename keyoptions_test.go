@@ -0,0 +1,95 @@
+package deebee_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithKeyOptions(t *testing.T) {
+	t.Run("should return error for invalid pattern", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithKeyOptions("[", deebee.WithFilter(prefixFilter("A"))))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("should return error when option returned error", func(t *testing.T) {
+		expectedError := &testError{}
+		option := func(db *deebee.DB) error {
+			return expectedError
+		}
+		// when
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithKeyOptions("*", option))
+		// then
+		assert.True(t, errors.Is(err, expectedError))
+		assert.Nil(t, db)
+	})
+
+	t.Run("should return client error for unsupported option", func(t *testing.T) {
+		options := map[string]deebee.Option{
+			"WithShardedLayout":   deebee.WithShardedLayout(),
+			"WithTempFileCleanup": deebee.WithTempFileCleanup(time.Hour, 0),
+			"WithDirSync":         deebee.WithDirSync(),
+		}
+		for name, option := range options {
+			t.Run(name, func(t *testing.T) {
+				// when
+				_, err := deebee.Open(fake.ExistingDir(), deebee.WithKeyOptions("key-*", option))
+				// then
+				assert.True(t, deebee.IsClientError(err))
+			})
+		}
+	})
+
+	t.Run("should apply options only to matching keys", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir,
+			deebee.WithFilter(prefixFilter("A")),
+			deebee.WithKeyOptions("events-*", deebee.WithFilter(prefixFilter("B"))),
+		)
+		// when
+		writeData(t, db, "events-1", []byte("data"))
+		writeData(t, db, "state", []byte("data"))
+		// then
+		assert.Equal(t, []byte("BAdata"), fileData(t, dir, "events-1"))
+		assert.Equal(t, []byte("Adata"), fileData(t, dir, "state"))
+		assert.Equal(t, []byte("data"), readData(t, db, "events-1"))
+		assert.Equal(t, []byte("data"), readData(t, db, "state"))
+	})
+
+	t.Run("should apply global options given after key options", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir,
+			deebee.WithKeyOptions("*", deebee.WithFilter(prefixFilter("B"))),
+			deebee.WithFilter(prefixFilter("A")),
+		)
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.Equal(t, []byte("BAdata"), fileData(t, dir, "key"))
+	})
+
+	t.Run("first matching pattern wins", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir,
+			deebee.WithKeyOptions("events-*", deebee.WithFilter(prefixFilter("A"))),
+			deebee.WithKeyOptions("*", deebee.WithFilter(prefixFilter("B"))),
+		)
+		// when
+		writeData(t, db, "events-1", []byte("data"))
+		// then
+		assert.Equal(t, []byte("Adata"), fileData(t, dir, "events-1"))
+	})
+}
+
+// fileData returns data of the only file stored for the key
+func fileData(t *testing.T, dir fake.Dir, key string) []byte {
+	files := dir.Dir(key).(fake.Dir).Files()
+	require.Len(t, files, 1)
+	return files[0].Data()
+}
@@ -0,0 +1,147 @@
+package deebee
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// stateKeys returns sorted keys of all state dirs, including the ones which have
+// no committed version or are deleted
+func (s *DB) stateKeys() ([]string, error) {
+	if s.sharded {
+		return s.shardedStateKeys()
+	}
+	names, err := s.keyDirs(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.decodeKeys(s.dir, names)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// keyDirs returns keys of dirs inside root. Nested dirs are returned as well when DB was
+// opened WithHierarchicalKeys, unless DB was opened WithKeyMapper, because then all dirs are flat.
+func (s *DB) keyDirs(root Dir) ([]string, error) {
+	dirs, err := root.ListDirs()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, name := range dirs {
+		if validateKey(name) != nil {
+			continue
+		}
+		keys = append(keys, name)
+		if !s.hierarchicalKeys || s.keyMapper != nil {
+			continue
+		}
+		children, err := s.keyDirs(root.Dir(name))
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			keys = append(keys, name+keySeparator+child)
+		}
+	}
+	return keys, nil
+}
+
+// keys returns sorted keys of states which can be read - having at least one committed
+// version and not deleted
+func (s *DB) keys() ([]string, error) {
+	if s.index != nil && s.asOf == nil {
+		return s.index.keys(dataFile), nil
+	}
+	stateKeys, err := s.stateKeys()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, key := range stateKeys {
+		youngest, exists, err := s.latestFile(key)
+		if err != nil {
+			return nil, err
+		}
+		if exists && youngest.kind == dataFile {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Exists returns true when the state can be read, without opening the reader. Deleted
+// states do not exist. Uses the index when DB was opened WithPreload.
+func (s *DB) Exists(key string) (exists bool, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkKey(key); err != nil {
+		return false, err
+	}
+	if err = s.checkAccess(ReadOperation, key); err != nil {
+		return false, err
+	}
+	if _, ok := s.configFor(key).writeBehind.get(key); ok {
+		return true, nil
+	}
+	if err = s.pendingCommits.wait(key, s.operationTimeout); err != nil {
+		return false, err
+	}
+	latest, exists, err := s.latestFile(key)
+	if err != nil {
+		return false, err
+	}
+	return exists && latest.kind == dataFile, nil
+}
+
+// Keys returns sorted keys of states which can be read. Deleted states are not returned.
+// Uses the index when DB was opened WithPreload.
+func (s *DB) Keys() (keys []string, err error) {
+	defer s.redactError(&err)
+	return s.keys()
+}
+
+// Count returns the number of states which can be read. Deleted states are not counted.
+// Uses the index when DB was opened WithPreload.
+func (s *DB) Count() (count int, err error) {
+	defer s.redactError(&err)
+	keys, err := s.keys()
+	return len(keys), err
+}
+
+// List returns sorted keys of direct children of prefix, which is either empty or a key
+// followed by "/". For example List("a/") returns "a/b" when state "a/b" or "a/b/c" can be
+// read. Empty prefix returns top-level keys. Uses the index when DB was opened WithPreload.
+func (s *DB) List(prefix string) (children []string, err error) {
+	prefix = s.normalizeKey(prefix)
+	defer s.redactError(&err)
+	if prefix != "" {
+		if !strings.HasSuffix(prefix, keySeparator) {
+			return nil, newClientError(fmt.Sprintf("prefix must end with \"%s\": \"%s\"", keySeparator, prefix))
+		}
+		if err = s.checkKey(strings.TrimSuffix(prefix, keySeparator)); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		child := prefix + strings.SplitN(key[len(prefix):], keySeparator, 2)[0]
+		if !seen[child] {
+			seen[child] = true
+			children = append(children, child)
+		}
+	}
+	sort.Strings(children)
+	return children, nil
+}
@@ -0,0 +1,140 @@
+package deebee_test
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Exists(t *testing.T) {
+	t.Run("should return client error for invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, key := range invalidKeys {
+			_, err := db.Exists(key)
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+
+	options := map[string][]deebee.Option{
+		"default": nil,
+		"preload": {deebee.WithPreload()},
+	}
+	for name, opts := range options {
+		t.Run(name, func(t *testing.T) {
+			db := openDB(t, fake.ExistingDir(), opts...)
+			writeData(t, db, "key", []byte("data"))
+			writeData(t, db, "deleted", []byte("data"))
+			require.NoError(t, db.Delete("deleted"))
+
+			t.Run("should return true for existing state", func(t *testing.T) {
+				exists, err := db.Exists("key")
+				require.NoError(t, err)
+				assert.True(t, exists)
+			})
+
+			t.Run("should return false for missing and deleted states", func(t *testing.T) {
+				for _, key := range []string{"missing", "deleted"} {
+					exists, err := db.Exists(key)
+					require.NoError(t, err)
+					assert.False(t, exists)
+				}
+			})
+		})
+	}
+}
+
+func TestDB_Count(t *testing.T) {
+	options := map[string][]deebee.Option{
+		"default": nil,
+		"preload": {deebee.WithPreload()},
+	}
+	for name, opts := range options {
+		t.Run(name, func(t *testing.T) {
+			t.Run("should count states which are not deleted", func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), opts...)
+				writeData(t, db, "a", []byte("data"))
+				writeData(t, db, "b", []byte("data"))
+				writeData(t, db, "deleted", []byte("data"))
+				require.NoError(t, db.Delete("deleted"))
+				// when
+				count, err := db.Count()
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, 2, count)
+			})
+		})
+	}
+}
+
+func TestDB_Keys(t *testing.T) {
+	options := map[string][]deebee.Option{
+		"default":      nil,
+		"preload":      {deebee.WithPreload()},
+		"hierarchical": {deebee.WithHierarchicalKeys()},
+	}
+	for name, opts := range options {
+		t.Run(name, func(t *testing.T) {
+			t.Run("should return sorted keys of states which are not deleted", func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), opts...)
+				writeData(t, db, "b", []byte("data"))
+				writeData(t, db, "a", []byte("data"))
+				writeData(t, db, "deleted", []byte("data"))
+				require.NoError(t, db.Delete("deleted"))
+				// when
+				keys, err := db.Keys()
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, []string{"a", "b"}, keys)
+			})
+		})
+	}
+}
+
+func TestDB_List(t *testing.T) {
+	t.Run("should return client error for invalid prefix", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithHierarchicalKeys())
+		for _, prefix := range []string{"a", "/", "a//", " a/"} {
+			_, err := db.List(prefix)
+			assert.True(t, deebee.IsClientError(err), prefix)
+		}
+	})
+
+	options := map[string][]deebee.Option{
+		"default": {deebee.WithHierarchicalKeys()},
+		"preload": {deebee.WithHierarchicalKeys(), deebee.WithPreload()},
+	}
+	for name, opts := range options {
+		t.Run(name, func(t *testing.T) {
+			db := openDB(t, fake.ExistingDir(), opts...)
+			writeData(t, db, "a", []byte("data"))
+			writeData(t, db, "a/b", []byte("data"))
+			writeData(t, db, "a/b/c", []byte("data"))
+			writeData(t, db, "a/b-x", []byte("data"))
+			writeData(t, db, "a/d/e", []byte("data"))
+			writeData(t, db, "a/deleted", []byte("data"))
+			require.NoError(t, db.Delete("a/deleted"))
+			writeData(t, db, "z", []byte("data"))
+
+			t.Run("should return top-level keys", func(t *testing.T) {
+				children, err := db.List("")
+				require.NoError(t, err)
+				assert.Equal(t, []string{"a", "z"}, children)
+			})
+
+			t.Run("should return children", func(t *testing.T) {
+				children, err := db.List("a/")
+				require.NoError(t, err)
+				assert.Equal(t, []string{"a/b", "a/b-x", "a/d"}, children)
+			})
+
+			t.Run("should return empty list for key without children", func(t *testing.T) {
+				children, err := db.List("z/")
+				require.NoError(t, err)
+				assert.Empty(t, children)
+			})
+		})
+	}
+}
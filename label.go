@@ -0,0 +1,145 @@
+package deebee
+
+import "encoding/json"
+
+// labelsFile is an internal file containing labels of all states
+const labelsFile = "labels"
+
+// Label gives the data version of the state a name, such as "known-good", which can be
+// used by ReaderOfLabel instead of the version number, for example to roll back
+// configuration. Label already given to another version of the state is moved. Labeled
+// versions are not removed by Compact. Returns data not found error when there is no such
+// data version, and restore required error when the version was archived (see WithArchive).
+//
+// Labels are stored in an internal file and are not synchronized between processes.
+func (s *DB) Label(key string, version int, label string) (err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkWritable(); err != nil {
+		return err
+	}
+	if err = s.checkKey(key); err != nil {
+		return err
+	}
+	if err = s.checkAccess(WriteOperation, key); err != nil {
+		return err
+	}
+	if label == "" {
+		return newClientError("empty label")
+	}
+	stateDir, err := s.existingStateDir(key)
+	if err != nil {
+		return err
+	}
+	unlock := s.protectionLocks.lock(key)
+	defer unlock()
+	if _, err = protectableVersion(stateDir, version); err != nil {
+		return err
+	}
+	return s.updateLabels(func(labels map[string]map[string]int) {
+		name := s.physicalKey(key)
+		if labels[name] == nil {
+			labels[name] = map[string]int{}
+		}
+		labels[name][label] = version
+	})
+}
+
+// Unlabel removes the label of the state. Does nothing when there is no such label.
+func (s *DB) Unlabel(key string, label string) (err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkWritable(); err != nil {
+		return err
+	}
+	if err = s.checkKey(key); err != nil {
+		return err
+	}
+	if err = s.checkAccess(WriteOperation, key); err != nil {
+		return err
+	}
+	return s.updateLabels(func(labels map[string]map[string]int) {
+		name := s.physicalKey(key)
+		delete(labels[name], label)
+		if len(labels[name]) == 0 {
+			delete(labels, name)
+		}
+	})
+}
+
+// Labels returns versions of the state by their labels
+func (s *DB) Labels(key string) (_ map[string]int, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkKey(key); err != nil {
+		return nil, err
+	}
+	if err = s.checkAccess(ReadOperation, key); err != nil {
+		return nil, err
+	}
+	return s.stateLabels(key)
+}
+
+// ReaderOfLabel returns Reader for the data version with given label. Returns data not
+// found error when there is no such label, or the labeled version no longer exists.
+func (s *DB) ReaderOfLabel(key string, label string) (_ *Reader, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	labels, err := s.Labels(key)
+	if err != nil {
+		return nil, err
+	}
+	version, ok := labels[label]
+	if !ok {
+		return nil, &dataNotFoundError{}
+	}
+	return s.VersionReader(key, version)
+}
+
+// stateLabels returns labels of the state. Returns empty map when there are none.
+func (s *DB) stateLabels(key string) (map[string]int, error) {
+	s.labelsMutex.Lock()
+	defer s.labelsMutex.Unlock()
+	labels, err := s.readLabels()
+	if err != nil {
+		return nil, err
+	}
+	stateLabels := map[string]int{}
+	for label, version := range labels[s.physicalKey(key)] {
+		stateLabels[label] = version
+	}
+	return stateLabels, nil
+}
+
+func (s *DB) updateLabels(update func(labels map[string]map[string]int)) error {
+	s.labelsMutex.Lock()
+	defer s.labelsMutex.Unlock()
+	labels, err := s.readLabels()
+	if err != nil {
+		return err
+	}
+	update(labels)
+	content, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+	return s.writeInternalFile(labelsFile, content)
+}
+
+// readLabels returns versions by their labels, for each physical key, so keys are not stored
+// in plaintext when DB was opened WithKeyMapper
+func (s *DB) readLabels() (map[string]map[string]int, error) {
+	labels := map[string]map[string]int{}
+	files, err := s.internalFiles()
+	if err != nil || !contains(files, labelsFile) {
+		return labels, err
+	}
+	content, err := s.readInternalFile(labelsFile)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, &labels); err != nil {
+		return nil, &corruptedError{message: "labels file is corrupted"}
+	}
+	return labels, nil
+}
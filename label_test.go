@@ -0,0 +1,140 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Label(t *testing.T) {
+	t.Run("should return data not found when version does not exist", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		tombstone := latestVersion(t, db, "key")
+		// expect
+		assert.True(t, deebee.IsDataNotFound(db.Label("missing", 0, "label")))
+		assert.True(t, deebee.IsDataNotFound(db.Label("key", tombstone+1, "label")))
+		assert.True(t, deebee.IsDataNotFound(db.Label("key", tombstone, "label")))
+	})
+
+	t.Run("should return client error for empty label", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.Label("key", 0, "")
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should read labeled version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("good"))
+		good := latestVersion(t, db, "key")
+		writeData(t, db, "key", []byte("bad"))
+		// when
+		require.NoError(t, db.Label("key", good, "known-good"))
+		// then
+		reader, err := db.ReaderOfLabel("key", "known-good")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("good"), readAll(t, reader))
+	})
+
+	t.Run("should move label to another version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("v1"))
+		require.NoError(t, db.Label("key", latestVersion(t, db, "key"), "label"))
+		writeData(t, db, "key", []byte("v2"))
+		v2 := latestVersion(t, db, "key")
+		// when
+		require.NoError(t, db.Label("key", v2, "label"))
+		// then
+		labels, err := db.Labels("key")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"label": v2}, labels)
+	})
+
+	t.Run("should keep labels of other keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key1", []byte("data"))
+		writeData(t, db, "key2", []byte("data"))
+		v1 := latestVersion(t, db, "key1")
+		// when
+		require.NoError(t, db.Label("key1", v1, "label"))
+		require.NoError(t, db.Label("key2", latestVersion(t, db, "key2"), "other"))
+		// then
+		labels, err := db.Labels("key1")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"label": v1}, labels)
+	})
+
+	t.Run("should persist labels", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Label("key", 0, "label"))
+		// when
+		labels, err := openDB(t, dir).Labels("key")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"label": 0}, labels)
+	})
+
+	t.Run("should protect version from Compact", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		old := latestVersion(t, db, "key")
+		writeData(t, db, "key", []byte("new"))
+		require.NoError(t, db.Label("key", old, "label"))
+		// when
+		err := db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		assertVersionsCount(t, db, "key", 2)
+	})
+}
+
+func TestDB_Unlabel(t *testing.T) {
+	t.Run("should remove label", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Label("key", 0, "label"))
+		// when
+		err := db.Unlabel("key", "label")
+		// then
+		require.NoError(t, err)
+		_, err = db.ReaderOfLabel("key", "label")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should do nothing when there is no such label", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		err := db.Unlabel("key", "label")
+		// then
+		assert.NoError(t, err)
+	})
+}
+
+func TestDB_ReaderOfLabel(t *testing.T) {
+	t.Run("should return data not found when there is no such label", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		_, err := db.ReaderOfLabel("key", "missing")
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return client error for invalid key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, key := range invalidKeys {
+			_, err := db.ReaderOfLabel(key, "label")
+			assert.True(t, deebee.IsClientError(err), key)
+		}
+	})
+}
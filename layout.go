@@ -0,0 +1,301 @@
+package deebee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// shardedLayoutMarker is a file stored in the root dir of DB using sharded layout
+const shardedLayoutMarker = "sharded"
+
+// listParallelism is the number of shard dirs listed concurrently
+const listParallelism = 16
+
+// WithShardedLayout stores each state dir inside one of 256 intermediate dirs chosen by
+// the hash of the key, for example "3f/key" instead of "key". Use it when DB stores
+// many keys, because filesystems such as ext4 or NFS become slow when a directory
+// contains millions of entries.
+//
+// Layout is recorded in the dir on the first Open. Open returns client error when dir
+// already contains states stored using the default, flat layout - use MigrateToShardedLayout
+// to convert them. Layout of existing dir is not checked when the option is not used.
+func WithShardedLayout() Option {
+	return func(db *DB) error {
+		db.sharded = true
+		return nil
+	}
+}
+
+// shard returns name of the intermediate dir for the key
+func shard(key string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return fmt.Sprintf("%02x", h.Sum32()%256)
+}
+
+// stateDir returns dir of the state with given key. Does not check if dir exists.
+func (s *DB) stateDir(key string) Dir {
+	return stateDirIn(s.dir, s.physicalKey(key), s.sharded)
+}
+
+func stateDirIn(root Dir, key string, sharded bool) Dir {
+	if sharded {
+		return keyDir(root.Dir(shard(key)), key)
+	}
+	return keyDir(root, key)
+}
+
+// statePathIn returns path of the state dir inside root path
+func statePathIn(root string, key string, sharded bool) string {
+	if sharded {
+		return filepath.Join(root, shard(key), filepath.FromSlash(key))
+	}
+	return filepath.Join(root, filepath.FromSlash(key))
+}
+
+// mkdirState creates the state dir together with the intermediate dirs
+func (s *DB) mkdirState(key string, stateDir Dir) error {
+	if err := s.mkdirStateDirs(s.physicalKey(key), stateDir); err != nil {
+		return err
+	}
+	if err := s.writeKeyManifest(key, stateDir); err != nil {
+		return err
+	}
+	return s.syncStateParents(s.physicalKey(key))
+}
+
+func (s *DB) mkdirStateDirs(physicalKey string, stateDir Dir) error {
+	if s.sharded {
+		if err := s.dir.Dir(shard(physicalKey)).Mkdir(); err != nil {
+			return err
+		}
+		if s.hierarchicalKeys {
+			return mkdirKey(s.dir.Dir(shard(physicalKey)), physicalKey)
+		}
+	} else if s.hierarchicalKeys {
+		return mkdirKey(s.dir, physicalKey)
+	}
+	return stateDir.Mkdir()
+}
+
+// checkLayout records sharded layout in the empty dir or checks if it was recorded before.
+// Returns client error when dir uses sharded layout, but DB was opened without the option.
+func (s *DB) checkLayout() error {
+	if !s.sharded {
+		// marker is opened first, so the root dir is not listed on Open. Opening succeeds
+		// also for the dir of the state with the same key, therefore files are listed then.
+		marker, err := s.dir.FileReader(shardedLayoutMarker)
+		if err != nil {
+			return nil
+		}
+		_ = marker.Close()
+		marked, err := hasShardedLayoutMarker(s.dir)
+		if err != nil {
+			return err
+		}
+		if marked {
+			return newClientError("dir uses sharded layout, open it WithShardedLayout")
+		}
+		return nil
+	}
+	marked, err := hasShardedLayoutMarker(s.dir)
+	if err != nil || marked {
+		return err
+	}
+	dirs, err := s.dir.ListDirs()
+	if err != nil {
+		return err
+	}
+	for _, name := range dirs {
+		if name != internalDirName {
+			return newClientError("dir uses flat layout, use MigrateToShardedLayout first")
+		}
+	}
+	if s.asOf != nil {
+		return nil // read-only view does not write the marker
+	}
+	return writeShardedLayoutMarker(s.dir)
+}
+
+func hasShardedLayoutMarker(dir Dir) (bool, error) {
+	files, err := dir.ListFiles()
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		if file == shardedLayoutMarker {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func writeShardedLayoutMarker(dir Dir) error {
+	marker, err := dir.FileWriter(shardedLayoutMarker)
+	if err != nil {
+		return err
+	}
+	if err = marker.Sync(); err != nil {
+		_ = marker.Close()
+		return err
+	}
+	return marker.Close()
+}
+
+// shardedStateKeys returns sorted keys of all state dirs stored in sharded layout. Shard
+// dirs are listed concurrently, because there are up to 256 of them.
+func (s *DB) shardedStateKeys() ([]string, error) {
+	shards, err := s.dir.ListDirs()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		mutex sync.Mutex
+		keys  []string
+	)
+	err = runParallel(context.Background(), shards, listParallelism, func(shardName string) error {
+		if len(shardName) != 2 {
+			return nil
+		}
+		dirs, err := s.keyDirs(s.dir.Dir(shardName))
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, name := range dirs {
+			if shard(name) == shardName {
+				names = append(names, name)
+			}
+		}
+		shardKeys, err := s.decodeKeys(s.dir.Dir(shardName), names)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		keys = append(keys, shardKeys...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// moveState moves all committed files of the state to another dir. Used when dirs can't
+// be renamed, therefore it is not atomic.
+func moveState(src, dst Dir) error {
+	files, err := committedFiles(src)
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	err = iterateFiles(dst, func(file string) bool {
+		existing[file] = true
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !existing[file] {
+			if _, err = copyFile(src, dst, file); err != nil {
+				return err
+			}
+		}
+	}
+	if err = copyKeyManifest(src, dst); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err = src.DeleteFile(file); err != nil {
+			return err
+		}
+	}
+	manifest, err := hasKeyManifest(src)
+	if err != nil || !manifest {
+		return err
+	}
+	return src.DeleteFile(keyManifestFile)
+}
+
+// committedFiles returns names of all files of the state, except temp files
+func committedFiles(stateDir Dir) ([]string, error) {
+	var files []string
+	err := iterateFiles(stateDir, func(file string) bool {
+		if f, err := parseFilename(file); err == nil && f.kind != tempFile {
+			files = append(files, file)
+		}
+		return true
+	})
+	return files, err
+}
+
+// MigrateToShardedLayout converts dir using the default, flat layout to the layout used by
+// DB opened WithShardedLayout. All versions are moved. Migration can be resumed after
+// failure by running it again. Does nothing when dir already uses sharded layout. DB using
+// the dir must not be used during the migration.
+//
+// Dirs of states are left empty, because Dir does not support removing dirs. Keys created
+// WithHierarchicalKeys are not supported.
+//
+// progress is called after each key and can be nil.
+func MigrateToShardedLayout(ctx context.Context, dir Dir, progress ProgressFunc) error {
+	if dir == nil {
+		return errors.New("nil dir")
+	}
+	marked, err := hasShardedLayoutMarker(dir)
+	if err != nil || marked {
+		return err
+	}
+	flat, err := Open(dir)
+	if err != nil {
+		return err
+	}
+	sharded := &DB{dir: dir, sharded: true}
+	err = flat.forEachKey(ctx, progress, func(key string) (int64, error) {
+		src := flat.stateDir(key)
+		files, err := committedFiles(src)
+		if err != nil || len(files) == 0 {
+			// shard dirs and dirs of states moved before the migration was resumed
+			return 0, err
+		}
+		dst := sharded.stateDir(key)
+		if err := sharded.mkdirState(key, dst); err != nil {
+			return 0, err
+		}
+		return 0, moveState(src, dst)
+	})
+	if err != nil {
+		return err
+	}
+	return writeShardedLayoutMarker(dir)
+}
+
+// VersionPath returns path of the file storing given data version of the state. Useful for
+// operational tools inspecting or damaging files directly. Works only when DB uses OsDir or
+// Dir created using NewOsDir. Returns data not found error when there is no such version.
+func (s *DB) VersionPath(key string, version int) (_ string, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	root, ok := asOsDir(s.dir)
+	if !ok {
+		return "", newClientError("version path is available only for OsDir")
+	}
+	versions, err := s.Versions(key)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if v.Version == version && !v.Deleted && !v.Archived {
+			return filepath.Join(statePathIn(string(root.OsDir), s.physicalKey(key), s.sharded), newFilename(version).name), nil
+		}
+	}
+	return "", &dataNotFoundError{}
+}
@@ -0,0 +1,193 @@
+package deebee_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithShardedLayout(t *testing.T) {
+	t.Run("should return client error when dir uses flat layout", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		// when
+		db, err := deebee.Open(dir, deebee.WithShardedLayout())
+		// then
+		assert.True(t, deebee.IsClientError(err))
+		assert.Nil(t, db)
+	})
+
+	t.Run("should return client error when dir uses sharded layout, but option is absent", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir, deebee.WithShardedLayout()), "key", []byte("data"))
+		// when
+		db, err := deebee.Open(dir)
+		// then
+		assert.True(t, deebee.IsClientError(err))
+		assert.Nil(t, db)
+	})
+
+	t.Run("should reopen flat dir with state named like the layout marker", func(t *testing.T) {
+		dir := existingRootDir(t)
+		writeData(t, openDB(t, dir), "sharded", []byte("data"))
+		// when
+		db, err := deebee.Open(dir)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "sharded"))
+	})
+
+	t.Run("should store state dirs in intermediate dirs", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithShardedLayout())
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		dirs, err := dir.ListDirs()
+		require.NoError(t, err)
+		require.Len(t, dirs, 1)
+		assert.Len(t, dirs[0], 2)
+		assert.Equal(t, []byte("data"), readData(t, openDB(t, dir, deebee.WithShardedLayout()), "key"))
+	})
+
+	t.Run("should iterate over all keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithShardedLayout())
+		for i := 0; i < 10; i++ {
+			writeData(t, db, fmt.Sprintf("key-%d", i), []byte("data"))
+		}
+		var total int
+		// when
+		_, err := db.Verify(context.Background(), func(progress deebee.Progress) {
+			total = progress.Total
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 10, total)
+	})
+
+	t.Run("should rename state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithShardedLayout())
+		for i := 0; i < 10; i++ {
+			oldKey := fmt.Sprintf("old-%d", i)
+			newKey := fmt.Sprintf("new-%d", i)
+			writeData(t, db, oldKey, []byte("v1"))
+			writeData(t, db, oldKey, []byte("v2"))
+			// when
+			err := db.Rename(oldKey, newKey)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []byte("v2"), readData(t, db, newKey))
+			versions, err := db.Versions(newKey)
+			require.NoError(t, err)
+			assert.Len(t, versions, 2)
+			_, err = db.Reader(oldKey)
+			assert.True(t, deebee.IsDataNotFound(err))
+		}
+	})
+}
+
+func TestMigrateToShardedLayout(t *testing.T) {
+	t.Run("should move all versions", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			writeData(t, db, key, []byte("old"))
+			writeData(t, db, key, []byte(key))
+		}
+		// when
+		err := deebee.MigrateToShardedLayout(context.Background(), dir, nil)
+		// then
+		require.NoError(t, err)
+		sharded := openDB(t, dir, deebee.WithShardedLayout())
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			assert.Equal(t, []byte(key), readData(t, sharded, key))
+			versions, err := sharded.Versions(key)
+			require.NoError(t, err)
+			assert.Len(t, versions, 2)
+		}
+	})
+
+	t.Run("should be resumable", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		require.NoError(t, deebee.MigrateToShardedLayout(context.Background(), dir, nil))
+		// when
+		err := deebee.MigrateToShardedLayout(context.Background(), dir, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, openDB(t, dir, deebee.WithShardedLayout()), "key"))
+	})
+
+	t.Run("should not create state dirs for shard dirs when resumed after failure", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		for i := 0; i < 10; i++ {
+			writeData(t, db, fmt.Sprintf("key-%d", i), []byte("data"))
+		}
+		require.NoError(t, deebee.MigrateToShardedLayout(context.Background(), dir, nil))
+		// the migration failed right before recording the sharded layout
+		require.NoError(t, dir.DeleteFile("sharded"))
+		dirs, err := dir.ListDirs()
+		require.NoError(t, err)
+		// when
+		err = deebee.MigrateToShardedLayout(context.Background(), dir, nil)
+		// then
+		require.NoError(t, err)
+		dirsAfterResume, err := dir.ListDirs()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, dirs, dirsAfterResume)
+		keys, err := openDB(t, dir, deebee.WithShardedLayout()).Keys()
+		require.NoError(t, err)
+		assert.Len(t, keys, 10)
+	})
+}
+
+func TestDB_VersionPath(t *testing.T) {
+	t.Run("should return client error when dir is not OsDir", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		_, err := db.VersionPath("key", 1)
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should return data not found for missing version", func(t *testing.T) {
+		db := openDB(t, deebee.OsDir(createTempDir(t)))
+		writeData(t, db, "key", []byte("data"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		_, err = db.VersionPath("key", versions[0].Version+1)
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	layouts := map[string][]deebee.Option{
+		"flat":    nil,
+		"sharded": {deebee.WithShardedLayout()},
+	}
+	for name, opts := range layouts {
+		t.Run(name, func(t *testing.T) {
+			t.Run("should return path of the version file", func(t *testing.T) {
+				db := openDB(t, deebee.OsDir(createTempDir(t)), opts...)
+				writeData(t, db, "key", []byte("data"))
+				versions, err := db.Versions("key")
+				require.NoError(t, err)
+				// when
+				path, err := db.VersionPath("key", versions[0].Version)
+				// then
+				require.NoError(t, err)
+				data, err := ioutil.ReadFile(path)
+				require.NoError(t, err)
+				assert.Equal(t, []byte("data"), data)
+			})
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package deebee
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const leaseSuffix = ".lease"
+
+// Lease gives its holder exclusive right to do something, for example to write to the DB
+// when many replicas share the dir on a network filesystem. Lease expires after ttl, unless
+// it is renewed. It is up to the application to stop writing once the lease is lost.
+type Lease struct {
+	db     *DB
+	name   string
+	ttl    time.Duration
+	holder string
+
+	mutex      sync.Mutex
+	generation int
+	expires    time.Time
+}
+
+type leaseHeldError struct {
+	name string
+}
+
+func (e *leaseHeldError) Error() string {
+	return fmt.Sprintf("lease \"%s\" is held by someone else", e.name)
+}
+
+// IsLeaseHeld returns true when the lease could not be acquired or renewed, because it is
+// held by someone else
+func IsLeaseHeld(err error) bool {
+	var held *leaseHeldError
+	return errors.As(err, &held)
+}
+
+// TryAcquireLease acquires the lease with given name, valid for ttl. Returns lease held
+// error (see IsLeaseHeld) when the lease is held by someone else and not expired yet.
+//
+// Lease is stored as internal files (see InternalFiles). Each acquisition, renewal and
+// release creates a file with the next generation number, and only the youngest one is
+// kept, so generation numbers are never reused. Dir.FileWriter must fail when the file already exists, so
+// only one replica can create given generation. Expiration time is based on the clock of
+// the replica which acquired the lease, therefore clocks of replicas must be synchronized
+// and ttl should be much longer than the expected clock skew.
+func (s *DB) TryAcquireLease(name string, ttl time.Duration) (_ *Lease, err error) {
+	defer s.redactError(&err)
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := validateKey(name); err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, newClientError("lease ttl must be positive")
+	}
+	holder, err := newLeaseHolder()
+	if err != nil {
+		return nil, err
+	}
+	generation, current, err := s.youngestLease(name)
+	if err != nil {
+		return nil, err
+	}
+	held, err := s.leaseHeld(name, generation, current, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if held {
+		return nil, &leaseHeldError{name: name}
+	}
+	lease := &Lease{
+		db:         s,
+		name:       name,
+		ttl:        ttl,
+		holder:     holder,
+		generation: generation,
+	}
+	if err = lease.writeNextGeneration(); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// Renew extends the lease by its ttl. Returns lease held error (see IsLeaseHeld) when
+// the lease expired and was acquired by someone else.
+func (l *Lease) Renew() (err error) {
+	defer l.db.redactError(&err)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	generation, _, err := l.db.youngestLease(l.name)
+	if err != nil {
+		return err
+	}
+	if generation != l.generation {
+		return &leaseHeldError{name: l.name}
+	}
+	return l.writeNextGeneration()
+}
+
+// Release gives up the lease, so others can acquire it immediately. The release is
+// recorded as the next generation which is already expired, instead of removing the lease
+// file, so generations are never reused and the released Lease can't be renewed.
+func (l *Lease) Release() (err error) {
+	defer l.db.redactError(&err)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	generation, _, err := l.db.youngestLease(l.name)
+	if err != nil {
+		return err
+	}
+	if generation != l.generation {
+		return nil // lease was acquired by someone else
+	}
+	err = l.createGeneration(l.generation+1, time.Unix(0, 0))
+	if IsLeaseHeld(err) {
+		return nil // lease expired and was acquired by someone else
+	}
+	if err != nil {
+		return err
+	}
+	_ = l.db.internalDir().DeleteFile(leaseFilename(l.name, l.generation))
+	return nil
+}
+
+// Writer returns Writer for new version of state with given key, fenced by the lease.
+// Close commits the data only when the lease was not acquired by someone else in the
+// meantime, otherwise lease held error (see IsLeaseHeld) is returned and the data is
+// discarded. This prevents a paused former holder from overwriting newer state written by
+// the new holder. Data is written directly, even when the key is configured WithWriteBehind.
+//
+// The check is done right before the version is committed, therefore a commit racing with
+// acquisition of the lease by someone else may still succeed. Use ttl much longer than the
+// time needed to commit.
+func (l *Lease) Writer(key string) (*Writer, error) {
+	key = l.db.normalizeKey(key)
+	w, err := l.db.newWriterWithTimeout(key)
+	if err != nil {
+		return nil, l.db.redact(err, key)
+	}
+	w.fence = l.checkHeld
+	return l.db.wrapWriter(key, w, w), nil
+}
+
+// Epoch returns the fencing token of the lease. Epoch increases each time the lease is
+// acquired or renewed, so it can be passed to external systems which reject requests with
+// an epoch older than the one they have already seen. Epoch is the generation of the
+// lease file, which is never reused, even after Release.
+func (l *Lease) Epoch() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.generation
+}
+
+// checkHeld returns lease held error when the lease was acquired by someone else
+func (l *Lease) checkHeld() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	generation, _, err := l.db.youngestLease(l.name)
+	if err != nil {
+		return err
+	}
+	if generation != l.generation {
+		return &leaseHeldError{name: l.name}
+	}
+	return nil
+}
+
+// Expires returns the time when the lease expires, unless it is renewed
+func (l *Lease) Expires() time.Time {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.expires
+}
+
+// writeNextGeneration creates the file with the next generation of the lease and removes
+// the previous one, so the youngest generation is always kept. Must be called with mutex
+// locked.
+func (l *Lease) writeNextGeneration() error {
+	next := l.generation + 1
+	expires := time.Now().Add(l.ttl)
+	if err := l.createGeneration(next, expires); err != nil {
+		return err
+	}
+	previous := l.generation
+	l.generation = next
+	l.expires = expires
+	if previous > 0 {
+		_ = l.db.internalDir().DeleteFile(leaseFilename(l.name, previous))
+	}
+	return nil
+}
+
+// createGeneration creates the file of given generation. Returns lease held error when
+// the file was created by someone else. Must be called with mutex locked.
+func (l *Lease) createGeneration(generation int, expires time.Time) error {
+	dir := l.db.internalDir()
+	if err := dir.Mkdir(); err != nil {
+		return err
+	}
+	file, err := dir.FileWriter(leaseFilename(l.name, generation))
+	if err != nil {
+		if exists, _ := l.db.leaseExists(l.name, generation); exists {
+			return &leaseHeldError{name: l.name}
+		}
+		return err
+	}
+	content := fmt.Sprintf("%s\n%d", l.holder, expires.UnixNano())
+	if _, err = file.Write([]byte(content)); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+type leaseFile struct {
+	expires time.Time
+}
+
+// leaseHeld returns true when the youngest generation of the lease did not expire. Damaged
+// file may be the one which is being written right now, therefore it is considered valid
+// for ttl since it was modified (when Dir implements FileModTimer).
+func (s *DB) leaseHeld(name string, generation int, current *leaseFile, ttl time.Duration) (bool, error) {
+	if generation == 0 {
+		return false, nil
+	}
+	if current != nil {
+		return time.Now().Before(current.expires), nil
+	}
+	modTimer, ok := s.internalDir().(FileModTimer)
+	if !ok {
+		return false, nil
+	}
+	modTime, err := modTimer.FileModTime(leaseFilename(name, generation))
+	if err != nil {
+		return false, err
+	}
+	return time.Now().Before(modTime.Add(ttl)), nil
+}
+
+// youngestLease returns the youngest generation of the lease. Returns nil leaseFile when
+// there is no such lease or its file is damaged.
+//
+// The youngest file may be removed by concurrent renewal or release between listing and
+// reading it. The next generation is always created before the previous one is removed,
+// so the files are listed again in such case.
+func (s *DB) youngestLease(name string) (int, *leaseFile, error) {
+	for {
+		youngest, err := s.youngestLeaseGeneration(name)
+		if err != nil {
+			return 0, nil, err
+		}
+		if youngest == 0 {
+			return 0, nil, nil
+		}
+		content, err := s.readInternalFile(leaseFilename(name, youngest))
+		if err != nil {
+			if exists, existsErr := s.leaseExists(name, youngest); existsErr == nil && !exists {
+				continue
+			}
+			return 0, nil, err
+		}
+		return youngest, parseLeaseFile(string(content)), nil
+	}
+}
+
+func (s *DB) youngestLeaseGeneration(name string) (int, error) {
+	files, err := s.internalFiles()
+	if err != nil {
+		return 0, err
+	}
+	youngest := 0
+	for _, file := range files {
+		if generation, ok := parseLeaseFilename(name, file); ok && generation > youngest {
+			youngest = generation
+		}
+	}
+	return youngest, nil
+}
+
+func (s *DB) leaseExists(name string, generation int) (bool, error) {
+	files, err := s.internalFiles()
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		if file == leaseFilename(name, generation) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func leaseFilename(name string, generation int) string {
+	return fmt.Sprintf("%s.%d%s", name, generation, leaseSuffix)
+}
+
+func parseLeaseFilename(name, file string) (int, bool) {
+	prefix := name + "."
+	if !strings.HasPrefix(file, prefix) || !strings.HasSuffix(file, leaseSuffix) {
+		return 0, false
+	}
+	generation, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(file, prefix), leaseSuffix))
+	if err != nil || generation <= 0 {
+		return 0, false
+	}
+	return generation, true
+}
+
+// parseLeaseFile returns nil when content is damaged, for example because the process
+// crashed while acquiring the lease or the file is being written right now.
+func parseLeaseFile(content string) *leaseFile {
+	lines := strings.Split(content, "\n")
+	if len(lines) != 2 {
+		return nil
+	}
+	expires, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &leaseFile{expires: time.Unix(0, expires)}
+}
+
+func newLeaseHolder() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,306 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_TryAcquireLease(t *testing.T) {
+	t.Run("should return client error for invalid name", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, name := range invalidKeys {
+			_, err := db.TryAcquireLease(name, time.Minute)
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+
+	t.Run("should return client error for invalid ttl", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		_, err := db.TryAcquireLease("leader", 0)
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should acquire lease", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		before := time.Now()
+		// when
+		lease, err := db.TryAcquireLease("leader", time.Minute)
+		// then
+		require.NoError(t, err)
+		assert.True(t, lease.Expires().After(before.Add(time.Minute-time.Millisecond)))
+	})
+
+	t.Run("should not acquire lease held by another replica", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		_, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		replica := openDB(t, dir)
+		// when
+		_, err = replica.TryAcquireLease("leader", time.Minute)
+		// then
+		assert.True(t, deebee.IsLeaseHeld(err))
+	})
+
+	t.Run("should not acquire lease renewed concurrently", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		lease, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		replica := openDB(t, dir)
+		renewed := make(chan error)
+		go func() {
+			for i := 0; i < 1000; i++ {
+				if err := lease.Renew(); err != nil {
+					renewed <- err
+					return
+				}
+			}
+			renewed <- nil
+		}()
+		// when
+		for i := 0; i < 1000; i++ {
+			_, err = replica.TryAcquireLease("leader", time.Minute)
+			// then
+			require.True(t, deebee.IsLeaseHeld(err), "unexpected error: %v", err)
+		}
+		require.NoError(t, <-renewed)
+	})
+
+	t.Run("should acquire lease with different name", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		_, err := db.TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// when
+		_, err = db.TryAcquireLease("other", time.Minute)
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("should acquire expired lease", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		expired, err := openDB(t, dir).TryAcquireLease("leader", time.Millisecond)
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+		// when
+		_, err = openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		// then
+		require.NoError(t, err)
+		// and
+		err = expired.Renew()
+		assert.True(t, deebee.IsLeaseHeld(err))
+	})
+
+	t.Run("should acquire released lease", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		lease, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, lease.Release())
+		// when
+		_, err = openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("should not acquire lease with file being written", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		internalDir := dir.Dir(".deebee")
+		require.NoError(t, internalDir.Mkdir())
+		file, err := internalDir.FileWriter("leader.1.lease")
+		require.NoError(t, err)
+		defer file.Close()
+		// when
+		_, err = openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		// then
+		assert.True(t, deebee.IsLeaseHeld(err))
+	})
+
+	t.Run("should not make the lease a state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		_, err := db.TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// expect
+		count, err := db.Count()
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
+
+func TestLease_Renew(t *testing.T) {
+	t.Run("should extend the lease", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		lease, err := openDB(t, dir).TryAcquireLease("leader", 10*time.Millisecond)
+		require.NoError(t, err)
+		expires := lease.Expires()
+		time.Sleep(20 * time.Millisecond)
+		// when
+		err = lease.Renew()
+		// then
+		require.NoError(t, err)
+		assert.True(t, lease.Expires().After(expires))
+		_, err = openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		assert.True(t, deebee.IsLeaseHeld(err))
+	})
+
+	t.Run("should keep only the youngest lease file", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		lease, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// when
+		require.NoError(t, lease.Renew())
+		require.NoError(t, lease.Renew())
+		// then
+		files, err := dir.Dir(".deebee").ListFiles()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"leader.3.lease"}, files)
+	})
+}
+
+func TestLease_Release(t *testing.T) {
+	t.Run("should not let released lease be renewed after it was acquired again", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		released, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, released.Release())
+		_, err = openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// when
+		err = released.Renew()
+		// then
+		assert.True(t, deebee.IsLeaseHeld(err))
+	})
+
+	t.Run("should not let released lease be renewed", func(t *testing.T) {
+		lease, err := openDB(t, fake.ExistingDir()).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, lease.Release())
+		// when
+		err = lease.Renew()
+		// then
+		assert.True(t, deebee.IsLeaseHeld(err))
+	})
+
+	t.Run("should keep the youngest generation", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		lease, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// when
+		require.NoError(t, lease.Release())
+		// then
+		files, err := dir.Dir(".deebee").ListFiles()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"leader.2.lease"}, files)
+	})
+
+	t.Run("should not remove lease acquired by someone else", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		expired, err := openDB(t, dir).TryAcquireLease("leader", time.Millisecond)
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+		_, err = openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// when
+		err = expired.Release()
+		// then
+		require.NoError(t, err)
+		_, err = openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		assert.True(t, deebee.IsLeaseHeld(err))
+	})
+}
+
+func TestLease_Writer(t *testing.T) {
+	t.Run("should commit data while the lease is held", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		lease, err := db.TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		writer, err := lease.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		require.NoError(t, lease.Renew())
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should reject data when the lease was acquired by someone else", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("old"))
+		expired, err := db.TryAcquireLease("leader", time.Millisecond)
+		require.NoError(t, err)
+		writer, err := expired.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("stale"))
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+		_, err = openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		assert.True(t, deebee.IsLeaseHeld(err))
+		assert.Equal(t, []byte("old"), readData(t, db, "key"))
+		assertVersionsCount(t, db, "key", 1)
+	})
+
+	t.Run("should reject data after the lease was released", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		lease, err := db.TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		writer, err := lease.Writer("key")
+		require.NoError(t, err)
+		require.NoError(t, lease.Release())
+		// when
+		err = writer.Close()
+		// then
+		assert.True(t, deebee.IsLeaseHeld(err))
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return client error for invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		lease, err := db.TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		for _, key := range invalidKeys {
+			_, err = lease.Writer(key)
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+}
+
+func TestLease_Epoch(t *testing.T) {
+	t.Run("should increase epoch on each acquisition and renewal", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		expired, err := openDB(t, dir).TryAcquireLease("leader", time.Millisecond)
+		require.NoError(t, err)
+		first := expired.Epoch()
+		time.Sleep(2 * time.Millisecond)
+		lease, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		second := lease.Epoch()
+		// when
+		require.NoError(t, lease.Renew())
+		// then
+		assert.Greater(t, second, first)
+		assert.Greater(t, lease.Epoch(), second)
+	})
+
+	t.Run("should increase epoch when lease is acquired after release", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		released, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, released.Release())
+		// when
+		lease, err := openDB(t, dir).TryAcquireLease("leader", time.Minute)
+		// then
+		require.NoError(t, err)
+		assert.Greater(t, lease.Epoch(), released.Epoch())
+	})
+}
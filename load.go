@@ -0,0 +1,71 @@
+package deebee
+
+import (
+	"fmt"
+	"io"
+)
+
+// Load passes the latest data version of the key to load, which should decode and validate
+// it. When load returns error, for example because a bad deployment persisted broken state,
+// older versions are tried, from the youngest to the oldest, until load succeeds. Versions
+// written before the state was deleted are not tried. Returns the version which was loaded.
+//
+// Returns data not found error when there is no state with given key or it was deleted.
+// When no version could be loaded, the error returned by load for the latest version is
+// returned. Errors opening the reader, such as Dir errors, are returned immediately.
+func (s *DB) Load(key string, load func(r io.Reader) error) (version int, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkKey(key); err != nil {
+		return 0, err
+	}
+	if err = s.checkAccess(ReadOperation, key); err != nil {
+		return 0, err
+	}
+	if err = s.flushWriteBehindKey(key); err != nil {
+		return 0, err
+	}
+	latest, exists, err := s.latestFile(key)
+	if err != nil {
+		return 0, err
+	}
+	if !exists || latest.kind == tombstoneFile {
+		return 0, &dataNotFoundError{}
+	}
+	versions, err := stateVersions(s.stateDir(key))
+	if err != nil {
+		return 0, err
+	}
+	config := s.configFor(key)
+	var latestErr error
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i].Version
+		if v > latest.version {
+			continue // written after the time DB was opened OpenAsOf
+		}
+		if versions[i].Deleted {
+			break
+		}
+		if versions[i].Archived {
+			continue
+		}
+		reader, err := s.versionReader(key, config, newFilename(v), ReaderOptions{})
+		if err != nil {
+			return 0, err
+		}
+		err = load(reader)
+		if closeErr := reader.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			return v, nil
+		}
+		if latestErr == nil {
+			latestErr = fmt.Errorf("loading version %d failed: %w", v, err)
+		}
+	}
+	if latestErr == nil {
+		return 0, &dataNotFoundError{}
+	}
+	return 0, latestErr
+}
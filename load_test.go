@@ -0,0 +1,134 @@
+package deebee_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Load(t *testing.T) {
+	t.Run("should return client error for invalid key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		_, err := db.Load("/", loadInto(nil))
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should return data not found for missing and deleted states", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "deleted", []byte("data"))
+		require.NoError(t, db.Delete("deleted"))
+		for _, key := range []string{"missing", "deleted"} {
+			_, err := db.Load(key, loadInto(nil))
+			assert.True(t, deebee.IsDataNotFound(err))
+		}
+	})
+
+	t.Run("should load latest version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		var data []byte
+		// when
+		version, err := db.Load("key", loadInto(&data))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), data)
+		assert.Equal(t, latestVersion(t, db, "key"), version)
+	})
+
+	t.Run("should fall back to older version when validation failed", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("valid"))
+		writeData(t, db, "key", []byte("broken"))
+		writeData(t, db, "key", []byte("broken"))
+		var data []byte
+		// when
+		_, err := db.Load("key", rejecting("broken", &data))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("valid"), data)
+	})
+
+	t.Run("should fall back to older version when checksum does not match", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("valid"))
+		writeData(t, db, "key", []byte("corrupted"))
+		latest := strconv.Itoa(latestVersion(t, db, "key"))
+		require.NoError(t, dir.Dir("key").(fake.Dir).Corrupt(latest))
+		var data []byte
+		// when
+		_, err := db.Load("key", loadInto(&data))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("valid"), data)
+	})
+
+	t.Run("should not load versions written before state was deleted", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("valid"))
+		require.NoError(t, db.Delete("key"))
+		writeData(t, db, "key", []byte("broken"))
+		// when
+		_, err := db.Load("key", rejecting("broken", nil))
+		// then
+		require.Error(t, err)
+		assert.False(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return error of latest version when no version is valid", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("1"))
+		writeData(t, db, "key", []byte("2"))
+		var failedWith []string
+		// when
+		_, err := db.Load("key", func(r io.Reader) error {
+			data, _ := ioutil.ReadAll(r)
+			failedWith = append(failedWith, string(data))
+			return errors.New("invalid " + string(data))
+		})
+		// then
+		assert.EqualError(t, err, "loading version "+strconv.Itoa(latestVersion(t, db, "key"))+" failed: invalid 2")
+		assert.Equal(t, []string{"2", "1"}, failedWith)
+	})
+}
+
+func loadInto(data *[]byte) func(io.Reader) error {
+	return func(r io.Reader) error {
+		d, err := ioutil.ReadAll(r)
+		if data != nil {
+			*data = d
+		}
+		return err
+	}
+}
+
+func rejecting(invalid string, data *[]byte) func(io.Reader) error {
+	return func(r io.Reader) error {
+		d, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if string(d) == invalid {
+			return errors.New("invalid data")
+		}
+		if data != nil {
+			*data = d
+		}
+		return nil
+	}
+}
+
+func latestVersion(t *testing.T, db *deebee.DB, key string) int {
+	versions, err := db.Versions(key)
+	require.NoError(t, err)
+	require.NotEmpty(t, versions)
+	return versions[len(versions)-1].Version
+}
@@ -0,0 +1,83 @@
+package deebee
+
+import "sync"
+
+// PauseMaintenance stops background activity, such as temp file cleanup (WithTempFileCleanup),
+// compaction (WithCompactionTrigger) and persisting data written to keys configured using
+// WithWriteBehind. Returns once all running background tasks are finished. Tasks which
+// should run while paused wait until ResumeMaintenance is called.
+//
+// Useful during latency-critical windows or before taking a snapshot of the filesystem.
+// Operations called explicitly, such as Compact or Flush, are not paused.
+func (s *DB) PauseMaintenance() {
+	s.maintenance.pause()
+}
+
+// ResumeMaintenance resumes background activity stopped by PauseMaintenance. Close resumes
+// it as well.
+func (s *DB) ResumeMaintenance() {
+	s.maintenance.resume()
+}
+
+// maintenance tracks background tasks
+type maintenance struct {
+	mutex   sync.Mutex
+	changed *sync.Cond
+	paused  bool
+	running int
+}
+
+// begin waits until maintenance is resumed and marks the task as running
+func (m *maintenance) begin() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for m.paused {
+		m.wait()
+	}
+	m.running++
+}
+
+// end marks the task started with begin as finished
+func (m *maintenance) end() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.running--
+	m.broadcast()
+}
+
+func (m *maintenance) isPaused() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.paused
+}
+
+func (m *maintenance) pause() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.paused = true
+	for m.running > 0 {
+		m.wait()
+	}
+}
+
+func (m *maintenance) resume() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.paused = false
+	m.broadcast()
+}
+
+// wait must be called with mutex locked
+func (m *maintenance) wait() {
+	if m.changed == nil {
+		m.changed = sync.NewCond(&m.mutex)
+	}
+	m.changed.Wait()
+}
+
+// broadcast must be called with mutex locked
+func (m *maintenance) broadcast() {
+	if m.changed != nil {
+		m.changed.Broadcast()
+	}
+}
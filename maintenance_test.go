@@ -0,0 +1,75 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_PauseMaintenance(t *testing.T) {
+	t.Run("should not persist write-behind data while paused", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithWriteBehind(time.Millisecond))
+		// when
+		db.PauseMaintenance()
+		writeData(t, db, "key", []byte("data"))
+		// then
+		time.Sleep(20 * time.Millisecond)
+		dirs, err := dir.ListDirs()
+		require.NoError(t, err)
+		assert.Empty(t, dirs)
+		// and
+		db.ResumeMaintenance()
+		assert.Eventually(t, func() bool {
+			versions, err := db.Versions("key")
+			return err == nil && len(versions) == 1
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("should not compact while paused", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxVersions: 1}))
+		// when
+		db.PauseMaintenance()
+		writeData(t, db, "key", []byte("1"))
+		writeData(t, db, "key", []byte("2"))
+		// then
+		time.Sleep(20 * time.Millisecond)
+		assertVersionsCount(t, db, "key", 2)
+		// and
+		db.ResumeMaintenance()
+		assert.Eventually(t, func() bool {
+			versions, err := db.Versions("key")
+			return err == nil && len(versions) == 1
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("should persist write-behind data on Close when paused", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithWriteBehind(time.Millisecond))
+		db.PauseMaintenance()
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.Close()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, openDB(t, dir), "key"))
+	})
+
+	t.Run("should allow to pause many times", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Millisecond))
+		// when
+		db.PauseMaintenance()
+		db.PauseMaintenance()
+		db.ResumeMaintenance()
+		// then
+		writeData(t, db, "key", []byte("data"))
+		assert.Eventually(t, func() bool {
+			versions, err := db.Versions("key")
+			return err == nil && len(versions) == 1
+		}, time.Second, time.Millisecond)
+	})
+}
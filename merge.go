@@ -0,0 +1,59 @@
+package deebee
+
+import (
+	"io/ioutil"
+)
+
+// Merge writes a new version of the state with data merged into the latest version using
+// merge function, which receives the current data and the incoming one. Data is written
+// as is when the state does not exist. Useful for state which tolerates concurrent
+// writers, such as counters or sets.
+//
+// Merges, queue operations and JSONL appends of the same key executed concurrently by
+// the same DB are serialized, but merges from other processes and writes using Writer are
+// not. Operations on different keys run in parallel.
+func (s *DB) Merge(key string, merge func(current, incoming []byte) []byte, data []byte) (err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if merge == nil {
+		return newClientError("nil merge function")
+	}
+	return s.update(key, func(current []byte, exists bool) ([]byte, error) {
+		if !exists {
+			return data, nil
+		}
+		return merge(current, data), nil
+	})
+}
+
+// update writes a new version of the state with data returned by fn, which receives the
+// latest data. Nothing is written when fn returns error.
+func (s *DB) update(key string, fn func(current []byte, exists bool) ([]byte, error)) error {
+	unlock := s.appendLocks.lock(key)
+	defer unlock()
+	reader, err := s.Reader(key)
+	if err != nil && !IsDataNotFound(err) {
+		return err
+	}
+	var current []byte
+	if reader != nil {
+		current, err = ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	data, err := fn(current, reader != nil)
+	if err != nil {
+		return err
+	}
+	writer, err := s.newWriterWithTimeout(key)
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(data); err != nil {
+		_ = writer.abort()
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,98 @@
+package deebee_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Merge(t *testing.T) {
+	concat := func(current, incoming []byte) []byte {
+		return append(current, incoming...)
+	}
+
+	t.Run("should return client error for nil merge function", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		err := db.Merge("key", nil, []byte("data"))
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should return client error for invalid key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, key := range invalidKeys {
+			err := db.Merge(key, concat, []byte("data"))
+			assert.True(t, deebee.IsClientError(err), key)
+		}
+	})
+
+	t.Run("should write data when state does not exist", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		err := db.Merge("key", concat, []byte("data"))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should merge data with the latest version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("a"))
+		// when
+		err := db.Merge("key", concat, []byte("b"))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("ab"), readData(t, db, "key"))
+	})
+
+	t.Run("should not lose concurrent merges", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		add := func(current, incoming []byte) []byte {
+			a, _ := strconv.Atoi(string(current))
+			b, _ := strconv.Atoi(string(incoming))
+			return []byte(strconv.Itoa(a + b))
+		}
+		var wg sync.WaitGroup
+		// when
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, db.Merge("counter", add, []byte("1")))
+			}()
+		}
+		wg.Wait()
+		// then
+		assert.Equal(t, []byte("10"), readData(t, db, "counter"))
+	})
+
+	t.Run("should not block merges of other keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		merging := make(chan struct{})
+		release := make(chan struct{})
+		blocking := func(current, incoming []byte) []byte {
+			close(merging)
+			<-release
+			return incoming
+		}
+		writeData(t, db, "blocked", []byte("data"))
+		done := make(chan error)
+		go func() {
+			done <- db.Merge("blocked", blocking, []byte("new"))
+		}()
+		<-merging
+		// when
+		err := db.Merge("other", concat, []byte("data"))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "other"))
+		close(release)
+		assert.NoError(t, <-done)
+	})
+}
@@ -0,0 +1,165 @@
+package deebee
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// MigrateOptions configures Migrate
+type MigrateOptions struct {
+	// History copies all data versions, from the oldest, instead of the latest one only
+	History bool
+}
+
+// Migrate copies states from src to dst, which can use different Dir implementations or
+// layouts. Files are copied as they are stored, without passing them through filters.
+// Each copied version is read back from dst and compared with the source. Deleted states
+// are skipped.
+//
+// Migration can be resumed after failure by running it again: versions already present
+// in dst are not copied again, therefore dst must not be modified by others in the meantime.
+//
+// progress is called after each key and can be nil.
+func Migrate(ctx context.Context, src, dst *DB, options MigrateOptions, progress ProgressFunc) (err error) {
+	if src == nil || dst == nil {
+		return newClientError("nil DB")
+	}
+	defer dst.redactError(&err)
+	if err := dst.checkWritable(); err != nil {
+		return err
+	}
+	return src.forEachKey(ctx, progress, func(key string) (int64, error) {
+		return migrateKey(src, dst, key, options)
+	})
+}
+
+func migrateKey(src, dst *DB, key string, options MigrateOptions) (int64, error) {
+	latest, exists, err := src.latestFile(key)
+	if err != nil || !exists || latest.kind == tombstoneFile {
+		return 0, err
+	}
+	toCopy := []Version{{Version: latest.version}}
+	if options.History {
+		if toCopy, err = dataVersions(src.stateDir(key)); err != nil {
+			return 0, err
+		}
+	}
+	copied, err := migratedCount(dst, key)
+	if err != nil {
+		return 0, err
+	}
+	if copied > len(toCopy) {
+		copied = len(toCopy)
+	}
+	if copied > 0 {
+		// the youngest version in dst might not be verified, because previous migration
+		// failed, therefore it is compared again
+		copied--
+		same, err := sameAsLatest(src, dst, key, toCopy[copied].Version)
+		if err != nil {
+			return 0, err
+		}
+		if same {
+			copied++
+		}
+	}
+	var bytesCopied int64
+	for _, version := range toCopy[copied:] {
+		n, err := migrateVersion(src, dst, key, version.Version)
+		bytesCopied += n
+		if err != nil {
+			return bytesCopied, err
+		}
+	}
+	return bytesCopied, nil
+}
+
+// migratedCount returns number of data versions of the state already stored in dst
+func migratedCount(dst *DB, key string) (int, error) {
+	stateDir, err := dst.existingStateDir(key)
+	if IsDataNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	versions, err := dataVersions(stateDir)
+	return len(versions), err
+}
+
+func dataVersions(stateDir Dir) ([]Version, error) {
+	versions, err := stateVersions(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	var data []Version
+	for _, v := range versions {
+		if !v.Deleted && !v.Archived {
+			data = append(data, v)
+		}
+	}
+	return data, nil
+}
+
+// sameAsLatest returns true when the latest version in dst has the same data as the version in src
+func sameAsLatest(src, dst *DB, key string, version int) (bool, error) {
+	dstLatest, exists, err := dst.latestFile(key)
+	if err != nil || !exists || dstLatest.kind != dataFile {
+		return false, err
+	}
+	srcSum, err := fileChecksum(src.stateDir(key), newFilename(version).name)
+	if err != nil {
+		return false, err
+	}
+	dstSum, err := fileChecksum(dst.stateDir(key), dstLatest.name)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(srcSum, dstSum), nil
+}
+
+// migrateVersion copies the version and verifies the copy
+func migrateVersion(src, dst *DB, key string, version int) (int64, error) {
+	reader, err := src.stateDir(key).FileReader(newFilename(version).name)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	writer, err := dst.newRawWriter(key)
+	if err != nil {
+		return 0, err
+	}
+	hash := sha256.New()
+	n, err := io.Copy(writer, io.TeeReader(reader, hash))
+	if err != nil {
+		_ = writer.abort()
+		return n, err
+	}
+	if err = writer.Close(); err != nil {
+		return n, err
+	}
+	dstSum, err := fileChecksum(dst.stateDir(key), writer.name.name)
+	if err != nil {
+		return n, err
+	}
+	if !bytes.Equal(hash.Sum(nil), dstSum) {
+		return n, fmt.Errorf("verification of version %d of key \"%s\" failed: data differs", version, key)
+	}
+	return n, nil
+}
+
+func fileChecksum(dir Dir, name string) ([]byte, error) {
+	reader, err := dir.FileReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, reader); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
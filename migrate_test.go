@@ -0,0 +1,69 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	t.Run("should return client error for nil DB", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		assert.True(t, deebee.IsClientError(deebee.Migrate(context.Background(), nil, db, deebee.MigrateOptions{}, nil)))
+		assert.True(t, deebee.IsClientError(deebee.Migrate(context.Background(), db, nil, deebee.MigrateOptions{}, nil)))
+	})
+
+	t.Run("should copy latest versions", func(t *testing.T) {
+		src := openDB(t, fake.ExistingDir())
+		writeData(t, src, "key", []byte("old"))
+		writeData(t, src, "key", []byte("new"))
+		writeData(t, src, "deleted", []byte("data"))
+		require.NoError(t, src.Delete("deleted"))
+		dst := openDB(t, fake.ExistingDir(), deebee.WithShardedLayout())
+		// when
+		err := deebee.Migrate(context.Background(), src, dst, deebee.MigrateOptions{}, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), readData(t, dst, "key"))
+		versions, err := dst.Versions("key")
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+		_, err = dst.Reader("deleted")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should copy all versions", func(t *testing.T) {
+		src := openDB(t, fake.ExistingDir())
+		writeData(t, src, "key", []byte("same"))
+		writeData(t, src, "key", []byte("same"))
+		writeData(t, src, "key", []byte("new"))
+		dst := openDB(t, fake.ExistingDir())
+		// when
+		err := deebee.Migrate(context.Background(), src, dst, deebee.MigrateOptions{History: true}, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), readData(t, dst, "key"))
+		versions, err := dst.Versions("key")
+		require.NoError(t, err)
+		assert.Len(t, versions, 3)
+	})
+
+	t.Run("should not copy versions again when resumed", func(t *testing.T) {
+		src := openDB(t, fake.ExistingDir())
+		writeData(t, src, "key", []byte("v1"))
+		writeData(t, src, "key", []byte("v2"))
+		dst := openDB(t, fake.ExistingDir())
+		require.NoError(t, deebee.Migrate(context.Background(), src, dst, deebee.MigrateOptions{History: true}, nil))
+		// when
+		err := deebee.Migrate(context.Background(), src, dst, deebee.MigrateOptions{History: true}, nil)
+		// then
+		require.NoError(t, err)
+		versions, err := dst.Versions("key")
+		require.NoError(t, err)
+		assert.Len(t, versions, 2)
+	})
+}
@@ -0,0 +1,107 @@
+package deebee
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// migrationsFile is an internal file containing IDs of applied migrations
+const migrationsFile = "migrations"
+
+// Migration transforms persisted states, for example when the format of data changed
+// between releases of the application
+type Migration struct {
+	// ID identifies the migration. It must not be changed once the migration was released.
+	ID string
+	// Migrate transforms the states using given DB
+	Migrate func(db *DB) error
+}
+
+// Migrator contains migrations run in the order of registration, each one exactly once
+type Migrator struct {
+	migrations []Migration
+}
+
+// Register adds the migration which runs after all migrations registered before
+func (m *Migrator) Register(id string, migrate func(db *DB) error) {
+	m.migrations = append(m.migrations, Migration{ID: id, Migrate: migrate})
+}
+
+// WithMigrator runs migrations registered in m, which were not applied yet, at the end of
+// Open. IDs of applied migrations are stored in an internal file, right after each migration
+// finished. Open fails when a migration failed. The migration is run again by the next Open.
+//
+// Migrations are not synchronized between processes, therefore the DB must not be used by
+// others while it is opened. Do not use with OpenAsOf.
+func WithMigrator(m *Migrator) Option {
+	return func(db *DB) error {
+		if m == nil {
+			return errors.New("nil migrator")
+		}
+		ids := map[string]struct{}{}
+		for _, migration := range m.migrations {
+			if migration.ID == "" || strings.Contains(migration.ID, "\n") {
+				return fmt.Errorf("invalid migration ID \"%s\"", migration.ID)
+			}
+			if _, exists := ids[migration.ID]; exists {
+				return fmt.Errorf("duplicate migration ID \"%s\"", migration.ID)
+			}
+			if migration.Migrate == nil {
+				return fmt.Errorf("nil migration \"%s\"", migration.ID)
+			}
+			ids[migration.ID] = struct{}{}
+		}
+		db.migrator = m
+		return nil
+	}
+}
+
+// migrate runs migrations which were not applied yet
+func (s *DB) migrate() error {
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for _, migration := range s.migrator.migrations {
+		if contains(applied, migration.ID) {
+			continue
+		}
+		if err = migration.Migrate(s); err != nil {
+			return fmt.Errorf("migration \"%s\" failed: %w", migration.ID, err)
+		}
+		applied = append(applied, migration.ID)
+		if err = s.writeInternalFile(migrationsFile, []byte(strings.Join(applied, "\n"))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliedMigrations returns IDs of applied migrations, in the order they were applied
+func (s *DB) appliedMigrations() ([]string, error) {
+	files, err := s.internalFiles()
+	if err != nil {
+		return nil, err
+	}
+	if !contains(files, migrationsFile) {
+		return nil, nil
+	}
+	content, err := s.readInternalFile(migrationsFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(content), "\n"), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
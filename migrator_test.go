@@ -0,0 +1,143 @@
+package deebee_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMigrator(t *testing.T) {
+	t.Run("should return error for invalid migrations", func(t *testing.T) {
+		noop := func(db *deebee.DB) error { return nil }
+		migrators := map[string]func(m *deebee.Migrator){
+			"empty ID": func(m *deebee.Migrator) {
+				m.Register("", noop)
+			},
+			"duplicate ID": func(m *deebee.Migrator) {
+				m.Register("1", noop)
+				m.Register("1", noop)
+			},
+			"nil migration": func(m *deebee.Migrator) {
+				m.Register("1", nil)
+			},
+		}
+		for name, register := range migrators {
+			t.Run(name, func(t *testing.T) {
+				migrator := &deebee.Migrator{}
+				register(migrator)
+				// when
+				db, err := deebee.Open(fake.ExistingDir(), deebee.WithMigrator(migrator))
+				// then
+				assert.Error(t, err)
+				assert.Nil(t, db)
+			})
+		}
+	})
+
+	t.Run("should run migrations in order", func(t *testing.T) {
+		var order []string
+		migrator := &deebee.Migrator{}
+		migrator.Register("first", func(db *deebee.DB) error {
+			order = append(order, "first")
+			return nil
+		})
+		migrator.Register("second", func(db *deebee.DB) error {
+			order = append(order, "second")
+			return nil
+		})
+		// when
+		_, err := deebee.Open(fake.ExistingDir(), deebee.WithMigrator(migrator))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first", "second"}, order)
+	})
+
+	t.Run("should run migration once", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		runs := 0
+		migrator := &deebee.Migrator{}
+		migrator.Register("1", func(db *deebee.DB) error {
+			runs++
+			return nil
+		})
+		_, err := deebee.Open(dir, deebee.WithMigrator(migrator))
+		require.NoError(t, err)
+		// when
+		_, err = deebee.Open(dir, deebee.WithMigrator(migrator))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, runs)
+	})
+
+	t.Run("should run only new migrations", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		first := &deebee.Migrator{}
+		first.Register("1", func(db *deebee.DB) error {
+			writeData(t, db, "key", []byte("v1"))
+			return nil
+		})
+		_, err := deebee.Open(dir, deebee.WithMigrator(first))
+		require.NoError(t, err)
+		second := &deebee.Migrator{}
+		second.Register("1", func(db *deebee.DB) error {
+			return errors.New("should not be run")
+		})
+		second.Register("2", func(db *deebee.DB) error {
+			data := readData(t, db, "key")
+			writeData(t, db, "key", append(data, "-v2"...))
+			return nil
+		})
+		// when
+		db, err := deebee.Open(dir, deebee.WithMigrator(second))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("v1-v2"), readData(t, db, "key"))
+	})
+
+	t.Run("should return error when migration failed and run it again by the next Open", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		migrationErr := errors.New("failed")
+		var applied []string
+		migrator := &deebee.Migrator{}
+		migrator.Register("1", func(db *deebee.DB) error {
+			applied = append(applied, "1")
+			return nil
+		})
+		migrator.Register("2", func(db *deebee.DB) error {
+			return migrationErr
+		})
+		// when
+		db, err := deebee.Open(dir, deebee.WithMigrator(migrator))
+		// then
+		assert.ErrorIs(t, err, migrationErr)
+		assert.Nil(t, db)
+		// and
+		fixed := &deebee.Migrator{}
+		fixed.Register("1", func(db *deebee.DB) error {
+			applied = append(applied, "1")
+			return nil
+		})
+		fixed.Register("2", func(db *deebee.DB) error {
+			applied = append(applied, "2")
+			return nil
+		})
+		_, err = deebee.Open(dir, deebee.WithMigrator(fixed))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1", "2"}, applied)
+	})
+
+	t.Run("should not make applied migrations a state", func(t *testing.T) {
+		migrator := &deebee.Migrator{}
+		migrator.Register("1", func(db *deebee.DB) error { return nil })
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithMigrator(migrator))
+		require.NoError(t, err)
+		// expect
+		count, err := db.Count()
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
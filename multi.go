@@ -0,0 +1,127 @@
+package deebee
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const multiParallelism = 8
+
+// KeyErrors contains errors by key. It is returned by GetMulti when some keys could not be
+// read.
+type KeyErrors map[string]error
+
+func (e KeyErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	messages := make([]string, len(keys))
+	for i, key := range keys {
+		messages[i] = fmt.Sprintf("%s: %s", key, e[key])
+	}
+	return fmt.Sprintf("%d keys failed: %s", len(keys), strings.Join(messages, "; "))
+}
+
+// GetMulti reads the latest data of many keys concurrently, which is much faster than
+// calling Reader sequentially when Dir is remote. Returns data of keys which were read
+// successfully. When some keys failed, KeyErrors is returned as well, so the error for
+// each key can be checked, for example using IsDataNotFound.
+func (s *DB) GetMulti(keys []string) (map[string][]byte, error) {
+	var (
+		mutex  sync.Mutex
+		data   = map[string][]byte{}
+		failed = KeyErrors{}
+	)
+	_ = runParallel(context.Background(), uniqueKeys(keys), multiParallelism, func(key string) error {
+		d, err := s.readAll(key)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err != nil {
+			failed[key] = err
+		} else {
+			data[key] = d
+		}
+		return nil
+	})
+	if len(failed) > 0 {
+		return data, failed
+	}
+	return data, nil
+}
+
+// PutMulti writes data of many keys concurrently, in the same way as Writer. Useful for
+// bulk import. Returns errors by key, as given by the caller, for keys which could not be
+// written. Each key is written independently, so when some keys failed the others are
+// still written.
+func (s *DB) PutMulti(data map[string][]byte) map[string]error {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	var (
+		mutex  sync.Mutex
+		failed = map[string]error{}
+	)
+	_ = runParallel(context.Background(), keys, multiParallelism, func(key string) error {
+		if err := s.writeAll(key, data[key]); err != nil {
+			mutex.Lock()
+			failed[key] = err
+			mutex.Unlock()
+		}
+		return nil
+	})
+	return failed
+}
+
+func (s *DB) writeAll(key string, data []byte) (err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if s.configFor(key).writeBehind != nil {
+		writer, err := s.Writer(key)
+		if err != nil {
+			return err
+		}
+		_, _ = writer.Write(data) // writing to memory does not fail
+		return writer.Close()
+	}
+	writer, err := s.newWriterWithTimeout(key)
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(data); err != nil {
+		_ = writer.abort()
+		return err
+	}
+	return writer.Close()
+}
+
+func (s *DB) readAll(key string) ([]byte, error) {
+	reader, err := s.Reader(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return data, reader.Close()
+}
+
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,113 @@
+package deebee_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_GetMulti(t *testing.T) {
+	t.Run("should return empty map for no keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		data, err := db.GetMulti(nil)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, data)
+	})
+
+	t.Run("should read many keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		expected := map[string][]byte{}
+		for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+			expected[key] = []byte("data-" + key)
+			writeData(t, db, key, expected[key])
+		}
+		keys := make([]string, 0, len(expected))
+		for key := range expected {
+			keys = append(keys, key)
+		}
+		// when
+		data, err := db.GetMulti(append(keys, "a"))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, expected, data)
+	})
+
+	t.Run("should return per-key errors", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "existing", []byte("data"))
+		// when
+		data, err := db.GetMulti([]string{"existing", "missing", "/invalid"})
+		// then
+		assert.Equal(t, map[string][]byte{"existing": []byte("data")}, data)
+		var keyErrors deebee.KeyErrors
+		require.True(t, errors.As(err, &keyErrors))
+		assert.Len(t, keyErrors, 2)
+		assert.True(t, deebee.IsDataNotFound(keyErrors["missing"]))
+		assert.True(t, deebee.IsClientError(keyErrors["/invalid"]))
+	})
+}
+
+func TestDB_PutMulti(t *testing.T) {
+	t.Run("should write many keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		data := map[string][]byte{}
+		for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+			data[key] = []byte("data-" + key)
+		}
+		// when
+		failed := db.PutMulti(data)
+		// then
+		assert.Empty(t, failed)
+		for key, expected := range data {
+			assert.Equal(t, expected, readData(t, db, key))
+		}
+	})
+
+	t.Run("should return per-key errors", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		failed := db.PutMulti(map[string][]byte{
+			"valid":    []byte("data"),
+			"/invalid": []byte("data"),
+		})
+		// then
+		require.Len(t, failed, 1)
+		assert.True(t, deebee.IsClientError(failed["/invalid"]))
+		assert.Equal(t, []byte("data"), readData(t, db, "valid"))
+	})
+
+	t.Run("should not commit key which failed to write", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(failingWriteFilter{}))
+		// when
+		failed := db.PutMulti(map[string][]byte{"key": []byte("data")})
+		// then
+		assert.Len(t, failed, 1)
+		_, err := db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+}
+
+type failingWriteFilter struct{}
+
+func (f failingWriteFilter) Writer(w io.WriteCloser) (io.WriteCloser, error) {
+	return failingWriter{w}, nil
+}
+
+func (f failingWriteFilter) Reader(r io.ReadCloser) (io.ReadCloser, error) {
+	return r, nil
+}
+
+type failingWriter struct {
+	io.WriteCloser
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
@@ -6,14 +6,20 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 )
 
+const listBatchSize = 1024
+
 type OsDir string
 
 func (o OsDir) FileReader(name string) (io.ReadCloser, error) {
 	if name == "" {
 		return nil, errors.New("empty file name")
 	}
+	if err := validatePlatformName(name); err != nil {
+		return nil, err
+	}
 	return os.Open(o.path(name))
 }
 
@@ -21,10 +27,29 @@ func (o OsDir) FileWriter(name string) (FileWriter, error) {
 	if name == "" {
 		return nil, errors.New("empty file name")
 	}
+	if err := validatePlatformName(name); err != nil {
+		return nil, err
+	}
 	flags := os.O_CREATE | os.O_EXCL | os.O_WRONLY
 	return os.OpenFile(o.path(name), flags, 0664)
 }
 
+func (o OsDir) FileModTime(name string) (time.Time, error) {
+	info, err := os.Stat(o.path(name))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (o OsDir) FileSize(name string) (int64, error) {
+	info, err := os.Stat(o.path(name))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
 func (o OsDir) path(name string) string {
 	return filepath.Join(string(o), name)
 }
@@ -34,18 +59,25 @@ func (o OsDir) Exists() (bool, error) {
 	if os.IsNotExist(err) {
 		return false, nil
 	}
+	if err != nil {
+		return false, err
+	}
 	return f.IsDir(), nil
 }
 
 func (o OsDir) Mkdir() error {
 	err := os.Mkdir(string(o), 0775)
 	if os.IsExist(err) {
-		return nil
+		// on case-insensitive filesystem the existing dir may differ in case
+		return checkCaseCollision(filepath.Dir(string(o)), filepath.Base(string(o)))
 	}
 	return err
 }
 
 func (o OsDir) Dir(name string) Dir {
+	if err := validatePlatformName(name); err != nil {
+		return invalidOsDir{err: err}
+	}
 	return OsDir(o.path(name))
 }
 
@@ -62,3 +94,105 @@ func (o OsDir) ListFiles() ([]string, error) {
 	}
 	return files, nil
 }
+
+// ListFilesIter reads directory entries in batches, so memory usage does not depend on
+// the number of files
+func (o OsDir) ListFilesIter(fn func(name string) bool) error {
+	dir, err := os.Open(string(o))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	for {
+		fileInfos, err := dir.Readdir(listBatchSize)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, f := range fileInfos {
+			if !f.IsDir() && !fn(f.Name()) {
+				return nil
+			}
+		}
+	}
+}
+
+func (o OsDir) ListDirs() ([]string, error) {
+	var dirs []string
+	fileInfos, err := ioutil.ReadDir(string(o))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fileInfos {
+		if f.IsDir() {
+			dirs = append(dirs, f.Name())
+		}
+	}
+	return dirs, nil
+}
+
+func (o OsDir) Rename(oldName, newName string) error {
+	if oldName == "" || newName == "" {
+		return errors.New("empty file name")
+	}
+	if err := validatePlatformName(newName); err != nil {
+		return err
+	}
+	return os.Rename(o.path(oldName), o.path(newName))
+}
+
+// SyncDir syncs the directory, so created, renamed and deleted entries survive power
+// loss. Does nothing on Windows, where directories can't be synced.
+func (o OsDir) SyncDir() error {
+	return syncOsDir(string(o))
+}
+
+func (o OsDir) DeleteFile(name string) error {
+	if name == "" {
+		return errors.New("empty file name")
+	}
+	return os.Remove(o.path(name))
+}
+
+// invalidOsDir is returned by OsDir.Dir when name can't be used on current platform
+type invalidOsDir struct {
+	err error
+}
+
+func (d invalidOsDir) FileReader(string) (io.ReadCloser, error) {
+	return nil, d.err
+}
+
+func (d invalidOsDir) FileWriter(string) (FileWriter, error) {
+	return nil, d.err
+}
+
+func (d invalidOsDir) Mkdir() error {
+	return d.err
+}
+
+func (d invalidOsDir) Dir(string) Dir {
+	return d
+}
+
+func (d invalidOsDir) Exists() (bool, error) {
+	return false, d.err
+}
+
+func (d invalidOsDir) ListFiles() ([]string, error) {
+	return nil, d.err
+}
+
+func (d invalidOsDir) ListDirs() ([]string, error) {
+	return nil, d.err
+}
+
+func (d invalidOsDir) Rename(string, string) error {
+	return d.err
+}
+
+func (d invalidOsDir) DeleteFile(string) error {
+	return d.err
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package deebee
+
+import "os"
+
+func validatePlatformName(string) error {
+	return nil
+}
+
+func checkCaseCollision(string, string) error {
+	return nil
+}
+
+func syncOsDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -3,10 +3,12 @@ package deebee_test
 import (
 	"io/ioutil"
 	"os"
+	"runtime"
 	"testing"
 
 	"github.com/jacekolszak/deebee"
 	"github.com/jacekolszak/deebee/test"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -74,3 +76,78 @@ func createTempDir(t *testing.T) string {
 	require.NoError(t, err)
 	return dir
 }
+
+func TestOsDir_Rename(t *testing.T) {
+	test.TestDir_Rename(t, dirs)
+}
+
+func TestOsDir_ListDirs(t *testing.T) {
+	test.TestDir_ListDirs(t, dirs)
+}
+
+func TestOsDir_ListFilesIter(t *testing.T) {
+	t.Run("should iterate over files only", func(t *testing.T) {
+		dir := existingRootDir(t).(deebee.OsDir)
+		test.WriteFile(t, dir, "name1", []byte{})
+		test.WriteFile(t, dir, "name2", []byte{})
+		test.Mkdir(t, dir, "excludedDir")
+		var files []string
+		// when
+		err := dir.ListFilesIter(func(name string) bool {
+			files = append(files, name)
+			return true
+		})
+		// then
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"name1", "name2"}, files)
+	})
+
+	t.Run("should stop when fn returns false", func(t *testing.T) {
+		dir := existingRootDir(t).(deebee.OsDir)
+		test.WriteFile(t, dir, "name1", []byte{})
+		test.WriteFile(t, dir, "name2", []byte{})
+		calls := 0
+		// when
+		err := dir.ListFilesIter(func(name string) bool {
+			calls++
+			return false
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("should return error when dir is missing", func(t *testing.T) {
+		dir := existingRootDir(t).Dir("missing").(deebee.OsDir)
+		err := dir.ListFilesIter(func(name string) bool {
+			return true
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestOsDir_DeleteFile(t *testing.T) {
+	test.TestDir_DeleteFile(t, dirs)
+}
+
+func TestOsDir_SyncDir(t *testing.T) {
+	t.Run("should sync dir with entries", func(t *testing.T) {
+		dir := existingRootDir(t).(deebee.OsDir)
+		test.WriteFile(t, dir, "name", []byte{})
+		test.Mkdir(t, dir, "nested")
+		// when
+		err := dir.SyncDir()
+		// then
+		assert.NoError(t, err)
+	})
+
+	if runtime.GOOS == "windows" {
+		return // Windows does not support syncing dirs
+	}
+
+	t.Run("should return error when dir is missing", func(t *testing.T) {
+		dir := existingRootDir(t).Dir("missing").(deebee.OsDir)
+		err := dir.SyncDir()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,63 @@
+//go:build windows
+// +build windows
+
+package deebee
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode/utf16"
+)
+
+const maxNameLength = 255
+
+var reservedNames = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+// validatePlatformName rejects names which Windows does not allow or silently changes
+func validatePlatformName(name string) error {
+	base := strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+	if _, reserved := reservedNames[strings.TrimRight(base, " ")]; reserved {
+		return newClientError(fmt.Sprintf("invalid name: reserved on Windows: \"%s\"", name))
+	}
+	for _, r := range name {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return newClientError(fmt.Sprintf("invalid name: contains character not allowed on Windows: \"%s\"", name))
+		}
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return newClientError(fmt.Sprintf("invalid name: ends with dot or space: \"%s\"", name))
+	}
+	if len(utf16.Encode([]rune(name))) > maxNameLength {
+		return newClientError(fmt.Sprintf("invalid name: longer than %d characters: \"%s\"", maxNameLength, name))
+	}
+	return nil
+}
+
+// checkCaseCollision returns error when dir already contains entry which differs from name
+// only in case. Windows filesystem is case-insensitive, so for example "State" and "state"
+// would silently point to the same directory. It lists the whole dir, therefore it is
+// called only by Mkdir, when the dir to create already exists. Keys of existing states
+// colliding this way are detected by WithKeyCaseCollisionCheck.
+func checkCaseCollision(dir, name string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.Name() != name && strings.EqualFold(f.Name(), name) {
+			return newClientError(fmt.Sprintf("name \"%s\" collides with existing \"%s\"", name, f.Name()))
+		}
+	}
+	return nil
+}
+
+// syncOsDir does nothing, because Windows does not allow syncing directories. NTFS
+// journals changes of directory entries.
+func syncOsDir(string) error {
+	return nil
+}
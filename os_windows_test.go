@@ -0,0 +1,66 @@
+//go:build windows
+// +build windows
+
+package deebee_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var invalidWindowsNames = []string{
+	"CON", "con", "Nul", "AUX.txt", "COM1", "lpt9", "a<b", "a>b", "a:b", "a\"b", "a|b", "a?b", "a*b", "a\x01b",
+	"trailing.", "trailing ", strings.Repeat("a", 256),
+}
+
+func TestOsDir_Windows(t *testing.T) {
+	t.Run("should return client error for names not allowed on Windows", func(t *testing.T) {
+		for _, name := range invalidWindowsNames {
+			t.Run(name, func(t *testing.T) {
+				dir := existingRootDir(t)
+				// when
+				file, err := dir.FileWriter(name)
+				// then
+				assert.Nil(t, file)
+				assert.True(t, deebee.IsClientError(err))
+				// when
+				err = dir.Dir(name).Mkdir()
+				// then
+				assert.True(t, deebee.IsClientError(err))
+			})
+		}
+	})
+
+	t.Run("should return client error when dir name collides with existing one", func(t *testing.T) {
+		dir := existingRootDir(t)
+		err := dir.Dir("State").Mkdir()
+		require.NoError(t, err)
+		// when
+		err = dir.Dir("state").Mkdir()
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should create existing dir again", func(t *testing.T) {
+		dir := existingRootDir(t)
+		require.NoError(t, dir.Dir("State").Mkdir())
+		// when
+		err := dir.Dir("State").Mkdir()
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("should not allow writing keys reserved on Windows", func(t *testing.T) {
+		db, err := deebee.Open(existingRootDir(t))
+		require.NoError(t, err)
+		// when
+		writer, err := db.Writer("NUL")
+		// then
+		assert.Nil(t, writer)
+		assert.True(t, deebee.IsClientError(err))
+	})
+}
@@ -0,0 +1,147 @@
+package deebee
+
+import (
+	"errors"
+	"os"
+)
+
+// OsDirOption configures Dir returned by NewOsDir
+type OsDirOption func(*osDirOptions) error
+
+type osDirOptions struct {
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	uid, gid int
+	chown    bool
+}
+
+// WithFileMode sets permissions of created files, for example 0600. Default is 0664.
+// Like in os.OpenFile, the umask of the process is applied.
+func WithFileMode(mode os.FileMode) OsDirOption {
+	return func(o *osDirOptions) error {
+		if mode&^os.ModePerm != 0 {
+			return errors.New("file mode must contain only permission bits")
+		}
+		o.fileMode = mode
+		return nil
+	}
+}
+
+// WithDirMode sets permissions of created directories, for example 0700. Default is 0775.
+// Like in os.Mkdir, the umask of the process is applied.
+func WithDirMode(mode os.FileMode) OsDirOption {
+	return func(o *osDirOptions) error {
+		if mode&^os.ModePerm != 0 {
+			return errors.New("dir mode must contain only permission bits")
+		}
+		o.dirMode = mode
+		return nil
+	}
+}
+
+// WithOwner changes the owner of created files and directories using os.Chown. -1 means
+// that uid or gid is not changed. Usually requires elevated privileges and is not
+// supported on Windows.
+func WithOwner(uid, gid int) OsDirOption {
+	return func(o *osDirOptions) error {
+		o.uid = uid
+		o.gid = gid
+		o.chown = true
+		return nil
+	}
+}
+
+// NewOsDir returns Dir stored in the os filesystem at path, like OsDir, but with custom
+// permissions and ownership of created files and directories. State often contains secrets,
+// therefore restrictive modes may be required.
+func NewOsDir(path string, options ...OsDirOption) (Dir, error) {
+	opts := defaultOsDirOptions()
+	for _, apply := range options {
+		if apply != nil {
+			if err := apply(opts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return osDir{OsDir: OsDir(path), options: opts}, nil
+}
+
+func defaultOsDirOptions() *osDirOptions {
+	return &osDirOptions{
+		fileMode: 0664,
+		dirMode:  0775,
+	}
+}
+
+// osDir is OsDir with custom permissions
+type osDir struct {
+	OsDir
+	options *osDirOptions
+}
+
+func (o osDir) FileWriter(name string) (FileWriter, error) {
+	if name == "" {
+		return nil, errors.New("empty file name")
+	}
+	if err := validatePlatformName(name); err != nil {
+		return nil, err
+	}
+	flags := os.O_CREATE | os.O_EXCL | os.O_WRONLY
+	file, err := os.OpenFile(o.path(name), flags, o.options.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	if o.options.chown {
+		if err = file.Chown(o.options.uid, o.options.gid); err != nil {
+			_ = file.Close()
+			_ = os.Remove(o.path(name))
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func (o osDir) Mkdir() error {
+	err := o.create()
+	if os.IsExist(err) {
+		return nil
+	}
+	return err
+}
+
+// create creates the directory. Returns error when it already exists.
+func (o osDir) create() error {
+	if err := os.Mkdir(string(o.OsDir), o.options.dirMode); err != nil {
+		return err
+	}
+	if o.options.chown {
+		return os.Chown(string(o.OsDir), o.options.uid, o.options.gid)
+	}
+	return nil
+}
+
+func (o osDir) Dir(name string) Dir {
+	dir := o.OsDir.Dir(name)
+	if d, ok := dir.(OsDir); ok {
+		return o.at(string(d))
+	}
+	return dir
+}
+
+// at returns dir at path with the same options
+func (o osDir) at(path string) osDir {
+	return osDir{OsDir: OsDir(path), options: o.options}
+}
+
+// asOsDir returns osDir for dirs stored in the os filesystem. OsDir is returned with
+// default options. Returns false for other dirs.
+func asOsDir(dir Dir) (osDir, bool) {
+	switch d := dir.(type) {
+	case OsDir:
+		return osDir{OsDir: d, options: defaultOsDirOptions()}, true
+	case osDir:
+		return d, true
+	default:
+		return osDir{}, false
+	}
+}
@@ -0,0 +1,102 @@
+package deebee_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var restrictiveDirs = map[string]test.NewDir{
+	"existing root": func(t *testing.T) deebee.Dir {
+		return newRestrictiveDir(t, createTempDir(t))
+	},
+	"nested": func(t *testing.T) deebee.Dir {
+		dir := newRestrictiveDir(t, createTempDir(t))
+		require.NoError(t, dir.Dir("nested").Mkdir())
+		return dir.Dir("nested")
+	},
+}
+
+func newRestrictiveDir(t *testing.T, path string) deebee.Dir {
+	dir, err := deebee.NewOsDir(path, deebee.WithFileMode(0600), deebee.WithDirMode(0700))
+	require.NoError(t, err)
+	return dir
+}
+
+func TestNewOsDir(t *testing.T) {
+	t.Run("should return error for invalid modes", func(t *testing.T) {
+		_, err := deebee.NewOsDir(createTempDir(t), deebee.WithFileMode(os.ModeDir|0600))
+		assert.Error(t, err)
+		_, err = deebee.NewOsDir(createTempDir(t), deebee.WithDirMode(os.ModeSymlink|0700))
+		assert.Error(t, err)
+	})
+
+	t.Run("should pass Dir tests", func(t *testing.T) {
+		test.TestDir_FileWriter(t, restrictiveDirs)
+		test.TestFileWriter_Write(t, restrictiveDirs)
+		test.TestDir_FileReader(t, restrictiveDirs)
+		test.TestFileReader_Read(t, restrictiveDirs)
+		test.TestDir_Exists(t, restrictiveDirs)
+		test.TestDir_Mkdir(t, restrictiveDirs)
+		test.TestDir_Dir(t, restrictiveDirs)
+		test.TestDir_ListFiles(t, restrictiveDirs)
+		test.TestDir_ListDirs(t, restrictiveDirs)
+		test.TestDir_Rename(t, restrictiveDirs)
+		test.TestDir_DeleteFile(t, restrictiveDirs)
+	})
+
+	if runtime.GOOS == "windows" {
+		return // Windows does not support unix permissions
+	}
+
+	t.Run("should create files and dirs with given modes", func(t *testing.T) {
+		path := createTempDir(t)
+		db := openDB(t, newRestrictiveDir(t, path))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assertMode(t, 0700, filepath.Join(path, "key"))
+		files, err := filepath.Glob(filepath.Join(path, "key", "*"))
+		require.NoError(t, err)
+		require.NotEmpty(t, files)
+		for _, file := range files {
+			assertMode(t, 0600, file)
+		}
+	})
+
+	t.Run("should export snapshot with given modes", func(t *testing.T) {
+		path := createTempDir(t)
+		db := openDB(t, newRestrictiveDir(t, path))
+		writeData(t, db, "key", []byte("data"))
+		snapshot := filepath.Join(createTempDir(t), "snapshot")
+		// when
+		err := db.ExportSnapshotDir(snapshot)
+		// then
+		require.NoError(t, err)
+		assertMode(t, 0700, snapshot)
+		assertMode(t, 0700, filepath.Join(snapshot, "key"))
+	})
+
+	t.Run("should change owner to the current one", func(t *testing.T) {
+		path := createTempDir(t)
+		dir, err := deebee.NewOsDir(path, deebee.WithOwner(os.Getuid(), os.Getgid()))
+		require.NoError(t, err)
+		db := openDB(t, dir)
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+}
+
+func assertMode(t *testing.T, expected os.FileMode, path string) {
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, info.Mode().Perm(), path)
+}
@@ -0,0 +1,67 @@
+// Package outbox provides store-and-forward outbox persisted in deebee.DB. Items, such as
+// messages which must be sent to other services, are stored durably first and delivered
+// later, even after the process crashed.
+package outbox
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jacekolszak/deebee"
+)
+
+// Outbox delivers items stored in deebee.Queue. Each Enqueue and each consumed item writes
+// a new version of the state, therefore the DB should be compacted periodically, for
+// example using deebee.WithCompactionTrigger.
+type Outbox struct {
+	queue *deebee.Queue
+}
+
+// New returns Outbox storing items in the state with given key
+func New(db *deebee.DB, key string) (*Outbox, error) {
+	if db == nil {
+		return nil, errors.New("nil db")
+	}
+	return &Outbox{queue: db.Queue(key)}, nil
+}
+
+// Enqueue stores the item at the end of the outbox. Item is stored durably when Enqueue
+// returns.
+func (o *Outbox) Enqueue(item []byte) error {
+	return o.queue.Push(item)
+}
+
+// Consume calls handler for each item, starting from the oldest one, and removes the item
+// once handler returned nil. Blocks until ctx is done or handler returned error, waiting
+// for new items when outbox is empty. Returns ctx.Err() or the error of the handler.
+//
+// Delivery is at-least-once: the item is passed to handler again, when the process crashed
+// before it was removed. Only one Consume can run at a time. Items enqueued by other
+// processes sharing the dir are noticed only after another item was enqueued or consumed
+// by this process.
+func (o *Outbox) Consume(ctx context.Context, handler func(ctx context.Context, item []byte) error) error {
+	if handler == nil {
+		return errors.New("nil handler")
+	}
+	for {
+		if err := o.queue.Wait(ctx); err != nil {
+			return err
+		}
+		item, ack, err := o.queue.Pop()
+		if deebee.IsDataNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = handler(ctx, item); err != nil {
+			return err
+		}
+		if err = ack(); err != nil {
+			return err
+		}
+	}
+}
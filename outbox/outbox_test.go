@@ -0,0 +1,121 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/jacekolszak/deebee/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("should return error for nil db", func(t *testing.T) {
+		o, err := outbox.New(nil, "outbox")
+		assert.Error(t, err)
+		assert.Nil(t, o)
+	})
+}
+
+func TestOutbox_Consume(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should consume items in the order they were enqueued", func(t *testing.T) {
+		o := newOutbox(t, fake.ExistingDir())
+		for _, item := range []string{"a", "b", "c"} {
+			require.NoError(t, o.Enqueue([]byte(item)))
+		}
+		// when
+		consumed := consume(t, o, 3)
+		// then
+		assert.Equal(t, []string{"a", "b", "c"}, consumed)
+	})
+
+	t.Run("should consume empty item", func(t *testing.T) {
+		o := newOutbox(t, fake.ExistingDir())
+		require.NoError(t, o.Enqueue([]byte{}))
+		// when
+		consumed := consume(t, o, 1)
+		// then
+		assert.Equal(t, []string{""}, consumed)
+	})
+
+	t.Run("should wait for items", func(t *testing.T) {
+		o := newOutbox(t, fake.ExistingDir())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = o.Enqueue([]byte("item"))
+		}()
+		// when
+		consumed := consume(t, o, 1)
+		// then
+		assert.Equal(t, []string{"item"}, consumed)
+	})
+
+	t.Run("should not consume removed items again", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		o := newOutbox(t, dir)
+		require.NoError(t, o.Enqueue([]byte("a")))
+		require.NoError(t, o.Enqueue([]byte("b")))
+		consume(t, o, 1)
+		// when
+		consumed := consume(t, newOutbox(t, dir), 1)
+		// then
+		assert.Equal(t, []string{"b"}, consumed)
+	})
+
+	t.Run("should keep item when handler failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		o := newOutbox(t, dir)
+		require.NoError(t, o.Enqueue([]byte("item")))
+		handlerErr := errors.New("failed")
+		// when
+		err := o.Consume(ctx, func(ctx context.Context, item []byte) error {
+			return handlerErr
+		})
+		// then
+		assert.ErrorIs(t, err, handlerErr)
+		consumed := consume(t, newOutbox(t, dir), 1)
+		assert.Equal(t, []string{"item"}, consumed)
+	})
+
+	t.Run("should return error when context is done", func(t *testing.T) {
+		o := newOutbox(t, fake.ExistingDir())
+		canceled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		// when
+		err := o.Consume(canceled, func(ctx context.Context, item []byte) error {
+			return nil
+		})
+		// then
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func newOutbox(t *testing.T, dir deebee.Dir) *outbox.Outbox {
+	db, err := deebee.Open(dir)
+	require.NoError(t, err)
+	o, err := outbox.New(db, "outbox")
+	require.NoError(t, err)
+	return o
+}
+
+// consume returns n consumed items. Items are removed from the outbox.
+func consume(t *testing.T, o *outbox.Outbox, n int) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var consumed []string
+	err := o.Consume(ctx, func(ctx context.Context, item []byte) error {
+		consumed = append(consumed, string(item))
+		if len(consumed) == n {
+			cancel()
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	return consumed
+}
@@ -0,0 +1,158 @@
+package deebee
+
+import "sort"
+
+// Pin protects the data version of the state from being removed by Compact, for example
+// to keep the state written before migration. Does nothing when the version is already
+// pinned. Returns data not found error when there is no such data version, and restore
+// required error when the version was archived (see WithArchive).
+func (s *DB) Pin(key string, version int) (err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.checkKey(key); err != nil {
+		return err
+	}
+	if err := s.checkAccess(WriteOperation, key); err != nil {
+		return err
+	}
+	stateDir, err := s.existingStateDir(key)
+	if err != nil {
+		return err
+	}
+	unlock := s.protectionLocks.lock(key)
+	defer unlock()
+	v, err := protectableVersion(stateDir, version)
+	if err != nil {
+		return err
+	}
+	if v.Pinned {
+		return nil
+	}
+	file, err := stateDir.FileWriter(newPinFilename(version).name)
+	if err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// protectableVersion returns the data version which can be pinned or labeled
+func protectableVersion(stateDir Dir, version int) (Version, error) {
+	versions, err := stateVersions(stateDir)
+	if err != nil {
+		return Version{}, err
+	}
+	i := sort.Search(len(versions), func(i int) bool {
+		return versions[i].Version >= version
+	})
+	if i == len(versions) || versions[i].Version != version || versions[i].Deleted {
+		return Version{}, &dataNotFoundError{}
+	}
+	if versions[i].Archived {
+		return Version{}, &restoreRequiredError{}
+	}
+	return versions[i], nil
+}
+
+// Unpin removes the protection added by Pin. Does nothing when the version is not pinned.
+func (s *DB) Unpin(key string, version int) (err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.checkKey(key); err != nil {
+		return err
+	}
+	if err := s.checkAccess(WriteOperation, key); err != nil {
+		return err
+	}
+	stateDir, err := s.existingStateDir(key)
+	if err != nil {
+		return err
+	}
+	versions, err := stateVersions(stateDir)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.Version == version && v.Pinned {
+			return stateDir.DeleteFile(newPinFilename(version).name)
+		}
+	}
+	return nil
+}
+
+// Version describes committed version of the state
+type Version struct {
+	Version int
+	// Deleted is true for version written by Delete
+	Deleted bool
+	// Pinned is true when version is protected using Pin
+	Pinned bool
+	// Archived is true when data was moved to the archive (see WithArchive)
+	Archived bool
+}
+
+// Versions returns committed versions of the state sorted from the oldest to the youngest.
+// Returns data not found error when there is no state with given key.
+func (s *DB) Versions(key string) (_ []Version, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkKey(key); err != nil {
+		return nil, err
+	}
+	if err = s.checkAccess(ReadOperation, key); err != nil {
+		return nil, err
+	}
+	stateDir, err := s.existingStateDir(key)
+	if err != nil {
+		return nil, err
+	}
+	return stateVersions(stateDir)
+}
+
+func stateVersions(stateDir Dir) ([]Version, error) {
+	var (
+		versions []Version
+		pinned   = map[int]bool{}
+		archived = map[int]bool{}
+		data     = map[int]bool{}
+	)
+	err := iterateFiles(stateDir, func(file string) bool {
+		f, err := parseFilename(file)
+		switch {
+		case err != nil || f.kind == tempFile:
+		case f.kind == pinFile:
+			pinned[f.version] = true
+		case f.kind == archivedFile:
+			archived[f.version] = true
+		default:
+			data[f.version] = true
+			versions = append(versions, Version{Version: f.version, Deleted: f.kind == tombstoneFile})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for version := range archived {
+		// data file is present too when archiving was interrupted
+		if !data[version] {
+			versions = append(versions, Version{Version: version, Archived: true})
+		}
+	}
+	for i := range versions {
+		versions[i].Pinned = pinned[versions[i].Version]
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+	return versions, nil
+}
@@ -0,0 +1,156 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Versions(t *testing.T) {
+	t.Run("should return data not found when state does not exist", func(t *testing.T) {
+		_, err := openDB(t, fake.ExistingDir()).Versions("missing")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return versions from the oldest", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("v1"))
+		writeData(t, db, "key", []byte("v2"))
+		require.NoError(t, db.Delete("key"))
+		// when
+		versions, err := db.Versions("key")
+		// then
+		require.NoError(t, err)
+		require.Len(t, versions, 3)
+		assert.Less(t, versions[0].Version, versions[1].Version)
+		assert.Less(t, versions[1].Version, versions[2].Version)
+		assert.False(t, versions[1].Deleted)
+		assert.True(t, versions[2].Deleted)
+	})
+}
+
+func TestDB_Pin(t *testing.T) {
+	t.Run("should return data not found when version does not exist", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		tombstone := versions[1].Version
+		// expect
+		assert.True(t, deebee.IsDataNotFound(db.Pin("missing", 0)))
+		assert.True(t, deebee.IsDataNotFound(db.Pin("key", tombstone+1)))
+		assert.True(t, deebee.IsDataNotFound(db.Pin("key", tombstone)))
+	})
+
+	t.Run("should mark version as pinned", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		require.NoError(t, db.Pin("key", versions[0].Version))
+		require.NoError(t, db.Pin("key", versions[0].Version))
+		// then
+		versions, err = db.Versions("key")
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.True(t, versions[0].Pinned)
+	})
+
+	t.Run("should protect version from Compact", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		require.NoError(t, db.Pin("key", versions[0].Version))
+		// when
+		err = db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		actual, err := db.Versions("key")
+		require.NoError(t, err)
+		assert.Equal(t, versions[0].Version, actual[0].Version)
+		assert.Len(t, actual, 2)
+	})
+
+	t.Run("should wait for Compact of the key", func(t *testing.T) {
+		dir := blockingDeleteDir{slowDir: slowDir{next: fake.ExistingDir()}, deleting: make(chan struct{}), unblock: make(chan struct{})}
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		compacted := make(chan error, 1)
+		go func() {
+			compacted <- db.Compact(context.Background(), nil)
+		}()
+		<-dir.deleting
+		// when
+		pinned := make(chan error, 1)
+		go func() {
+			pinned <- db.Pin("key", versions[0].Version)
+		}()
+		// then
+		select {
+		case <-pinned:
+			require.Fail(t, "Pin should wait for Compact")
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(dir.unblock)
+		require.NoError(t, <-compacted)
+		assert.True(t, deebee.IsDataNotFound(<-pinned))
+	})
+}
+
+// blockingDeleteDir signals deleting and waits for unblock before the file is deleted
+type blockingDeleteDir struct {
+	slowDir
+	deleting chan struct{}
+	unblock  chan struct{}
+}
+
+func (d blockingDeleteDir) Dir(name string) deebee.Dir {
+	return blockingDeleteDir{slowDir: slowDir{next: d.next.Dir(name)}, deleting: d.deleting, unblock: d.unblock}
+}
+
+func (d blockingDeleteDir) DeleteFile(name string) error {
+	select {
+	case d.deleting <- struct{}{}:
+	case <-d.unblock:
+	}
+	<-d.unblock
+	return d.slowDir.DeleteFile(name)
+}
+
+func TestDB_Unpin(t *testing.T) {
+	t.Run("should allow Compact to remove unpinned version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		require.NoError(t, db.Pin("key", versions[0].Version))
+		// when
+		require.NoError(t, db.Unpin("key", versions[0].Version))
+		// then
+		require.NoError(t, db.Compact(context.Background(), nil))
+		actual, err := db.Versions("key")
+		require.NoError(t, err)
+		require.Len(t, actual, 1)
+		assert.Equal(t, versions[1].Version, actual[0].Version)
+	})
+
+	t.Run("should do nothing when version is not pinned", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// expect
+		assert.NoError(t, db.Unpin("key", 0))
+	})
+}
@@ -0,0 +1,137 @@
+package deebee
+
+import (
+	"sort"
+	"sync"
+)
+
+// WithPreload makes Open scan all state dirs and keep the latest version of each state
+// in memory. Open is slower, but afterwards Reader and FS resolve the latest version
+// without listing files, which is a big win when Dir is backed by an object store.
+//
+// The index is updated by DB operations only, therefore the DB must be the only one
+// modifying the dir.
+func WithPreload() Option {
+	return func(db *DB) error {
+		db.index = &stateIndex{}
+		return nil
+	}
+}
+
+// preload builds the index of the latest committed file of each state
+func (s *DB) preload() error {
+	keys, err := s.stateKeys()
+	if err != nil {
+		return err
+	}
+	latest := make(map[string]filename, len(keys))
+	for _, key := range keys {
+		youngest, exists, err := youngestFile(s.stateDir(key))
+		if err != nil {
+			return err
+		}
+		if exists {
+			latest[key] = youngest
+		}
+	}
+	s.index.latest = latest
+	return nil
+}
+
+// latestFile returns the latest committed file of the state, which is either a data file
+// or a tombstone. Uses the index when DB was opened WithPreload. When DB was opened using
+// OpenAsOf, the youngest file committed at or before that time is returned.
+func (s *DB) latestFile(key string) (filename, bool, error) {
+	if err := s.checkKey(key); err != nil {
+		return filename{}, false, err
+	}
+	if s.index != nil && s.asOf == nil {
+		file, exists := s.index.get(key)
+		s.stats.add(statCacheHits, 1)
+		return file, exists, nil
+	}
+	stateDir, err := s.existingStateDir(key)
+	if IsDataNotFound(err) {
+		return filename{}, false, nil
+	}
+	if err != nil {
+		return filename{}, false, err
+	}
+	if s.asOf != nil {
+		return youngestFileAsOf(stateDir, *s.asOf)
+	}
+	return youngestFile(stateDir)
+}
+
+// stateIndex contains the latest committed file of each state. Nil index is not used.
+type stateIndex struct {
+	mutex  sync.Mutex
+	latest map[string]filename
+}
+
+func (i *stateIndex) get(key string) (filename, bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	file, exists := i.latest[key]
+	return file, exists
+}
+
+// committed updates the latest file of the state, unless younger file was committed before
+func (i *stateIndex) committed(key string, file filename) {
+	if i == nil {
+		return
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	latest, exists := i.latest[key]
+	if !exists || file.youngerThan(latest) {
+		i.latest[key] = file
+	}
+}
+
+// set replaces the latest file of the state
+func (i *stateIndex) set(key string, file filename) {
+	if i == nil {
+		return
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.latest[key] = file
+}
+
+func (i *stateIndex) rename(oldKey, newKey string) {
+	if i == nil {
+		return
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if file, exists := i.latest[oldKey]; exists {
+		i.latest[newKey] = file
+		delete(i.latest, oldKey)
+	}
+}
+
+// snapshot returns a copy of the latest files by key
+func (i *stateIndex) snapshot() map[string]filename {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	latest := make(map[string]filename, len(i.latest))
+	for key, file := range i.latest {
+		latest[key] = file
+	}
+	return latest
+}
+
+// keys returns sorted keys of states which latest file is of given kind
+func (i *stateIndex) keys(kind fileKind) []string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	var keys []string
+	for key, file := range i.latest {
+		if file.kind == kind {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
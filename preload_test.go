@@ -0,0 +1,78 @@
+package deebee_test
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithPreload(t *testing.T) {
+	t.Run("should return error when scanning dir failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		// when
+		db, err := deebee.Open(failing.ListFiles(dir), deebee.WithPreload())
+		// then
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("should read data written before Open", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		writeData(t, db, "deleted", []byte("data"))
+		require.NoError(t, db.Delete("deleted"))
+		// when
+		db = openDB(t, dir, deebee.WithPreload())
+		// then
+		assert.Equal(t, []byte("new"), readData(t, db, "key"))
+		_, err := db.Reader("deleted")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should read data written after Open", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithPreload())
+		writeData(t, db, "key", []byte("old"))
+		// when
+		writeData(t, db, "key", []byte("new"))
+		// then
+		assert.Equal(t, []byte("new"), readData(t, db, "key"))
+	})
+
+	t.Run("should not read deleted state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithPreload())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		require.NoError(t, db.Delete("key"))
+		// then
+		_, err := db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should read undeleted state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithPreload())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		// when
+		require.NoError(t, db.Undelete("key"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should read renamed state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithPreload())
+		writeData(t, db, "old", []byte("data"))
+		// when
+		require.NoError(t, db.Rename("old", "new"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "new"))
+		_, err := db.Reader("old")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+}
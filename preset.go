@@ -0,0 +1,123 @@
+package deebee
+
+import "time"
+
+// DurableDefaults is a preset for states which must not be lost. Each version is synced
+// before Close returns, checksums are verified on read, empty data is rejected and hourly
+// versions from the last day are kept by Compact, so data can be restored after a bad write.
+//
+// Options given after the preset override it.
+func DurableDefaults() Option {
+	return preset("durable",
+		WithChecksum(),
+		WithRejectEmptyData(),
+		WithRetention(Tiered(Tier{Age: 24 * time.Hour, Every: time.Hour})),
+	)
+}
+
+// FastDefaults is a preset for states which can be recreated, such as caches. Writes are
+// kept in memory and persisted in the background every second, without checksums. Data
+// written during the last second is lost when the process crashes.
+//
+// Options given after the preset override it.
+func FastDefaults() Option {
+	return preset("fast",
+		WithWriteBehind(time.Second),
+	)
+}
+
+// ArchivalDefaults is a preset for states kept for a long time. Data is compressed using
+// gzip, checksums are verified on read and daily versions from the last year are kept by
+// Compact.
+//
+// Options given after the preset override it.
+func ArchivalDefaults() Option {
+	return preset("archival",
+		WithFilter(GzipFilter()),
+		WithChecksum(),
+		WithRetention(Tiered(Tier{Age: 365 * 24 * time.Hour, Every: 24 * time.Hour})),
+	)
+}
+
+// preset applies options and records the name of the preset returned by Options
+func preset(name string, options ...Option) Option {
+	return func(db *DB) error {
+		for _, apply := range options {
+			if err := apply(db); err != nil {
+				return err
+			}
+		}
+		db.preset = name
+		return nil
+	}
+}
+
+// Options describes the configuration of the DB. Options given to WithKeyOptions are not
+// included.
+type Options struct {
+	// Preset is the name of the preset used, such as "durable", "fast" or "archival". Empty
+	// when no preset was used.
+	Preset string
+	// Checksum is true when DB was opened WithChecksum
+	Checksum bool
+	// Filters is the number of filters added using WithFilter, including compression
+	Filters int
+	// Compression is true when GzipFilter was added
+	Compression bool
+	// Retention is true when retention policy was set using WithRetention
+	Retention bool
+	// RejectEmptyData is true when DB was opened WithRejectEmptyData
+	RejectEmptyData bool
+	// GroupCommitWindow is the window set using WithGroupCommit, zero when not used
+	GroupCommitWindow time.Duration
+	// WriteBehindInterval is the flush interval set using WithWriteBehind, zero when not used
+	WriteBehindInterval time.Duration
+	// OperationTimeout is set using WithOperationTimeout, zero when not used
+	OperationTimeout time.Duration
+	// ShardedLayout is true when DB was opened WithShardedLayout
+	ShardedLayout bool
+	// HierarchicalKeys is true when DB was opened WithHierarchicalKeys
+	HierarchicalKeys bool
+	// FileHeader is true when DB was opened WithFileHeader
+	FileHeader bool
+	// KeyMapper is true when DB was opened WithKeyMapper or WithKeyEncryption
+	KeyMapper bool
+	// KeyEncryption is true when DB was opened WithKeyEncryption
+	KeyEncryption bool
+	// DirSync is true when DB was opened WithDirSync
+	DirSync bool
+}
+
+// Options returns the configuration of the DB, for example to log it on startup
+func (s *DB) Options() Options {
+	s.tunableMutex.RLock()
+	defer s.tunableMutex.RUnlock()
+	options := Options{
+		Preset:           s.preset,
+		Checksum:         s.checksum,
+		Filters:          len(s.filters),
+		Retention:        s.retention != nil,
+		RejectEmptyData:  s.rejectEmpty,
+		OperationTimeout: s.operationTimeout,
+		ShardedLayout:    s.sharded,
+		HierarchicalKeys: s.hierarchicalKeys,
+		FileHeader:       s.fileHeader,
+		KeyMapper:        s.keyMapper != nil,
+		DirSync:          s.dirSync,
+	}
+	for _, filter := range s.filters {
+		if gzip, ok := filter.(gzipFilter); ok && gzip.compress {
+			options.Compression = true
+		}
+	}
+	if _, ok := s.keyMapper.(*encryptingKeyMapper); ok {
+		options.KeyEncryption = true
+	}
+	if s.groupCommit != nil {
+		options.GroupCommitWindow = s.groupCommit.window
+	}
+	if s.writeBehind != nil {
+		options.WriteBehindInterval = s.writeBehind.interval
+	}
+	return options
+}
@@ -0,0 +1,106 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresets(t *testing.T) {
+	t.Run("should read written data", func(t *testing.T) {
+		presets := map[string]deebee.Option{
+			"durable":  deebee.DurableDefaults(),
+			"fast":     deebee.FastDefaults(),
+			"archival": deebee.ArchivalDefaults(),
+		}
+		for name, preset := range presets {
+			t.Run(name, func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir(), preset)
+				writeData(t, db, "key", []byte("data"))
+				// when
+				actual := readData(t, db, "key")
+				// then
+				assert.Equal(t, []byte("data"), actual)
+				assert.Equal(t, name, db.Options().Preset)
+			})
+		}
+	})
+
+	t.Run("should describe durable preset", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.DurableDefaults())
+		// when
+		options := db.Options()
+		// then
+		expected := deebee.Options{
+			Preset:          "durable",
+			Checksum:        true,
+			Retention:       true,
+			RejectEmptyData: true,
+		}
+		assert.Equal(t, expected, options)
+	})
+
+	t.Run("should describe fast preset", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.FastDefaults())
+		// when
+		options := db.Options()
+		// then
+		expected := deebee.Options{
+			Preset:              "fast",
+			WriteBehindInterval: time.Second,
+		}
+		assert.Equal(t, expected, options)
+	})
+
+	t.Run("should describe archival preset", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.ArchivalDefaults())
+		// when
+		options := db.Options()
+		// then
+		expected := deebee.Options{
+			Preset:      "archival",
+			Checksum:    true,
+			Filters:     1,
+			Compression: true,
+			Retention:   true,
+		}
+		assert.Equal(t, expected, options)
+	})
+
+	t.Run("should override preset with options given after it", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.DurableDefaults(), deebee.WithAllowEmptyData())
+		// when
+		writeData(t, db, "key", []byte{})
+		// then
+		assert.False(t, db.Options().RejectEmptyData)
+	})
+}
+
+func TestDB_Options(t *testing.T) {
+	t.Run("should describe DB opened without options", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		assert.Equal(t, deebee.Options{}, db.Options())
+	})
+
+	t.Run("should describe options", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithGroupCommit(time.Millisecond),
+			deebee.WithOperationTimeout(time.Minute),
+			deebee.WithShardedLayout(),
+			deebee.WithHierarchicalKeys(),
+		)
+		// when
+		options := db.Options()
+		// then
+		expected := deebee.Options{
+			GroupCommitWindow: time.Millisecond,
+			OperationTimeout:  time.Minute,
+			ShardedLayout:     true,
+			HierarchicalKeys:  true,
+		}
+		assert.Equal(t, expected, options)
+	})
+}
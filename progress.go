@@ -0,0 +1,88 @@
+package deebee
+
+import (
+	"context"
+	"sync"
+)
+
+// Progress describes progress of a long operation, such as Verify, Compact, Backup or Restore
+type Progress struct {
+	// Key processed most recently
+	Key string
+	// Done is the number of keys processed so far
+	Done int
+	// Total is the number of keys to process
+	Total int
+	// Bytes read or written so far. Zero for operations not transferring data.
+	Bytes int64
+}
+
+// ProgressFunc is called after each processed key
+type ProgressFunc func(Progress)
+
+func (f ProgressFunc) report(progress Progress) {
+	if f != nil {
+		f(progress)
+	}
+}
+
+// forEachKey runs fn for each state key and reports progress after each key. Stops when
+// ctx is done or fn returned error.
+func (s *DB) forEachKey(ctx context.Context, progress ProgressFunc, fn func(key string) (bytes int64, err error)) error {
+	keys, err := s.stateKeys()
+	if err != nil {
+		return err
+	}
+	p := Progress{Total: len(keys)}
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		bytes, err := fn(key)
+		if err != nil {
+			return err
+		}
+		p.Key = key
+		p.Done++
+		p.Bytes += bytes
+		progress.report(p)
+	}
+	return nil
+}
+
+// forEachKeyParallel runs fn for each state key using at most parallelism goroutines.
+// Progress is reported in the order of keys, as if keys were processed sequentially.
+// Stops when ctx is done or fn returned error.
+func (s *DB) forEachKeyParallel(ctx context.Context, parallelism int, progress ProgressFunc, fn func(key string) (bytes int64, err error)) error {
+	keys, err := s.stateKeys()
+	if err != nil {
+		return err
+	}
+	var (
+		mutex sync.Mutex
+		done  = make(map[string]int64, len(keys)) // bytes of processed keys not reported yet
+		next  int                                 // index of the next key to report
+		p     = Progress{Total: len(keys)}
+	)
+	return runParallel(ctx, keys, parallelism, func(key string) error {
+		bytes, err := fn(key)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		done[key] = bytes
+		for ; next < len(keys); next++ {
+			bytes, ok := done[keys[next]]
+			if !ok {
+				break
+			}
+			delete(done, keys[next])
+			p.Key = keys[next]
+			p.Done++
+			p.Bytes += bytes
+			progress.report(p)
+		}
+		return nil
+	})
+}
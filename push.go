@@ -0,0 +1,207 @@
+package deebee
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// checksumHeader contains hex encoded SHA-256 of the data of the state
+const checksumHeader = "Deebee-Checksum"
+
+// Push sends the latest version of each state to the DB served by PushHandler at endpoint,
+// for example "https://example.com/deebee". Data is sent together with its SHA-256 checksum,
+// which is verified by the receiver before the version is committed. Nil client means
+// http.DefaultClient.
+//
+// States which the receiver already has are skipped, therefore interrupted Push can be
+// resumed by running it again. Deleted states are skipped. Data is sent after passing
+// through filters, so both DBs can use different filters.
+//
+// progress is called after each key and can be nil.
+func (s *DB) Push(ctx context.Context, client *http.Client, endpoint string, progress ProgressFunc) (err error) {
+	defer s.redactError(&err)
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if _, err = url.Parse(endpoint); err != nil {
+		return newClientError(fmt.Sprintf("invalid endpoint: %s", err))
+	}
+	return s.forEachKey(ctx, progress, func(key string) (int64, error) {
+		return s.push(ctx, client, endpoint, key)
+	})
+}
+
+func (s *DB) push(ctx context.Context, client *http.Client, endpoint, key string) (int64, error) {
+	latest, exists, err := s.latestFile(key)
+	if err != nil || !exists || latest.kind == tombstoneFile {
+		return 0, err
+	}
+	sum, size, err := s.versionChecksum(key, latest)
+	if err != nil {
+		return 0, err
+	}
+	remote, err := remoteChecksum(ctx, client, endpoint, key)
+	if err != nil || bytes.Equal(sum, remote) {
+		return 0, err
+	}
+	reader, err := s.versionReader(key, s.configFor(key), latest, ReaderOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	// reader is closed after the response was received, not by the client
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, pushURL(endpoint, key), ioutil.NopCloser(reader))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set(checksumHeader, hex.EncodeToString(sum))
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		return 0, responseError(response)
+	}
+	return size, nil
+}
+
+// versionChecksum returns SHA-256 and the size of the data of the version
+func (s *DB) versionChecksum(key string, version filename) ([]byte, int64, error) {
+	reader, err := s.versionReader(key, s.configFor(key), version, ReaderOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hash.Sum(nil), size, nil
+}
+
+// remoteChecksum returns checksum of the state stored by the receiver. Returns nil when
+// the receiver does not have the state.
+func remoteChecksum(ctx context.Context, client *http.Client, endpoint, key string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, pushURL(endpoint, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	_ = response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+		return hex.DecodeString(response.Header.Get(checksumHeader))
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, responseError(response)
+	}
+}
+
+func pushURL(endpoint, key string) string {
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + "key=" + url.QueryEscape(key)
+}
+
+func responseError(response *http.Response) error {
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+	return fmt.Errorf("receiver responded with %s: %s", response.Status, strings.TrimSpace(string(message)))
+}
+
+// PushHandler returns http.Handler receiving states sent by Push. Each state is written as
+// a new version, only when its data matches the checksum. Key is passed in the "key" query
+// parameter. The handler does not authenticate requests - wrap it with a handler which does.
+func (s *DB) PushHandler() http.Handler {
+	return pushHandler{db: s}
+}
+
+type pushHandler struct {
+	db *DB
+}
+
+func (h pushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := h.db.normalizeKey(r.URL.Query().Get("key"))
+	switch r.Method {
+	case http.MethodHead:
+		h.checksum(w, key)
+	case http.MethodPut:
+		h.receive(w, r, key)
+	default:
+		w.Header().Set("Allow", "HEAD, PUT")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h pushHandler) checksum(w http.ResponseWriter, key string) {
+	reader, err := h.db.Reader(key)
+	if err != nil {
+		h.fail(w, key, err)
+		return
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, reader); err != nil {
+		h.fail(w, key, err)
+		return
+	}
+	w.Header().Set(checksumHeader, hex.EncodeToString(hash.Sum(nil)))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h pushHandler) receive(w http.ResponseWriter, r *http.Request, key string) {
+	expected, err := hex.DecodeString(r.Header.Get(checksumHeader))
+	if err != nil || len(expected) != sha256.Size {
+		h.fail(w, key, newClientError("missing or invalid checksum"))
+		return
+	}
+	writer, err := h.db.newWriterWithTimeout(key)
+	if err != nil {
+		h.fail(w, key, err)
+		return
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(writer, hash), r.Body); err != nil {
+		_ = writer.abort()
+		h.fail(w, key, err)
+		return
+	}
+	if !bytes.Equal(hash.Sum(nil), expected) {
+		_ = writer.abort()
+		h.fail(w, key, &corruptedError{message: "checksum mismatch"})
+		return
+	}
+	if err = writer.Close(); err != nil {
+		h.fail(w, key, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h pushHandler) fail(w http.ResponseWriter, key string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case IsDataNotFound(err):
+		status = http.StatusNotFound
+	case IsCorrupted(err):
+		status = http.StatusUnprocessableEntity
+	case IsClientError(err):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, h.db.redact(err, key).Error(), status)
+}
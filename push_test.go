@@ -0,0 +1,153 @@
+package deebee_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Push(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should return client error for invalid endpoint", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		err := db.Push(ctx, nil, ":", nil)
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should push latest versions", func(t *testing.T) {
+		src := openDB(t, fake.ExistingDir(), deebee.WithFilter(deebee.GzipFilter()))
+		writeData(t, src, "key1", []byte("old"))
+		writeData(t, src, "key1", []byte("data1"))
+		writeData(t, src, "key2", []byte("data2"))
+		dst := openDB(t, fake.ExistingDir(), deebee.WithChecksum())
+		server := httptest.NewServer(dst.PushHandler())
+		defer server.Close()
+		// when
+		err := src.Push(ctx, nil, server.URL, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data1"), readData(t, dst, "key1"))
+		assert.Equal(t, []byte("data2"), readData(t, dst, "key2"))
+		assertVersionsCount(t, dst, "key1", 1)
+	})
+
+	t.Run("should skip states already pushed", func(t *testing.T) {
+		src := openDB(t, fake.ExistingDir())
+		writeData(t, src, "key1", []byte("data1"))
+		writeData(t, src, "key2", []byte("data2"))
+		dst := openDB(t, fake.ExistingDir())
+		writeData(t, dst, "key1", []byte("data1"))
+		server := httptest.NewServer(dst.PushHandler())
+		defer server.Close()
+		var progress []deebee.Progress
+		// when
+		err := src.Push(ctx, nil, server.URL, func(p deebee.Progress) {
+			progress = append(progress, p)
+		})
+		// then
+		require.NoError(t, err)
+		assertVersionsCount(t, dst, "key1", 1)
+		assertVersionsCount(t, dst, "key2", 1)
+		require.Len(t, progress, 2)
+		assert.Equal(t, deebee.Progress{Key: "key2", Done: 2, Total: 2, Bytes: 5}, progress[1])
+	})
+
+	t.Run("should skip deleted states", func(t *testing.T) {
+		src := openDB(t, fake.ExistingDir())
+		writeData(t, src, "key", []byte("data"))
+		require.NoError(t, src.Delete("key"))
+		dst := openDB(t, fake.ExistingDir())
+		server := httptest.NewServer(dst.PushHandler())
+		defer server.Close()
+		// when
+		err := src.Push(ctx, nil, server.URL, nil)
+		// then
+		require.NoError(t, err)
+		_, err = dst.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return error when receiver failed", func(t *testing.T) {
+		src := openDB(t, fake.ExistingDir())
+		writeData(t, src, "key", []byte("data"))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "failed", http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		// when
+		err := src.Push(ctx, nil, server.URL, nil)
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should stop when context is canceled", func(t *testing.T) {
+		src := openDB(t, fake.ExistingDir())
+		writeData(t, src, "key", []byte("data"))
+		dst := openDB(t, fake.ExistingDir())
+		server := httptest.NewServer(dst.PushHandler())
+		defer server.Close()
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		// when
+		err := src.Push(canceled, nil, server.URL, nil)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = dst.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+}
+
+func TestDB_PushHandler(t *testing.T) {
+	t.Run("should reject data not matching the checksum", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		server := httptest.NewServer(db.PushHandler())
+		defer server.Close()
+		request, err := http.NewRequest(http.MethodPut, server.URL+"?key=key", strings.NewReader("data"))
+		require.NoError(t, err)
+		request.Header.Set("Deebee-Checksum", strings.Repeat("00", 32))
+		// when
+		response, err := http.DefaultClient.Do(request)
+		// then
+		require.NoError(t, err)
+		_ = response.Body.Close()
+		assert.Equal(t, http.StatusUnprocessableEntity, response.StatusCode)
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should reject request without checksum", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		server := httptest.NewServer(db.PushHandler())
+		defer server.Close()
+		request, err := http.NewRequest(http.MethodPut, server.URL+"?key=key", strings.NewReader("data"))
+		require.NoError(t, err)
+		// when
+		response, err := http.DefaultClient.Do(request)
+		// then
+		require.NoError(t, err)
+		_ = response.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("should reject invalid key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		server := httptest.NewServer(db.PushHandler())
+		defer server.Close()
+		// when
+		response, err := http.Head(server.URL + "?key=")
+		// then
+		require.NoError(t, err)
+		_ = response.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
@@ -0,0 +1,195 @@
+package deebee
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+)
+
+// queueFormatVersion starts the state of the queue, so the state is never empty, even
+// when all items were consumed
+const queueFormatVersion = 1
+
+// Queue is a persistent FIFO of items stored in a single state. Each operation writes a
+// new version of the state containing only items which were not acknowledged, so consumed
+// items are removed from the latest version immediately. Use Compact, retention or
+// WithCompactionTrigger to remove old versions.
+type Queue struct {
+	db  *DB
+	key string
+}
+
+// Queue returns Queue stored in the state with given key. The state should not be
+// written using Writer.
+func (s *DB) Queue(key string) *Queue {
+	key = s.normalizeKey(key)
+	return &Queue{db: s, key: key}
+}
+
+// Push adds the item at the end of the queue. Item is stored durably when Push returns.
+func (q *Queue) Push(item []byte) (err error) {
+	defer q.db.redactError(&err, q.key)
+	return q.db.update(q.key, func(current []byte, exists bool) ([]byte, error) {
+		state, err := decodeQueue(current, exists)
+		if err != nil {
+			return nil, err
+		}
+		state.items = append(state.items, queueItem{seq: state.next, data: item})
+		state.next++
+		return state.encode(), nil
+	})
+}
+
+// Peek returns the oldest item without removing it. Returns data not found error when
+// the queue is empty.
+func (q *Queue) Peek() (_ []byte, err error) {
+	defer q.db.redactError(&err, q.key)
+	item, err := q.first()
+	return item.data, err
+}
+
+// Pop returns the oldest item and the function acknowledging it. The item is removed only
+// by ack, therefore it is returned again by Pop when the process crashed before the item
+// was processed. Calling ack again does nothing. Returns data not found error when the
+// queue is empty.
+func (q *Queue) Pop() (item []byte, ack func() error, err error) {
+	defer q.db.redactError(&err, q.key)
+	first, err := q.first()
+	if err != nil {
+		return nil, nil, err
+	}
+	return first.data, func() error {
+		return q.remove(first.seq)
+	}, nil
+}
+
+// Wait blocks until the queue is not empty. Returns ctx.Err() when ctx was done before.
+// Only items pushed using this DB are noticed, items pushed by other processes sharing the
+// dir are not.
+func (q *Queue) Wait(ctx context.Context) (err error) {
+	defer q.db.redactError(&err, q.key)
+	for {
+		rev, err := q.db.latestRevision(q.key)
+		if err != nil {
+			return err
+		}
+		if _, err = q.first(); !IsDataNotFound(err) {
+			return err
+		}
+		if _, err = q.db.WaitForChange(ctx, q.key, rev); err != nil && !IsDataNotFound(err) {
+			return err
+		}
+	}
+}
+
+func (q *Queue) first() (queueItem, error) {
+	state, err := q.read()
+	if err != nil {
+		return queueItem{}, err
+	}
+	if len(state.items) == 0 {
+		return queueItem{}, &dataNotFoundError{}
+	}
+	return state.items[0], nil
+}
+
+func (q *Queue) read() (queueState, error) {
+	reader, err := q.db.Reader(q.key)
+	if IsDataNotFound(err) {
+		return queueState{}, nil
+	}
+	if err != nil {
+		return queueState{}, err
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return queueState{}, err
+	}
+	return decodeQueue(data, true)
+}
+
+// remove removes the item with given sequence number, if it is still in the queue
+func (q *Queue) remove(seq uint64) (err error) {
+	defer q.db.redactError(&err, q.key)
+	return q.db.update(q.key, func(current []byte, exists bool) ([]byte, error) {
+		state, err := decodeQueue(current, exists)
+		if err != nil {
+			return nil, err
+		}
+		for i, item := range state.items {
+			if item.seq == seq {
+				state.items = append(state.items[:i], state.items[i+1:]...)
+				break
+			}
+		}
+		return state.encode(), nil
+	})
+}
+
+// queueState is stored as the format version, the next sequence number and items, each
+// one as the sequence number, the length of data and data. Numbers are uvarints.
+type queueState struct {
+	next  uint64
+	items []queueItem
+}
+
+type queueItem struct {
+	seq  uint64
+	data []byte
+}
+
+func (s queueState) encode() []byte {
+	encoded := []byte{queueFormatVersion}
+	encoded = appendUvarint(encoded, s.next)
+	for _, item := range s.items {
+		encoded = appendUvarint(encoded, item.seq)
+		encoded = appendUvarint(encoded, uint64(len(item.data)))
+		encoded = append(encoded, item.data...)
+	}
+	return encoded
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, v)
+	return append(b, buf[:n]...)
+}
+
+func decodeQueue(data []byte, exists bool) (queueState, error) {
+	if !exists {
+		return queueState{}, nil
+	}
+	if len(data) == 0 || data[0] != queueFormatVersion {
+		return queueState{}, &corruptedError{message: "unsupported queue format"}
+	}
+	data = data[1:]
+	var state queueState
+	var ok bool
+	if state.next, data, ok = readUvarint(data); !ok {
+		return queueState{}, &corruptedError{message: "queue is truncated"}
+	}
+	for len(data) > 0 {
+		var item queueItem
+		var size uint64
+		item.seq, data, ok = readUvarint(data)
+		if ok {
+			size, data, ok = readUvarint(data)
+		}
+		if !ok || uint64(len(data)) < size {
+			return queueState{}, &corruptedError{message: "queue is truncated"}
+		}
+		item.data = data[:size]
+		data = data[size:]
+		state.items = append(state.items, item)
+	}
+	return state, nil
+}
+
+func readUvarint(data []byte) (uint64, []byte, bool) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, data, false
+	}
+	return v, data[n:], true
+}
@@ -0,0 +1,117 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	t.Run("should return data not found error when queue is empty", func(t *testing.T) {
+		q := openDB(t, fake.ExistingDir()).Queue("queue")
+		// when
+		_, err := q.Peek()
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+		// and
+		_, _, err = q.Pop()
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return client error for invalid key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, key := range invalidKeys {
+			err := db.Queue(key).Push([]byte("item"))
+			assert.True(t, deebee.IsClientError(err), key)
+		}
+	})
+
+	t.Run("should pop items in the order they were pushed", func(t *testing.T) {
+		q := openDB(t, fake.ExistingDir()).Queue("queue")
+		for _, item := range []string{"a", "", "c"} {
+			require.NoError(t, q.Push([]byte(item)))
+		}
+		// expect
+		for _, expected := range []string{"a", "", "c"} {
+			item, ack, err := q.Pop()
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(item))
+			require.NoError(t, ack())
+		}
+		_, err := q.Peek()
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should not remove item which was not acknowledged", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		q := openDB(t, dir).Queue("queue")
+		require.NoError(t, q.Push([]byte("item")))
+		_, _, err := q.Pop()
+		require.NoError(t, err)
+		// when
+		item, err := openDB(t, dir).Queue("queue").Peek()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("item"), item)
+	})
+
+	t.Run("should remove only acknowledged item when ack is called twice", func(t *testing.T) {
+		q := openDB(t, fake.ExistingDir()).Queue("queue")
+		require.NoError(t, q.Push([]byte("item")))
+		require.NoError(t, q.Push([]byte("item")))
+		_, ack, err := q.Pop()
+		require.NoError(t, err)
+		require.NoError(t, ack())
+		// when
+		err = ack()
+		// then
+		require.NoError(t, err)
+		item, err := q.Peek()
+		require.NoError(t, err)
+		assert.Equal(t, []byte("item"), item)
+	})
+
+	t.Run("should store only items which were not acknowledged", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		q := openDB(t, dir).Queue("queue")
+		require.NoError(t, q.Push(makeData(1000, 1)))
+		_, ack, err := q.Pop()
+		require.NoError(t, err)
+		// when
+		require.NoError(t, ack())
+		// then
+		assert.Less(t, len(readData(t, openDB(t, dir), "queue")), 10)
+	})
+
+	t.Run("should wait until item is pushed", func(t *testing.T) {
+		q := openDB(t, fake.ExistingDir()).Queue("queue")
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = q.Push([]byte("item"))
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		// when
+		err := q.Wait(ctx)
+		// then
+		require.NoError(t, err)
+		item, err := q.Peek()
+		require.NoError(t, err)
+		assert.Equal(t, []byte("item"), item)
+	})
+
+	t.Run("should return error when context is done before item was pushed", func(t *testing.T) {
+		q := openDB(t, fake.ExistingDir()).Queue("queue")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		// when
+		err := q.Wait(ctx)
+		// then
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,37 @@
+package deebee
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// ReadRange returns at most length bytes of the latest data of the state, starting at
+// offset. Fewer bytes are returned when data ends earlier. Useful for reading parts of
+// large values, such as an index stored at the end. Data is seeked when Reader supports
+// Seek (see Reader.Seek), otherwise bytes before offset are read and discarded.
+//
+// Checksum is not verified, unless the range reaches the end of data.
+func (s *DB) ReadRange(key string, offset, length int64) (_ []byte, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if offset < 0 || length < 0 {
+		return nil, newClientError("negative offset or length")
+	}
+	reader, err := s.Reader(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	if reader.seekable() {
+		_, err = reader.Seek(offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(ioutil.Discard, reader, offset)
+	}
+	if err == io.EOF {
+		return []byte{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(io.LimitReader(reader, length))
+}
@@ -0,0 +1,72 @@
+package deebee_test
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_ReadRange(t *testing.T) {
+	t.Run("should return client error for negative arguments", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// expect
+		_, err := db.ReadRange("key", -1, 1)
+		assert.True(t, deebee.IsClientError(err))
+		_, err = db.ReadRange("key", 0, -1)
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should return data not found when state does not exist", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		_, err := db.ReadRange("missing", 0, 1)
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	dirs := map[string]func(t *testing.T) deebee.Dir{
+		"seekable": existingRootDir,
+		"not seekable": func(t *testing.T) deebee.Dir {
+			return fake.ExistingDir()
+		},
+	}
+	for name, newDir := range dirs {
+		t.Run(name, func(t *testing.T) {
+			tests := map[string]struct {
+				offset, length int64
+				expected       string
+			}{
+				"whole data":  {offset: 0, length: 10, expected: "0123456789"},
+				"middle":      {offset: 2, length: 3, expected: "234"},
+				"end":         {offset: 8, length: 5, expected: "89"},
+				"after end":   {offset: 20, length: 5, expected: ""},
+				"zero length": {offset: 2, length: 0, expected: ""},
+			}
+			for testName, test := range tests {
+				t.Run(testName, func(t *testing.T) {
+					db := openDB(t, newDir(t))
+					writeData(t, db, "key", []byte("0123456789"))
+					// when
+					data, err := db.ReadRange("key", test.offset, test.length)
+					// then
+					require.NoError(t, err)
+					assert.Equal(t, test.expected, string(data))
+				})
+			}
+		})
+	}
+
+	t.Run("should read range of filtered data", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t), deebee.WithFilter(deebee.GzipFilter()), deebee.WithChecksum())
+		writeData(t, db, "key", []byte("0123456789"))
+		// when
+		data, err := db.ReadRange("key", 2, 3)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("234"), data)
+	})
+}
@@ -0,0 +1,146 @@
+package deebee
+
+import (
+	"context"
+	"io"
+)
+
+// Recompress rewrites data versions older than the latest data version using codec, which
+// must be created by GzipFilter or GzipFilterLevel. Use it with a stronger level, such as
+// gzip.BestCompression, to reduce the storage used by versions kept for a long time
+// (see WithRetention), including versions written uncompressed. The latest data version
+// is never rewritten.
+//
+// codec replaces GzipFilter or DecompressFilter in the filter chain of each key. Keys
+// not using any of them are skipped, because they could not read compressed versions.
+// Data is not passed through the read transformer. Each version is replaced atomically,
+// keeping its version number, pins and labels. Versions being read by readers which were
+// not closed yet are not rewritten.
+//
+// progress is called after each key and can be nil.
+func (s *DB) Recompress(ctx context.Context, codec Filter, progress ProgressFunc) (err error) {
+	defer s.redactError(&err)
+	gzip, ok := codec.(gzipFilter)
+	if !ok || !gzip.compress {
+		return newClientError("codec must be created by GzipFilter or GzipFilterLevel")
+	}
+	if err = s.checkWritable(); err != nil {
+		return err
+	}
+	return s.forEachKey(ctx, progress, func(key string) (int64, error) {
+		config, ok := s.configFor(key).withCompression(gzip)
+		if !ok {
+			return 0, nil
+		}
+		return s.recompressKey(key, config)
+	})
+}
+
+// withCompression returns copy of the config using the codec instead of GzipFilter or
+// DecompressFilter. Returns false when the config does not use any of them.
+func (c keyConfig) withCompression(codec gzipFilter) (keyConfig, bool) {
+	replaced := false
+	filters := make([]Filter, len(c.filters))
+	for i, filter := range c.filters {
+		if _, ok := filter.(gzipFilter); ok {
+			filter = codec
+			replaced = true
+		}
+		filters[i] = filter
+	}
+	c.filters = filters
+	return c, replaced
+}
+
+func (s *DB) recompressKey(key string, config keyConfig) (int64, error) {
+	if err := s.checkAccess(WriteOperation, key); err != nil {
+		return 0, err
+	}
+	stateDir := s.stateDir(key)
+	var files []filename
+	err := iterateFiles(stateDir, func(file string) bool {
+		if f, err := parseFilename(file); err == nil && f.kind != tempFile && f.kind != pinFile {
+			files = append(files, f)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	latest, found := youngestData(files)
+	if !found {
+		return 0, nil
+	}
+	var written int64
+	for _, f := range files {
+		if f.kind != dataFile || !latest.youngerThan(f) {
+			continue
+		}
+		n, err := s.recompressVersion(key, stateDir, f, config)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// recompressVersion writes the data version to a temporary file using config, and then
+// replaces the version with it. The version is replaced the same way Compact removes
+// versions, so a version removed by Compact in the meantime is not brought back.
+func (s *DB) recompressVersion(key string, stateDir Dir, version filename, config keyConfig) (int64, error) {
+	reader, err := s.storedDataReader(key, s.configFor(key), version, ReaderOptions{})
+	if err != nil {
+		return 0, err
+	}
+	_ = stateDir.DeleteFile(version.temp()) // left by previous run which failed
+	file, err := stateDir.FileWriter(version.temp())
+	if err != nil {
+		_ = reader.Close()
+		return 0, err
+	}
+	n, err := writeRecompressed(file, reader, config)
+	_ = reader.Close()
+	if err != nil {
+		_ = stateDir.DeleteFile(version.temp())
+		return n, err
+	}
+	replaced, err := s.openVersions.unlessOpen(key, version.version, func() error {
+		existing, err := stateDir.FileReader(version.name)
+		if err != nil {
+			// removed by Compact after the reader was closed
+			return stateDir.DeleteFile(version.temp())
+		}
+		_ = existing.Close()
+		return stateDir.Rename(version.temp(), version.name)
+	})
+	if !replaced {
+		_ = stateDir.DeleteFile(version.temp())
+	}
+	return n, err
+}
+
+// writeRecompressed writes data from reader to the file using config, then syncs and
+// closes the file
+func writeRecompressed(file FileWriter, reader io.Reader, config keyConfig) (int64, error) {
+	filtered, err := config.storedDataWriter(file)
+	if err != nil {
+		_ = file.Close()
+		return 0, err
+	}
+	n, err := io.Copy(filtered, reader)
+	if err != nil {
+		_ = filtered.Close()
+		_ = file.Close()
+		return n, err
+	}
+	if err = filtered.Close(); err != nil {
+		_ = file.Close()
+		return n, err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return n, err
+	}
+	return n, file.Close()
+}
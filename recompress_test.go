@@ -0,0 +1,149 @@
+package deebee_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Recompress(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should return client error for codec other than gzip", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(deebee.GzipFilter()))
+		codecs := []deebee.Filter{nil, deebee.DecompressFilter(), prefixFilter("p")}
+		for _, codec := range codecs {
+			// when
+			err := db.Recompress(ctx, codec, nil)
+			// then
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+
+	t.Run("should compress old versions written without compression", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithFilter(deebee.DecompressFilter()), deebee.WithChecksum())
+		old := makeData(1024*1024, 1)
+		writeData(t, db, "key", old)
+		writeData(t, db, "key", makeData(1024*1024, 2))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		err = db.Recompress(ctx, deebee.GzipFilterLevel(gzip.BestCompression), nil)
+		// then
+		require.NoError(t, err)
+		assert.Less(t, len(versionFile(t, dir, "key", versions[0].Version).Data()), len(old)/100)
+		assert.Equal(t, old, readVersion(t, db, "key", versions[0].Version))
+	})
+
+	t.Run("should not rewrite the latest version", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithFilter(deebee.DecompressFilter()))
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("latest"))
+		latest := latestVersion(t, db, "key")
+		// when
+		err := db.Recompress(ctx, deebee.GzipFilter(), nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("latest"), versionFile(t, dir, "key", latest).Data())
+	})
+
+	t.Run("should not rewrite version being read", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithFilter(deebee.DecompressFilter()))
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		reader, err := db.VersionReader("key", versions[0].Version)
+		require.NoError(t, err)
+		// when
+		err = db.Recompress(ctx, deebee.GzipFilter(), nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), versionFile(t, dir, "key", versions[0].Version).Data())
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), data)
+		require.NoError(t, reader.Close())
+		assert.Len(t, dir.Dir("key").(fake.Dir).Files(), 2, "temp file should be removed")
+	})
+
+	t.Run("should skip keys not using compression", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		err = db.Recompress(ctx, deebee.GzipFilter(), nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), versionFile(t, dir, "key", versions[0].Version).Data())
+	})
+
+	t.Run("should keep versions readable", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir,
+			deebee.WithFilter(deebee.GzipFilter()),
+			deebee.WithFilter(prefixFilter("prefix")),
+			deebee.WithFileHeader(),
+			deebee.WithChecksum(),
+		)
+		for i := 0; i < 3; i++ {
+			writeData(t, db, "key", []byte("data"+strconv.Itoa(i)))
+		}
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		err = db.Recompress(ctx, deebee.GzipFilterLevel(gzip.BestCompression), nil)
+		// then
+		require.NoError(t, err)
+		for i, v := range versions {
+			assert.Equal(t, []byte("data"+strconv.Itoa(i)), readVersion(t, db, "key", v.Version))
+		}
+		assert.Empty(t, tempFiles(dir, "key"))
+	})
+
+	t.Run("should report progress", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(deebee.GzipFilter()))
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		var reported []deebee.Progress
+		// when
+		err := db.Recompress(ctx, deebee.GzipFilter(), func(p deebee.Progress) {
+			reported = append(reported, p)
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []deebee.Progress{{Key: "key", Done: 1, Total: 1, Bytes: 3}}, reported)
+	})
+}
+
+func versionFile(t *testing.T, dir fake.Dir, key string, version int) *fake.File {
+	for _, file := range dir.Dir(key).(fake.Dir).Files() {
+		if file.Name() == strconv.Itoa(version) {
+			return file
+		}
+	}
+	require.FailNow(t, "version file not found")
+	return nil
+}
+
+func readVersion(t *testing.T, db *deebee.DB, key string, version int) []byte {
+	reader, err := db.VersionReader(key, version)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	return data
+}
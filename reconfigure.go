@@ -0,0 +1,67 @@
+package deebee
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Reconfigure changes options of the opened DB, without reopening it. Only tunable options
+// can be used: WithRetention, WithMaxConcurrentWriters, WithMaxConcurrentWritersPerKey and
+// WithSlowOpThreshold. Other options return client error. Options are validated first - when
+// any of them is invalid, nothing is changed. Reconfigured event is emitted after the change.
+//
+// Options given to WithKeyOptions are not changed, therefore keys matching their patterns
+// keep the retention policy set on Open. Writers created when no writer limit was set are
+// not counted by limits set later.
+func (s *DB) Reconfigure(options ...Option) error {
+	scratch := &DB{}
+	for _, apply := range options {
+		if apply != nil {
+			if err := apply(scratch); err != nil {
+				return fmt.Errorf("applying option failed: %w", err)
+			}
+		}
+	}
+	retention := scratch.retention
+	maxWriters, maxWritersPerKey := scratch.writerLimits.max, scratch.writerLimits.maxPerKey
+	slowOps := scratch.slowOps
+	scratch.retention = nil
+	scratch.writerLimits.max, scratch.writerLimits.maxPerKey = 0, 0
+	scratch.slowOps = nil
+	if !reflect.DeepEqual(scratch, &DB{}) {
+		return newClientError("only WithRetention, WithMaxConcurrentWriters, WithMaxConcurrentWritersPerKey " +
+			"and WithSlowOpThreshold can be reconfigured")
+	}
+
+	if retention != nil && !Capabilities(s.dir).FileModTime {
+		return missingCapabilityError("FileModTimer", "WithRetention")
+	}
+
+	s.tunableMutex.Lock()
+	if retention != nil {
+		s.retention = retention
+	}
+	if slowOps != nil {
+		slowOps.emit = s.emit
+		s.slowOps = slowOps
+	}
+	s.tunableMutex.Unlock()
+	s.writerLimits.set(maxWriters, maxWritersPerKey)
+	s.emit(Event{Type: Reconfigured})
+	return nil
+}
+
+// currentKeyConfig returns the DB-wide keyConfig, whose retention can be changed using
+// Reconfigure
+func (s *DB) currentKeyConfig() keyConfig {
+	s.tunableMutex.RLock()
+	defer s.tunableMutex.RUnlock()
+	return s.keyConfig
+}
+
+// currentSlowOps returns slowOps, which can be changed using Reconfigure
+func (s *DB) currentSlowOps() *slowOps {
+	s.tunableMutex.RLock()
+	defer s.tunableMutex.RUnlock()
+	return s.slowOps
+}
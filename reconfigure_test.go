@@ -0,0 +1,108 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Reconfigure(t *testing.T) {
+	keepAll := func(now time.Time, commitTimes []time.Time) []bool {
+		keep := make([]bool, len(commitTimes))
+		for i := range keep {
+			keep[i] = true
+		}
+		return keep
+	}
+
+	t.Run("should return client error for options which are not tunable", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		err := db.Reconfigure(deebee.WithChecksum())
+		// then
+		assert.True(t, deebee.IsClientError(err))
+		assert.False(t, db.Options().Checksum)
+	})
+
+	t.Run("should not change anything when option is invalid", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		err := db.Reconfigure(deebee.WithRetention(keepAll), deebee.WithMaxConcurrentWriters(0))
+		// then
+		assert.Error(t, err)
+		assert.False(t, db.Options().Retention)
+	})
+
+	t.Run("should change retention", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		// when
+		err := db.Reconfigure(deebee.WithRetention(keepAll))
+		// then
+		require.NoError(t, err)
+		require.NoError(t, db.Compact(context.Background(), nil))
+		assertVersionsCount(t, db, "key", 2)
+	})
+
+	t.Run("should change writer limits", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		err := db.Reconfigure(deebee.WithMaxConcurrentWriters(1))
+		// then
+		require.NoError(t, err)
+		writer, err := db.Writer("key1")
+		require.NoError(t, err)
+		defer writer.Close()
+		_, err = db.Writer("key2")
+		assert.True(t, deebee.IsTooManyWriters(err))
+	})
+
+	t.Run("should change slow operation threshold", func(t *testing.T) {
+		const delay = 20 * time.Millisecond
+		events := &slowOpEvents{}
+		db := openDB(t, slowDir{next: fake.ExistingDir(), delay: delay},
+			deebee.WithSlowOpThreshold(time.Minute),
+			deebee.WithEventHandler(events.handle),
+		)
+		// when
+		err := db.Reconfigure(deebee.WithSlowOpThreshold(delay / 2))
+		// then
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("data"))
+		assert.NotEmpty(t, events.get())
+	})
+
+	t.Run("should emit event", func(t *testing.T) {
+		events := make(chan deebee.Event, 1)
+		db := openDB(t, fake.ExistingDir(), deebee.WithEventHandler(func(event deebee.Event) {
+			events <- event
+		}))
+		// when
+		err := db.Reconfigure(deebee.WithMaxConcurrentWritersPerKey(1))
+		// then
+		require.NoError(t, err)
+		assertEvent(t, events, deebee.Reconfigured, "")
+	})
+
+	t.Run("should reconfigure concurrently with writes", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithSlowOpThreshold(time.Minute))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 10; i++ {
+				_ = db.Reconfigure(deebee.WithRetention(keepAll), deebee.WithSlowOpThreshold(time.Hour))
+			}
+		}()
+		for i := 0; i < 10; i++ {
+			writeData(t, db, "key", []byte("data"))
+			require.NoError(t, db.Compact(context.Background(), nil))
+		}
+		<-done
+	})
+}
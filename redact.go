@@ -0,0 +1,111 @@
+package deebee
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// WithErrorRedaction removes filesystem paths and keys from messages of errors returned
+// by DB, readers and writers, so they can be safely shipped off-box with logs. Errors can
+// still be classified using IsClientError, IsDataNotFound or errors.Is.
+func WithErrorRedaction() Option {
+	return func(db *DB) error {
+		db.errorRedaction = true
+		return nil
+	}
+}
+
+// redactError replaces *err with redacted version when WithErrorRedaction is used.
+// Keys are redacted only when quoted in the message.
+func (s *DB) redactError(err *error, keys ...string) {
+	if *err != nil && s.errorRedaction {
+		*err = s.redact(*err, keys...)
+	}
+}
+
+func (s *DB) redact(err error, keys ...string) error {
+	if err == nil || !s.errorRedaction {
+		return err
+	}
+	var secrets []string
+	if root, ok := asOsDir(s.dir); ok {
+		secrets = append(secrets, string(root.OsDir))
+	}
+	var pathError *os.PathError
+	if errors.As(err, &pathError) {
+		secrets = append(secrets, pathError.Path)
+	}
+	var linkError *os.LinkError
+	if errors.As(err, &linkError) {
+		secrets = append(secrets, linkError.Old, linkError.New)
+	}
+	for _, key := range keys {
+		secrets = append(secrets, `"`+key+`"`)
+	}
+	message := err.Error()
+	for _, secret := range secrets {
+		if secret != "" {
+			message = strings.ReplaceAll(message, secret, redacted)
+		}
+	}
+	return &redactedError{err: err, message: message}
+}
+
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+func (e *redactedError) IsClientError() bool {
+	return IsClientError(e.err)
+}
+
+type redactingReader struct {
+	io.ReadCloser
+	db  *DB
+	key string
+}
+
+func (r *redactingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		return n, err
+	}
+	return n, r.db.redact(err, r.key)
+}
+
+func (r *redactingReader) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.Copy(w, r.ReadCloser)
+	return n, r.db.redact(err, r.key)
+}
+
+func (r *redactingReader) Close() error {
+	return r.db.redact(r.ReadCloser.Close(), r.key)
+}
+
+type redactingWriter struct {
+	io.WriteCloser
+	db  *DB
+	key string
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	return n, w.db.redact(err, w.key)
+}
+
+func (w *redactingWriter) Close() error {
+	return w.db.redact(w.WriteCloser.Close(), w.key)
+}
@@ -0,0 +1,81 @@
+package deebee_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithErrorRedaction(t *testing.T) {
+	t.Run("should redact path of missing database dir", func(t *testing.T) {
+		dir := filepath.Join(createTempDir(t), "secret-dir")
+		// when
+		_, err := deebee.Open(deebee.OsDir(dir), deebee.WithErrorRedaction())
+		// then
+		require.Error(t, err)
+		assert.True(t, deebee.IsClientError(err))
+		assert.NotContains(t, err.Error(), "secret-dir")
+	})
+
+	t.Run("should redact invalid key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithErrorRedaction())
+		// when
+		_, err := db.Writer("secret key ")
+		// then
+		require.Error(t, err)
+		assert.True(t, deebee.IsClientError(err))
+		assert.NotContains(t, err.Error(), "secret key")
+	})
+
+	t.Run("should redact keys of renamed state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithErrorRedaction())
+		writeData(t, db, "secret-old", []byte("old"))
+		writeData(t, db, "secret-new", []byte("new"))
+		// when
+		err := db.Rename("secret-old", "secret-new")
+		// then
+		require.Error(t, err)
+		assert.True(t, deebee.IsClientError(err))
+		assert.NotContains(t, err.Error(), "secret")
+	})
+
+	t.Run("should keep data not found classification", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithErrorRedaction())
+		// when
+		_, err := db.Reader("missing")
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should redact filesystem path and keep wrapped error", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t), deebee.WithErrorRedaction())
+		path := filepath.Join(createTempDir(t), "secret-snapshot")
+		require.NoError(t, os.Mkdir(path, 0775))
+		// when
+		err := db.ExportSnapshotDir(path)
+		// then
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, os.ErrExist))
+		assert.NotContains(t, err.Error(), "secret-snapshot")
+	})
+
+	t.Run("should redact error returned by writer Close", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, failing.Rename(dir), deebee.WithErrorRedaction())
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		require.Error(t, err)
+		assert.NotEqual(t, "", err.Error())
+		assert.NotNil(t, errors.Unwrap(err))
+	})
+}
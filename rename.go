@@ -0,0 +1,169 @@
+package deebee
+
+import "fmt"
+
+// Rename moves the state with all its versions to newKey in one atomic operation (as long
+// as Dir.Rename is atomic). Returns client error when newKey already exists, even when
+// it was deleted, and data not found error when there is no state with oldKey.
+//
+// Writers for oldKey which are still open when the state is renamed will fail on Close.
+//
+// When DB was opened WithKeyMapper, or WithShardedLayout and keys are stored in
+// different intermediate dirs,
+// versions are copied one by one, therefore Rename is not atomic and the empty dir of
+// oldKey is left. The same applies to keys created WithHierarchicalKeys when they have
+// different parents or the state of oldKey has children, which are not moved.
+func (s *DB) Rename(oldKey, newKey string) (err error) {
+	oldKey = s.normalizeKey(oldKey)
+	newKey = s.normalizeKey(newKey)
+	defer s.redactError(&err, oldKey, newKey)
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.checkKey(oldKey); err != nil {
+		return err
+	}
+	if err := s.checkKey(newKey); err != nil {
+		return err
+	}
+	if err := s.checkAccess(DeleteOperation, oldKey); err != nil {
+		return err
+	}
+	if err := s.checkAccess(WriteOperation, newKey); err != nil {
+		return err
+	}
+	if err := s.checkKeyCase(newKey); err != nil {
+		return err
+	}
+	if err := s.flushWriteBehindKey(oldKey); err != nil {
+		return err
+	}
+	if err := s.flushWriteBehindKey(newKey); err != nil {
+		return err
+	}
+	if _, err := s.existingStateDir(oldKey); err != nil {
+		return err
+	}
+	newStateDir := s.stateDir(newKey)
+	newKeyExists, err := newStateDir.Exists()
+	if err != nil {
+		return err
+	}
+	if newKeyExists {
+		newKeyExists, err = hasFiles(newStateDir)
+		if err != nil {
+			return err
+		}
+	}
+	if newKeyExists {
+		return newClientError(fmt.Sprintf("key \"%s\" already exists", newKey))
+	}
+	if err = s.renameStateDir(oldKey, newKey, newStateDir); err != nil {
+		return err
+	}
+	if err = s.syncRenamed(oldKey, newKey); err != nil {
+		return err
+	}
+	s.index.rename(oldKey, newKey)
+	s.committed.rename(oldKey, newKey)
+	s.changes.changed(oldKey)
+	s.changes.changed(newKey)
+	s.dirCache.remove(oldKey)
+	s.keyCase.forget(oldKey)
+	return nil
+}
+
+func (s *DB) renameStateDir(oldKey, newKey string, newStateDir Dir) error {
+	if s.keyMapper != nil {
+		// the dir contains the manifest of the old key, therefore versions are moved
+		if err := s.mkdirState(newKey, newStateDir); err != nil {
+			return err
+		}
+		return moveState(s.stateDir(oldKey), newStateDir)
+	}
+	if s.hierarchicalKeys {
+		return s.renameHierarchicalStateDir(oldKey, newKey, newStateDir)
+	}
+	if !s.sharded {
+		return s.dir.Rename(oldKey, newKey)
+	}
+	if shard(oldKey) == shard(newKey) {
+		return s.dir.Dir(shard(oldKey)).Rename(oldKey, newKey)
+	}
+	if err := s.mkdirState(newKey, newStateDir); err != nil {
+		return err
+	}
+	return moveState(s.stateDir(oldKey), newStateDir)
+}
+
+// syncRenamed syncs both state dirs, which may have versions moved, and their parents,
+// when DB was opened WithDirSync. The new state is synced first, so the state is not lost
+// on power loss, but both keys may exist.
+func (s *DB) syncRenamed(oldKey, newKey string) error {
+	if !s.dirSync {
+		return nil
+	}
+	if err := s.syncDir(s.stateDir(newKey)); err != nil {
+		return err
+	}
+	if err := s.syncStateParents(s.physicalKey(newKey)); err != nil {
+		return err
+	}
+	oldStateDir := s.stateDir(oldKey)
+	exists, err := oldStateDir.Exists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err = s.syncDir(oldStateDir); err != nil {
+			return err
+		}
+	}
+	return s.syncStateParents(s.physicalKey(oldKey))
+}
+
+// renameHierarchicalStateDir renames the dir only when it has no child states and stays
+// in the same parent dir. Otherwise versions are moved, so children are left in place.
+func (s *DB) renameHierarchicalStateDir(oldKey, newKey string, newStateDir Dir) error {
+	oldParent, oldName := splitKey(oldKey)
+	newParent, newName := splitKey(newKey)
+	sameParent := oldParent == newParent && (!s.sharded || shard(oldKey) == shard(newKey))
+	if sameParent {
+		children, err := s.stateDir(oldKey).ListDirs()
+		if err != nil {
+			return err
+		}
+		newKeyDirExists, err := newStateDir.Exists()
+		if err != nil {
+			return err
+		}
+		if len(children) == 0 && !newKeyDirExists {
+			return s.parentDir(oldKey, oldParent).Rename(oldName, newName)
+		}
+	}
+	if err := s.mkdirState(newKey, newStateDir); err != nil {
+		return err
+	}
+	return moveState(s.stateDir(oldKey), newStateDir)
+}
+
+// parentDir returns dir containing the state dir of the key with given parent key
+func (s *DB) parentDir(key, parent string) Dir {
+	root := s.dir
+	if s.sharded {
+		root = root.Dir(shard(key))
+	}
+	if parent == "" {
+		return root
+	}
+	return keyDir(root, parent)
+}
+
+func hasFiles(dir Dir) (bool, error) {
+	found := false
+	err := iterateFiles(dir, func(string) bool {
+		found = true
+		return false
+	})
+	return found, err
+}
@@ -0,0 +1,76 @@
+package deebee_test
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Rename(t *testing.T) {
+	t.Run("should return error for invalid keys", func(t *testing.T) {
+		for _, key := range invalidKeys {
+			t.Run(key, func(t *testing.T) {
+				db := openDB(t, fake.ExistingDir())
+				writeData(t, db, "key", []byte("data"))
+				// expect
+				assert.True(t, deebee.IsClientError(db.Rename(key, "new")))
+				assert.True(t, deebee.IsClientError(db.Rename("key", key)))
+			})
+		}
+	})
+
+	t.Run("should return data not found when old key does not exist", func(t *testing.T) {
+		err := openDB(t, fake.ExistingDir()).Rename("missing", "new")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return client error when new key already exists", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "old", []byte("old"))
+		writeData(t, db, "new", []byte("new"))
+		// when
+		err := db.Rename("old", "new")
+		// then
+		assert.True(t, deebee.IsClientError(err))
+		assert.Equal(t, []byte("old"), readData(t, db, "old"))
+		assert.Equal(t, []byte("new"), readData(t, db, "new"))
+	})
+
+	dirs := map[string]func(t *testing.T) deebee.Dir{
+		"fake": func(t *testing.T) deebee.Dir { return fake.ExistingDir() },
+		"os":   existingRootDir,
+	}
+	for name, newDir := range dirs {
+		t.Run(name, func(t *testing.T) {
+
+			t.Run("should move all versions", func(t *testing.T) {
+				db := openDB(t, newDir(t))
+				writeData(t, db, "old", []byte("v1"))
+				writeData(t, db, "old", []byte("v2"))
+				require.NoError(t, db.Delete("old"))
+				// when
+				err := db.Rename("old", "new")
+				// then
+				require.NoError(t, err)
+				_, err = db.Reader("old")
+				assert.True(t, deebee.IsDataNotFound(err))
+				require.NoError(t, db.Undelete("new"))
+				assert.Equal(t, []byte("v2"), readData(t, db, "new"))
+			})
+		})
+	}
+
+	t.Run("should return error when Dir.Rename failed", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "old", []byte("data"))
+		db := openDB(t, failing.Rename(dir))
+		// when
+		err := db.Rename("old", "new")
+		// then
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,176 @@
+package deebee
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+)
+
+// RepairOptions configures Repair
+type RepairOptions struct {
+	// Options are used to open the DB. They must describe how data was written, for example
+	// WithChecksum, WithFilter or WithShardedLayout.
+	Options []Option
+	// RemoveCorrupted removes corrupted data versions (see IsCorrupted), so Reader returns
+	// the youngest intact version instead. Versions which could not be read because of other
+	// errors, such as I/O errors, are only reported. Corrupted versions of the state without
+	// intact versions are kept, unless Force is set.
+	RemoveCorrupted bool
+	// Force makes RemoveCorrupted remove corrupted versions also when the state has no
+	// intact version, which removes the state. Run Repair without it first to review the
+	// report.
+	Force bool
+	// Compact runs Compact after other steps
+	Compact bool
+	// Progress is called after each key and can be nil
+	Progress ProgressFunc
+}
+
+// RepairReport describes what Repair found and did
+type RepairReport struct {
+	// RemovedTempFiles lists versions which were never committed, for example because the
+	// process crashed while writing them
+	RemovedTempFiles []RemovedVersion
+	// Corrupted lists data versions which could not be read
+	Corrupted []CorruptedVersion
+	// RemovedCorrupted is true when all versions listed in Corrupted were removed
+	RemovedCorrupted bool
+	// Compacted lists versions removed by compaction
+	Compacted []RemovedVersion
+}
+
+// CorruptedVersion is the data version which could not be read
+type CorruptedVersion struct {
+	Key     string
+	Version int
+	Err     error
+	// Removed is true when the version was removed because of RepairOptions.RemoveCorrupted
+	Removed bool
+}
+
+// Repair recovers the DB stored in dir, for example after a crash, before the application
+// using it is started. The DB is not returned, so it can't be read or written in the
+// meantime, and it must not be used by other processes. Repair does the following:
+//
+//   - removes temp files of versions which were never committed, regardless of their age
+//   - reads all data versions, to find corrupted ones, and removes them when
+//     RepairOptions.RemoveCorrupted is set. The last versions of the state are removed only
+//     when RepairOptions.Force is set too.
+//   - runs Compact, when RepairOptions.Compact is set
+//
+// Returned report describes what was found and done, also when Repair failed.
+func Repair(ctx context.Context, dir Dir, options RepairOptions) (RepairReport, error) {
+	var report RepairReport
+	db, err := Open(dir, options.Options...)
+	if err != nil {
+		return report, err
+	}
+	err = db.repair(ctx, options, &report)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return report, err
+}
+
+func (s *DB) repair(ctx context.Context, options RepairOptions, report *RepairReport) error {
+	err := s.forEachKey(ctx, options.Progress, func(key string) (int64, error) {
+		removed, err := s.removeTempFiles(key)
+		report.RemovedTempFiles = append(report.RemovedTempFiles, removed...)
+		if err != nil {
+			return 0, err
+		}
+		corrupted, intact, bytes, err := s.scrubKey(key)
+		if err == nil && options.RemoveCorrupted && (intact > 0 || options.Force) {
+			err = s.removeCorrupted(key, corrupted)
+		}
+		report.Corrupted = append(report.Corrupted, corrupted...)
+		return bytes, err
+	})
+	if err != nil {
+		return s.redact(err)
+	}
+	report.RemovedCorrupted = true
+	for _, c := range report.Corrupted {
+		report.RemovedCorrupted = report.RemovedCorrupted && c.Removed
+	}
+	if options.Compact {
+		compacted, err := s.CompactWithOptions(ctx, CompactOptions{}, nil)
+		report.Compacted = compacted.Removed
+		return err
+	}
+	return nil
+}
+
+// removeTempFiles removes all temp files of the state
+func (s *DB) removeTempFiles(key string) ([]RemovedVersion, error) {
+	stateDir := s.stateDir(key)
+	var temps []filename
+	err := iterateFiles(stateDir, func(file string) bool {
+		if f, err := parseFilename(file); err == nil && f.kind == tempFile {
+			temps = append(temps, f)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	var removed []RemovedVersion
+	for _, temp := range temps {
+		if err = stateDir.DeleteFile(temp.name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, RemovedVersion{Key: key, Version: temp.version})
+	}
+	return removed, nil
+}
+
+// removeCorrupted removes versions which could not be read because they are corrupted and
+// marks them as removed
+func (s *DB) removeCorrupted(key string, corrupted []CorruptedVersion) error {
+	for i, c := range corrupted {
+		if !IsCorrupted(c.Err) {
+			continue
+		}
+		if err := s.stateDir(key).DeleteFile(newFilename(c.Version).name); err != nil {
+			return err
+		}
+		corrupted[i].Removed = true
+	}
+	return nil
+}
+
+// scrubKey reads all data versions of the state and returns the ones which could not be
+// read along with the number of intact ones
+func (s *DB) scrubKey(key string) ([]CorruptedVersion, int, int64, error) {
+	versions, err := dataVersions(s.stateDir(key))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	config := s.configFor(key)
+	var (
+		corrupted []CorruptedVersion
+		bytes     int64
+	)
+	for _, v := range versions {
+		n, err := s.scrubVersion(key, config, v.Version)
+		bytes += n
+		if err != nil {
+			corrupted = append(corrupted, CorruptedVersion{Key: key, Version: v.Version, Err: s.redact(err, key)})
+			s.stats.add(statCorruptionEvents, 1)
+			s.emit(Event{Type: CorruptionDetected, Key: key, Version: v.Version, Err: s.redact(err, key)})
+		}
+	}
+	return corrupted, len(versions) - len(corrupted), bytes, nil
+}
+
+func (s *DB) scrubVersion(key string, config keyConfig, version int) (int64, error) {
+	reader, err := s.storedDataReader(key, config, newFilename(version), ReaderOptions{})
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(ioutil.Discard, reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
@@ -0,0 +1,160 @@
+package deebee_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepair(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should return error when dir does not exist", func(t *testing.T) {
+		_, err := deebee.Repair(ctx, fake.MissingDir(), deebee.RepairOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("should remove temp files", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("data"))
+		_, err := db.Writer("key")
+		require.NoError(t, err)
+		// when
+		report, err := deebee.Repair(ctx, dir, deebee.RepairOptions{})
+		// then
+		require.NoError(t, err)
+		require.Len(t, report.RemovedTempFiles, 1)
+		assert.Equal(t, "key", report.RemovedTempFiles[0].Key)
+		assert.Empty(t, tempFiles(dir, "key"))
+	})
+
+	t.Run("should report corrupted versions", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("data"))
+		version := latestVersion(t, db, "key")
+		corruptLatest(t, dir, "key")
+		// when
+		report, err := deebee.Repair(ctx, dir, deebee.RepairOptions{
+			Options: []deebee.Option{deebee.WithChecksum()},
+		})
+		// then
+		require.NoError(t, err)
+		require.Len(t, report.Corrupted, 1)
+		assert.Equal(t, "key", report.Corrupted[0].Key)
+		assert.Equal(t, version, report.Corrupted[0].Version)
+		assert.True(t, deebee.IsCorrupted(report.Corrupted[0].Err))
+		assert.False(t, report.RemovedCorrupted)
+		assertVersionsCount(t, db, "key", 1)
+	})
+
+	t.Run("should remove corrupted versions, so the youngest intact version is read", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("intact"))
+		writeData(t, db, "key", []byte("corrupted"))
+		require.NoError(t, dir.Dir("key").(fake.Dir).Corrupt(strconv.Itoa(latestVersion(t, db, "key"))))
+		// when
+		report, err := deebee.Repair(ctx, dir, deebee.RepairOptions{
+			Options:         []deebee.Option{deebee.WithChecksum()},
+			RemoveCorrupted: true,
+		})
+		// then
+		require.NoError(t, err)
+		assert.Len(t, report.Corrupted, 1)
+		assert.True(t, report.Corrupted[0].Removed)
+		assert.True(t, report.RemovedCorrupted)
+		assert.Equal(t, []byte("intact"), readData(t, db, "key"))
+	})
+
+	t.Run("should not remove versions which could not be read because of other errors", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		// when
+		report, err := deebee.Repair(ctx, failing.FileReader(dir), deebee.RepairOptions{
+			RemoveCorrupted: true,
+			Force:           true,
+		})
+		// then
+		require.NoError(t, err)
+		require.Len(t, report.Corrupted, 2)
+		assert.False(t, report.Corrupted[0].Removed)
+		assert.False(t, report.RemovedCorrupted)
+		assertVersionsCount(t, db, "key", 2)
+	})
+
+	t.Run("should not remove corrupted versions of state without intact version", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("data"))
+		corruptLatest(t, dir, "key")
+		// when
+		report, err := deebee.Repair(ctx, dir, deebee.RepairOptions{
+			Options:         []deebee.Option{deebee.WithChecksum()},
+			RemoveCorrupted: true,
+		})
+		// then
+		require.NoError(t, err)
+		require.Len(t, report.Corrupted, 1)
+		assert.False(t, report.Corrupted[0].Removed)
+		assert.False(t, report.RemovedCorrupted)
+		assertVersionsCount(t, db, "key", 1)
+	})
+
+	t.Run("should remove corrupted versions of state without intact version when forced", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("data"))
+		corruptLatest(t, dir, "key")
+		// when
+		report, err := deebee.Repair(ctx, dir, deebee.RepairOptions{
+			Options:         []deebee.Option{deebee.WithChecksum()},
+			RemoveCorrupted: true,
+			Force:           true,
+		})
+		// then
+		require.NoError(t, err)
+		require.Len(t, report.Corrupted, 1)
+		assert.True(t, report.RemovedCorrupted)
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should compact", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		// when
+		report, err := deebee.Repair(ctx, dir, deebee.RepairOptions{Compact: true})
+		// then
+		require.NoError(t, err)
+		assert.Len(t, report.Compacted, 1)
+		assertVersionsCount(t, db, "key", 1)
+	})
+
+	t.Run("should report progress", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir)
+		writeData(t, db, "key", []byte("data"))
+		var reported []deebee.Progress
+		// when
+		_, err := deebee.Repair(ctx, dir, deebee.RepairOptions{
+			Progress: func(p deebee.Progress) {
+				reported = append(reported, p)
+			},
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []deebee.Progress{{Key: "key", Done: 1, Total: 1, Bytes: 4}}, reported)
+	})
+}
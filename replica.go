@@ -0,0 +1,75 @@
+package deebee
+
+import (
+	"errors"
+)
+
+// ReplicaSet writes to the primary DB and reads from replicas. Replicas must be copies of
+// the primary dir, such as created by ExportSnapshotDir, Backup or filesystem replication,
+// because versions are compared to find out whether a replica is stale. Replicas should
+// not be opened WithPreload, because the index does not see files copied to the dir.
+type ReplicaSet struct {
+	primary  *DB
+	replicas []*DB
+}
+
+// NewReplicaSet returns ReplicaSet. Replicas are tried in the given order.
+func NewReplicaSet(primary *DB, replicas ...*DB) (*ReplicaSet, error) {
+	if primary == nil {
+		return nil, errors.New("nil primary")
+	}
+	for _, replica := range replicas {
+		if replica == nil {
+			return nil, errors.New("nil replica")
+		}
+	}
+	return &ReplicaSet{primary: primary, replicas: replicas}, nil
+}
+
+// Writer returns Writer of the primary DB
+func (r *ReplicaSet) Writer(key string) (*Writer, error) {
+	return r.primary.Writer(key)
+}
+
+// Version returns the latest version of the key in the primary DB, including versions
+// written by Delete. Returned version can be passed to Reader to avoid reading stale data,
+// for example after the key was written. Returns 0 when there is no such key.
+func (r *ReplicaSet) Version(key string) (version int, err error) {
+	key = r.primary.normalizeKey(key)
+	defer r.primary.redactError(&err, key)
+	latest, exists, err := r.primary.latestFile(key)
+	if err != nil || !exists {
+		return 0, err
+	}
+	return latest.version, nil
+}
+
+// Reader returns Reader from the first replica which is available and has version of
+// the key not older than minVersion. Zero minVersion means that any version is good
+// enough. The primary DB is used when no replica can be used.
+func (r *ReplicaSet) Reader(key string, minVersion int) (*Reader, error) {
+	if err := r.primary.checkKey(key); err != nil {
+		return nil, err
+	}
+	for _, replica := range r.replicas {
+		if reader, ok := replica.readerNotOlderThan(key, minVersion); ok {
+			return reader, nil
+		}
+	}
+	return r.primary.Reader(key)
+}
+
+// readerNotOlderThan returns false when DB failed, the key does not exist or its version is
+// older than minVersion. Deleted keys are read from the primary too.
+func (s *DB) readerNotOlderThan(key string, minVersion int) (*Reader, bool) {
+	key = s.normalizeKey(key)
+	latest, exists, err := s.latestFile(key)
+	if err != nil || !exists || latest.version < minVersion || latest.kind == tombstoneFile {
+		return nil, false
+	}
+	reader, err := s.Reader(key)
+	if err != nil {
+		return nil, false
+	}
+	return reader, true
+}
@@ -0,0 +1,139 @@
+package deebee_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReplicaSet(t *testing.T) {
+	t.Run("should return error for nil primary", func(t *testing.T) {
+		_, err := deebee.NewReplicaSet(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for nil replica", func(t *testing.T) {
+		_, err := deebee.NewReplicaSet(openDB(t, fake.ExistingDir()), nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestReplicaSet_Reader(t *testing.T) {
+	t.Run("should read from replica", func(t *testing.T) {
+		primary := openDB(t, fake.ExistingDir())
+		replica := openDB(t, fake.ExistingDir())
+		writeData(t, replica, "key", []byte("replica"))
+		set, err := deebee.NewReplicaSet(primary, replica)
+		require.NoError(t, err)
+		// when
+		reader, err := set.Reader("key", 0)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("replica"), readAll(t, reader))
+	})
+
+	t.Run("should skip failing replica", func(t *testing.T) {
+		primary := openDB(t, fake.ExistingDir())
+		failingDir := fake.ExistingDir()
+		writeData(t, openDB(t, failingDir), "key", []byte("failing"))
+		failingReplica := openDB(t, failing.FileReader(failingDir))
+		replica := openDB(t, fake.ExistingDir())
+		writeData(t, replica, "key", []byte("replica"))
+		set, err := deebee.NewReplicaSet(primary, failingReplica, replica)
+		require.NoError(t, err)
+		// when
+		reader, err := set.Reader("key", 0)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("replica"), readAll(t, reader))
+	})
+
+	t.Run("should read from primary when replicas are stale", func(t *testing.T) {
+		primaryDir := fake.ExistingDir()
+		primary := openDB(t, primaryDir)
+		writeData(t, primary, "key", []byte("old"))
+		replicaDir := fake.ExistingDir()
+		require.NoError(t, primary.Backup(context.Background(), replicaDir, nil))
+		replica := openDB(t, replicaDir)
+		set, err := deebee.NewReplicaSet(primary, replica)
+		require.NoError(t, err)
+		// when
+		writer, err := set.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("new"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		version, err := set.Version("key")
+		require.NoError(t, err)
+		// then
+		reader, err := set.Reader("key", version)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), readAll(t, reader))
+		// and
+		reader, err = set.Reader("key", 0)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), readAll(t, reader))
+	})
+
+	t.Run("should read from primary when key is missing in replicas", func(t *testing.T) {
+		primary := openDB(t, fake.ExistingDir())
+		writeData(t, primary, "key", []byte("primary"))
+		set, err := deebee.NewReplicaSet(primary, openDB(t, fake.ExistingDir()))
+		require.NoError(t, err)
+		// when
+		reader, err := set.Reader("key", 0)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("primary"), readAll(t, reader))
+	})
+
+	t.Run("should return client error for invalid keys", func(t *testing.T) {
+		set, err := deebee.NewReplicaSet(openDB(t, fake.ExistingDir()))
+		require.NoError(t, err)
+		for _, key := range invalidKeys {
+			_, err = set.Reader(key, 0)
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+}
+
+func TestReplicaSet_Version(t *testing.T) {
+	t.Run("should return 0 for missing key", func(t *testing.T) {
+		set, err := deebee.NewReplicaSet(openDB(t, fake.ExistingDir()))
+		require.NoError(t, err)
+		// when
+		version, err := set.Version("missing")
+		// then
+		require.NoError(t, err)
+		assert.Zero(t, version)
+	})
+
+	t.Run("should increase after write", func(t *testing.T) {
+		primary := openDB(t, fake.ExistingDir())
+		set, err := deebee.NewReplicaSet(primary)
+		require.NoError(t, err)
+		writeData(t, primary, "key", []byte("1"))
+		first, err := set.Version("key")
+		require.NoError(t, err)
+		// when
+		writeData(t, primary, "key", []byte("2"))
+		// then
+		second, err := set.Version("key")
+		require.NoError(t, err)
+		assert.Greater(t, second, first)
+	})
+}
+
+func readAll(t *testing.T, reader io.ReadCloser) []byte {
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,147 @@
+package deebee
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// FileModTimer is an optional interface which can be implemented by Dir. Modification
+// time of committed file is used as the commit time of the version by retention policies
+// and OpenAsOf. Commit times never decrease with the version number, which is the only
+// order of versions: when the wall clock went back, the version gets the commit time of
+// the previous version. Therefore, versions with equal commit times are ordered by version
+// number.
+type FileModTimer interface {
+	// FileModTime returns modification time of the file. Must return error when file
+	// does not exist
+	FileModTime(name string) (time.Time, error)
+}
+
+// RetentionPolicy decides which versions older than the latest one are kept by Compact.
+// commitTimes are sorted from the oldest to the youngest version. Returned slice has the
+// same length, with true for each version which should be kept.
+type RetentionPolicy func(now time.Time, commitTimes []time.Time) (keep []bool)
+
+// WithRetention makes Compact keep versions older than the latest one selected by policy.
+// By default, Compact keeps only the latest version. Requires Dir implementing FileModTimer,
+// which provides commit times of versions.
+func WithRetention(policy RetentionPolicy) Option {
+	return func(db *DB) error {
+		if policy == nil {
+			return errors.New("nil retention policy")
+		}
+		db.retention = policy
+		return nil
+	}
+}
+
+// usesRetention returns true when retention policy was set for all keys or for keys
+// matching some pattern
+func (s *DB) usesRetention() bool {
+	if s.retention != nil {
+		return true
+	}
+	for _, o := range s.keyOptions {
+		if o.config.retention != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Tier of the Tiered retention policy
+type Tier struct {
+	// Age is the maximum age of versions kept by the tier
+	Age time.Duration
+	// Every is the interval in which at most one version is kept - the youngest one.
+	// Zero keeps all versions.
+	Every time.Duration
+}
+
+// Tiered returns grandfather-father-son retention policy. Each version is handled by the
+// tier with the smallest Age not lower than the age of the version. Versions older than
+// Age of all tiers are not kept. For example, to keep all versions from the last hour,
+// hourly versions for a day and daily versions for a month, use:
+//
+//	deebee.Tiered(
+//		deebee.Tier{Age: time.Hour},
+//		deebee.Tier{Age: 24 * time.Hour, Every: time.Hour},
+//		deebee.Tier{Age: 30 * 24 * time.Hour, Every: 24 * time.Hour},
+//	)
+func Tiered(tiers ...Tier) RetentionPolicy {
+	sorted := append([]Tier{}, tiers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Age < sorted[j].Age
+	})
+	return func(now time.Time, commitTimes []time.Time) []bool {
+		type bucket struct {
+			tier  int
+			start time.Time
+		}
+		keep := make([]bool, len(commitTimes))
+		buckets := map[bucket]bool{}
+		for i := len(commitTimes) - 1; i >= 0; i-- {
+			age := now.Sub(commitTimes[i])
+			tier := sort.Search(len(sorted), func(t int) bool {
+				return sorted[t].Age >= age
+			})
+			if tier == len(sorted) {
+				continue
+			}
+			if sorted[tier].Every <= 0 {
+				keep[i] = true
+				continue
+			}
+			b := bucket{tier: tier, start: commitTimes[i].Truncate(sorted[tier].Every)}
+			if !buckets[b] {
+				buckets[b] = true
+				keep[i] = true
+			}
+		}
+		return keep
+	}
+}
+
+// retained returns which files should be kept according to retention policy. files
+// must be sorted from the oldest. All files are kept when modTimer is nil, because commit
+// times are unknown.
+func retained(modTimer FileModTimer, files []filename, policy RetentionPolicy) ([]bool, error) {
+	keep := make([]bool, len(files))
+	if policy == nil {
+		return keep, nil
+	}
+	if modTimer == nil {
+		for i := range keep {
+			keep[i] = true
+		}
+		return keep, nil
+	}
+	commitTimes, err := commitTimesOf(modTimer, files)
+	if err != nil {
+		return nil, err
+	}
+	keep = policy(time.Now(), commitTimes)
+	if len(keep) != len(commitTimes) {
+		return nil, fmt.Errorf("retention policy returned %d results for %d versions", len(keep), len(commitTimes))
+	}
+	return keep, nil
+}
+
+// commitTimesOf returns commit times of files sorted from the oldest. Returned times never
+// decrease, even when the wall clock went back between commits.
+func commitTimesOf(modTimer FileModTimer, files []filename) ([]time.Time, error) {
+	commitTimes := make([]time.Time, len(files))
+	for i, f := range files {
+		t, err := modTimer.FileModTime(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && t.Before(commitTimes[i-1]) {
+			t = commitTimes[i-1]
+		}
+		commitTimes[i] = t
+	}
+	return commitTimes, nil
+}
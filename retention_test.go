@@ -0,0 +1,142 @@
+package deebee_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRetention(t *testing.T) {
+	t.Run("should return error for nil policy", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithRetention(nil))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("should keep versions selected by policy", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithRetention(deebee.Tiered(deebee.Tier{Age: time.Hour})))
+		now := time.Now()
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-time.Minute))
+		writeDataCommittedAt(t, db, dir, "key", now)
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		err = db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		actual, err := db.Versions("key")
+		require.NoError(t, err)
+		assert.Equal(t, versions[1:], actual)
+	})
+
+	t.Run("should pass monotonic commit times when clock went back", func(t *testing.T) {
+		var commitTimes []time.Time
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithRetention(func(_ time.Time, times []time.Time) []bool {
+			commitTimes = times
+			return make([]bool, len(times))
+		}))
+		now := time.Now()
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-3*time.Hour)) // clock went back
+		writeDataCommittedAt(t, db, dir, "key", now.Add(-2*time.Hour))
+		writeDataCommittedAt(t, db, dir, "key", now)
+		// when
+		err := db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		expected := []time.Time{now.Add(-time.Hour), now.Add(-time.Hour), now.Add(-time.Hour)}
+		require.Len(t, commitTimes, len(expected))
+		for i := range expected {
+			assert.True(t, expected[i].Equal(commitTimes[i]), "commit time %d", i)
+		}
+	})
+
+	t.Run("should return error when policy returned wrong number of results", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithRetention(func(time.Time, []time.Time) []bool {
+			return nil
+		}))
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("new"))
+		// when
+		err := db.Compact(context.Background(), nil)
+		// then
+		assert.Error(t, err)
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		assert.Len(t, versions, 2)
+	})
+
+	t.Run("should be scoped using WithKeyOptions", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		keepAll := deebee.WithRetention(deebee.Tiered(deebee.Tier{Age: time.Hour}))
+		db := openDB(t, dir, deebee.WithKeyOptions("kept", keepAll))
+		writeData(t, db, "kept", []byte("old"))
+		writeData(t, db, "kept", []byte("new"))
+		writeData(t, db, "other", []byte("old"))
+		writeData(t, db, "other", []byte("new"))
+		// when
+		err := db.Compact(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		kept, err := db.Versions("kept")
+		require.NoError(t, err)
+		assert.Len(t, kept, 2)
+		other, err := db.Versions("other")
+		require.NoError(t, err)
+		assert.Len(t, other, 1)
+	})
+}
+
+func TestTiered(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	policy := deebee.Tiered(
+		deebee.Tier{Age: 24 * time.Hour, Every: time.Hour},
+		deebee.Tier{Age: time.Hour},
+	)
+
+	t.Run("should keep all versions from the first tier", func(t *testing.T) {
+		keep := policy(now, []time.Time{
+			now.Add(-50 * time.Minute),
+			now.Add(-10 * time.Minute),
+		})
+		assert.Equal(t, []bool{true, true}, keep)
+	})
+
+	t.Run("should keep the youngest version in each interval", func(t *testing.T) {
+		keep := policy(now, []time.Time{
+			now.Add(-3*time.Hour - 50*time.Minute),
+			now.Add(-3*time.Hour - 10*time.Minute),
+			now.Add(-2*time.Hour - 30*time.Minute),
+		})
+		assert.Equal(t, []bool{false, true, true}, keep)
+	})
+
+	t.Run("should not keep versions older than all tiers", func(t *testing.T) {
+		keep := policy(now, []time.Time{
+			now.Add(-25 * time.Hour),
+		})
+		assert.Equal(t, []bool{false}, keep)
+	})
+}
+
+// writeDataCommittedAt writes new version of the state and sets its commit time
+func writeDataCommittedAt(t *testing.T, db *deebee.DB, dir fake.Dir, key string, commitTime time.Time) {
+	writeData(t, db, key, []byte(commitTime.String()))
+	versions, err := db.Versions(key)
+	require.NoError(t, err)
+	latest := strconv.Itoa(versions[len(versions)-1].Version)
+	for _, file := range dir.Dir(key).(fake.Dir).Files() {
+		if file.Name() == latest {
+			file.SetModTime(commitTime)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package deebee
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Revision identifies the committed version of a state. It is opaque and can be compared
+// for equality only, for example used as HTTP ETag. Empty Revision does not identify any
+// version.
+type Revision string
+
+func newRevision(version int) Revision {
+	return Revision(strconv.Itoa(version))
+}
+
+type notModifiedError struct{}
+
+func (e *notModifiedError) Error() string {
+	return "not modified"
+}
+
+// IsNotModified returns true when ReaderIfChanged was called with revision of the latest version
+func IsNotModified(err error) bool {
+	var notModified *notModifiedError
+	return errors.As(err, &notModified)
+}
+
+// ReaderWithRevision returns Reader for state with given key together with the revision
+// of the data being read. Data of keys configured using WithWriteBehind is persisted first.
+func (s *DB) ReaderWithRevision(key string) (*Reader, Revision, error) {
+	return s.ReaderIfChanged(key, "")
+}
+
+// ReaderIfChanged returns Reader for state with given key together with the revision of
+// the data, unless the latest revision equals lastRev. Then not modified error is returned
+// (see IsNotModified), so pollers do not have to read and decode the same data again.
+// Data of keys configured using WithWriteBehind is persisted first.
+func (s *DB) ReaderIfChanged(key string, lastRev Revision) (_ *Reader, rev Revision, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkKey(key); err != nil {
+		return nil, "", err
+	}
+	if err = s.checkAccess(ReadOperation, key); err != nil {
+		return nil, "", err
+	}
+	if err = s.flushWriteBehindKey(key); err != nil {
+		return nil, "", err
+	}
+	var (
+		reader  io.ReadCloser
+		version int
+	)
+	err = withTimeout("creating reader", s.operationTimeout, func() (err error) {
+		reader, version, err = s.revisionReader(key, lastRev)
+		return err
+	}, func() {
+		_ = reader.Close()
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return s.decorateReader(key, version, reader), newRevision(version), nil
+}
+
+// revisionReader returns reader of the latest version, unless its revision equals lastRev
+func (s *DB) revisionReader(key string, lastRev Revision) (_ io.ReadCloser, version int, err error) {
+	if err = s.pendingCommits.wait(key, s.operationTimeout); err != nil {
+		return nil, 0, err
+	}
+	defer s.dirCache.invalidateOnError(key, &err)
+	latest, exists, err := s.latestFile(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !exists || latest.kind == tombstoneFile {
+		return nil, 0, &dataNotFoundError{}
+	}
+	if newRevision(latest.version) == lastRev {
+		return nil, 0, &notModifiedError{}
+	}
+	reader, err := s.versionReader(key, s.configFor(key), latest, ReaderOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	return reader, latest.version, nil
+}
@@ -0,0 +1,107 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_ReaderWithRevision(t *testing.T) {
+	t.Run("should return client error for invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, key := range invalidKeys {
+			_, _, err := db.ReaderWithRevision(key)
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+
+	t.Run("should return data not found error when there is no state", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		_, _, err := db.ReaderWithRevision("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return data not found error when state was deleted", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		// when
+		_, _, err := db.ReaderWithRevision("key")
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return data with revision", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		reader, rev, err := db.ReaderWithRevision("key")
+		// then
+		require.NoError(t, err)
+		assert.NotEmpty(t, rev)
+		assert.Equal(t, []byte("data"), readAll(t, reader))
+	})
+
+	t.Run("should return different revision for each version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		reader, oldRev, err := db.ReaderWithRevision("key")
+		require.NoError(t, err)
+		_ = reader.Close()
+		writeData(t, db, "key", []byte("new"))
+		// when
+		reader, newRev, err := db.ReaderWithRevision("key")
+		// then
+		require.NoError(t, err)
+		_ = reader.Close()
+		assert.NotEqual(t, oldRev, newRev)
+	})
+}
+
+func TestDB_ReaderIfChanged(t *testing.T) {
+	t.Run("should return not modified error when revision is the latest one", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		reader, rev, err := db.ReaderWithRevision("key")
+		require.NoError(t, err)
+		_ = reader.Close()
+		// when
+		reader, _, err = db.ReaderIfChanged("key", rev)
+		// then
+		assert.True(t, deebee.IsNotModified(err))
+		assert.Nil(t, reader)
+	})
+
+	t.Run("should return data when state changed", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		reader, oldRev, err := db.ReaderWithRevision("key")
+		require.NoError(t, err)
+		_ = reader.Close()
+		writeData(t, db, "key", []byte("new"))
+		// when
+		reader, newRev, err := db.ReaderIfChanged("key", oldRev)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), readAll(t, reader))
+		assert.NotEqual(t, oldRev, newRev)
+	})
+
+	t.Run("should persist write behind data before comparing revisions", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		writeData(t, db, "key", []byte("old"))
+		reader, oldRev, err := db.ReaderWithRevision("key")
+		require.NoError(t, err)
+		_ = reader.Close()
+		writeData(t, db, "key", []byte("new"))
+		// when
+		reader, _, err = db.ReaderIfChanged("key", oldRev)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), readAll(t, reader))
+	})
+}
@@ -0,0 +1,358 @@
+// Package shardeddb distributes keys across many deebee.DB instances, for example stored
+// on different disks or buckets, using consistent hashing. It allows to store more keys
+// than fit on one volume, while keeping the API of a single DB.
+package shardeddb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jacekolszak/deebee"
+)
+
+// virtualNodes is the number of points each shard has on the hash ring. More points
+// distribute keys more evenly.
+const virtualNodes = 128
+
+// DB routes each key to one of the shards. Adding or removing a shard changes the shard
+// of roughly 1/N keys only - use Rebalance to move them. Until then, keys are still read
+// from the shard they were written to.
+//
+// Shards are not closed by DB.
+type DB struct {
+	shards map[string]*deebee.DB
+	names  []string // sorted
+	ring   []point  // sorted by hash
+}
+
+type point struct {
+	hash  uint64
+	shard string
+}
+
+// New returns DB routing keys to given shards by name. Keys are routed by shard name, not
+// by position, so the same names must be used each time the DB is created.
+func New(shards map[string]*deebee.DB) (*DB, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("no shards")
+	}
+	db := &DB{shards: map[string]*deebee.DB{}}
+	for name, shard := range shards {
+		if shard == nil {
+			return nil, fmt.Errorf("nil shard %s", name)
+		}
+		db.shards[name] = shard
+		db.names = append(db.names, name)
+		for i := 0; i < virtualNodes; i++ {
+			db.ring = append(db.ring, point{hash: hash(name + "#" + strconv.Itoa(i)), shard: name})
+		}
+	}
+	sort.Strings(db.names)
+	sort.Slice(db.ring, func(i, j int) bool {
+		if db.ring[i].hash == db.ring[j].hash {
+			return db.ring[i].shard < db.ring[j].shard
+		}
+		return db.ring[i].hash < db.ring[j].hash
+	})
+	return db, nil
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// Shard returns the name of the shard owning the key
+func (d *DB) Shard(key string) string {
+	h := hash(key)
+	i := sort.Search(len(d.ring), func(i int) bool {
+		return d.ring[i].hash >= h
+	})
+	if i == len(d.ring) {
+		i = 0
+	}
+	return d.ring[i].shard
+}
+
+// Writer writes a new version of the state to the shard owning the key
+func (d *DB) Writer(key string) (*deebee.Writer, error) {
+	return d.shards[d.Shard(key)].Writer(key)
+}
+
+// Reader reads the latest version of the state from the shard owning the key. When the
+// shard does not have the state, other shards are tried, because the state might not
+// have been moved by Rebalance yet.
+func (d *DB) Reader(key string) (*deebee.Reader, error) {
+	names := d.candidates(key)
+	reader, err := d.shards[names[0]].Reader(key)
+	if !deebee.IsDataNotFound(err) {
+		return reader, err
+	}
+	for _, name := range names[1:] {
+		reader, otherErr := d.shards[name].Reader(key)
+		if !deebee.IsDataNotFound(otherErr) {
+			return reader, otherErr
+		}
+	}
+	return nil, err
+}
+
+// Exists returns true when the state can be read from any shard
+func (d *DB) Exists(key string) (bool, error) {
+	for _, name := range d.candidates(key) {
+		exists, err := d.shards[name].Exists(key)
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+// Delete deletes the state in all shards having it. Returns data not found error when no
+// shard has the state.
+func (d *DB) Delete(key string) error {
+	err := d.shards[d.Shard(key)].Delete(key)
+	deleted := err == nil
+	if err != nil && !deebee.IsDataNotFound(err) {
+		return err
+	}
+	for _, name := range d.candidates(key)[1:] {
+		otherErr := d.shards[name].Delete(key)
+		if otherErr == nil {
+			deleted = true
+			continue
+		}
+		if !deebee.IsDataNotFound(otherErr) {
+			return otherErr
+		}
+	}
+	if !deleted {
+		return err
+	}
+	return nil
+}
+
+// candidates returns shard names starting with the owner of the key
+func (d *DB) candidates(key string) []string {
+	owner := d.Shard(key)
+	names := []string{owner}
+	for _, name := range d.names {
+		if name != owner {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ShardErrors contains errors by shard name. It is returned when some shards failed, together
+// with results of the other shards.
+type ShardErrors map[string]error
+
+func (e ShardErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = fmt.Sprintf("shard %s: %s", name, e[name])
+	}
+	return fmt.Sprintf("%d shards failed: %s", len(names), strings.Join(messages, "; "))
+}
+
+// forEachShard runs fn for each shard concurrently and returns ShardErrors when some
+// shards failed
+func (d *DB) forEachShard(fn func(name string, shard *deebee.DB) error) error {
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		failed = ShardErrors{}
+	)
+	for _, name := range d.names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := fn(name, d.shards[name]); err != nil {
+				mutex.Lock()
+				failed[name] = err
+				mutex.Unlock()
+			}
+		}(name)
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// Keys returns sorted keys of states which can be read from any shard. Shards are listed
+// concurrently. When some shards failed, keys of the other shards are returned together
+// with ShardErrors.
+func (d *DB) Keys() ([]string, error) {
+	var (
+		mutex sync.Mutex
+		seen  = map[string]bool{}
+		keys  []string
+	)
+	err := d.forEachShard(func(name string, shard *deebee.DB) error {
+		shardKeys, err := shard.Keys()
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, key := range shardKeys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+// Verify runs deebee.DB.Verify for all shards concurrently and returns errors by key for
+// states which could not be read. When some shards failed, states of the other shards
+// are verified anyway and ShardErrors is returned.
+//
+// progress is called after each key and can be nil. Progress of all shards is summed, so
+// Total grows while shards start.
+func (d *DB) Verify(ctx context.Context, progress deebee.ProgressFunc) (map[string]error, error) {
+	var (
+		mutex     sync.Mutex
+		failed    = map[string]error{}
+		perShard  = map[string]deebee.Progress{}
+		reportAll = func(name string, p deebee.Progress) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			perShard[name] = p
+			sum := deebee.Progress{Key: p.Key}
+			for _, shardProgress := range perShard {
+				sum.Done += shardProgress.Done
+				sum.Total += shardProgress.Total
+				sum.Bytes += shardProgress.Bytes
+			}
+			progress(sum)
+		}
+	)
+	err := d.forEachShard(func(name string, shard *deebee.DB) error {
+		var shardProgress deebee.ProgressFunc
+		if progress != nil {
+			shardProgress = func(p deebee.Progress) {
+				reportAll(name, p)
+			}
+		}
+		shardFailed, err := shard.Verify(ctx, shardProgress)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for key, keyErr := range shardFailed {
+			failed[key] = keyErr
+		}
+		return nil
+	})
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return nil, ctxErr
+	}
+	return failed, err
+}
+
+// Count returns the number of states which can be read from any shard
+func (d *DB) Count() (int, error) {
+	keys, err := d.Keys()
+	return len(keys), err
+}
+
+// Rebalance moves states stored in shards which do not own them to their owners, for
+// example after a shard was added. The latest version is copied, previous versions are
+// not. A state already written to the owner is not copied, because the owner has the
+// newer version. Moved states are deleted from the old shard with deebee.DB.Delete, so
+// they are removed from disk once the old shard is compacted.
+//
+// Rebalance can run concurrently with other operations, but the state written to the old
+// shard by other process in the meantime might be lost. progress is called after each
+// key and can be nil.
+func (d *DB) Rebalance(ctx context.Context, progress deebee.ProgressFunc) error {
+	type move struct {
+		key  string
+		from string
+	}
+	var moves []move
+	for _, name := range d.names {
+		keys, err := d.shards[name].Keys()
+		if err != nil {
+			return fmt.Errorf("shard %s: %w", name, err)
+		}
+		for _, key := range keys {
+			if d.Shard(key) != name {
+				moves = append(moves, move{key: key, from: name})
+			}
+		}
+	}
+	p := deebee.Progress{Total: len(moves)}
+	for _, m := range moves {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		bytes, err := d.move(m.key, m.from)
+		if err != nil {
+			return fmt.Errorf("moving %s from shard %s: %w", m.key, m.from, err)
+		}
+		p.Key = m.key
+		p.Done++
+		p.Bytes += bytes
+		if progress != nil {
+			progress(p)
+		}
+	}
+	return nil
+}
+
+func (d *DB) move(key, from string) (int64, error) {
+	src := d.shards[from]
+	dst := d.shards[d.Shard(key)]
+	exists, err := dst.Exists(key)
+	if err != nil {
+		return 0, err
+	}
+	var bytes int64
+	if !exists {
+		if bytes, err = copyLatest(src, dst, key); err != nil {
+			return bytes, err
+		}
+	}
+	if err = src.Delete(key); err != nil && !deebee.IsDataNotFound(err) {
+		return bytes, err
+	}
+	return bytes, nil
+}
+
+// copyLatest reads the whole state first, so nothing is written to dst when reading fails
+func copyLatest(src, dst *deebee.DB, key string) (int64, error) {
+	reader, err := src.Reader(key)
+	if err != nil {
+		return 0, err
+	}
+	data, err := ioutil.ReadAll(reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), dst.PutMulti(map[string][]byte{key: data})[key]
+}
@@ -0,0 +1,351 @@
+package shardeddb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/jacekolszak/deebee/shardeddb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("should return error for no shards", func(t *testing.T) {
+		db, err := shardeddb.New(nil)
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("should return error for nil shard", func(t *testing.T) {
+		db, err := shardeddb.New(map[string]*deebee.DB{"a": nil})
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+}
+
+func TestDB_Shard(t *testing.T) {
+	t.Run("should route keys to all shards", func(t *testing.T) {
+		db := newShardedDB(t, openShards(t, "a", "b", "c"))
+		counts := map[string]int{}
+		// when
+		for i := 0; i < 1000; i++ {
+			counts[db.Shard(fmt.Sprintf("key%d", i))]++
+		}
+		// then
+		require.Len(t, counts, 3)
+		for shard, count := range counts {
+			assert.Greater(t, count, 150, shard)
+		}
+	})
+
+	t.Run("should move only part of keys when shard is added", func(t *testing.T) {
+		shards := openShards(t, "a", "b", "c")
+		before := newShardedDB(t, shards)
+		shards["d"] = openShard(t)
+		after := newShardedDB(t, shards)
+		moved := 0
+		// when
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key%d", i)
+			if before.Shard(key) != after.Shard(key) {
+				assert.Equal(t, "d", after.Shard(key))
+				moved++
+			}
+		}
+		// then
+		assert.Greater(t, moved, 150)
+		assert.Less(t, moved, 350)
+	})
+}
+
+func TestDB_Writer(t *testing.T) {
+	t.Run("should write to the shard owning the key", func(t *testing.T) {
+		shards := openShards(t, "a", "b")
+		db := newShardedDB(t, shards)
+		// when
+		write(t, db, "key", "data")
+		// then
+		exists, err := shards[db.Shard("key")].Exists("key")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, "data", read(t, db, "key"))
+	})
+}
+
+func TestDB_Reader(t *testing.T) {
+	t.Run("should return data not found", func(t *testing.T) {
+		db := newShardedDB(t, openShards(t, "a", "b"))
+		// when
+		_, err := db.Reader("key")
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should read state not moved yet", func(t *testing.T) {
+		shards := openShards(t, "a")
+		writeShard(t, shards["a"], "key", "data")
+		shards["b"] = openShard(t)
+		db := newShardedDB(t, shards)
+		// expect
+		assert.Equal(t, "data", read(t, db, "key"))
+	})
+}
+
+func TestDB_Delete(t *testing.T) {
+	t.Run("should return data not found", func(t *testing.T) {
+		db := newShardedDB(t, openShards(t, "a", "b"))
+		// when
+		err := db.Delete("key")
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should delete state in all shards", func(t *testing.T) {
+		shards := openShards(t, "a", "b")
+		writeShard(t, shards["a"], "key", "data")
+		writeShard(t, shards["b"], "key", "data")
+		db := newShardedDB(t, shards)
+		// when
+		err := db.Delete("key")
+		// then
+		require.NoError(t, err)
+		exists, err := db.Exists("key")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestDB_Keys(t *testing.T) {
+	t.Run("should return sorted keys of all shards without duplicates", func(t *testing.T) {
+		shards := openShards(t, "a", "b")
+		writeShard(t, shards["a"], "z", "data")
+		writeShard(t, shards["a"], "x", "data")
+		writeShard(t, shards["b"], "y", "data")
+		writeShard(t, shards["b"], "x", "data")
+		db := newShardedDB(t, shards)
+		// when
+		keys, err := db.Keys()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"x", "y", "z"}, keys)
+		count, err := db.Count()
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("should return keys of other shards when some shard failed", func(t *testing.T) {
+		shards := openShards(t, "a")
+		writeShard(t, shards["a"], "key", "data")
+		shards["b"] = openDB(t, failing.ListDirs(fake.ExistingDir()))
+		db := newShardedDB(t, shards)
+		// when
+		keys, err := db.Keys()
+		// then
+		assert.Equal(t, []string{"key"}, keys)
+		var shardErrors shardeddb.ShardErrors
+		require.True(t, errors.As(err, &shardErrors))
+		assert.Len(t, shardErrors, 1)
+		assert.Error(t, shardErrors["b"])
+	})
+}
+
+func TestDB_Verify(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should return errors of keys which could not be read from all shards", func(t *testing.T) {
+		dirA := fake.ExistingDir()
+		dirB := fake.ExistingDir()
+		shards := map[string]*deebee.DB{
+			"a": openDB(t, dirA, deebee.WithChecksum()),
+			"b": openDB(t, dirB, deebee.WithChecksum()),
+		}
+		writeShard(t, shards["a"], "a", "data")
+		writeShard(t, shards["a"], "intact", "data")
+		writeShard(t, shards["b"], "b", "data")
+		corrupt(t, dirA, "a")
+		corrupt(t, dirB, "b")
+		db := newShardedDB(t, shards)
+		// when
+		failed, err := db.Verify(ctx, nil)
+		// then
+		require.NoError(t, err)
+		assert.Len(t, failed, 2)
+		assert.True(t, deebee.IsCorrupted(failed["a"]))
+		assert.True(t, deebee.IsCorrupted(failed["b"]))
+	})
+
+	t.Run("should report summed progress", func(t *testing.T) {
+		shards := openShards(t, "a", "b")
+		writeShard(t, shards["a"], "a", "12")
+		writeShard(t, shards["b"], "b", "345")
+		db := newShardedDB(t, shards)
+		var last deebee.Progress
+		// when
+		_, err := db.Verify(ctx, func(p deebee.Progress) {
+			last = p
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 2, last.Done)
+		assert.Equal(t, 2, last.Total)
+		assert.Equal(t, int64(5), last.Bytes)
+	})
+
+	t.Run("should verify other shards when some shard failed", func(t *testing.T) {
+		shards := openShards(t, "a")
+		writeShard(t, shards["a"], "key", "data")
+		shards["b"] = openDB(t, failing.ListDirs(fake.ExistingDir()))
+		db := newShardedDB(t, shards)
+		// when
+		failed, err := db.Verify(ctx, nil)
+		// then
+		assert.Empty(t, failed)
+		var shardErrors shardeddb.ShardErrors
+		require.True(t, errors.As(err, &shardErrors))
+		assert.Contains(t, shardErrors, "b")
+	})
+
+	t.Run("should return error when context is done", func(t *testing.T) {
+		shards := openShards(t, "a", "b")
+		writeShard(t, shards["a"], "key", "data")
+		db := newShardedDB(t, shards)
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		// when
+		_, err := db.Verify(canceled, nil)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestDB_Rebalance(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should move states to shards owning them", func(t *testing.T) {
+		shards := openShards(t, "a")
+		for i := 0; i < 20; i++ {
+			writeShard(t, shards["a"], fmt.Sprintf("key%d", i), fmt.Sprintf("data%d", i))
+		}
+		shards["b"] = openShard(t)
+		db := newShardedDB(t, shards)
+		var reported []deebee.Progress
+		// when
+		err := db.Rebalance(ctx, func(p deebee.Progress) {
+			reported = append(reported, p)
+		})
+		// then
+		require.NoError(t, err)
+		require.NotEmpty(t, reported)
+		for name, shard := range shards {
+			keys, err := shard.Keys()
+			require.NoError(t, err)
+			for _, key := range keys {
+				assert.Equal(t, name, db.Shard(key), key)
+			}
+		}
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, fmt.Sprintf("data%d", i), read(t, db, fmt.Sprintf("key%d", i)))
+		}
+	})
+
+	t.Run("should not overwrite newer state written to the owner", func(t *testing.T) {
+		shards := openShards(t, "a", "b")
+		db := newShardedDB(t, shards)
+		key := keyOwnedBy(t, db, "b")
+		writeShard(t, shards["a"], key, "old")
+		write(t, db, key, "new")
+		// when
+		err := db.Rebalance(ctx, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "new", read(t, db, key))
+		exists, err := shards["a"].Exists(key)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("should stop when context is canceled", func(t *testing.T) {
+		shards := openShards(t, "a", "b")
+		db := newShardedDB(t, shards)
+		writeShard(t, shards["a"], keyOwnedBy(t, db, "b"), "data")
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		// when
+		err := db.Rebalance(canceled, nil)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func openShard(t *testing.T) *deebee.DB {
+	return openDB(t, fake.ExistingDir())
+}
+
+func openDB(t *testing.T, dir deebee.Dir, options ...deebee.Option) *deebee.DB {
+	db, err := deebee.Open(dir, options...)
+	require.NoError(t, err)
+	return db
+}
+
+func corrupt(t *testing.T, dir fake.Dir, key string) {
+	stateDir := dir.Dir(key).(fake.Dir)
+	files := stateDir.Files()
+	require.Len(t, files, 1)
+	require.NoError(t, stateDir.Corrupt(files[0].Name()))
+}
+
+func openShards(t *testing.T, names ...string) map[string]*deebee.DB {
+	shards := map[string]*deebee.DB{}
+	for _, name := range names {
+		shards[name] = openShard(t)
+	}
+	return shards
+}
+
+func newShardedDB(t *testing.T, shards map[string]*deebee.DB) *shardeddb.DB {
+	db, err := shardeddb.New(shards)
+	require.NoError(t, err)
+	return db
+}
+
+func keyOwnedBy(t *testing.T, db *shardeddb.DB, shard string) string {
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if db.Shard(key) == shard {
+			return key
+		}
+	}
+	require.FailNow(t, "no key owned by shard", shard)
+	return ""
+}
+
+func writeShard(t *testing.T, db *deebee.DB, key, data string) {
+	writer, err := db.Writer(key)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+}
+
+func write(t *testing.T, db *shardeddb.DB, key, data string) {
+	writer, err := db.Writer(key)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+}
+
+func read(t *testing.T, db *shardeddb.DB, key string) string {
+	reader, err := db.Reader(key)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
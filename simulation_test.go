@@ -0,0 +1,165 @@
+package deebee_test
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	simulationSeed  = flag.Int64("simulation.seed", 0, "seed of TestSimulation. When 0, a set of predefined seeds is used")
+	simulationSteps = flag.Int("simulation.steps", 500, "number of steps executed by TestSimulation for each seed")
+)
+
+// TestSimulation drives the DB with randomized, but deterministic, interleavings
+// of writes, reads and crashes. Failing run can be reproduced using:
+//
+//	go test -run TestSimulation -simulation.seed=<seed>
+func TestSimulation(t *testing.T) {
+	seeds := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if *simulationSeed != 0 {
+		seeds = []int64{*simulationSeed}
+	}
+	for _, seed := range seeds {
+		seed := seed
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			newSimulation(t, seed).run(*simulationSteps)
+		})
+	}
+}
+
+var simulationKeys = []string{"a", "b", "c"}
+
+type simulation struct {
+	t       *testing.T
+	random  *rand.Rand
+	dir     deebee.Dir
+	db      *deebee.DB
+	pending []*pendingWrite
+	// committed contains data of committed write with the youngest version for each key
+	committed map[string]*pendingWrite
+	// sequence orders writes in the same way as DB orders versions
+	sequence int
+}
+
+type pendingWrite struct {
+	key      string
+	writer   io.WriteCloser
+	data     []byte
+	sequence int
+}
+
+func newSimulation(t *testing.T, seed int64) *simulation {
+	dir := fake.ExistingDir()
+	return &simulation{
+		t:         t,
+		random:    rand.New(rand.NewSource(seed)),
+		dir:       dir,
+		db:        openDB(t, dir),
+		committed: map[string]*pendingWrite{},
+	}
+}
+
+func (s *simulation) run(steps int) {
+	actions := []func(){
+		s.openWriter,
+		s.write,
+		s.write,
+		s.closeWriter,
+		s.read,
+		s.read,
+		s.crash,
+	}
+	for i := 0; i < steps; i++ {
+		actions[s.random.Intn(len(actions))]()
+		s.assertInvariants()
+		if s.t.Failed() {
+			s.t.Fatalf("invariant violated at step %d", i)
+		}
+	}
+}
+
+func (s *simulation) openWriter() {
+	key := s.randomKey()
+	writer, err := s.db.Writer(key)
+	require.NoError(s.t, err)
+	s.pending = append(s.pending, &pendingWrite{
+		key:      key,
+		writer:   writer,
+		data:     []byte{},
+		sequence: s.sequence,
+	})
+	s.sequence++
+}
+
+func (s *simulation) write() {
+	if len(s.pending) == 0 {
+		return
+	}
+	w := s.pending[s.random.Intn(len(s.pending))]
+	data := make([]byte, s.random.Intn(64))
+	s.random.Read(data)
+	_, err := w.writer.Write(data)
+	require.NoError(s.t, err)
+	w.data = append(w.data, data...)
+}
+
+func (s *simulation) closeWriter() {
+	if len(s.pending) == 0 {
+		return
+	}
+	i := s.random.Intn(len(s.pending))
+	w := s.pending[i]
+	s.pending = append(s.pending[:i], s.pending[i+1:]...)
+	err := w.writer.Close()
+	require.NoError(s.t, err)
+	latest, exists := s.committed[w.key]
+	if !exists || w.sequence > latest.sequence {
+		s.committed[w.key] = w
+	}
+}
+
+func (s *simulation) read() {
+	s.assertLatestReadable(s.randomKey())
+}
+
+// crash simulates application crash. Writers which were not closed are abandoned
+// and the DB is opened again using the same dir.
+func (s *simulation) crash() {
+	s.pending = nil
+	s.db = openDB(s.t, s.dir)
+}
+
+func (s *simulation) assertInvariants() {
+	for _, key := range simulationKeys {
+		s.assertLatestReadable(key)
+	}
+}
+
+// assertLatestReadable checks that data read is always a fully committed version
+// and no younger committed version exists
+func (s *simulation) assertLatestReadable(key string) {
+	reader, err := s.db.Reader(key)
+	latest, committed := s.committed[key]
+	if !committed {
+		assert.True(s.t, deebee.IsDataNotFound(err), "key %s: data not found expected, got %v", key, err)
+		return
+	}
+	require.NoError(s.t, err)
+	defer reader.Close()
+	actual, err := ioutil.ReadAll(reader)
+	require.NoError(s.t, err)
+	assert.Equal(s.t, latest.data, actual, "key %s", key)
+}
+
+func (s *simulation) randomKey() string {
+	return simulationKeys[s.random.Intn(len(simulationKeys))]
+}
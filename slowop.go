@@ -0,0 +1,156 @@
+package deebee
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WithSlowOpThreshold emits SlowOperation event whenever reading, writing or syncing the
+// file storing data takes longer than threshold. Reading and writing is measured for the
+// whole file, summing the time spent in all Read or Write calls, so stalls spread over many
+// chunks are reported too. Time spent by the application between calls is not included.
+// Useful for diagnosing intermittent stalls of network filesystems, such as NFS, or of
+// overloaded disks.
+func WithSlowOpThreshold(threshold time.Duration) Option {
+	return func(db *DB) error {
+		if threshold <= 0 {
+			return errors.New("slow operation threshold must be positive")
+		}
+		db.slowOps = &slowOps{threshold: threshold, emit: db.emit}
+		return nil
+	}
+}
+
+// SlowOp is a kind of file operation reported in SlowOperation events
+type SlowOp int
+
+const (
+	// ReadOp is reading the file, reported when the reader is closed
+	ReadOp SlowOp = iota + 1
+	// WriteOp is writing the file, reported when the file is synced or closed
+	WriteOp
+	// SyncOp is a single sync of the file
+	SyncOp
+)
+
+func (o SlowOp) String() string {
+	switch o {
+	case ReadOp:
+		return "read"
+	case WriteOp:
+		return "write"
+	case SyncOp:
+		return "sync"
+	default:
+		return fmt.Sprintf("SlowOp(%d)", int(o))
+	}
+}
+
+type slowOps struct {
+	threshold time.Duration
+	emit      func(Event)
+}
+
+// report emits the event when the operation was slow
+func (s *slowOps) report(op SlowOp, key string, elapsed time.Duration, bytes int64) {
+	if elapsed > s.threshold {
+		s.emit(Event{Type: SlowOperation, Key: key, SlowOp: op, Duration: elapsed, Bytes: bytes})
+	}
+}
+
+// reader returns r measuring all Read calls until Close. Seek is still supported when r
+// supports it.
+func (s *slowOps) reader(key string, r io.ReadCloser) io.ReadCloser {
+	if s == nil {
+		return r
+	}
+	reader := &slowReader{ReadCloser: r, ops: s, key: key}
+	if seeker, ok := r.(io.Seeker); ok {
+		return &slowReadSeeker{slowReader: reader, seeker: seeker}
+	}
+	return reader
+}
+
+// writer returns w measuring all Write calls until Sync or Close, and each Sync
+func (s *slowOps) writer(key string, w FileWriter) FileWriter {
+	if s == nil {
+		return w
+	}
+	return &slowWriter{FileWriter: w, ops: s, key: key}
+}
+
+// measured sums the time and bytes of calls made so far
+type measured struct {
+	elapsed time.Duration
+	bytes   int64
+}
+
+func (m *measured) measure(fn func() (int, error)) (int, error) {
+	started := time.Now()
+	n, err := fn()
+	m.elapsed += time.Since(started)
+	m.bytes += int64(n)
+	return n, err
+}
+
+type slowReader struct {
+	io.ReadCloser
+	ops      *slowOps
+	key      string
+	measured measured
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	return r.measured.measure(func() (int, error) {
+		return r.ReadCloser.Read(p)
+	})
+}
+
+func (r *slowReader) Close() error {
+	r.ops.report(ReadOp, r.key, r.measured.elapsed, r.measured.bytes)
+	r.measured = measured{}
+	return r.ReadCloser.Close()
+}
+
+type slowReadSeeker struct {
+	*slowReader
+	seeker io.Seeker
+}
+
+func (r *slowReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.seeker.Seek(offset, whence)
+}
+
+type slowWriter struct {
+	FileWriter
+	ops      *slowOps
+	key      string
+	measured measured
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	return w.measured.measure(func() (int, error) {
+		return w.FileWriter.Write(p)
+	})
+}
+
+// reportWrites reports Write calls made since the previous report
+func (w *slowWriter) reportWrites() {
+	w.ops.report(WriteOp, w.key, w.measured.elapsed, w.measured.bytes)
+	w.measured = measured{}
+}
+
+func (w *slowWriter) Sync() error {
+	w.reportWrites()
+	started := time.Now()
+	err := w.FileWriter.Sync()
+	w.ops.report(SyncOp, w.key, time.Since(started), 0)
+	return err
+}
+
+func (w *slowWriter) Close() error {
+	w.reportWrites()
+	return w.FileWriter.Close()
+}
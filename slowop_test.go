@@ -0,0 +1,194 @@
+package deebee_test
+
+import (
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSlowOpThreshold(t *testing.T) {
+	const delay = 20 * time.Millisecond
+
+	t.Run("should return error for not positive threshold", func(t *testing.T) {
+		for _, threshold := range []time.Duration{0, -1} {
+			_, err := deebee.Open(fake.ExistingDir(), deebee.WithSlowOpThreshold(threshold))
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("should emit events for slow operations", func(t *testing.T) {
+		events := &slowOpEvents{}
+		db := openDB(t, slowDir{next: fake.ExistingDir(), delay: delay},
+			deebee.WithSlowOpThreshold(delay/2),
+			deebee.WithEventHandler(events.handle),
+		)
+		// when
+		writeData(t, db, "key", []byte("data"))
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		// then
+		ops := map[deebee.SlowOp]deebee.Event{}
+		for _, event := range events.get() {
+			if _, ok := ops[event.SlowOp]; !ok {
+				ops[event.SlowOp] = event
+			}
+		}
+		require.Contains(t, ops, deebee.WriteOp)
+		require.Contains(t, ops, deebee.SyncOp)
+		require.Contains(t, ops, deebee.ReadOp)
+		assert.Equal(t, "key", ops[deebee.WriteOp].Key)
+		assert.Equal(t, int64(4), ops[deebee.WriteOp].Bytes)
+		assert.GreaterOrEqual(t, int64(ops[deebee.SyncOp].Duration), int64(delay))
+		assert.Equal(t, int64(4), ops[deebee.ReadOp].Bytes)
+	})
+
+	t.Run("should measure reads and writes of the whole file", func(t *testing.T) {
+		events := &slowOpEvents{}
+		db := openDB(t, slowDir{next: fake.ExistingDir(), delay: delay},
+			deebee.WithSlowOpThreshold(3*delay/2),
+			deebee.WithEventHandler(events.handle),
+		)
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		for _, b := range []byte("data") {
+			_, err = writer.Write([]byte{b})
+			require.NoError(t, err)
+		}
+		require.NoError(t, writer.Close())
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		// when
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		// then
+		assert.Equal(t, []byte("data"), data)
+		ops := map[deebee.SlowOp][]deebee.Event{}
+		for _, event := range events.get() {
+			ops[event.SlowOp] = append(ops[event.SlowOp], event)
+		}
+		require.Len(t, ops[deebee.WriteOp], 1)
+		assert.Equal(t, int64(4), ops[deebee.WriteOp][0].Bytes)
+		require.Len(t, ops[deebee.ReadOp], 1)
+		assert.Equal(t, int64(4), ops[deebee.ReadOp][0].Bytes)
+		assert.GreaterOrEqual(t, int64(ops[deebee.ReadOp][0].Duration), int64(2*delay))
+	})
+
+	t.Run("should not emit events for fast operations", func(t *testing.T) {
+		events := &slowOpEvents{}
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithSlowOpThreshold(time.Minute),
+			deebee.WithEventHandler(events.handle),
+		)
+		// when
+		writeData(t, db, "key", []byte("data"))
+		readData(t, db, "key")
+		// then
+		assert.Empty(t, events.get())
+	})
+
+	t.Run("should keep reader seekable", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t), deebee.WithSlowOpThreshold(time.Minute))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		data, err := db.ReadRange("key", 2, 2)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("ta"), data)
+	})
+}
+
+type slowOpEvents struct {
+	mutex  sync.Mutex
+	events []deebee.Event
+}
+
+func (e *slowOpEvents) handle(event deebee.Event) {
+	if event.Type != deebee.SlowOperation {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *slowOpEvents) get() []deebee.Event {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]deebee.Event{}, e.events...)
+}
+
+// slowDir delays each read, write and sync of files
+type slowDir struct {
+	next  deebee.Dir
+	delay time.Duration
+}
+
+func (d slowDir) Dir(name string) deebee.Dir {
+	return slowDir{next: d.next.Dir(name), delay: d.delay}
+}
+
+func (d slowDir) Mkdir() error                         { return d.next.Mkdir() }
+func (d slowDir) Exists() (bool, error)                { return d.next.Exists() }
+func (d slowDir) ListFiles() ([]string, error)         { return d.next.ListFiles() }
+func (d slowDir) ListDirs() ([]string, error)          { return d.next.ListDirs() }
+func (d slowDir) Rename(oldName, newName string) error { return d.next.Rename(oldName, newName) }
+func (d slowDir) DeleteFile(name string) error         { return d.next.DeleteFile(name) }
+
+func (d slowDir) FileReader(name string) (io.ReadCloser, error) {
+	reader, err := d.next.FileReader(name)
+	if err != nil {
+		return nil, err
+	}
+	return slowFile{ReadCloser: reader, delay: d.delay}, nil
+}
+
+func (d slowDir) FileWriter(name string) (deebee.FileWriter, error) {
+	writer, err := d.next.FileWriter(name)
+	if err != nil {
+		return nil, err
+	}
+	return slowFileWriter{FileWriter: writer, delay: d.delay}, nil
+}
+
+type slowFile struct {
+	io.ReadCloser
+	delay time.Duration
+}
+
+func (f slowFile) Read(p []byte) (int, error) {
+	time.Sleep(f.delay)
+	return f.ReadCloser.Read(p)
+}
+
+type slowFileWriter struct {
+	deebee.FileWriter
+	delay time.Duration
+}
+
+func (f slowFileWriter) Write(p []byte) (int, error) {
+	time.Sleep(f.delay)
+	return f.FileWriter.Write(p)
+}
+
+func (f slowFileWriter) Sync() error {
+	time.Sleep(f.delay)
+	return f.FileWriter.Sync()
+}
+
+func TestSlowOp_String(t *testing.T) {
+	assert.Equal(t, "read", deebee.ReadOp.String())
+	assert.Equal(t, "write", deebee.WriteOp.String())
+	assert.Equal(t, "sync", deebee.SyncOp.String())
+	assert.Equal(t, "SlowOp(10)", deebee.SlowOp(10).String())
+}
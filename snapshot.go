@@ -0,0 +1,48 @@
+package deebee
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// ExportSnapshotDir creates a copy of the latest versions of all states in a new
+// directory at path, without stopping writers. Resulting directory can be opened as DB
+// or copied with external tools such as rsync. Works only when DB uses OsDir or
+// Dir created using NewOsDir.
+//
+// Committed versions are never modified, therefore files are hard linked when possible
+// and copied otherwise (for example when path is on a different filesystem). Each state
+// is consistent, but states are exported one after another.
+func (s *DB) ExportSnapshotDir(path string) (err error) {
+	defer s.redactError(&err)
+	root, ok := asOsDir(s.dir)
+	if !ok {
+		return newClientError("snapshot dir can be exported only for OsDir")
+	}
+	snapshotDir := root.at(path)
+	if err := snapshotDir.create(); err != nil {
+		return err
+	}
+	snapshot := &DB{dir: snapshotDir, sharded: s.sharded, hierarchicalKeys: s.hierarchicalKeys, keyMapper: s.keyMapper}
+	if err := snapshot.checkLayout(); err != nil {
+		return err
+	}
+	return s.forEachKey(context.Background(), nil, func(key string) (int64, error) {
+		stateDir := s.stateDir(key)
+		youngest, exists, err := s.latestFile(key)
+		if err != nil || !exists || youngest.kind == tombstoneFile {
+			return 0, err
+		}
+		snapshotStateDir := snapshot.stateDir(key)
+		if err = snapshot.mkdirState(key, snapshotStateDir); err != nil {
+			return 0, err
+		}
+		source := filepath.Join(statePathIn(string(root.OsDir), s.physicalKey(key), s.sharded), youngest.name)
+		target := filepath.Join(statePathIn(path, s.physicalKey(key), s.sharded), youngest.name)
+		if err = os.Link(source, target); err == nil {
+			return 0, nil
+		}
+		return copyFile(stateDir, snapshotStateDir, youngest.name)
+	})
+}
@@ -0,0 +1,60 @@
+package deebee_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_ExportSnapshotDir(t *testing.T) {
+	t.Run("should return client error when DB does not use OsDir", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		err := db.ExportSnapshotDir(filepath.Join(createTempDir(t), "snapshot"))
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should return error when path already exists", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t))
+		err := db.ExportSnapshotDir(createTempDir(t))
+		assert.Error(t, err)
+	})
+
+	t.Run("should export latest versions", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t))
+		writeData(t, db, "a", []byte("old"))
+		writeData(t, db, "a", []byte("new"))
+		writeData(t, db, "b", []byte("data"))
+		writeData(t, db, "deleted", []byte("data"))
+		require.NoError(t, db.Delete("deleted"))
+		path := filepath.Join(createTempDir(t), "snapshot")
+		// when
+		err := db.ExportSnapshotDir(path)
+		// then
+		require.NoError(t, err)
+		snapshot := openDB(t, deebee.OsDir(path))
+		assert.Equal(t, []byte("new"), readData(t, snapshot, "a"))
+		assert.Equal(t, []byte("data"), readData(t, snapshot, "b"))
+		_, err = snapshot.Reader("deleted")
+		assert.True(t, deebee.IsDataNotFound(err))
+		files, err := deebee.OsDir(path).Dir("a").ListFiles()
+		require.NoError(t, err)
+		assert.Len(t, files, 1)
+	})
+
+	t.Run("snapshot should not change after update", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t))
+		writeData(t, db, "key", []byte("snapshot"))
+		path := filepath.Join(createTempDir(t), "snapshot")
+		require.NoError(t, db.ExportSnapshotDir(path))
+		// when
+		writeData(t, db, "key", []byte("updated"))
+		require.NoError(t, db.Compact(context.Background(), nil))
+		// then
+		assert.Equal(t, []byte("snapshot"), readData(t, openDB(t, deebee.OsDir(path)), "key"))
+	})
+}
@@ -0,0 +1,90 @@
+package deebee
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Stats contains counters aggregated since the DB was opened
+type Stats struct {
+	// Writes is the number of committed versions
+	Writes int64
+	// Reads is the number of readers opened
+	Reads int64
+	// BytesWritten is the number of bytes written to writers, before passing them through filters
+	BytesWritten int64
+	// BytesRead is the number of bytes read from readers, after passing them through filters
+	BytesRead int64
+	// CorruptionEvents is the number of states which failed Verify
+	CorruptionEvents int64
+	// Compactions is the number of finished Compact runs
+	Compactions int64
+	// CacheHits is the number of lookups served by WithPreload index or WithDirExistenceCache
+	CacheHits int64
+}
+
+// Stats returns counters aggregated since Open. It is cheap, so it can be called periodically
+// to log a summary.
+func (s *DB) Stats() Stats {
+	c := s.stats
+	if c == nil {
+		return Stats{}
+	}
+	return Stats{
+		Writes:           c.get(statWrites),
+		Reads:            c.get(statReads),
+		BytesWritten:     c.get(statBytesWritten),
+		BytesRead:        c.get(statBytesRead),
+		CorruptionEvents: c.get(statCorruptionEvents),
+		Compactions:      c.get(statCompactions),
+		CacheHits:        c.get(statCacheHits),
+	}
+}
+
+// statsCounter identifies the counter kept by statsCounters
+type statsCounter int
+
+const (
+	statWrites statsCounter = iota
+	statReads
+	statBytesWritten
+	statBytesRead
+	statCorruptionEvents
+	statCompactions
+	statCacheHits
+	// statsCounterCount is the number of counters
+	statsCounterCount
+)
+
+// statsCounters are updated atomically. Nil counters are not updated.
+type statsCounters struct {
+	values [statsCounterCount]int64
+}
+
+func (c *statsCounters) add(counter statsCounter, delta int64) {
+	if c != nil && delta != 0 {
+		atomic.AddInt64(&c.values[counter], delta)
+	}
+}
+
+func (c *statsCounters) get(counter statsCounter) int64 {
+	return atomic.LoadInt64(&c.values[counter])
+}
+
+// countingReader counts bytes read
+type countingReader struct {
+	io.ReadCloser
+	stats *statsCounters
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.stats.add(statBytesRead, int64(n))
+	return n, err
+}
+
+func (r *countingReader) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.Copy(w, r.ReadCloser)
+	r.stats.add(statBytesRead, n)
+	return n, err
+}
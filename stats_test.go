@@ -0,0 +1,55 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Stats(t *testing.T) {
+	t.Run("should return zero stats for new DB", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		assert.Equal(t, deebee.Stats{}, db.Stats())
+	})
+
+	t.Run("should count writes and reads", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		readData(t, db, "key")
+		// then
+		stats := db.Stats()
+		assert.Equal(t, int64(2), stats.Writes)
+		assert.Equal(t, int64(7), stats.BytesWritten)
+		assert.Equal(t, int64(1), stats.Reads)
+		assert.Equal(t, int64(4), stats.BytesRead)
+	})
+
+	t.Run("should count compactions and corruption events", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, dir, deebee.WithFilter(failingReadFilter{}))
+		// when
+		require.NoError(t, db.Compact(context.Background(), nil))
+		_, err := db.Verify(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		stats := db.Stats()
+		assert.Equal(t, int64(1), stats.Compactions)
+		assert.Equal(t, int64(1), stats.CorruptionEvents)
+	})
+
+	t.Run("should count cache hits", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithDirExistenceCache())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		readData(t, db, "key")
+		// then
+		assert.Equal(t, int64(1), db.Stats().CacheHits)
+	})
+}
@@ -0,0 +1,169 @@
+package deebee
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+)
+
+// Writer writes a new version of the state. Data is committed by Close. Accessors are
+// useful for logging, once the writer was closed.
+type Writer struct {
+	next io.WriteCloser
+	key  string
+	// hash is nil until EnableChecksum was called
+	hash hash.Hash
+	// committed is nil for data kept in memory by WithWriteBehind
+	committed *writer
+	written   int64
+	version   int
+	checksum  []byte
+	onCommit  []func(Version)
+}
+
+// wrapWriter returns Writer writing to next. committed is the writer which commits the
+// version, nil when data is kept in memory.
+func (s *DB) wrapWriter(key string, next io.WriteCloser, committed *writer) *Writer {
+	if s.errorRedaction {
+		next = &redactingWriter{WriteCloser: next, db: s, key: key}
+	}
+	return &Writer{
+		next:      next,
+		key:       key,
+		committed: committed,
+		version:   -1,
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.next.Write(p)
+	w.written += int64(n)
+	if w.hash != nil {
+		_, _ = w.hash.Write(p[:n])
+	}
+	return n, err
+}
+
+// EnableChecksum makes the writer calculate SHA-256 of written data, returned by Checksum
+// after Close. It is disabled by default, because hashing costs CPU time. Returns client
+// error when data was already written.
+func (w *Writer) EnableChecksum() error {
+	if w.written > 0 {
+		return newClientError("checksum must be enabled before data is written")
+	}
+	if w.hash == nil {
+		w.hash = sha256.New()
+	}
+	return nil
+}
+
+// Close commits the version
+func (w *Writer) Close() error {
+	if err := w.next.Close(); err != nil {
+		return err
+	}
+	if w.hash != nil {
+		w.checksum = w.hash.Sum(nil)
+	}
+	if w.committed == nil {
+		return nil
+	}
+	w.version = w.committed.name.version
+	for _, fn := range w.onCommit {
+		fn(Version{Version: w.version})
+	}
+	return nil
+}
+
+// OnCommit registers fn called by Close after the version was durably committed, for
+// example to update in-memory cache. Functions are called in the order of registration,
+// before Close returns. They are not called when Close failed, or when data is kept in
+// memory because of WithWriteBehind - use Flush to persist such data. Writer returned by
+// WriterAsync calls them in the background once the commit finished, therefore they must
+// be registered before Close.
+func (w *Writer) OnCommit(fn func(Version)) {
+	if fn != nil {
+		w.onCommit = append(w.onCommit, fn)
+	}
+}
+
+// Key returns the key of the state
+func (w *Writer) Key() string {
+	return w.key
+}
+
+// Version returns the committed version. Returns -1 before Close succeeded, when data
+// is kept in memory because of WithWriteBehind, or when Writer was returned by WriterAsync.
+func (w *Writer) Version() int {
+	return w.version
+}
+
+// BytesWritten returns the number of bytes written, before passing them through filters
+func (w *Writer) BytesWritten() int64 {
+	return w.written
+}
+
+// Checksum returns SHA-256 of the written data, before passing it through filters. Returns
+// nil before Close succeeded, or when EnableChecksum was not called.
+func (w *Writer) Checksum() []byte {
+	return w.checksum
+}
+
+// Reader reads the data version of the state. Accessors are useful for logging.
+type Reader struct {
+	next io.ReadCloser
+	// seeker is nil when data can't be seeked
+	seeker  io.Seeker
+	key     string
+	version int
+	read    int64
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.next.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+// WriteTo writes data to w. When data is read as stored, without filters, checksum
+// verification and read transformer, the file returned by Dir is copied directly, so
+// io.Copy from *os.File into a socket or another file avoids intermediate buffers.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.Copy(w, r.next)
+	r.read += n
+	return n, err
+}
+
+func (r *Reader) Close() error {
+	return r.next.Close()
+}
+
+// Seek is supported only when Dir returns seekable readers and data is read as stored,
+// without filters and checksum verification. Returns error otherwise.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	if r.seeker == nil {
+		return 0, errors.New("seek not supported")
+	}
+	return r.seeker.Seek(offset, whence)
+}
+
+func (r *Reader) seekable() bool {
+	return r.seeker != nil
+}
+
+// Key returns the key of the state
+func (r *Reader) Key() string {
+	return r.key
+}
+
+// Version returns the data version being read. Returns -1 for data kept in memory because
+// of WithWriteBehind.
+func (r *Reader) Version() int {
+	return r.version
+}
+
+// BytesRead returns the number of bytes read so far, after passing them through filters
+func (r *Reader) BytesRead() int64 {
+	return r.read
+}
@@ -0,0 +1,251 @@
+package deebee_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	t.Run("should describe the writer before Close", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		// when
+		_, err = writer.Write([]byte("data"))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "key", writer.Key())
+		assert.Equal(t, -1, writer.Version())
+		assert.Equal(t, int64(4), writer.BytesWritten())
+		assert.Nil(t, writer.Checksum())
+	})
+
+	t.Run("should describe committed version after Close", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(deebee.GzipFilter()))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		require.NoError(t, writer.EnableChecksum())
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, latestVersion(t, db, "key"), writer.Version())
+		assert.Equal(t, int64(4), writer.BytesWritten())
+		checksum := sha256.Sum256([]byte("data"))
+		assert.Equal(t, checksum[:], writer.Checksum())
+	})
+
+	t.Run("should not return version of data kept in memory", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		require.NoError(t, writer.EnableChecksum())
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, -1, writer.Version())
+		assert.NotNil(t, writer.Checksum())
+	})
+
+	t.Run("should not calculate checksum by default", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, writer.Checksum())
+	})
+
+	t.Run("should return client error when checksum is enabled after write", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		defer writer.Close()
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		err = writer.EnableChecksum()
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+}
+
+func TestReader(t *testing.T) {
+	t.Run("should describe the reader", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		writeData(t, db, "key", []byte("data"))
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		defer reader.Close()
+		// when
+		_, err = ioutil.ReadAll(reader)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "key", reader.Key())
+		assert.Equal(t, latestVersion(t, db, "key"), reader.Version())
+		assert.Equal(t, int64(4), reader.BytesRead())
+	})
+
+	t.Run("should return version being read", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		old := latestVersion(t, db, "key")
+		writeData(t, db, "key", []byte("data"))
+		// when
+		reader, err := db.VersionReader("key", old)
+		// then
+		require.NoError(t, err)
+		defer reader.Close()
+		assert.Equal(t, old, reader.Version())
+	})
+
+	t.Run("should not return version of data kept in memory", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		reader, err := db.Reader("key")
+		// then
+		require.NoError(t, err)
+		defer reader.Close()
+		assert.Equal(t, -1, reader.Version())
+	})
+
+	t.Run("should return error when seek is not supported", func(t *testing.T) {
+		db := openDB(t, existingRootDir(t), deebee.WithFilter(deebee.GzipFilter()))
+		writeData(t, db, "key", []byte("data"))
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		defer reader.Close()
+		// when
+		_, err = reader.Seek(1, io.SeekStart)
+		// then
+		assert.Error(t, err)
+	})
+}
+
+func TestWriter_OnCommit(t *testing.T) {
+	t.Run("should call functions after commit", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		var calls []string
+		writer.OnCommit(func(v deebee.Version) {
+			calls = append(calls, "first")
+			assert.Equal(t, []byte("data"), readData(t, db, "key"))
+			assert.Equal(t, latestVersion(t, db, "key"), v.Version)
+		})
+		writer.OnCommit(nil)
+		writer.OnCommit(func(v deebee.Version) {
+			calls = append(calls, "second")
+		})
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		assert.Empty(t, calls)
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first", "second"}, calls)
+	})
+
+	t.Run("should not call function when Close failed", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithRejectEmptyData())
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		called := false
+		writer.OnCommit(func(v deebee.Version) {
+			called = true
+		})
+		// when
+		err = writer.Close()
+		// then
+		require.Error(t, err)
+		assert.False(t, called)
+	})
+
+	t.Run("should not call function for data kept in memory", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		called := false
+		writer.OnCommit(func(v deebee.Version) {
+			called = true
+		})
+		// when
+		err = writer.Close()
+		// then
+		require.NoError(t, err)
+		assert.False(t, called)
+	})
+}
+
+func TestReader_WriteTo(t *testing.T) {
+	t.Run("should copy file directly when data is read as stored", func(t *testing.T) {
+		options := map[string][]deebee.Option{
+			"default":     nil,
+			"file header": {deebee.WithFileHeader()},
+		}
+		for name, opts := range options {
+			t.Run(name, func(t *testing.T) {
+				db := openDB(t, existingRootDir(t), opts...)
+				writeData(t, db, "key", []byte("data"))
+				reader, err := db.Reader("key")
+				require.NoError(t, err)
+				defer reader.Close()
+				dst := &readerFromRecorder{}
+				// when
+				n, err := io.Copy(dst, reader)
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, int64(4), n)
+				assert.Equal(t, "data", dst.String())
+				assert.Regexp(t, `^\*?os\.`, fmt.Sprintf("%T", dst.src), "file should be copied without deebee wrappers")
+				assert.Equal(t, int64(4), reader.BytesRead())
+				assert.Equal(t, int64(4), db.Stats().BytesRead)
+			})
+		}
+	})
+
+	t.Run("should verify checksum", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithChecksum())
+		writeData(t, db, "key", []byte("data"))
+		corruptLatest(t, dir, "key")
+		reader, err := db.Reader("key")
+		require.NoError(t, err)
+		defer reader.Close()
+		// when
+		_, err = reader.WriteTo(ioutil.Discard)
+		// then
+		assert.True(t, deebee.IsCorrupted(err))
+	})
+}
+
+// readerFromRecorder records the reader passed to ReadFrom
+type readerFromRecorder struct {
+	bytes.Buffer
+	src io.Reader
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.src = src
+	return r.Buffer.ReadFrom(src)
+}
@@ -0,0 +1,72 @@
+package deebee
+
+import (
+	"context"
+	"sync"
+)
+
+// Sync is a durability barrier. Like Flush, it persists data of keys configured using
+// WithWriteBehind and waits until commits started by closing writers returned by
+// WriterAsync are finished. Afterwards all data written before Sync was called is stored
+// durably, so the application can acknowledge external requests.
+//
+// Returns the latest version of each key committed using this DB since the previous Sync
+// (or Open), including tombstones written by Delete. Versions are reported once, so when
+// Sync is called concurrently each version is returned by only one of the calls. Returns
+// ctx.Err() when ctx was done before.
+func (s *DB) Sync(ctx context.Context) (map[string]int, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, s.redact(err)
+	}
+	return s.committed.take(), nil
+}
+
+// committedVersions contains the latest version of each key committed since the previous
+// Sync
+type committedVersions struct {
+	mutex  sync.Mutex
+	latest map[string]int
+}
+
+// committed updates the latest version of the key, unless younger version was committed before
+func (c *committedVersions) committed(key string, version int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.latest == nil {
+		c.latest = map[string]int{}
+	}
+	if latest, exists := c.latest[key]; !exists || version > latest {
+		c.latest[key] = version
+	}
+}
+
+// set replaces the latest version of the key
+func (c *committedVersions) set(key string, version int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.latest == nil {
+		c.latest = map[string]int{}
+	}
+	c.latest[key] = version
+}
+
+func (c *committedVersions) rename(oldKey, newKey string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if version, exists := c.latest[oldKey]; exists {
+		c.latest[newKey] = version
+		delete(c.latest, oldKey)
+	}
+}
+
+// take returns versions and forgets them, so they are not reported again
+func (c *committedVersions) take() map[string]int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	list := c.latest
+	if list == nil {
+		list = map[string]int{}
+	}
+	c.latest = nil
+	return list
+}
@@ -0,0 +1,119 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Sync(t *testing.T) {
+	t.Run("should return no versions when nothing was written", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		versions, err := db.Sync(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("should return latest committed versions", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "a", []byte("old"))
+		writeData(t, db, "a", []byte("new"))
+		writeData(t, db, "b", []byte("data"))
+		// when
+		versions, err := db.Sync(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"a": latestVersion(t, db, "a"), "b": latestVersion(t, db, "b")}, versions)
+	})
+
+	t.Run("should return only versions committed since the previous Sync", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "a", []byte("data"))
+		_, err := db.Sync(context.Background())
+		require.NoError(t, err)
+		writeData(t, db, "b", []byte("data"))
+		// when
+		versions, err := db.Sync(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"b": latestVersion(t, db, "b")}, versions)
+	})
+
+	t.Run("should wait for async commits", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		// when
+		versions, err := db.Sync(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"key": latestVersion(t, db, "key")}, versions)
+	})
+
+	t.Run("should persist write behind data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db, err := deebee.Open(dir, deebee.WithWriteBehind(time.Hour))
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("data"))
+		// when
+		versions, err := db.Sync(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Contains(t, versions, "key")
+		// and
+		reopened := openDB(t, dir)
+		assert.Equal(t, []byte("data"), readData(t, reopened, "key"))
+	})
+
+	t.Run("should return tombstone version", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		// when
+		synced, err := db.Sync(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"key": versions[1].Version}, synced)
+	})
+
+	t.Run("should return version under the new key after rename", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "old", []byte("data"))
+		require.NoError(t, db.Rename("old", "new"))
+		// when
+		versions, err := db.Sync(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"new": latestVersion(t, db, "new")}, versions)
+	})
+
+	t.Run("should return error when context is done before commit finished", func(t *testing.T) {
+		unblock := make(chan struct{})
+		db := openDB(t, fake.ExistingDir(), deebee.WithFilter(blockingCloseFilter(unblock)))
+		writer, err := db.WriterAsync("key", nil)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		versions, err := db.Sync(ctx)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, versions)
+		close(unblock)
+		_, err = db.Sync(context.Background())
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package deebee
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// SyncFilesystem flushes all data written to the filesystem containing the directory
+// using syncfs. Used by WithGroupCommit.
+func (o OsDir) SyncFilesystem() error {
+	dir, err := os.Open(string(o))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if err = unix.Syncfs(int(dir.Fd())); err != nil {
+		return &os.SyscallError{Syscall: "syncfs", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package deebee_test
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOsDir_SyncFilesystem(t *testing.T) {
+	t.Run("should flush filesystem", func(t *testing.T) {
+		dir := existingRootDir(t).(deebee.OsDir)
+		test.WriteFile(t, dir, "name", []byte("data"))
+		// when
+		err := dir.SyncFilesystem()
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return error when dir is missing", func(t *testing.T) {
+		dir := existingRootDir(t).Dir("missing").(deebee.OsDir)
+		err := dir.SyncFilesystem()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,123 @@
+package deebee
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Names of background tasks reported by Health
+const (
+	CompactionTask       = "compaction"
+	TempFileCleanupTask  = "temp file cleanup"
+	WriteBehindFlushTask = "write-behind flush"
+)
+
+// maxBackoffExponent limits the delay of retrying failing task to 64 times its interval
+const maxBackoffExponent = 6
+
+// Health describes background tasks, such as compaction (WithCompactionTrigger), temp file
+// cleanup (WithTempFileCleanup) or persisting data of keys configured using WithWriteBehind
+type Health struct {
+	// Tasks contains tasks which have run at least once, by their names such as CompactionTask
+	Tasks map[string]TaskHealth
+}
+
+// Healthy returns true when the last run of each task succeeded
+func (h Health) Healthy() bool {
+	for _, task := range h.Tasks {
+		if task.ConsecutiveFailures > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TaskHealth describes the background task
+type TaskHealth struct {
+	// ConsecutiveFailures is the number of failed runs since the last successful one.
+	// Runs which panicked are counted as failed.
+	ConsecutiveFailures int
+	// LastError is the error of the last failed run. Nil when the task never failed.
+	LastError error
+	// LastFailure is the time of the last failed run
+	LastFailure time.Time
+	// LastSuccess is the time of the last successful run
+	LastSuccess time.Time
+}
+
+// Health returns the state of background tasks. Task which keeps failing is retried with
+// increasing delay, so it does not overload the Dir.
+func (s *DB) Health() Health {
+	return s.tasks.health()
+}
+
+type taskPanicError struct {
+	task  string
+	value interface{}
+}
+
+func (e *taskPanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.task, e.value)
+}
+
+// tasks records results of background tasks
+type tasks struct {
+	mutex sync.Mutex
+	runs  map[string]TaskHealth
+}
+
+// runTask runs fn as the background task, waiting until maintenance is resumed. Panic is
+// recovered and returned as error, so one failing task does not stop the others or crash
+// the process. TaskPanicked event is emitted then.
+func (s *DB) runTask(task string, fn func() error) (err error) {
+	s.maintenance.begin()
+	defer s.maintenance.end()
+	defer func() {
+		if r := recover(); r != nil {
+			err = &taskPanicError{task: task, value: r}
+			s.emit(Event{Type: TaskPanicked, Err: err})
+		}
+		s.tasks.finished(task, err)
+	}()
+	return fn()
+}
+
+func (t *tasks) finished(task string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.runs == nil {
+		t.runs = map[string]TaskHealth{}
+	}
+	health := t.runs[task]
+	if err != nil {
+		health.ConsecutiveFailures++
+		health.LastError = err
+		health.LastFailure = time.Now()
+	} else {
+		health.ConsecutiveFailures = 0
+		health.LastSuccess = time.Now()
+	}
+	t.runs[task] = health
+}
+
+// backoff returns interval multiplied by 2 for each consecutive failure of the task
+func (t *tasks) backoff(task string, interval time.Duration) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	failures := t.runs[task].ConsecutiveFailures
+	if failures > maxBackoffExponent {
+		failures = maxBackoffExponent
+	}
+	return interval << uint(failures)
+}
+
+func (t *tasks) health() Health {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	health := Health{Tasks: map[string]TaskHealth{}}
+	for task, run := range t.runs {
+		health.Tasks[task] = run
+	}
+	return health
+}
@@ -0,0 +1,76 @@
+package deebee_test
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Health(t *testing.T) {
+	t.Run("should be healthy when no task was run", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		health := db.Health()
+		// then
+		assert.True(t, health.Healthy())
+		assert.Empty(t, health.Tasks)
+	})
+
+	t.Run("should report successful task", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxVersions: 1}))
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Close()) // waits for compaction
+		// when
+		health := db.Health()
+		// then
+		assert.True(t, health.Healthy())
+		require.Contains(t, health.Tasks, deebee.CompactionTask)
+		task := health.Tasks[deebee.CompactionTask]
+		assert.Zero(t, task.ConsecutiveFailures)
+		assert.False(t, task.LastSuccess.IsZero())
+	})
+
+	t.Run("should recover from panic of background task", func(t *testing.T) {
+		events := make(chan deebee.Event, 10)
+		db, err := deebee.Open(sizePanickingDir{slowDir{next: fake.ExistingDir()}},
+			deebee.WithCompactionTrigger(deebee.CompactionTrigger{MaxBytes: 1}),
+			deebee.WithEventHandler(func(event deebee.Event) {
+				if event.Type == deebee.TaskPanicked {
+					events <- event
+				}
+			}),
+		)
+		require.NoError(t, err)
+		// when
+		writeData(t, db, "key1", []byte("data"))
+		writeData(t, db, "key2", []byte("data"))
+		require.NoError(t, db.Close()) // waits for compaction
+		// then
+		require.Len(t, events, 2)
+		event := <-events
+		assert.Error(t, event.Err)
+		health := db.Health()
+		assert.False(t, health.Healthy())
+		task := health.Tasks[deebee.CompactionTask]
+		assert.Equal(t, 2, task.ConsecutiveFailures)
+		assert.Equal(t, event.Err, task.LastError)
+		assert.Equal(t, []byte("data"), readData(t, db, "key1"))
+	})
+}
+
+// sizePanickingDir panics when size of the file is requested
+type sizePanickingDir struct {
+	slowDir
+}
+
+func (d sizePanickingDir) Dir(name string) deebee.Dir {
+	return sizePanickingDir{slowDir{next: d.next.Dir(name)}}
+}
+
+func (d sizePanickingDir) FileSize(string) (int64, error) {
+	panic("file size")
+}
@@ -3,6 +3,7 @@ package test
 
 import (
 	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/jacekolszak/deebee"
@@ -56,7 +57,7 @@ func TestDir_FileWriter(t *testing.T, dirs Dirs) {
 				WriteFile(t, dir, fileName, []byte{})
 				// when
 				file, err := dir.FileWriter(fileName)
-				require.Error(t, err)
+				assert.ErrorIs(t, err, os.ErrExist)
 				assert.Nil(t, file)
 			})
 		})
@@ -298,3 +299,146 @@ func TestDir_ListFiles(t *testing.T, dirs Dirs) {
 		})
 	}
 }
+
+func TestDir_ListDirs(t *testing.T, dirs Dirs) {
+	for dirType, newDir := range dirs {
+		t.Run(dirType, func(t *testing.T) {
+
+			t.Run("for empty dir returns empty slice", func(t *testing.T) {
+				dirs, err := newDir(t).ListDirs()
+				require.NoError(t, err)
+				assert.Empty(t, dirs)
+			})
+
+			t.Run("should return two dirs", func(t *testing.T) {
+				dir := newDir(t)
+				Mkdir(t, dir, "name1")
+				Mkdir(t, dir, "name2")
+				// when
+				dirs, err := dir.ListDirs()
+				// then
+				require.NoError(t, err)
+				assert.Len(t, dirs, 2)
+				assert.Contains(t, dirs, "name1")
+				assert.Contains(t, dirs, "name2")
+			})
+
+			t.Run("should return error when dir is missing", func(t *testing.T) {
+				dirs, err := newDir(t).Dir("missing").ListDirs()
+				require.Error(t, err)
+				assert.Nil(t, dirs)
+			})
+
+			t.Run("should return dirs only", func(t *testing.T) {
+				dir := newDir(t)
+				WriteFile(t, dir, "excludedFile", []byte{})
+				// when
+				dirs, err := dir.ListDirs()
+				// then
+				require.NoError(t, err)
+				assert.Empty(t, dirs)
+			})
+
+			t.Run("should not return dirs which were not created", func(t *testing.T) {
+				dir := newDir(t)
+				_ = dir.Dir("notCreated")
+				// when
+				dirs, err := dir.ListDirs()
+				// then
+				require.NoError(t, err)
+				assert.Empty(t, dirs)
+			})
+		})
+	}
+}
+
+func TestDir_Rename(t *testing.T, dirs Dirs) {
+	for dirType, newDir := range dirs {
+		t.Run(dirType, func(t *testing.T) {
+
+			t.Run("should return error for empty names", func(t *testing.T) {
+				dir := newDir(t)
+				WriteFile(t, dir, fileName, []byte{})
+				err := dir.Rename("", fileName)
+				assert.Error(t, err)
+				err = dir.Rename(fileName, "")
+				assert.Error(t, err)
+			})
+
+			t.Run("should return error when file is missing", func(t *testing.T) {
+				err := newDir(t).Rename("missing", fileName)
+				assert.Error(t, err)
+			})
+
+			t.Run("should rename file", func(t *testing.T) {
+				dir := newDir(t)
+				data := []byte("payload")
+				WriteFile(t, dir, "old", data)
+				// when
+				err := dir.Rename("old", "new")
+				// then
+				require.NoError(t, err)
+				files, err := dir.ListFiles()
+				require.NoError(t, err)
+				assert.Equal(t, []string{"new"}, files)
+				assert.Equal(t, data, ReadFile(t, dir, "new"))
+			})
+
+			t.Run("should rename dir with files", func(t *testing.T) {
+				dir := newDir(t)
+				data := []byte("payload")
+				WriteFile(t, Mkdir(t, dir, "old"), fileName, data)
+				// when
+				err := dir.Rename("old", "new")
+				// then
+				require.NoError(t, err)
+				dirs, err := dir.ListDirs()
+				require.NoError(t, err)
+				assert.Equal(t, []string{"new"}, dirs)
+				assert.Equal(t, data, ReadFile(t, dir.Dir("new"), fileName))
+				exists, err := dir.Dir("old").Exists()
+				require.NoError(t, err)
+				assert.False(t, exists)
+			})
+
+			t.Run("should return error when renamed dir already exists", func(t *testing.T) {
+				dir := newDir(t)
+				WriteFile(t, Mkdir(t, dir, "old"), fileName, []byte{})
+				WriteFile(t, Mkdir(t, dir, "new"), fileName, []byte{})
+				// when
+				err := dir.Rename("old", "new")
+				// then
+				assert.Error(t, err)
+			})
+		})
+	}
+}
+
+func TestDir_DeleteFile(t *testing.T, dirs Dirs) {
+	for dirType, newDir := range dirs {
+		t.Run(dirType, func(t *testing.T) {
+
+			t.Run("should return error for empty name", func(t *testing.T) {
+				err := newDir(t).DeleteFile("")
+				assert.Error(t, err)
+			})
+
+			t.Run("should return error when file is missing", func(t *testing.T) {
+				err := newDir(t).DeleteFile(fileName)
+				assert.Error(t, err)
+			})
+
+			t.Run("should delete file", func(t *testing.T) {
+				dir := newDir(t)
+				WriteFile(t, dir, fileName, []byte("payload"))
+				// when
+				err := dir.DeleteFile(fileName)
+				// then
+				require.NoError(t, err)
+				files, err := dir.ListFiles()
+				require.NoError(t, err)
+				assert.Empty(t, files)
+			})
+		})
+	}
+}
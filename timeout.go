@@ -0,0 +1,102 @@
+package deebee
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// WithOperationTimeout limits time of creating Reader and Writer and of closing them,
+// which includes syncing the data. Operation which does not finish in time returns
+// timeout error (see IsTimeout). Useful when Dir is backed by network filesystem,
+// such as NFS, which can hang indefinitely.
+//
+// The timed out operation is not interrupted - it is abandoned and keeps running in the
+// background. Therefore, data of writer which Close timed out may still be committed.
+func WithOperationTimeout(timeout time.Duration) Option {
+	return func(db *DB) error {
+		if timeout <= 0 {
+			return errors.New("operation timeout must be positive")
+		}
+		db.operationTimeout = timeout
+		return nil
+	}
+}
+
+type timeoutError struct {
+	operation string
+	timeout   time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.operation, e.timeout)
+}
+
+func (e *timeoutError) Timeout() bool {
+	return true
+}
+
+// IsTimeout returns true when operation did not finish before timeout configured
+// using WithOperationTimeout
+func IsTimeout(err error) bool {
+	var timeout *timeoutError
+	return errors.As(err, &timeout)
+}
+
+// withTimeout runs fn and waits at most timeout for the result. When fn finishes
+// successfully after the timeout, abandon is called to release resources created by fn.
+// Zero timeout means no limit.
+func withTimeout(operation string, timeout time.Duration, fn func() error, abandon func()) error {
+	if timeout == 0 {
+		return fn()
+	}
+	var (
+		mutex    sync.Mutex
+		timedOut bool
+	)
+	result := make(chan error, 1)
+	go func() {
+		err := fn()
+		mutex.Lock()
+		defer mutex.Unlock()
+		if timedOut {
+			if err == nil && abandon != nil {
+				abandon()
+			}
+			return
+		}
+		result <- err
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		mutex.Lock()
+		defer mutex.Unlock()
+		select {
+		case err := <-result:
+			return err
+		default:
+			timedOut = true
+			return &timeoutError{operation: operation, timeout: timeout}
+		}
+	}
+}
+
+// timeoutReader limits time of closing the reader
+type timeoutReader struct {
+	io.ReadCloser
+	timeout time.Duration
+}
+
+func (r *timeoutReader) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, r.ReadCloser)
+}
+
+func (r *timeoutReader) Close() error {
+	return withTimeout("closing reader", r.timeout, r.ReadCloser.Close, nil)
+}
@@ -0,0 +1,72 @@
+package deebee_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithOperationTimeout(t *testing.T) {
+	t.Run("should return error for non-positive timeout", func(t *testing.T) {
+		for _, timeout := range []time.Duration{0, -1} {
+			db, err := deebee.Open(fake.ExistingDir(), deebee.WithOperationTimeout(timeout))
+			assert.Error(t, err)
+			assert.Nil(t, db)
+		}
+	})
+
+	t.Run("should write and read data when operations finish in time", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithOperationTimeout(time.Minute))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should return timeout error when writer Close hangs", func(t *testing.T) {
+		unblock := make(chan struct{})
+		defer close(unblock)
+		db := openDB(t, existingRootDir(t),
+			deebee.WithOperationTimeout(10*time.Millisecond),
+			deebee.WithFilter(blockingCloseFilter(unblock)))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		assert.True(t, deebee.IsTimeout(err))
+		assert.True(t, deebee.IsRetryable(err))
+	})
+
+	t.Run("should return timeout error when creating reader hangs", func(t *testing.T) {
+		unblock := make(chan struct{})
+		defer close(unblock)
+		dir := existingRootDir(t)
+		writeData(t, openDB(t, dir), "key", []byte("data"))
+		db := openDB(t, dir,
+			deebee.WithOperationTimeout(10*time.Millisecond),
+			deebee.WithFilter(blockingReaderFilter(unblock)))
+		// when
+		reader, err := db.Reader("key")
+		// then
+		assert.True(t, deebee.IsTimeout(err))
+		assert.Nil(t, reader)
+	})
+}
+
+// blockingReaderFilter blocks creation of the reader until channel is closed
+type blockingReaderFilter chan struct{}
+
+func (f blockingReaderFilter) Writer(w io.WriteCloser) (io.WriteCloser, error) {
+	return w, nil
+}
+
+func (f blockingReaderFilter) Reader(r io.ReadCloser) (io.ReadCloser, error) {
+	<-f
+	return r, nil
+}
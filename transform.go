@@ -0,0 +1,50 @@
+package deebee
+
+import (
+	"errors"
+	"io"
+)
+
+// WithReadTransformer registers the function transforming data of each read, after all
+// filters were applied. It is useful for applications migrating from a legacy format -
+// the transformer can detect old payloads and convert them to the new format until they
+// are rewritten. Data stored in files is never modified.
+//
+// Transformer should return r when data does not have to be converted. Error returned
+// by transformer is returned by Reader.
+func WithReadTransformer(transformer func(key string, r io.Reader) (io.Reader, error)) Option {
+	return func(db *DB) error {
+		if transformer == nil {
+			return errors.New("nil read transformer")
+		}
+		db.readTransformer = transformer
+		return nil
+	}
+}
+
+// transform applies the read transformer. r is closed when transformer failed.
+func (s *DB) transform(key string, r io.ReadCloser) (io.ReadCloser, error) {
+	if s.readTransformer == nil {
+		return r, nil
+	}
+	transformed, err := s.readTransformer(key, r)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if transformed == nil {
+		_ = r.Close()
+		return nil, errors.New("read transformer returned nil reader")
+	}
+	return &transformedReader{Reader: transformed, closer: r}, nil
+}
+
+// transformedReader closes the reader which was transformed
+type transformedReader struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *transformedReader) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,97 @@
+package deebee_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithReadTransformer(t *testing.T) {
+	t.Run("should return error for nil transformer", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithReadTransformer(nil))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	// legacyTransformer converts data starting with "v1:" to upper case
+	legacyTransformer := func(key string, r io.Reader) (io.Reader, error) {
+		buffered := bufio.NewReader(r)
+		prefix, err := buffered.Peek(3)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if string(prefix) != "v1:" {
+			return buffered, nil
+		}
+		var data bytes.Buffer
+		if _, err = io.Copy(&data, buffered); err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(bytes.ToUpper(data.Bytes()[3:])), nil
+	}
+
+	t.Run("should transform legacy data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "legacy", []byte("v1:data"))
+		db := openDB(t, dir, deebee.WithReadTransformer(legacyTransformer))
+		// expect
+		assert.Equal(t, []byte("DATA"), readData(t, db, "legacy"))
+	})
+
+	t.Run("should not transform data in the new format", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithReadTransformer(legacyTransformer))
+		writeData(t, db, "key", []byte("data"))
+		// expect
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+		// and
+		writeData(t, db, "empty", []byte{})
+		assert.Empty(t, readData(t, db, "empty"))
+	})
+
+	t.Run("should pass key and data after filters", func(t *testing.T) {
+		var keys []string
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithFilter(prefixFilter("prefix")),
+			deebee.WithReadTransformer(func(key string, r io.Reader) (io.Reader, error) {
+				keys = append(keys, key)
+				return r, nil
+			}),
+		)
+		writeData(t, db, "key", []byte("data"))
+		// when
+		data := readData(t, db, "key")
+		// then
+		assert.Equal(t, []byte("data"), data)
+		assert.Equal(t, []string{"key"}, keys)
+	})
+
+	t.Run("should return transformer error", func(t *testing.T) {
+		transformerError := errors.New("transformer failed")
+		db := openDB(t, fake.ExistingDir(), deebee.WithReadTransformer(func(string, io.Reader) (io.Reader, error) {
+			return nil, transformerError
+		}))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		_, err := db.Reader("key")
+		// then
+		assert.True(t, errors.Is(err, transformerError))
+	})
+
+	t.Run("should not modify stored data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		writeData(t, openDB(t, dir), "legacy", []byte("v1:data"))
+		db := openDB(t, dir, deebee.WithReadTransformer(legacyTransformer))
+		readData(t, db, "legacy")
+		// expect
+		files := dir.Dir("legacy").(fake.Dir).Files()
+		require.Len(t, files, 1)
+		assert.Equal(t, []byte("v1:data"), files[0].Data())
+	})
+}
@@ -0,0 +1,86 @@
+package deebee
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// WithWriteValidator calls validate with the data of each new version before it is
+// committed. When validate returns error, for example because data is empty or has wrong
+// magic bytes, nothing is committed and Writer.Close returns the error wrapped. Data is
+// validated before it is passed through filters.
+//
+// Data is buffered in memory until the writer is closed, so the option should not be used
+// for big states.
+func WithWriteValidator(validate func(key string, data []byte) error) Option {
+	return func(db *DB) error {
+		if validate == nil {
+			return newClientError("nil write validator")
+		}
+		db.validator = validate
+		return nil
+	}
+}
+
+// validate runs the validator configured for the key
+func (c keyConfig) validate(key string, data []byte) error {
+	if c.validator == nil {
+		return nil
+	}
+	if err := c.validator(key, data); err != nil {
+		return fmt.Errorf("validation of key \"%s\" failed: %w", key, err)
+	}
+	return nil
+}
+
+// validatingWriter buffers the data and passes it to the next writer only when it is valid
+type validatingWriter struct {
+	bytes.Buffer
+	next   io.WriteCloser
+	key    string
+	config keyConfig
+}
+
+func (w *validatingWriter) Close() error {
+	if err := w.config.validate(w.key, w.Bytes()); err != nil {
+		_ = w.next.Close()
+		return err
+	}
+	if _, err := w.next.Write(w.Bytes()); err != nil {
+		_ = w.next.Close()
+		return err
+	}
+	return w.next.Close()
+}
+
+// WithRejectEmptyData refuses to commit versions without data, which are a common symptom
+// of serializer bugs. Writer.Close returns client error and nothing is committed. Use
+// WithKeyOptions together with WithAllowEmptyData for keys which legitimately store empty
+// data.
+func WithRejectEmptyData() Option {
+	return func(db *DB) error {
+		db.rejectEmpty = true
+		return nil
+	}
+}
+
+// WithAllowEmptyData overrides WithRejectEmptyData, when used in WithKeyOptions
+func WithAllowEmptyData() Option {
+	return func(db *DB) error {
+		db.rejectEmpty = false
+		return nil
+	}
+}
+
+func emptyDataError(key string) error {
+	return newClientError(fmt.Sprintf("refusing to commit empty data of key \"%s\"", key))
+}
+
+// checkData checks the whole data of the version before it is committed
+func (c keyConfig) checkData(key string, data []byte) error {
+	if c.rejectEmpty && len(data) == 0 {
+		return emptyDataError(key)
+	}
+	return c.validate(key, data)
+}
@@ -0,0 +1,134 @@
+package deebee_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNotJSON = errors.New("not JSON")
+
+func requireJSON(key string, data []byte) error {
+	if !bytes.HasPrefix(data, []byte("{")) {
+		return errNotJSON
+	}
+	return nil
+}
+
+func TestWithWriteValidator(t *testing.T) {
+	t.Run("should return error for nil validator", func(t *testing.T) {
+		db, err := deebee.Open(fake.ExistingDir(), deebee.WithWriteValidator(nil))
+		assert.Error(t, err)
+		assert.Nil(t, db)
+	})
+
+	t.Run("should commit valid data", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteValidator(requireJSON))
+		// when
+		writeData(t, db, "key", []byte("{}"))
+		// then
+		assert.Equal(t, []byte("{}"), readData(t, db, "key"))
+	})
+
+	t.Run("should not commit invalid data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithWriteValidator(requireJSON))
+		writeData(t, db, "key", []byte("{}"))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("invalid"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		assert.True(t, errors.Is(err, errNotJSON))
+		assert.Equal(t, []byte("{}"), readData(t, db, "key"))
+		// and
+		files := dir.Dir("key").(fake.Dir).Files()
+		assert.Len(t, files, 1, "temporary file should be removed")
+	})
+
+	t.Run("should validate data before filters", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithWriteValidator(requireJSON),
+			deebee.WithFilter(prefixFilter("prefix")))
+		// when
+		writeData(t, db, "key", []byte("{}"))
+		// then
+		assert.Equal(t, []byte("{}"), readData(t, db, "key"))
+	})
+
+	t.Run("should validate only keys matching pattern", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithKeyOptions("json-*", deebee.WithWriteValidator(requireJSON)))
+		// when
+		writeData(t, db, "text", []byte("text"))
+		err := writeDataWithError(db, "json-1", []byte("text"))
+		// then
+		assert.Equal(t, []byte("text"), readData(t, db, "text"))
+		assert.True(t, errors.Is(err, errNotJSON))
+	})
+
+	t.Run("should not keep invalid data written using write-behind", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithWriteValidator(requireJSON),
+			deebee.WithWriteBehind(time.Hour))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("invalid"))
+		require.NoError(t, err)
+		// when
+		err = writer.Close()
+		// then
+		assert.True(t, errors.Is(err, errNotJSON))
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+}
+
+func TestWithRejectEmptyData(t *testing.T) {
+	t.Run("should not commit empty data", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithRejectEmptyData())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := writeDataWithError(db, "key", nil)
+		// then
+		assert.True(t, deebee.IsClientError(err))
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+		files := dir.Dir("key").(fake.Dir).Files()
+		assert.Len(t, files, 1, "temporary file should be removed")
+	})
+
+	t.Run("should reject empty data written using write-behind", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithRejectEmptyData(), deebee.WithWriteBehind(time.Hour))
+		// when
+		err := writeDataWithError(db, "key", nil)
+		// then
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should commit empty data of key allowing it", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithRejectEmptyData(),
+			deebee.WithKeyOptions("empty-*", deebee.WithAllowEmptyData()))
+		// when
+		writeData(t, db, "empty-key", nil)
+		// then
+		assert.Empty(t, readData(t, db, "empty-key"))
+	})
+
+	t.Run("should commit empty data by default", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		// when
+		writeData(t, db, "key", nil)
+		// then
+		assert.Empty(t, readData(t, db, "key"))
+	})
+}
@@ -0,0 +1,55 @@
+package deebee
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+const verifyParallelism = 8
+
+// Verify reads the latest version of each state through filters, so any verification
+// implemented by filters is performed. Returns errors by key for states which could not
+// be read. Deleted states and states without committed version are skipped.
+//
+// States are read concurrently, which is much faster when Dir is remote. progress is
+// called in the order of keys and can be nil.
+func (s *DB) Verify(ctx context.Context, progress ProgressFunc) (map[string]error, error) {
+	var (
+		mutex  sync.Mutex
+		failed = map[string]error{}
+	)
+	err := s.forEachKeyParallel(ctx, verifyParallelism, progress, func(key string) (int64, error) {
+		bytes, err := s.verifyKey(key)
+		if err != nil {
+			err = s.redact(err, key)
+			mutex.Lock()
+			failed[key] = err
+			mutex.Unlock()
+			s.stats.add(statCorruptionEvents, 1)
+			s.emit(Event{Type: CorruptionDetected, Key: key, Err: err})
+		}
+		return bytes, nil
+	})
+	if err != nil {
+		return nil, s.redact(err)
+	}
+	return failed, nil
+}
+
+func (s *DB) verifyKey(key string) (int64, error) {
+	reader, err := s.Reader(key)
+	if IsDataNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	bytes, err := io.Copy(ioutil.Discard, reader)
+	if err != nil {
+		_ = reader.Close()
+		return bytes, err
+	}
+	return bytes, reader.Close()
+}
@@ -0,0 +1,112 @@
+package deebee_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/failing"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Verify(t *testing.T) {
+	t.Run("should return no errors for empty DB", func(t *testing.T) {
+		failed, err := openDB(t, fake.ExistingDir()).Verify(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Empty(t, failed)
+	})
+
+	t.Run("should return errors of keys which could not be read", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyOptions("broken", deebee.WithFilter(failingReadFilter{})))
+		writeData(t, db, "broken", []byte("data"))
+		writeData(t, db, "ok", []byte("data"))
+		// when
+		failed, err := db.Verify(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		require.Len(t, failed, 1)
+		assert.Error(t, failed["broken"])
+	})
+
+	t.Run("should skip deleted keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		require.NoError(t, db.Delete("key"))
+		// when
+		failed, err := db.Verify(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, failed)
+	})
+
+	t.Run("should report progress", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "a", []byte("12"))
+		writeData(t, db, "b", []byte("345"))
+		var reported []deebee.Progress
+		// when
+		_, err := db.Verify(context.Background(), func(p deebee.Progress) {
+			reported = append(reported, p)
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []deebee.Progress{
+			{Key: "a", Done: 1, Total: 2, Bytes: 2},
+			{Key: "b", Done: 2, Total: 2, Bytes: 5},
+		}, reported)
+	})
+
+	t.Run("should report progress in the order of keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		var keys []string
+		for i := 0; i < 30; i++ {
+			key := fmt.Sprintf("key%02d", i)
+			keys = append(keys, key)
+			writeData(t, db, key, []byte("data"))
+		}
+		var reported []string
+		// when
+		_, err := db.Verify(context.Background(), func(p deebee.Progress) {
+			reported = append(reported, p.Key)
+			assert.Equal(t, len(reported), p.Done)
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, keys, reported)
+	})
+
+	t.Run("should verify states in sharded layout", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithShardedLayout())
+		for _, key := range []string{"c", "a", "b"} {
+			writeData(t, db, key, []byte("data"))
+		}
+		var reported []string
+		// when
+		failed, err := db.Verify(context.Background(), func(p deebee.Progress) {
+			reported = append(reported, p.Key)
+		})
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, failed)
+		assert.Equal(t, []string{"a", "b", "c"}, reported)
+	})
+
+	t.Run("should return error when context is done", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// when
+		_, err := db.Verify(ctx, nil)
+		// then
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should return error when ListDirs failed", func(t *testing.T) {
+		_, err := openDB(t, failing.ListDirs(fake.ExistingDir())).Verify(context.Background(), nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,99 @@
+package deebee
+
+import (
+	"context"
+	"sync"
+)
+
+// WaitForChange blocks until the latest revision of the state differs from sinceRev and
+// returns the new revision. Returns immediately when it differs already. Use empty sinceRev
+// to wait until the state is created. Returns data not found error when the state was
+// deleted, and ctx.Err() when ctx was done before the state changed.
+//
+// Only changes made using this DB are noticed, changes made by other processes sharing the
+// dir are not.
+func (s *DB) WaitForChange(ctx context.Context, key string, sinceRev Revision) (rev Revision, err error) {
+	key = s.normalizeKey(key)
+	defer s.redactError(&err, key)
+	if err = s.checkKey(key); err != nil {
+		return "", err
+	}
+	if err = s.checkAccess(ReadOperation, key); err != nil {
+		return "", err
+	}
+	for {
+		changed := s.changes.subscribe(key)
+		rev, err = s.latestRevision(key)
+		if err != nil || rev != sinceRev {
+			s.changes.unsubscribe(key, changed)
+			break
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			s.changes.unsubscribe(key, changed)
+			return "", ctx.Err()
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if rev == "" {
+		return "", &dataNotFoundError{}
+	}
+	return rev, nil
+}
+
+// latestRevision returns revision of the latest data version, or empty revision when there
+// is no state or it was deleted
+func (s *DB) latestRevision(key string) (_ Revision, err error) {
+	defer s.dirCache.invalidateOnError(key, &err)
+	latest, exists, err := s.latestFile(key)
+	if err != nil {
+		return "", err
+	}
+	if !exists || latest.kind == tombstoneFile {
+		return "", nil
+	}
+	return newRevision(latest.version), nil
+}
+
+// changeNotifier notifies goroutines waiting for changes of states
+type changeNotifier struct {
+	mutex   sync.Mutex
+	waiting map[string]map[chan struct{}]struct{}
+}
+
+// subscribe returns channel closed on the next change of the state
+func (n *changeNotifier) subscribe(key string) chan struct{} {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.waiting == nil {
+		n.waiting = map[string]map[chan struct{}]struct{}{}
+	}
+	if n.waiting[key] == nil {
+		n.waiting[key] = map[chan struct{}]struct{}{}
+	}
+	changed := make(chan struct{})
+	n.waiting[key][changed] = struct{}{}
+	return changed
+}
+
+func (n *changeNotifier) unsubscribe(key string, changed chan struct{}) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	delete(n.waiting[key], changed)
+	if len(n.waiting[key]) == 0 {
+		delete(n.waiting, key)
+	}
+}
+
+// changed notifies all goroutines waiting for the change of the state
+func (n *changeNotifier) changed(key string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for changed := range n.waiting[key] {
+		close(changed)
+	}
+	delete(n.waiting, key)
+}
@@ -0,0 +1,110 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_WaitForChange(t *testing.T) {
+	t.Run("should return client error for invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		for _, key := range invalidKeys {
+			_, err := db.WaitForChange(context.Background(), key, "")
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+
+	t.Run("should return immediately when revision is different", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		// when
+		rev, err := db.WaitForChange(context.Background(), "key", "")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, latestRevision(t, db, "key"), rev)
+	})
+
+	t.Run("should wait until new version is committed", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("old"))
+		oldRev := latestRevision(t, db, "key")
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			writeData(t, db, "key", []byte("new"))
+		}()
+		// when
+		rev, err := db.WaitForChange(context.Background(), "key", oldRev)
+		// then
+		require.NoError(t, err)
+		assert.NotEqual(t, oldRev, rev)
+		assert.Equal(t, latestRevision(t, db, "key"), rev)
+	})
+
+	t.Run("should wait until state is created", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			writeData(t, db, "key", []byte("data"))
+		}()
+		// when
+		rev, err := db.WaitForChange(context.Background(), "key", "")
+		// then
+		require.NoError(t, err)
+		assert.NotEmpty(t, rev)
+	})
+
+	t.Run("should return data not found error when state was deleted", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		rev := latestRevision(t, db, "key")
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = db.Delete("key")
+		}()
+		// when
+		_, err := db.WaitForChange(context.Background(), "key", rev)
+		// then
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should return error when context is done", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		// when
+		_, err := db.WaitForChange(ctx, "key", latestRevision(t, db, "key"))
+		// then
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("should not wake up on change of other key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		writeData(t, db, "key", []byte("data"))
+		writeData(t, db, "other", []byte("data"))
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		deleted := make(chan error, 1)
+		go func() {
+			deleted <- db.Delete("other")
+		}()
+		// when
+		_, err := db.WaitForChange(ctx, "key", latestRevision(t, db, "key"))
+		// then
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.NoError(t, <-deleted)
+	})
+}
+
+func latestRevision(t *testing.T, db *deebee.DB, key string) deebee.Revision {
+	reader, rev, err := db.ReaderWithRevision(key)
+	require.NoError(t, err)
+	_ = reader.Close()
+	return rev
+}
@@ -0,0 +1,141 @@
+package deebee
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+)
+
+// Watch returns channel receiving VersionCommitted, VersionDeleted and VersionRestored
+// events of all keys matching pattern, so components interested in a family of keys need
+// one subscription only. Event.Key is the key which changed. Pattern syntax is the one of
+// path.Match, for example "user-*". Use "*" to watch all keys, or the key itself to watch
+// a single key.
+//
+// Events of keys which cannot be read, because the function given to WithAccessControl
+// denied ReadOperation, are not delivered.
+//
+// Events are queued when the receiver is slow, so writers are never delayed. When more than
+// 1024 events are queued, the oldest ones are dropped and EventsDropped is delivered
+// instead. The channel is closed when ctx is done. Only changes made using this DB are
+// delivered.
+func (s *DB) Watch(ctx context.Context, pattern string) (<-chan Event, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, newClientError(fmt.Sprintf("invalid pattern %s: %s", pattern, err))
+	}
+	w := &watcher{
+		pattern: s.normalizeKey(pattern),
+		wake:    make(chan struct{}, 1),
+		allowed: func(key string) bool {
+			return s.checkAccess(ReadOperation, key) == nil
+		},
+	}
+	s.watchers.add(w)
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer s.watchers.remove(w)
+		w.deliver(ctx, events)
+	}()
+	return events, nil
+}
+
+// watchers contains watchers registered using Watch
+type watchers struct {
+	mutex sync.Mutex
+	all   map[*watcher]struct{}
+}
+
+func (ws *watchers) add(w *watcher) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	if ws.all == nil {
+		ws.all = map[*watcher]struct{}{}
+	}
+	ws.all[w] = struct{}{}
+}
+
+func (ws *watchers) remove(w *watcher) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	delete(ws.all, w)
+}
+
+// notify queues event in all watchers with pattern matching the key, which are allowed
+// to read the key
+func (ws *watchers) notify(event Event) {
+	ws.mutex.Lock()
+	var matching []*watcher
+	for w := range ws.all {
+		if matched, _ := path.Match(w.pattern, event.Key); matched {
+			matching = append(matching, w)
+		}
+	}
+	ws.mutex.Unlock()
+	// access control is a user function, so it is not called under the mutex
+	for _, w := range matching {
+		if w.allowed(event.Key) {
+			w.queue(event)
+		}
+	}
+}
+
+// maxQueuedEvents is the number of events queued by the watcher, after which the oldest
+// events are dropped
+const maxQueuedEvents = 1024
+
+type watcher struct {
+	pattern string
+	allowed func(key string) bool
+	mutex   sync.Mutex
+	queued  []Event
+	// dropped is true when events were dropped and EventsDropped was not delivered yet
+	dropped bool
+	// wake has buffer of one, so queue never blocks
+	wake chan struct{}
+}
+
+func (w *watcher) queue(event Event) {
+	w.mutex.Lock()
+	if len(w.queued) == maxQueuedEvents {
+		w.queued = w.queued[1:]
+		w.dropped = true
+	}
+	w.queued = append(w.queued, event)
+	w.mutex.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends queued events to the channel until ctx is done
+func (w *watcher) deliver(ctx context.Context, events chan<- Event) {
+	for {
+		w.mutex.Lock()
+		if len(w.queued) == 0 && !w.dropped {
+			w.mutex.Unlock()
+			select {
+			case <-w.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		var event Event
+		if w.dropped {
+			event = Event{Type: EventsDropped}
+			w.dropped = false
+		} else {
+			event = w.queued[0]
+			w.queued = w.queued[1:]
+		}
+		w.mutex.Unlock()
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
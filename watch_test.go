@@ -0,0 +1,121 @@
+package deebee_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Watch(t *testing.T) {
+	t.Run("should return client error for invalid pattern", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		_, err := db.Watch(context.Background(), "user-[")
+		assert.True(t, deebee.IsClientError(err))
+	})
+
+	t.Run("should deliver events of keys matching pattern", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := db.Watch(ctx, "user-*")
+		require.NoError(t, err)
+		// when
+		writeData(t, db, "user-1", []byte("data"))
+		writeData(t, db, "order-1", []byte("data"))
+		writeData(t, db, "user-2", []byte("data"))
+		require.NoError(t, db.Delete("user-1"))
+		// then
+		assertEvent(t, events, deebee.VersionCommitted, "user-1")
+		assertEvent(t, events, deebee.VersionCommitted, "user-2")
+		assertEvent(t, events, deebee.VersionDeleted, "user-1")
+	})
+
+	t.Run("should deliver events to many watchers", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		all, err := db.Watch(ctx, "*")
+		require.NoError(t, err)
+		single, err := db.Watch(ctx, "key")
+		require.NoError(t, err)
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assertEvent(t, all, deebee.VersionCommitted, "key")
+		assertEvent(t, single, deebee.VersionCommitted, "key")
+	})
+
+	t.Run("should not deliver events of keys which cannot be read", func(t *testing.T) {
+		publicOnly := func(op deebee.Operation, key string) error {
+			if op == deebee.ReadOperation && key != "public" {
+				return errors.New("denied")
+			}
+			return nil
+		}
+		db := openDB(t, fake.ExistingDir(), deebee.WithAccessControl(publicOnly))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := db.Watch(ctx, "*")
+		require.NoError(t, err)
+		// when
+		writeData(t, db, "secret", []byte("data"))
+		writeData(t, db, "public", []byte("data"))
+		// then
+		assertEvent(t, events, deebee.VersionCommitted, "public")
+	})
+
+	t.Run("should drop oldest events when receiver is too slow", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := db.Watch(ctx, "*")
+		require.NoError(t, err)
+		// when
+		for i := 0; i < 1100; i++ {
+			writeData(t, db, fmt.Sprintf("key%d", i), []byte("data"))
+		}
+		// then
+		first := <-events
+		// the first event could be taken by the delivering goroutine before the queue overflowed
+		if first.Type != deebee.EventsDropped {
+			assert.Equal(t, "key0", first.Key)
+			assertEvent(t, events, deebee.EventsDropped, "")
+		}
+		var last deebee.Event
+		for i := 0; i < 1024; i++ {
+			last = <-events
+			require.Equal(t, deebee.VersionCommitted, last.Type)
+		}
+		assert.Equal(t, "key1099", last.Key)
+	})
+
+	t.Run("should close channel when context is done", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir())
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := db.Watch(ctx, "*")
+		require.NoError(t, err)
+		writeData(t, db, "key", []byte("data"))
+		// when
+		cancel()
+		// then
+		for range events {
+		}
+	})
+}
+
+func assertEvent(t *testing.T, events <-chan deebee.Event, expectedType deebee.EventType, expectedKey string) {
+	select {
+	case event := <-events:
+		assert.Equal(t, expectedType, event.Type)
+		assert.Equal(t, expectedKey, event.Key)
+	case <-time.After(time.Second):
+		assert.Fail(t, "timeout waiting for event")
+	}
+}
@@ -0,0 +1,282 @@
+package deebee
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WithWriteBehind keeps data written to the key in memory. Close of the writer returns
+// once the data is in memory and Reader returns it immediately. Data is persisted in the
+// background at most once per flushInterval - only the latest data written within the
+// interval is stored as a new version. Useful for high-frequency updates, such as counters,
+// where durability of each write is not required.
+//
+// Data not persisted yet is lost when the process crashes. Flush and Close persist all
+// data immediately. Delete and Rename persist the data of the key before they are executed.
+// Other operations, such as Versions, Backup or Compact, see only the persisted data.
+//
+// Usually used with WithKeyOptions.
+func WithWriteBehind(flushInterval time.Duration) Option {
+	return func(db *DB) error {
+		if flushInterval <= 0 {
+			return errors.New("write behind flush interval must be positive")
+		}
+		db.writeBehind = &writeBehind{
+			interval: flushInterval,
+			values:   map[string]writeBehindValue{},
+		}
+		return nil
+	}
+}
+
+type writeBehind struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	// values contains data not persisted yet
+	values    map[string]writeBehindValue
+	scheduled bool
+	// lastGeneration is incremented on each write
+	lastGeneration int
+	// flushMutex prevents concurrent persisting of the same values
+	flushMutex sync.Mutex
+}
+
+type writeBehindValue struct {
+	data []byte
+	// generation is unique for each write, so it is known whether value was changed
+	// when it was being persisted
+	generation int
+}
+
+// generation returns generation of the value not persisted yet, or 0 when there is no such value
+func (w *writeBehind) generation(key string) int {
+	if w == nil {
+		return 0
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.values[key].generation
+}
+
+// discard removes the value when it was not changed since given generation, because
+// a younger version was committed
+func (w *writeBehind) discard(key string, generation int) {
+	if w == nil {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if value, ok := w.values[key]; ok && value.generation == generation {
+		delete(w.values, key)
+	}
+}
+
+// pending returns sizes of values not persisted yet by key
+func (w *writeBehind) pending() map[string]int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	sizes := make(map[string]int, len(w.values))
+	for key, value := range w.values {
+		sizes[key] = len(value.data)
+	}
+	return sizes
+}
+
+func (w *writeBehind) get(key string) ([]byte, bool) {
+	if w == nil {
+		return nil, false
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	value, ok := w.values[key]
+	return value.data, ok
+}
+
+// set stores data in memory and schedules flush
+func (w *writeBehind) set(s *DB, key string, data []byte) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.lastGeneration++
+	w.values[key] = writeBehindValue{
+		data:       data,
+		generation: w.lastGeneration,
+	}
+	w.schedule(s)
+}
+
+// schedule runs flush after the interval, unless it is already scheduled. Must be called
+// with mutex locked.
+func (w *writeBehind) schedule(s *DB) {
+	if w.scheduled {
+		return
+	}
+	w.scheduled = true
+	time.AfterFunc(s.tasks.backoff(WriteBehindFlushTask, w.interval), func() {
+		err := s.runTask(WriteBehindFlushTask, func() error {
+			return w.flush(s)
+		})
+		if err != nil {
+			s.emit(Event{Type: WriteBehindFlushFailed, Err: err})
+			w.mutex.Lock()
+			w.schedule(s)
+			w.mutex.Unlock()
+		}
+	})
+}
+
+// flush persists all values. Values which could not be persisted are flushed next time.
+func (w *writeBehind) flush(s *DB) error {
+	if w == nil {
+		return nil
+	}
+	w.mutex.Lock()
+	w.scheduled = false
+	keys := make([]string, 0, len(w.values))
+	for key := range w.values {
+		keys = append(keys, key)
+	}
+	w.mutex.Unlock()
+	sort.Strings(keys)
+	var failed []string
+	for _, key := range keys {
+		if err := w.flushKey(s, key); err != nil {
+			failed = append(failed, key)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("persisting data of %d keys failed", len(failed))
+	}
+	return nil
+}
+
+// flushKey persists the value of the key, if there is any
+func (w *writeBehind) flushKey(s *DB, key string) error {
+	if w == nil {
+		return nil
+	}
+	w.flushMutex.Lock()
+	defer w.flushMutex.Unlock()
+	w.mutex.Lock()
+	value, ok := w.values[key]
+	w.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	return s.persist(key, value)
+}
+
+// persist writes value as a new version. Value is removed from memory on commit, unless
+// it was changed in the meantime.
+func (s *DB) persist(key string, value writeBehindValue) error {
+	writer, err := s.newWriter(key)
+	if err != nil {
+		return err
+	}
+	writer.generation = value.generation
+	if _, err = writer.Write(value.data); err != nil {
+		_ = writer.abort()
+		return err
+	}
+	return writer.Close()
+}
+
+// writeBehinds returns all instances configured for DB and for key patterns
+func (s *DB) writeBehinds() []*writeBehind {
+	var all []*writeBehind
+	if s.writeBehind != nil {
+		all = append(all, s.writeBehind)
+	}
+	for _, o := range s.keyOptions {
+		if o.config.writeBehind != nil && o.config.writeBehind != s.writeBehind {
+			all = append(all, o.config.writeBehind)
+		}
+	}
+	return all
+}
+
+func (s *DB) flushWriteBehind() error {
+	for _, w := range s.writeBehinds() {
+		if err := w.flush(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushWriteBehindKey persists the data of the key, when it was written using write behind
+func (s *DB) flushWriteBehindKey(key string) error {
+	return s.configFor(key).writeBehind.flushKey(s, key)
+}
+
+// newWriteBehindWriter returns writer keeping the data in memory
+func (s *DB) newWriteBehindWriter(key string, w *writeBehind, onCommit func(error)) (io.WriteCloser, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkKey(key); err != nil {
+		return nil, err
+	}
+	if err := s.checkAccess(WriteOperation, key); err != nil {
+		return nil, err
+	}
+	if err := s.checkKeyCase(key); err != nil {
+		return nil, err
+	}
+	return &writeBehindWriter{
+		db:          s,
+		key:         key,
+		writeBehind: w,
+		onCommit:    onCommit,
+		untrack:     s.handles.track(WriterHandle, key),
+	}, nil
+}
+
+type writeBehindWriter struct {
+	bytes.Buffer
+	db          *DB
+	key         string
+	writeBehind *writeBehind
+	// onCommit is set for writers returned by WriterAsync
+	onCommit func(error)
+	closed   bool
+	untrack  func()
+}
+
+func (w *writeBehindWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("writer already closed")
+	}
+	n, err := w.Buffer.Write(p)
+	w.db.stats.add(statBytesWritten, int64(n))
+	return n, err
+}
+
+func (w *writeBehindWriter) Close() error {
+	if w.closed {
+		return errors.New("writer already closed")
+	}
+	w.closed = true
+	w.untrack()
+	if err := w.db.configFor(w.key).checkData(w.key, w.Bytes()); err != nil {
+		if w.onCommit != nil {
+			w.onCommit(err)
+		}
+		return err
+	}
+	w.writeBehind.set(w.db, w.key, w.Bytes())
+	if w.onCommit != nil {
+		w.onCommit(nil)
+	}
+	return nil
+}
+
+// writeBehindReader returns reader of the data not persisted yet
+func (s *DB) writeBehindReader(key string, data []byte) (io.ReadCloser, error) {
+	return s.transform(key, ioutil.NopCloser(bytes.NewReader(data)))
+}
@@ -0,0 +1,165 @@
+package deebee_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWriteBehind(t *testing.T) {
+	t.Run("should return error for invalid interval", func(t *testing.T) {
+		for _, interval := range []time.Duration{0, -1} {
+			db, err := deebee.Open(fake.ExistingDir(), deebee.WithWriteBehind(interval))
+			assert.Error(t, err)
+			assert.Nil(t, db)
+		}
+	})
+
+	t.Run("should read data which was not persisted yet", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithWriteBehind(time.Hour))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+		exists, err := db.Exists("key")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		// and
+		dirs, err := dir.ListDirs()
+		require.NoError(t, err)
+		assert.Empty(t, dirs)
+	})
+
+	t.Run("should persist only the latest data on Flush", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithWriteBehind(time.Hour))
+		writeData(t, db, "key", []byte("1"))
+		writeData(t, db, "key", []byte("2"))
+		// when
+		err := db.Flush(context.Background())
+		// then
+		require.NoError(t, err)
+		versions, err := db.Versions("key")
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+		assert.Equal(t, []byte("2"), readData(t, openDB(t, dir), "key"))
+	})
+
+	t.Run("should persist data in the background", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithWriteBehind(time.Millisecond))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.Eventually(t, func() bool {
+			versions, err := db.Versions("key")
+			return err == nil && len(versions) == 1
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, []byte("data"), readData(t, openDB(t, dir), "key"))
+	})
+
+	t.Run("should persist data on Close", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithWriteBehind(time.Hour))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.Close()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, openDB(t, dir), "key"))
+	})
+
+	t.Run("should delete data which was not persisted yet", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		writeData(t, db, "key", []byte("data"))
+		// when
+		err := db.Delete("key")
+		// then
+		require.NoError(t, err)
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+		// and
+		require.NoError(t, db.Flush(context.Background()))
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should rename data which was not persisted yet", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		writeData(t, db, "old", []byte("data"))
+		// when
+		err := db.Rename("old", "new")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("data"), readData(t, db, "new"))
+		_, err = db.Reader("old")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should call onCommit of WriterAsync", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		committed := make(chan error, 1)
+		writer, err := db.WriterAsync("key", func(err error) {
+			committed <- err
+		})
+		require.NoError(t, err)
+		// when
+		require.NoError(t, writer.Close())
+		// then
+		assert.NoError(t, <-committed)
+	})
+
+	t.Run("should return error when writer is closed twice", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		// when
+		err = writer.Close()
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("should return client error for invalid keys", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriteBehind(time.Hour))
+		for _, key := range invalidKeys {
+			_, err := db.Writer(key)
+			assert.True(t, deebee.IsClientError(err))
+		}
+	})
+
+	t.Run("should be used only for keys matching the pattern", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		db := openDB(t, dir, deebee.WithKeyOptions("counter-*", deebee.WithWriteBehind(time.Hour)))
+		// when
+		writeData(t, db, "counter-1", []byte("1"))
+		writeData(t, db, "key", []byte("data"))
+		// then
+		dirs, err := dir.ListDirs()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"key"}, dirs)
+		// and
+		require.NoError(t, db.Flush(context.Background()))
+		assert.Equal(t, []byte("1"), readData(t, openDB(t, dir), "counter-1"))
+	})
+
+	t.Run("should read version committed after data was written to memory", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithKeyOptions("counter", deebee.WithWriteBehind(time.Hour)))
+		writeData(t, db, "counter", []byte("memory"))
+		writeData(t, db, "src", []byte("copied"))
+		// when
+		err := db.CopyKey("src", "counter")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("copied"), readData(t, db, "counter"))
+		// and
+		require.NoError(t, db.Flush(context.Background()))
+		assert.Equal(t, []byte("copied"), readData(t, db, "counter"))
+	})
+}
@@ -0,0 +1,157 @@
+package deebee
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// writer writes data to temporary file. The file is renamed to its final name
+// on Close, therefore Reader never sees partially written data.
+type writer struct {
+	// writeBehind contains data of the key kept in memory, with generation from the time
+	// the writer was created. Such data is older than the committed version.
+	writeBehind *writeBehind
+	generation  int
+	filtered    io.WriteCloser
+	file        FileWriter
+	dir         Dir
+	name        filename
+	groupCommit *groupCommit
+	// syncDir syncs the state dir after the version is renamed
+	syncDir     func(Dir) error
+	timeout     time.Duration
+	key         string
+	index       *stateIndex
+	committed   *committedVersions
+	stats       *statsCounters
+	emit        func(Event)
+	compactor   *compactor
+	rejectEmpty bool
+	// release is called once the writer is closed, so it is no longer counted by writerLimits
+	release func()
+	// fence is checked right before the version is committed. Nil fence is not checked.
+	fence func() error
+	// written is the number of bytes written, before passing them through filters
+	written int64
+	// deadline aborts the writer when it was not closed in time. Nil when WithWriterDeadline
+	// was not used.
+	deadline *time.Timer
+	// mutex protects the writer from being aborted by deadline while it is used
+	mutex sync.Mutex
+	// done is true after the writer was committed or aborted
+	done bool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.done {
+		return 0, writerDoneError(w.key)
+	}
+	n, err := w.filtered.Write(p)
+	w.written += int64(n)
+	w.stats.add(statBytesWritten, int64(n))
+	return n, err
+}
+
+func (w *writer) Close() error {
+	return withTimeout("closing writer", w.timeout, w.commit, nil)
+}
+
+func (w *writer) commit() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.done {
+		return writerDoneError(w.key)
+	}
+	w.finish()
+	defer w.release()
+	if w.rejectEmpty && w.written == 0 {
+		_ = w.discard()
+		return emptyDataError(w.key)
+	}
+	if err := w.filtered.Close(); err != nil {
+		_ = w.file.Close()
+		_ = w.dir.DeleteFile(w.name.temp())
+		return err
+	}
+	if err := w.sync(); err != nil {
+		_ = w.file.Close()
+		_ = w.dir.DeleteFile(w.name.temp())
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		_ = w.dir.DeleteFile(w.name.temp())
+		return err
+	}
+	if w.fence != nil {
+		if err := w.fence(); err != nil {
+			_ = w.dir.DeleteFile(w.name.temp())
+			return err
+		}
+	}
+	if err := w.dir.Rename(w.name.temp(), w.name.name); err != nil {
+		_ = w.dir.DeleteFile(w.name.temp())
+		return err
+	}
+	if err := w.syncDir(w.dir); err != nil {
+		// the version may not survive power loss, therefore it is removed and not reported
+		// as committed
+		_ = w.dir.DeleteFile(w.name.name)
+		return err
+	}
+	w.index.committed(w.key, w.name)
+	w.committed.committed(w.key, w.name.version)
+	w.writeBehind.discard(w.key, w.generation)
+	w.stats.add(statWrites, 1)
+	w.emit(Event{Type: VersionCommitted, Key: w.key, Version: w.name.version})
+	w.compactor.committed(w.key)
+	return nil
+}
+
+// abort discards written data without committing it. Does nothing when the writer was
+// already committed or aborted.
+func (w *writer) abort() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.done {
+		return nil
+	}
+	w.finish()
+	defer w.release()
+	return w.discard()
+}
+
+// finish marks the writer as done, so it can't be aborted by deadline
+func (w *writer) finish() {
+	w.done = true
+	if w.deadline != nil {
+		w.deadline.Stop()
+	}
+}
+
+func (w *writer) discard() error {
+	_ = w.filtered.Close()
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	return w.dir.DeleteFile(w.name.temp())
+}
+
+func (w *writer) sync() error {
+	if w.groupCommit != nil {
+		return w.groupCommit.sync(w.dir, w.file)
+	}
+	return w.file.Sync()
+}
+
+// unclosableWriter is the last writer in the filter chain. The file is closed
+// by the writer after it is synced.
+type unclosableWriter struct {
+	io.Writer
+}
+
+func (unclosableWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package deebee
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithWriterDeadline aborts writers which were not closed within d since they were created.
+// Data written so far is discarded and the temp file is removed, so writers leaked by
+// forgotten Close in error paths do not leave garbage and are no longer counted by
+// WithMaxConcurrentWriters. WriterExpired event is emitted for each aborted writer.
+//
+// Write and Close of the aborted writer return client error. Writers of keys configured
+// using WithWriteBehind are not aborted, because their data is kept in memory.
+func WithWriterDeadline(d time.Duration) Option {
+	return func(db *DB) error {
+		if d <= 0 {
+			return errors.New("writer deadline must be positive")
+		}
+		db.writerDeadline = d
+		return nil
+	}
+}
+
+// expire aborts the writer, unless it was already committed or aborted
+func (w *writer) expire() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.done {
+		return
+	}
+	w.finish()
+	defer w.release()
+	err := w.discard()
+	w.emit(Event{Type: WriterExpired, Key: w.key, Version: w.name.version, Err: err})
+}
+
+func writerDoneError(key string) error {
+	return newClientError(fmt.Sprintf("writer of key \"%s\" was already closed or aborted", key))
+}
@@ -0,0 +1,70 @@
+package deebee_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWriterDeadline(t *testing.T) {
+	t.Run("should return error for non-positive deadline", func(t *testing.T) {
+		for _, d := range []time.Duration{0, -1} {
+			db, err := deebee.Open(fake.ExistingDir(), deebee.WithWriterDeadline(d))
+			assert.Error(t, err)
+			assert.Nil(t, db)
+		}
+	})
+
+	t.Run("should commit writer closed before deadline", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithWriterDeadline(time.Minute))
+		// when
+		writeData(t, db, "key", []byte("data"))
+		// then
+		assert.Equal(t, []byte("data"), readData(t, db, "key"))
+	})
+
+	t.Run("should abort writer not closed before deadline", func(t *testing.T) {
+		dir := fake.ExistingDir()
+		events := make(chan deebee.Event, 1)
+		db := openDB(t, dir,
+			deebee.WithWriterDeadline(time.Millisecond),
+			deebee.WithEventHandler(func(event deebee.Event) {
+				events <- event
+			}))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		// when
+		assertEvent(t, events, deebee.WriterExpired, "key")
+		// then
+		assert.Empty(t, tempFiles(dir, "key"))
+		_, err = writer.Write([]byte("more"))
+		assert.True(t, deebee.IsClientError(err))
+		err = writer.Close()
+		assert.True(t, deebee.IsClientError(err))
+		_, err = db.Reader("key")
+		assert.True(t, deebee.IsDataNotFound(err))
+	})
+
+	t.Run("should release aborted writer", func(t *testing.T) {
+		events := make(chan deebee.Event, 1)
+		db := openDB(t, fake.ExistingDir(),
+			deebee.WithWriterDeadline(time.Millisecond),
+			deebee.WithMaxConcurrentWriters(1),
+			deebee.WithEventHandler(func(event deebee.Event) {
+				events <- event
+			}))
+		_, err := db.Writer("key")
+		require.NoError(t, err)
+		assertEvent(t, events, deebee.WriterExpired, "key")
+		// when
+		_, err = db.Writer("key")
+		// then
+		assert.NoError(t, err)
+	})
+}
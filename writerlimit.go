@@ -0,0 +1,121 @@
+package deebee
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// WithMaxConcurrentWriters limits the number of writers which were created and not closed
+// yet, for the whole DB. Creating Writer above the limit returns too many writers error
+// (see IsTooManyWriters), which is retryable. Protects Dir backed by an object store with
+// request-rate limits and prevents unbounded fan-out of goroutines.
+//
+// Writer is counted until its Close finishes. Writers of keys configured using
+// WithWriteBehind are counted only when their data is persisted in the background.
+func WithMaxConcurrentWriters(n int) Option {
+	return func(db *DB) error {
+		if n <= 0 {
+			return errors.New("max concurrent writers must be positive")
+		}
+		db.writerLimits.max = n
+		return nil
+	}
+}
+
+// WithMaxConcurrentWritersPerKey limits the number of writers of a single key, which were
+// created and not closed yet. See WithMaxConcurrentWriters.
+func WithMaxConcurrentWritersPerKey(n int) Option {
+	return func(db *DB) error {
+		if n <= 0 {
+			return errors.New("max concurrent writers per key must be positive")
+		}
+		db.writerLimits.maxPerKey = n
+		return nil
+	}
+}
+
+type tooManyWritersError struct {
+	message string
+}
+
+func (e *tooManyWritersError) Error() string {
+	return e.message
+}
+
+// Retryable returns true, because writers are closed eventually
+func (e *tooManyWritersError) Retryable() bool {
+	return true
+}
+
+// IsTooManyWriters returns true when Writer could not be created, because of the limit set
+// using WithMaxConcurrentWriters or WithMaxConcurrentWritersPerKey
+func IsTooManyWriters(err error) bool {
+	var tooMany *tooManyWritersError
+	return errors.As(err, &tooMany)
+}
+
+// writerLimits counts open writers. Zero limit is not checked.
+type writerLimits struct {
+	max       int
+	maxPerKey int
+	mutex     sync.Mutex
+	total     int
+	perKey    map[string]int
+}
+
+// acquire counts the new writer of the key. Returned function must be called once the
+// writer is closed.
+func (l *writerLimits) acquire(key string) (release func(), err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.max == 0 && l.maxPerKey == 0 {
+		return func() {}, nil
+	}
+	if l.max > 0 && l.total >= l.max {
+		return nil, &tooManyWritersError{message: fmt.Sprintf("too many writers: limit of %d reached", l.max)}
+	}
+	if l.maxPerKey > 0 && l.perKey[key] >= l.maxPerKey {
+		return nil, &tooManyWritersError{message: fmt.Sprintf("too many writers of key \"%s\": limit of %d reached", key, l.maxPerKey)}
+	}
+	if l.perKey == nil {
+		l.perKey = map[string]int{}
+	}
+	l.total++
+	l.perKey[key]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.release(key)
+		})
+	}, nil
+}
+
+// set changes limits which are not zero. Writers created when no limit was set are not counted.
+// limits returns max and maxPerKey
+func (l *writerLimits) limits() (int, int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.max, l.maxPerKey
+}
+
+func (l *writerLimits) set(max, maxPerKey int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if max != 0 {
+		l.max = max
+	}
+	if maxPerKey != 0 {
+		l.maxPerKey = maxPerKey
+	}
+}
+
+func (l *writerLimits) release(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.total--
+	l.perKey[key]--
+	if l.perKey[key] == 0 {
+		delete(l.perKey, key)
+	}
+}
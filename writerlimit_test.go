@@ -0,0 +1,75 @@
+package deebee_test
+
+import (
+	"testing"
+
+	"github.com/jacekolszak/deebee"
+	"github.com/jacekolszak/deebee/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMaxConcurrentWriters(t *testing.T) {
+	t.Run("should return error for non-positive limit", func(t *testing.T) {
+		for _, n := range []int{0, -1} {
+			db, err := deebee.Open(fake.ExistingDir(), deebee.WithMaxConcurrentWriters(n))
+			assert.Error(t, err)
+			assert.Nil(t, db)
+			db, err = deebee.Open(fake.ExistingDir(), deebee.WithMaxConcurrentWritersPerKey(n))
+			assert.Error(t, err)
+			assert.Nil(t, db)
+		}
+	})
+
+	t.Run("should return retryable error when limit is reached", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithMaxConcurrentWriters(2))
+		_, err := db.Writer("key1")
+		require.NoError(t, err)
+		_, err = db.Writer("key2")
+		require.NoError(t, err)
+		// when
+		writer, err := db.Writer("key3")
+		// then
+		assert.True(t, deebee.IsTooManyWriters(err))
+		assert.True(t, deebee.IsRetryable(err))
+		assert.Nil(t, writer)
+	})
+
+	t.Run("should create writer when other writer was closed", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithMaxConcurrentWriters(1))
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("data"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		// when
+		writer, err = db.Writer("key")
+		// then
+		require.NoError(t, err)
+		assert.NoError(t, writer.Close())
+	})
+
+	t.Run("should release writer when Close failed", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithMaxConcurrentWriters(1), deebee.WithRejectEmptyData())
+		writer, err := db.Writer("key")
+		require.NoError(t, err)
+		require.Error(t, writer.Close())
+		// when
+		_, err = db.Writer("key")
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("should limit writers per key", func(t *testing.T) {
+		db := openDB(t, fake.ExistingDir(), deebee.WithMaxConcurrentWritersPerKey(1))
+		_, err := db.Writer("key")
+		require.NoError(t, err)
+		// when
+		_, err = db.Writer("key")
+		// then
+		assert.True(t, deebee.IsTooManyWriters(err))
+		// and
+		_, err = db.Writer("other")
+		assert.NoError(t, err)
+	})
+}